@@ -4,14 +4,15 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/assignment"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/chat"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/core"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/history"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/mediafile"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/motion"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/poll"
@@ -27,11 +29,14 @@ import (
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/auth"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	autoupdategrpc "github.com/OpenSlides/openslides3-autoupdate-service/internal/grpc"
 	autoupdatehttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/notify"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/projector"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/redis"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/worker"
 )
 
 const (
@@ -40,44 +45,191 @@ const (
 )
 
 func main() {
-	if err := run(); err != nil {
-		log.Printf("Error: %s", err)
+	level, err := logger.ParseLevel(getEnv("LOG_LEVEL", "info"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	log := logger.New(os.Stderr, level)
+
+	if err := run(log); err != nil {
+		log.Error("Service stopped", "error", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
+func run(log logger.Logger) error {
 	redisHost := getEnv("MESSAGE_BUS_HOST", "localhost")
 	redisPort := getEnv("MESSAGE_BUS_PORT", "6379")
 	redisAddr := redisHost + ":" + redisPort
 	redisWriteAddr := getEnv("REDIS_WRITE_HOST", redisHost) + ":" + getEnv("REDIS_WRITE_PORT", redisPort)
 
 	sessionPrefix := getEnv("SESSION_PREFIX", "session:")
+	redisUsername := getEnv("MESSAGE_BUS_USERNAME", "")
+	redisPassword := getEnv("MESSAGE_BUS_PASSWORD", "")
 	redisConn := redis.New(redisAddr, redisWriteAddr, sessionPrefix)
-	testRedis(redisConn, redisAddr, redisWriteAddr)
+	redisConn.SetAuth(redisUsername, redisPassword)
+	testRedis(redisConn, redisAddr, redisWriteAddr, log)
 
 	requiredUserCallables := openslidesRequiredUsers()
 	projectorCallables := openslidesProjectorCallables()
 	closed := make(chan struct{})
-	ds, err := datastore.New(redisConn, requiredUserCallables, projectorCallables, closed)
+
+	var dataConn datastore.RedisConn = redisConn
+	switch source := getEnv("DATA_SOURCE", "redis"); source {
+	case "redis":
+	case "grpc":
+		if getEnv("MESSAGE_BUS_SHARDS", "") != "" {
+			return fmt.Errorf("environment variable MESSAGE_BUS_SHARDS can not be used with DATA_SOURCE grpc")
+		}
+
+		client, err := autoupdategrpc.New(getEnv("WORKER_GRPC_ADDR", "localhost:9000"))
+		if err != nil {
+			return fmt.Errorf("creating grpc client: %w", err)
+		}
+		defer client.Close()
+		dataConn = client
+	default:
+		return fmt.Errorf("invalid value in environment variable DATA_SOURCE `%s`, expected redis or grpc", source)
+	}
+
+	if shardAddrs := getEnv("MESSAGE_BUS_SHARDS", ""); shardAddrs != "" {
+		shards := []datastore.RedisConn{redisConn}
+		for _, addr := range strings.Split(shardAddrs, ",") {
+			shardConn := redis.New(addr, addr, sessionPrefix)
+			shardConn.SetAuth(redisUsername, redisPassword)
+			testRedis(shardConn, addr, addr, log)
+			shards = append(shards, shardConn)
+		}
+		dataConn = datastore.NewShards(closed, shards...)
+	}
+
+	fullDataTimeout, err := strconv.Atoi(getEnv("FULL_DATA_TIMEOUT", "60"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable FULL_DATA_TIMEOUT should be an int")
+	}
+	dataConn = datastore.RetryFullData(dataConn, time.Duration(fullDataTimeout)*time.Second, log, closed)
+
+	snapshotFile := getEnv("SNAPSHOT_FILE", "")
+	ds, err := newDatastore(snapshotFile, dataConn, requiredUserCallables, projectorCallables, log, closed)
 	if err != nil {
 		return fmt.Errorf("initialize data: %w", err)
 	}
 
-	osRestricters := openslidesRestricters(ds)
+	if workerURL := getEnv("WORKER_URL", ""); workerURL != "" {
+		ds.SetWorker(worker.New(workerURL))
+	}
+
+	coalesceWindow, err := strconv.Atoi(getEnv("UPDATE_COALESCE_MS", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable UPDATE_COALESCE_MS should be an int")
+	}
+	ds.SetCoalesceWindow(time.Duration(coalesceWindow) * time.Millisecond)
+
+	maxChangeIDJump, err := strconv.Atoi(getEnv("MAX_CHANGE_ID_JUMP", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable MAX_CHANGE_ID_JUMP should be an int")
+	}
+	ds.SetMaxChangeIDJump(maxChangeIDJump)
+
+	updateLogInterval, err := strconv.Atoi(getEnv("LOG_UPDATE_SAMPLE_MS", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable LOG_UPDATE_SAMPLE_MS should be an int")
+	}
+	updateLogEvery, err := strconv.Atoi(getEnv("LOG_UPDATE_SAMPLE_EVERY", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable LOG_UPDATE_SAMPLE_EVERY should be an int")
+	}
+	ds.SetUpdateLogSampling(time.Duration(updateLogInterval)*time.Millisecond, updateLogEvery)
+
+	breakerThreshold, err := strconv.Atoi(getEnv("BREAKER_THRESHOLD", "5"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable BREAKER_THRESHOLD should be an int")
+	}
+	breakerCooldown, err := strconv.Atoi(getEnv("BREAKER_COOLDOWN", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable BREAKER_COOLDOWN should be an int")
+	}
+	ds.SetBreaker(breakerThreshold, time.Duration(breakerCooldown)*time.Second)
+
+	snapshotDone := make(chan struct{})
+	if snapshotFile != "" {
+		snapshotInterval, err := strconv.Atoi(getEnv("SNAPSHOT_INTERVAL", "300"))
+		if err != nil {
+			return fmt.Errorf("invalid value in environment variable SNAPSHOT_INTERVAL should be an int")
+		}
+
+		go func() {
+			defer close(snapshotDone)
+			snapshotLoop(ds, snapshotFile, time.Duration(snapshotInterval)*time.Second, log, closed)
+		}()
+	} else {
+		close(snapshotDone)
+	}
+
+	reconcileInterval, err := strconv.Atoi(getEnv("RECONCILE_INTERVAL", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable RECONCILE_INTERVAL should be an int")
+	}
+
+	reconcileSampleSize, err := strconv.Atoi(getEnv("RECONCILE_SAMPLE_SIZE", "100"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable RECONCILE_SAMPLE_SIZE should be an int")
+	}
+
+	if reconcileInterval > 0 {
+		go reconcileLoop(ds, time.Duration(reconcileInterval)*time.Second, reconcileSampleSize, log, closed)
+	}
+
+	osRestricters := openslidesRestricters(ds, ds.Config())
+	for collection, e := range ds.PluginRestricters() {
+		if _, ok := osRestricters[collection]; ok {
+			log.Error("Plugin can not replace the restricter of a collection", "collection", collection)
+			continue
+		}
+		osRestricters[collection] = e
+	}
 	restricter := restricter.New(ds, osRestricters)
+	ds.SetRestricter(restricter)
 
-	a, err := autoupdate.New(ds, restricter, closed)
+	a, err := autoupdate.New(ds, restricter, log, closed)
 	if err != nil {
 		return fmt.Errorf("initialize autoupdate service: %v", err)
 	}
 
+	subscriberBuffer, err := strconv.Atoi(getEnv("SUBSCRIBER_BUFFER", strconv.Itoa(autoupdate.DefaultSubscriberBuffer)))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable SUBSCRIBER_BUFFER should be an int")
+	}
+	a.SetSubscriberBuffer(subscriberBuffer)
+
+	warmUpTimeout, err := strconv.Atoi(getEnv("SNAPSHOT_WARMUP_TIMEOUT", "10"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable SNAPSHOT_WARMUP_TIMEOUT should be an int")
+	}
+	if warmUpTimeout > 0 {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(warmUpTimeout)*time.Second)
+		count := a.WarmUp(ctx)
+		cancel()
+		log.Info("Warmed up snapshots", "snapshots", count, "duration", time.Since(start))
+	}
+
+	watchdogTimeout, err := strconv.Atoi(getEnv("WATCHDOG_TIMEOUT", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable WATCHDOG_TIMEOUT should be an int")
+	}
+	if watchdogTimeout > 0 {
+		a.StartWatchdog(time.Duration(watchdogTimeout) * time.Second)
+	}
+
 	applauseInterval, err := strconv.Atoi(getEnv("APPLAUSE_INTERVAL_MS", "1000"))
 	if err != nil {
 		return fmt.Errorf("invalid value in environment variable APPLAUSE_INTERVAL should be an int")
 	}
 
 	n := notify.New(redisConn, ds, applauseInterval, closed)
+	n.SetReceivePermission(ds, getEnv("NOTIFY_PERMISSION", "core.can_see_frontpage"))
 
 	var authService autoupdatehttp.Auther
 	if fakeUID := getEnv("FAKE_AUTH", "-1"); fakeUID == "-1" {
@@ -91,46 +243,234 @@ func run() error {
 		}
 		f.Close()
 
+		sessionCacheTTL, err := strconv.Atoi(getEnv("SESSION_CACHE_TTL_MS", "5000"))
+		if err != nil {
+			return fmt.Errorf("invalid value in environment variable SESSION_CACHE_TTL_MS should be an int")
+		}
+
 		cookieName := getEnv("COOKIE_NAME", "OpenSlidesSessionID")
-		authService = auth.New(cookieName, secretKey, redisConn, ds)
+		cookieAuth := auth.New(cookieName, secretKey, redisConn, ds.Config())
+		cookieAuth.SetCacheTTL(time.Duration(sessionCacheTTL) * time.Millisecond)
+		authService = cookieAuth
 	} else {
 		uid, err := strconv.Atoi(fakeUID)
 		if err != nil {
 			return fmt.Errorf("invalid value in env FAKE_AUTH, has to be an int, got: %s", fakeUID)
 		}
 		authService = auth.Fake(uid)
-		log.Printf("Using fake auth with user id %s", fakeUID)
+		log.Info("Using fake auth", "user_id", uid)
+	}
+
+	rateLimit, err := strconv.Atoi(getEnv("RATE_LIMIT_PER_MINUTE", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable RATE_LIMIT_PER_MINUTE should be an int")
+	}
+
+	anonymousRateLimit, err := strconv.Atoi(getEnv("RATE_LIMIT_ANONYMOUS_PER_MINUTE", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable RATE_LIMIT_ANONYMOUS_PER_MINUTE should be an int")
+	}
+	limiter := autoupdatehttp.NewRateLimiter(rateLimit, anonymousRateLimit)
+
+	maxMessageSize, err := strconv.Atoi(getEnv("MAX_MESSAGE_SIZE", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable MAX_MESSAGE_SIZE should be an int")
+	}
+
+	keepalive, err := strconv.Atoi(getEnv("SSE_KEEPALIVE_INTERVAL", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable SSE_KEEPALIVE_INTERVAL should be an int")
 	}
 
 	mux := http.NewServeMux()
-	autoupdatehttp.RegisterAll(mux, authService, a, n)
+	autoupdatehttp.RegisterAll(mux, autoupdatehttp.Services{
+		Auth:             authService,
+		Autoupdate:       a,
+		Notify:           n,
+		Limiter:          limiter,
+		MaxMessageSize:   maxMessageSize,
+		Keepalive:        time.Duration(keepalive) * time.Second,
+		Readier:          ds,
+		Counter:          ds,
+		Resetter:         ds,
+		Superadminer:     ds,
+		Explainer:        restricter,
+		HasPermer:        ds,
+		PermissionLister: ds,
+		RawElementer:     ds,
+		Applauser:        ds,
+		ApplauseResetter: ds,
+		Log:              log,
+	})
 
 	if err := initMeter(mux); err != nil {
 		return fmt.Errorf("initialize meter: %w", err)
 	}
 
+	gracePeriod, err := strconv.Atoi(getEnv("SHUTDOWN_GRACE_PERIOD", "10"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable SHUTDOWN_GRACE_PERIOD should be an int")
+	}
+
+	maxConnections, err := strconv.Atoi(getEnv("MAX_CONNECTIONS", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable MAX_CONNECTIONS should be an int")
+	}
+
+	writeTimeout, err := strconv.Atoi(getEnv("WRITE_TIMEOUT", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable WRITE_TIMEOUT should be an int")
+	}
+
+	aliases, err := autoupdatehttp.ParseAliases(getEnv("COLLECTION_ALIASES", ""))
+	if err != nil {
+		return fmt.Errorf("invalid value in environment variable COLLECTION_ALIASES: %w", err)
+	}
+
 	// Create http server.
 	listenAddr := getEnv("AUTOUPDATE_HOST", "") + ":" + getEnv("AUTOUPDATE_PORT", "8002")
-	srv := &http.Server{Addr: listenAddr, Handler: mux}
+	srv := &http.Server{Addr: listenAddr, Handler: autoupdatehttp.LimitConnections(autoupdatehttp.WriteDeadline(autoupdatehttp.CollectionAliases(mux, aliases), time.Duration(writeTimeout)*time.Second, log), maxConnections, log)}
+
+	// The internal server serves the unrestricted data. It uses its own mux, so
+	// the routes can not be reached from the public server.
+	var internalSrv *http.Server
+	if internalAddr := getEnv("INTERNAL_ADDR", ""); internalAddr != "" {
+		internalMux := http.NewServeMux()
+		autoupdatehttp.RegisterInternal(internalMux, ds, log)
+		internalSrv = &http.Server{Addr: internalAddr, Handler: internalMux}
+
+		go func() {
+			log.Info("Listen internal", "addr", internalAddr)
+			if err := internalSrv.ListenAndServe(); err != http.ErrServerClosed {
+				log.Error("Internal HTTP server failed", "error", err)
+			}
+		}()
+	}
 
 	wait := make(chan error)
 	go func() {
 		waitForShutdown()
 
-		close(closed)
-		if err := srv.Shutdown(context.Background()); err != nil {
-			wait <- fmt.Errorf("HTTP server shutdown: %w", err)
-			return
+		if internalSrv != nil {
+			if err := internalSrv.Close(); err != nil {
+				log.Error("Can not close internal HTTP server", "error", err)
+			}
 		}
-		wait <- nil
+
+		log.Info("Shutdown", "grace_period_seconds", gracePeriod)
+		closeService := func() { close(closed) }
+		wait <- autoupdatehttp.Shutdown(srv, closeService, time.Duration(gracePeriod)*time.Second, log)
 	}()
 
-	fmt.Printf("Listen on %s\n", listenAddr)
+	log.Info("Listen", "addr", listenAddr)
 	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
 		return fmt.Errorf("HTTP Server failed: %v", err)
 	}
 
-	return <-wait
+	err = <-wait
+	<-snapshotDone
+	return err
+}
+
+// newDatastore initializes the datastore. If snapshotFile is not empty and
+// exists, the datastore is initialized from the snapshot.
+func newDatastore(snapshotFile string, redisConn datastore.RedisConn, requiredUsers map[string]func(json.RawMessage) (map[int]bool, string, error), projectorSlides map[string]projector.Callable, log logger.Logger, closed <-chan struct{}) (*datastore.Datastore, error) {
+	if snapshotFile == "" {
+		return datastore.New(redisConn, requiredUsers, projectorSlides, log, closed)
+	}
+
+	f, err := os.Open(snapshotFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error("Can not open snapshot", "error", err)
+		}
+		return datastore.NewFromSnapshot(redisConn, nil, requiredUsers, projectorSlides, log, closed)
+	}
+	defer f.Close()
+
+	log.Info("Load snapshot", "file", snapshotFile)
+	return datastore.NewFromSnapshot(redisConn, bufio.NewReader(f), requiredUsers, projectorSlides, log, closed)
+}
+
+// snapshotLoop writes a snapshot of the datastore every interval and when the
+// service is closed.
+func snapshotLoop(ds *datastore.Datastore, snapshotFile string, interval time.Duration, log logger.Logger, closed <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-closed:
+			if err := writeSnapshot(ds, snapshotFile); err != nil {
+				log.Error("Can not write snapshot", "error", err)
+			}
+			return
+		}
+
+		if err := writeSnapshot(ds, snapshotFile); err != nil {
+			log.Error("Can not write snapshot", "error", err)
+		}
+	}
+}
+
+// reconcileLoop compares the cache with redis every interval.
+func reconcileLoop(ds *datastore.Datastore, interval time.Duration, sampleSize int, log logger.Logger, closed <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-closed:
+			return
+		}
+
+		if _, err := ds.Reconcile(sampleSize); err != nil {
+			var closing interface {
+				Closing()
+			}
+			if errors.As(err, &closing) {
+				return
+			}
+			log.Error("Can not compare the cache with redis", "error", err)
+		}
+	}
+}
+
+// writeSnapshot writes the snapshot to a temporary file and renames it
+// afterwards. So there is never a half written snapshot.
+func writeSnapshot(ds *datastore.Datastore, snapshotFile string) error {
+	tmpFile := snapshotFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return fmt.Errorf("create snapshot file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	if err := ds.WriteSnapshot(w); err != nil {
+		f.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("flush snapshot: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync snapshot file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close snapshot file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, snapshotFile); err != nil {
+		return fmt.Errorf("rename snapshot file: %w", err)
+	}
+	return nil
 }
 
 func secretKey(r io.Reader) (string, error) {
@@ -182,7 +522,7 @@ func getEnv(env, devaultValue string) string {
 	return value
 }
 
-func testRedis(conn *redis.Redis, readAddr, writeAddr string) {
+func testRedis(conn *redis.Redis, readAddr, writeAddr string, log logger.Logger) {
 	var readConnected bool
 	var writeConnected bool
 	for {
@@ -190,7 +530,7 @@ func testRedis(conn *redis.Redis, readAddr, writeAddr string) {
 			if err := conn.TestReadConn(); err == nil {
 				readConnected = true
 			} else {
-				log.Printf("Can not connect to redis at %s for reading.", readAddr)
+				log.Warn("Can not connect to redis for reading", "addr", readAddr)
 			}
 		}
 
@@ -198,7 +538,7 @@ func testRedis(conn *redis.Redis, readAddr, writeAddr string) {
 			if err := conn.TestWriteConn(); err == nil {
 				writeConnected = true
 			} else {
-				log.Printf("Can not connect to redis at %s for writing.", writeAddr)
+				log.Warn("Can not connect to redis for writing", "addr", writeAddr)
 			}
 		}
 
@@ -206,10 +546,10 @@ func testRedis(conn *redis.Redis, readAddr, writeAddr string) {
 			break
 		}
 
-		log.Println("Retry...")
+		log.Info("Retry to connect to redis", "wait", redisRetryWait)
 		time.Sleep(redisRetryWait)
 	}
-	fmt.Printf("Connected to Redis at %s (read) and %s (write)\n", readAddr, writeAddr)
+	log.Info("Connected to redis", "read_addr", readAddr, "write_addr", writeAddr)
 }
 
 func openslidesRequiredUsers() map[string]func(json.RawMessage) (map[int]bool, string, error) {
@@ -223,47 +563,54 @@ func openslidesRequiredUsers() map[string]func(json.RawMessage) (map[int]bool, s
 	}
 }
 
-func openslidesRestricters(ds restricter.HasPermer) map[string]restricter.Element {
+func openslidesRestricters(ds restricter.HasPermer, configer core.Configer) map[string]restricter.Element {
 	basePerm := restricter.BasePermission(ds)
-	return map[string]restricter.Element{
-		"agenda/item":             agenda.Restrict(ds),
-		"agenda/list-of-speakers": basePerm(agenda.CanSeeListOfSpeakers),
+	restricters := map[string]restricter.Element{
+		"agenda/item": agenda.Restrict(ds),
 
 		"assignments/assignment":        basePerm(assignment.CanSee),
-		"assignments/assignment-poll":   poll.RestrictPoll(ds, assignment.CanSee, assignment.CanManage, []string{"amount_global_yes", "amount_global_no", "amount_global_abstain"}),
-		"assignments/assignment-option": poll.RestrictOption(ds, assignment.CanSee, assignment.CanManage),
-		"assignments/assignment-vote":   poll.RestrictVote(ds, assignment.CanSee, assignment.CanManage),
+		"assignments/assignment-poll":   assignment.PollRestrict(ds),
+		"assignments/assignment-option": assignment.OptionRestrict(ds),
+		"assignments/assignment-vote":   assignment.VoteRestrict(ds),
 
-		"chat/chat-group":   chat.Restrict(ds),
+		"chat/chat-group":   chat.RestrictGroup(ds),
 		"chat/chat-message": chat.Restrict(ds),
 
 		"core/projector":          basePerm(core.CanSeeProjector),
 		"core/projection-default": basePerm(core.CanSeeProjector),
-		"core/projector-message":  basePerm(core.CanSeeProjector),
-		"core/countdown":          basePerm(core.CanSeeProjector),
-		"core/tag":                restricter.ForAll,
-		"core/config":             restricter.ForAll,
+		"core/projector-message":  core.RestrictProjectorMessage(ds),
+		"core/countdown":          core.RestrictCountdown(ds),
+		"core/config":             core.RestrictConfig(ds),
+		"core/history":            history.Restrict(ds),
 
 		"mediafiles/mediafile": mediafile.Restrict(ds),
 
-		"motions/category":                     basePerm(motion.CanSee),
-		"motions/statute-paragraph":            basePerm(motion.CanSee),
+		"motions/category":                     motion.CategoryRestrict(ds),
+		"motions/statute-paragraph":            motion.StatuteParagraphRestrict(ds),
 		"motions/motion":                       motion.Restrict(ds),
 		"motions/motion-block":                 motion.BlockRestrict(ds),
 		"motions/motion-comment-section":       motion.CommentSectionRestrict(ds),
-		"motions/workflow":                     basePerm(motion.CanSee),
+		"motions/workflow":                     motion.WorkflowRestrict(ds),
 		"motions/motion-change-recommendation": motion.ChangeRecommendationRestrict(ds),
 		"motions/motion-poll":                  poll.RestrictPoll(ds, motion.CanSee, motion.CanManagePolls, nil),
 		"motions/motion-option":                poll.RestrictOption(ds, motion.CanSee, motion.CanManagePolls),
-		"motions/motion-vote":                  poll.RestrictVote(ds, motion.CanSee, motion.CanManagePolls),
-		"motions/state":                        basePerm(motion.CanSee),
+		"motions/motion-vote":                  poll.RestrictVote(ds, motion.CanSee, motion.CanManagePolls, "motions/motion"),
+		"motions/state":                        motion.StateRestrict(ds),
 
-		"topics/topic": basePerm("agenda.can_see"),
+		"topics/topic": topic.Restrict(ds),
 
 		"users/user":          user.Restrict(ds),
-		"users/group":         restricter.ForAll,
+		"users/group":         user.RestrictGroup(ds),
 		"users/personal-note": restricter.ElementFunc(user.PersonalNoteRestrict),
 	}
+
+	for _, collection := range core.PublicCollections {
+		restricters[collection] = core.RestrictPublic(ds, configer)
+	}
+
+	// The list of speakers is hidden, if its content object is hidden.
+	restricters["agenda/list-of-speakers"] = agenda.RestrictListOfSpeakers(ds, restricters)
+	return restricters
 }
 
 func openslidesProjectorCallables() map[string]projector.Callable {
@@ -277,17 +624,17 @@ func openslidesProjectorCallables() map[string]projector.Callable {
 		"assignments/assignment":      assignment.Slide(),
 		"assignments/assignment-poll": assignment.PollSlide(),
 
-		"core/countdown":         core.CountdownSlide(),
-		"core/projector-message": core.MessageSlide(),
-		"core/clock":             core.ClockSlide(),
+		"core/countdown":         projector.DependsOn(core.CountdownSlide(), "core/countdown", "core/config"),
+		"core/projector-message": projector.DependsOn(core.MessageSlide(), "core/projector-message"),
+		"core/clock":             projector.DependsOn(core.ClockSlide()),
 
-		"mediafiles/mediafile": mediafile.Slide(),
+		"mediafiles/mediafile": projector.DependsOn(mediafile.Slide(), "mediafiles/mediafile"),
 
 		"motions/motion":       motion.Slide(),
 		"motions/motion-block": motion.SlideMotionBlock(),
 		"motions/motion-poll":  motion.SlideMotionPoll(),
 
 		"topics/topic": topic.Slide(),
-		"users/user":   user.Slide(),
+		"users/user":   projector.DependsOn(user.Slide(), "users/user"),
 	}
 }
@@ -7,13 +7,42 @@ import (
 	"testing"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
 )
 
 func TestRestrictedData(t *testing.T) {
+	// The example data was created with the OpenSlides server. The autoupdate
+	// service restricts the fields of these collections differently, for
+	// example managers see all motion comments, the presence of a user is
+	// only visible with users.can_see_extra_data and the default time of a
+	// countdown is only visible for projector managers. Only the visibility of
+	// the elements is compared.
+	differentFields := map[string]bool{
+		"core/countdown":       true,
+		"users/group":          true,
+		"users/user":           true,
+		"mediafiles/mediafile": true,
+		"motions/motion":       true,
+	}
+
+	// The autoupdate service hides the elements of these collections in more
+	// cases then the OpenSlides server. For example a list of speakers is
+	// hidden, if its content object is hidden, an amendment is hidden, if
+	// its parent motion is hidden, some config values are only visible for
+	// managers, tags are only visible with core.can_see_frontpage and a topic
+	// is hidden, if its agenda item is hidden.
+	moreRestricted := map[string]bool{
+		"agenda/list-of-speakers": true,
+		"core/config":             true,
+		"core/tag":                true,
+		"motions/motion":          true,
+		"topics/topic":            true,
+	}
+
 	for _, tt := range test.ExampleRestrictedData() {
 		t.Run(tt.Name, func(t *testing.T) {
-			restricters := openslidesRestricters(tt.Permer)
+			restricters := openslidesRestricters(tt.Permer, tt.Permer)
 			r := restricters[tt.Collection]
 
 			got, err := r.Restrict(tt.UID, tt.Element)
@@ -30,10 +59,17 @@ func TestRestrictedData(t *testing.T) {
 			}
 
 			if got == nil {
+				if moreRestricted[tt.Collection] {
+					return
+				}
 				t.Errorf("Restrict() returned nil, expected %s", tt.Expected)
 				return
 			}
 
+			if differentFields[tt.Collection] {
+				return
+			}
+
 			test.ExpectEqualJSON(t, got, tt.Expected)
 		})
 	}
@@ -83,7 +119,7 @@ func TestProjector(t *testing.T) {
 			}
 			ds.FullData = fd
 
-			p := datastore.NewProjectors(ds, callables, closed)
+			p := datastore.NewProjectors(ds, callables, logger.Noop, closed)
 			if err := p.Update(fd); err != nil {
 				t.Fatalf("Can not update projector data: %v", err)
 			}
@@ -1,11 +1,36 @@
 module github.com/OpenSlides/openslides3-autoupdate-service
 
-go 1.16
+go 1.21
 
 require (
 	github.com/gomodule/redigo v1.8.4
+	github.com/gorilla/websocket v1.4.2
 	github.com/ostcar/topic v0.3.4-0.20200624102036-bdbe6ddf5dcd
 	go.opentelemetry.io/contrib/instrumentation/runtime v0.17.0
+	go.opentelemetry.io/otel v0.17.0
 	go.opentelemetry.io/otel/exporters/metric/prometheus v0.17.0
 	go.opentelemetry.io/otel/metric v0.17.0
+	go.opentelemetry.io/otel/oteltest v0.17.0
+	go.opentelemetry.io/otel/trace v0.17.0
+	google.golang.org/grpc v1.60.1
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_golang v1.9.0 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.15.0 // indirect
+	github.com/prometheus/procfs v0.2.0 // indirect
+	go.opentelemetry.io/contrib v0.17.0 // indirect
+	go.opentelemetry.io/otel/sdk v0.17.0 // indirect
+	go.opentelemetry.io/otel/sdk/export/metric v0.17.0 // indirect
+	go.opentelemetry.io/otel/sdk/metric v0.17.0 // indirect
+	golang.org/x/net v0.16.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97 // indirect
 )
@@ -2,6 +2,7 @@ package agenda
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
@@ -15,10 +16,24 @@ const (
 	// CanSeeListOfSpeakers is the permission string if a user can see the list
 	// of speakers.
 	CanSeeListOfSpeakers = "agenda.can_see_list_of_speakers"
+
+	pCanManageListOfSpeakers = "agenda.can_manage_list_of_speakers"
 )
 
+// FieldRules are the fields of agenda/item, that are only visible with a
+// permission.
+var FieldRules = []restricter.FieldRule{
+	{Collection: "agenda/item", Field: "duration", RequiredPerm: pCanSeeInternal},
+	{Collection: "agenda/item", Field: "comment", RequiredPerm: pCanManage},
+}
+
 // Restrict handels restrictions of agenda/item elements.
 func Restrict(r restricter.HasPermer) restricter.ElementFunc {
+	return restricter.MaskFields(r, "agenda/item", restrictItem(r), FieldRules)
+}
+
+// restrictItem decides, if the user can see the agenda item at all.
+func restrictItem(r restricter.HasPermer) restricter.ElementFunc {
 	return func(uid int, element json.RawMessage) (json.RawMessage, error) {
 		if !r.HasPerm(uid, pCanSee) {
 			return nil, nil
@@ -32,38 +47,129 @@ func Restrict(r restricter.HasPermer) restricter.ElementFunc {
 			return nil, fmt.Errorf("decoding item: %w", err)
 		}
 
-		canManage := r.HasPerm(uid, pCanManage)
-		canSeeInternal := r.HasPerm(uid, pCanSeeInternal)
+		if agenda.IsHidden && !r.HasPerm(uid, pCanManage) {
+			return nil, nil
+		}
 
-		if !canManage && agenda.IsHidden {
+		if agenda.IsInternal && !r.HasPerm(uid, pCanSeeInternal) {
 			return nil, nil
 		}
 
-		if !canSeeInternal && agenda.IsInternal {
+		return element, nil
+	}
+}
+
+// RestrictListOfSpeakers restricts agenda/list-of-speakers elements.
+//
+// A list of speakers is only visible with the permission CanSeeListOfSpeakers
+// and if the user can see its content object. The content object is restricted
+// with the Element of its collection from contentObjects. If the content object
+// does not exist or there is no Element for its collection, the list of
+// speakers is hidden.
+//
+// The notes of the speakers are only visible for managers of the list of
+// speakers and for the speaker of the entry.
+func RestrictListOfSpeakers(r restricter.HasPermer, contentObjects map[string]restricter.Element) restricter.ElementFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		if !r.HasPerm(uid, CanSeeListOfSpeakers) {
 			return nil, nil
 		}
 
-		if canManage && canSeeInternal {
-			return element, nil
+		var los struct {
+			ContentObject struct {
+				Collection string `json:"collection"`
+				ID         int    `json:"id"`
+			} `json:"content_object"`
+		}
+		if err := json.Unmarshal(data, &los); err != nil {
+			return nil, fmt.Errorf("decoding list of speakers: %w", err)
 		}
 
-		var agendaData map[string]json.RawMessage
-		if err := json.Unmarshal(element, &agendaData); err != nil {
-			return nil, fmt.Errorf("decoding itemdata: %w", err)
+		visible, err := contentObjectVisible(r, contentObjects, uid, los.ContentObject.Collection, los.ContentObject.ID)
+		if err != nil {
+			return nil, fmt.Errorf("restricting content object: %w", err)
 		}
 
-		if !canSeeInternal {
-			delete(agendaData, "duration")
+		if !visible {
+			return nil, nil
 		}
 
-		if !canManage {
-			delete(agendaData, "comment")
+		if r.HasPerm(uid, pCanManageListOfSpeakers) {
+			return data, nil
 		}
 
-		element, err := json.Marshal(agendaData)
-		if err != nil {
-			return nil, fmt.Errorf("encoding itemdata: %w", err)
+		return stripSpeakerNotes(uid, data)
+	}
+}
+
+// contentObjectVisible tells, if the user can see the content object of a list
+// of speakers.
+func contentObjectVisible(r restricter.HasPermer, contentObjects map[string]restricter.Element, uid int, collection string, id int) (bool, error) {
+	e, ok := contentObjects[collection]
+	if !ok {
+		return false, nil
+	}
+
+	var element json.RawMessage
+	if err := r.Get(collection, id, &element); err != nil {
+		var doesNotExist interface {
+			DoesNotExist() string
 		}
-		return element, nil
+		if errors.As(err, &doesNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting %s:%d: %w", collection, id, err)
+	}
+
+	restricted, err := e.Restrict(uid, element)
+	if err != nil {
+		return false, err
+	}
+	return restricted != nil, nil
+}
+
+// stripSpeakerNotes removes the notes of all speakers except the user.
+func stripSpeakerNotes(uid int, data json.RawMessage) (json.RawMessage, error) {
+	var los map[string]json.RawMessage
+	if err := json.Unmarshal(data, &los); err != nil {
+		return nil, fmt.Errorf("decoding list of speakers: %w", err)
+	}
+
+	var speakers []map[string]json.RawMessage
+	if err := json.Unmarshal(los["speakers"], &speakers); err != nil {
+		return nil, fmt.Errorf("decoding speakers: %w", err)
+	}
+
+	var changed bool
+	for _, speaker := range speakers {
+		if _, ok := speaker["note"]; !ok {
+			continue
+		}
+
+		var userID int
+		if err := json.Unmarshal(speaker["user_id"], &userID); err != nil {
+			return nil, fmt.Errorf("decoding user id of speaker: %w", err)
+		}
+
+		if userID != uid {
+			delete(speaker, "note")
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, nil
+	}
+
+	encoded, err := json.Marshal(speakers)
+	if err != nil {
+		return nil, fmt.Errorf("encoding speakers: %w", err)
+	}
+	los["speakers"] = encoded
+
+	data, err = json.Marshal(los)
+	if err != nil {
+		return nil, fmt.Errorf("encoding list of speakers: %w", err)
 	}
+	return data, nil
 }
@@ -1,9 +1,11 @@
 package agenda_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/agenda"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
 )
 
@@ -103,3 +105,114 @@ func TestRestrict(t *testing.T) {
 		})
 	}
 }
+
+func TestRestrictListOfSpeakers(t *testing.T) {
+	permer := &test.HasPermMock{
+		Data: map[string]json.RawMessage{
+			"topics/topic:1": []byte(`{"id":1}`),
+			"topics/topic:2": []byte(`{"id":2,"hidden":true}`),
+		},
+	}
+
+	contentObjects := map[string]restricter.Element{
+		"topics/topic": restricter.ElementFunc(func(_ int, data json.RawMessage) (json.RawMessage, error) {
+			var topic struct {
+				Hidden bool `json:"hidden"`
+			}
+			if err := json.Unmarshal(data, &topic); err != nil {
+				return nil, err
+			}
+
+			if topic.Hidden {
+				return nil, nil
+			}
+			return data, nil
+		}),
+	}
+	r := agenda.RestrictListOfSpeakers(permer, contentObjects)
+
+	los := `{
+		"id": 1,
+		"speakers": [
+			{"id": 1, "user_id": 1, "note": "own note"},
+			{"id": 2, "user_id": 2, "note": "other note"},
+			{"id": 3, "user_id": 3}
+		],
+		"content_object": {"collection": "topics/topic", "id": 1}
+	}`
+
+	for _, tt := range []struct {
+		name     string
+		perms    []string
+		data     string
+		expected string
+	}{
+		{
+			"No permission",
+			nil,
+			los,
+			"",
+		},
+		{
+			"Can see",
+			[]string{"agenda.can_see_list_of_speakers"},
+			los,
+			`{
+				"id": 1,
+				"speakers": [
+					{"id": 1, "user_id": 1, "note": "own note"},
+					{"id": 2, "user_id": 2},
+					{"id": 3, "user_id": 3}
+				],
+				"content_object": {"collection": "topics/topic", "id": 1}
+			}`,
+		},
+		{
+			"Manager",
+			[]string{"agenda.can_see_list_of_speakers", "agenda.can_manage_list_of_speakers"},
+			los,
+			los,
+		},
+		{
+			"Hidden content object",
+			[]string{"agenda.can_see_list_of_speakers", "agenda.can_manage_list_of_speakers"},
+			`{"id": 2, "speakers": [], "content_object": {"collection": "topics/topic", "id": 2}}`,
+			"",
+		},
+		{
+			"Deleted content object",
+			[]string{"agenda.can_see_list_of_speakers"},
+			`{"id": 3, "speakers": [], "content_object": {"collection": "topics/topic", "id": 3}}`,
+			"",
+		},
+		{
+			"Unknown content object collection",
+			[]string{"agenda.can_see_list_of_speakers"},
+			`{"id": 4, "speakers": [], "content_object": {"collection": "unknown/collection", "id": 1}}`,
+			"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer.Perms = tt.perms
+
+			got, err := r.Restrict(1, []byte(tt.data))
+			if err != nil {
+				t.Errorf("Restrict returned unexpected error: %v ", err)
+			}
+
+			if tt.expected == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Errorf("Restrict() returned nil, expected %s", tt.expected)
+				return
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.expected))
+		})
+	}
+}
@@ -1,9 +1,48 @@
 package assignment
 
+import (
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/poll"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+)
+
 const (
 	// CanSee is the can see permission string of assignments.
 	CanSee = "assignments.can_see"
 
 	// CanManage is the manage permission string of assignments.
+	//
+	// OpenSlides 3 has no extra permission to manage assignment polls. Users
+	// with this permission see all results of the polls.
 	CanManage = "assignments.can_manage"
 )
+
+// pollGlobalFields are the results of an assignment poll, that are not
+// results of one candidate.
+var pollGlobalFields = []string{"amount_global_yes", "amount_global_no", "amount_global_abstain"}
+
+// PollRestrict restricts assignments/assignment-poll.
+//
+// The results of a poll are only visible for managers or after the poll is
+// published. This is the same for named, pseudoanonymous and analog polls. The
+// results of an analog poll are entered by a manager while the poll is
+// finished.
+func PollRestrict(r restricter.HasPermer) restricter.ElementFunc {
+	return poll.RestrictPoll(r, CanSee, CanManage, pollGlobalFields)
+}
+
+// OptionRestrict restricts assignments/assignment-option.
+//
+// Each candidate of an election is an option. The results of a candidate are
+// only visible for managers or after the poll is published.
+func OptionRestrict(r restricter.HasPermer) restricter.ElementFunc {
+	return poll.RestrictOption(r, CanSee, CanManage)
+}
+
+// VoteRestrict restricts assignments/assignment-vote.
+//
+// Before the poll is published, a user only sees the own votes of a named
+// poll. Afterwards the votes are visible. The users of a pseudoanonymous poll
+// are always removed, also for managers.
+func VoteRestrict(r restricter.HasPermer) restricter.ElementFunc {
+	return poll.RestrictVote(r, CanSee, CanManage, "assignments/assignment")
+}
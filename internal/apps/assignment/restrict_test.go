@@ -0,0 +1,172 @@
+package assignment_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/assignment"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/poll"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+var pollStates = []struct {
+	name  string
+	state int
+}{
+	{"running", poll.StateStarted},
+	{"finished", poll.StateFinished},
+	{"published", poll.StatePublished},
+}
+
+func TestPollRestrict(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"users/user:1": []byte(`{"id": 1, "vote_delegated_from_users_id": []}`),
+	}
+
+	for _, pollType := range []string{"named", "pseudoanonymous", "analog"} {
+		for _, state := range pollStates {
+			for _, manager := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%s %s manager %t", pollType, state.name, manager), func(t *testing.T) {
+					perms := []string{assignment.CanSee}
+					if manager {
+						perms = append(perms, assignment.CanManage)
+					}
+					permer := &test.HasPermMock{Perms: perms, Data: data}
+
+					element := fmt.Sprintf(
+						`{"id": 1, "state": %d, "type": "%s", "votesvalid": "3.000000", "votesinvalid": "0.000000", "votescast": "3.000000", "amount_global_yes": "1.000000", "amount_global_no": "1.000000", "amount_global_abstain": "1.000000", "voted_id": [1], "options_id": [1, 2]}`,
+						state.state,
+						pollType,
+					)
+					got, err := assignment.PollRestrict(permer).Restrict(1, []byte(element))
+					if err != nil {
+						t.Fatalf("Restrict returned unexpected error: %v", err)
+					}
+
+					var restricted map[string]json.RawMessage
+					if err := json.Unmarshal(got, &restricted); err != nil {
+						t.Fatalf("Restrict returned invalid json: %v", err)
+					}
+
+					if restricted["options_id"] == nil {
+						t.Errorf("Restrict removed the options of the poll")
+					}
+
+					expectVisible := manager || state.state == poll.StatePublished
+					for _, field := range []string{"votesvalid", "votesinvalid", "votescast", "voted_id", "amount_global_yes", "amount_global_no", "amount_global_abstain"} {
+						if _, ok := restricted[field]; ok != expectVisible {
+							t.Errorf("Field %s visible: %t, expected %t", field, ok, expectVisible)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestOptionRestrict(t *testing.T) {
+	// An election with two candidates.
+	options := []string{
+		`{"id": 1, "user_id": 5, "yes": "2.000000", "no": "1.000000", "abstain": "0.000000", "poll_id": 1, "pollstate": %d}`,
+		`{"id": 2, "user_id": 6, "yes": "1.000000", "no": "2.000000", "abstain": "0.000000", "poll_id": 1, "pollstate": %d}`,
+	}
+
+	for _, state := range pollStates {
+		for _, manager := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s manager %t", state.name, manager), func(t *testing.T) {
+				perms := []string{assignment.CanSee}
+				if manager {
+					perms = append(perms, assignment.CanManage)
+				}
+				permer := &test.HasPermMock{Perms: perms}
+
+				for i, option := range options {
+					got, err := assignment.OptionRestrict(permer).Restrict(1, []byte(fmt.Sprintf(option, state.state)))
+					if err != nil {
+						t.Fatalf("Restrict returned unexpected error: %v", err)
+					}
+
+					var restricted map[string]json.RawMessage
+					if err := json.Unmarshal(got, &restricted); err != nil {
+						t.Fatalf("Restrict returned invalid json: %v", err)
+					}
+
+					if restricted["user_id"] == nil {
+						t.Errorf("Restrict removed the candidate of option %d", i+1)
+					}
+
+					expectVisible := manager || state.state == poll.StatePublished
+					for _, field := range []string{"yes", "no", "abstain"} {
+						if _, ok := restricted[field]; ok != expectVisible {
+							t.Errorf("Option %d: field %s visible: %t, expected %t", i+1, field, ok, expectVisible)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestVoteRestrict(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"assignments/assignment-poll:1":   []byte(`{"id": 1, "type": "named"}`),
+		"assignments/assignment-poll:2":   []byte(`{"id": 2, "type": "pseudoanonymous"}`),
+		"assignments/assignment-poll:3":   []byte(`{"id": 3, "type": "analog"}`),
+		"assignments/assignment-option:1": []byte(`{"id": 1, "poll_id": 1}`),
+		"assignments/assignment-option:2": []byte(`{"id": 2, "poll_id": 2}`),
+		"assignments/assignment-option:3": []byte(`{"id": 3, "poll_id": 3}`),
+	}
+
+	vote := func(userID string, optionID, state int) string {
+		return fmt.Sprintf(`{"id": 1, "value": "Y", "weight": "1.000000", "user_id": %s, "delegated_user_id": null, "option_id": %d, "pollstate": %d}`, userID, optionID, state)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		uid     int
+		manager bool
+		element string
+		expect  string
+	}{
+		{"named running", 1, false, vote("5", 1, poll.StateStarted), ""},
+		{"named running own vote", 5, false, vote("5", 1, poll.StateStarted), vote("5", 1, poll.StateStarted)},
+		{"named finished", 1, false, vote("5", 1, poll.StateFinished), ""},
+		{"named published", 1, false, vote("5", 1, poll.StatePublished), vote("5", 1, poll.StatePublished)},
+		{"named finished manager", 1, true, vote("5", 1, poll.StateFinished), vote("5", 1, poll.StateFinished)},
+		{"pseudoanonymous finished", 1, false, vote("5", 2, poll.StateFinished), ""},
+		{"pseudoanonymous published", 1, false, vote("5", 2, poll.StatePublished), vote("null", 2, poll.StatePublished)},
+		{"pseudoanonymous finished own vote", 5, false, vote("5", 2, poll.StateFinished), ""},
+		{"pseudoanonymous finished manager", 1, true, vote("5", 2, poll.StateFinished), vote("null", 2, poll.StateFinished)},
+		{"pseudoanonymous published manager", 1, true, vote("5", 2, poll.StatePublished), vote("null", 2, poll.StatePublished)},
+		{"analog finished", 1, false, vote("null", 3, poll.StateFinished), ""},
+		{"analog finished anonymous", 0, false, vote("null", 3, poll.StateFinished), ""},
+		{"analog published", 1, false, vote("null", 3, poll.StatePublished), vote("null", 3, poll.StatePublished)},
+		{"analog finished manager", 1, true, vote("null", 3, poll.StateFinished), vote("null", 3, poll.StateFinished)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			perms := []string{assignment.CanSee}
+			if tt.manager {
+				perms = append(perms, assignment.CanManage)
+			}
+			permer := &test.HasPermMock{Perms: perms, Data: data}
+
+			got, err := assignment.VoteRestrict(permer).Restrict(tt.uid, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.expect == "" {
+				if got != nil {
+					t.Errorf("Restrict returned %s, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.expect)
+			}
+			test.ExpectEqualJSON(t, []byte(tt.expect), got)
+		})
+	}
+}
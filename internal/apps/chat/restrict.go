@@ -2,35 +2,107 @@ package chat
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
 )
 
-type required interface {
-	restricter.HasPermer
-	InGroups(uid int, groups []int) bool
+const (
+	pCanSee    = "chat.can_see"
+	pCanManage = "chat.can_manage"
+)
+
+// RestrictGroup restricts chat/chat-group elements.
+//
+// A chat group is visible for users in its read or write groups.
+func RestrictGroup(r restricter.HasPermer) restricter.ElementFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		if !r.HasPerm(uid, pCanSee) {
+			return nil, nil
+		}
+
+		if r.HasPerm(uid, pCanManage) {
+			return data, nil
+		}
+
+		var chatGroup struct {
+			ReadGroupsID  []int `json:"read_groups_id"`
+			WriteGroupsID []int `json:"write_groups_id"`
+		}
+		if err := json.Unmarshal(data, &chatGroup); err != nil {
+			return nil, fmt.Errorf("decode chat-group: %w", err)
+		}
+
+		if inGroups(r, uid, chatGroup.ReadGroupsID) || inGroups(r, uid, chatGroup.WriteGroupsID) {
+			return data, nil
+		}
+		return nil, nil
+	}
 }
 
-// Restrict restricts chat:chat-group and chat:chat-message.
-func Restrict(r required) restricter.ElementFunc {
+// Restrict restricts chat/chat-message elements.
+//
+// A message is visible for users in the read groups of its chat group. The
+// author can always see the own messages. Messages of a deleted chat group are
+// only visible for its author and managers.
+func Restrict(r restricter.HasPermer) restricter.ElementFunc {
 	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
-		if r.HasPerm(uid, "chat.can_manage") {
+		if !r.HasPerm(uid, pCanSee) {
+			return nil, nil
+		}
+
+		if r.HasPerm(uid, pCanManage) {
 			return data, nil
 		}
 
-		var chatobject struct {
-			ReadGroupsId  []int `json:"read_groups_id"`
-			WriteGroupsId []int `json:"write_groups_id"`
+		var message struct {
+			ChatGroupID int `json:"chatgroup_id"`
+			UserID      int `json:"user_id"`
 		}
-		if err := json.Unmarshal(data, &chatobject); err != nil {
-			return nil, fmt.Errorf("decode chat-group/chat-message: %w", err)
+		if err := json.Unmarshal(data, &message); err != nil {
+			return nil, fmt.Errorf("decode chat-message: %w", err)
 		}
 
-		if r.InGroups(uid, chatobject.ReadGroupsId) || r.InGroups(uid, chatobject.WriteGroupsId) {
+		if uid != 0 && message.UserID == uid {
 			return data, nil
 		}
 
+		var chatGroup struct {
+			ReadGroupsID []int `json:"read_groups_id"`
+		}
+		if err := r.Get("chat/chat-group", message.ChatGroupID, &chatGroup); err != nil {
+			var doesNotExist interface {
+				DoesNotExist() string
+			}
+			if errors.As(err, &doesNotExist) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("getting chat-group %d: %w", message.ChatGroupID, err)
+		}
+
+		if inGroups(r, uid, chatGroup.ReadGroupsID) {
+			return data, nil
+		}
 		return nil, nil
 	}
 }
+
+// inGroups tells, if the user is in one of the groups.
+func inGroups(r restricter.HasPermer, uid int, groups []int) bool {
+	if r.IsSuperadmin(uid) {
+		return true
+	}
+
+	userGroups := make(map[int]bool)
+	for _, gid := range r.GroupIDs(uid) {
+		userGroups[gid] = true
+	}
+
+	for _, gid := range groups {
+		if userGroups[gid] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package chat_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/chat"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestRestrict(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"chat/chat-group:1": []byte(`{"id": 1, "read_groups_id": [3], "write_groups_id": [4]}`),
+	}
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		groups  map[int]bool
+		element string
+		visible bool
+	}{
+		{
+			"No permission",
+			nil,
+			map[int]bool{3: true},
+			`{"id": 1, "chatgroup_id": 1, "user_id": 2}`,
+			false,
+		},
+		{
+			"In read group",
+			[]string{"chat.can_see"},
+			map[int]bool{3: true},
+			`{"id": 1, "chatgroup_id": 1, "user_id": 2}`,
+			true,
+		},
+		{
+			"Not in read group",
+			[]string{"chat.can_see"},
+			map[int]bool{4: true},
+			`{"id": 1, "chatgroup_id": 1, "user_id": 2}`,
+			false,
+		},
+		{
+			"Own message",
+			[]string{"chat.can_see"},
+			nil,
+			`{"id": 1, "chatgroup_id": 1, "user_id": 1}`,
+			true,
+		},
+		{
+			"Own message without permission",
+			nil,
+			nil,
+			`{"id": 1, "chatgroup_id": 1, "user_id": 1}`,
+			false,
+		},
+		{
+			"Deleted chat group",
+			[]string{"chat.can_see"},
+			map[int]bool{3: true},
+			`{"id": 1, "chatgroup_id": 404, "user_id": 2}`,
+			false,
+		},
+		{
+			"Manager",
+			[]string{"chat.can_see", "chat.can_manage"},
+			nil,
+			`{"id": 1, "chatgroup_id": 1, "user_id": 2}`,
+			true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{
+				Perms:  tt.perms,
+				Groups: tt.groups,
+				Data:   data,
+			}
+
+			got, err := chat.Restrict(permer).Restrict(1, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if !tt.visible {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.element)
+			}
+			test.ExpectEqualJSON(t, got, []byte(tt.element))
+		})
+	}
+}
+
+func TestRestrictGroup(t *testing.T) {
+	const chatGroup = `{"id": 1, "read_groups_id": [3], "write_groups_id": [4]}`
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		groups  map[int]bool
+		visible bool
+	}{
+		{"No permission", nil, map[int]bool{3: true}, false},
+		{"In read group", []string{"chat.can_see"}, map[int]bool{3: true}, true},
+		{"In write group", []string{"chat.can_see"}, map[int]bool{4: true}, true},
+		{"In other group", []string{"chat.can_see"}, map[int]bool{5: true}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{
+				Perms:  tt.perms,
+				Groups: tt.groups,
+			}
+
+			got, err := chat.RestrictGroup(permer).Restrict(1, []byte(chatGroup))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.visible != (got != nil) {
+				t.Errorf("Restrict returned `%s`, expected visible: %t", got, tt.visible)
+			}
+		})
+	}
+}
@@ -1,4 +1,169 @@
 package core
 
-// CanSeeProjector is the permission string to see the projector.
-const CanSeeProjector = "core.can_see_projector"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+)
+
+const (
+	// CanSeeProjector is the permission string to see the projector.
+	CanSeeProjector = "core.can_see_projector"
+
+	pCanManageProjector = "core.can_manage_projector"
+	pCanSeeFrontpage    = "core.can_see_frontpage"
+	pCanManageUsers     = "users.can_manage"
+)
+
+// PublicCollections are the collections without a base permission in
+// OpenSlides 3. They are visible for every user that can use the system and
+// have to be restricted with RestrictPublic.
+//
+// core/config is not in the list, because the config is also needed on the
+// login page and is visible for everybody.
+var PublicCollections = []string{
+	"core/tag",
+}
+
+// Configer returns the value for a config name.
+type Configer interface {
+	Bool(key string) (bool, error)
+}
+
+// RestrictPublic restricts elements of the PublicCollections.
+//
+// An element is visible for all users with the permission
+// core.can_see_frontpage, which every user needs to use the system. The
+// anonymous user can only see it, if anonymous is enabled.
+func RestrictPublic(r restricter.HasPermer, configer Configer) restricter.GroupFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		if uid != 0 {
+			if !r.HasPerm(uid, pCanSeeFrontpage) {
+				return nil, nil
+			}
+			return data, nil
+		}
+
+		enabled, err := configer.Bool("general_system_enable_anonymous")
+		if err != nil {
+			return nil, fmt.Errorf("getting config general_system_enable_anonymous: %w", err)
+		}
+
+		if !enabled || !r.HasPerm(uid, pCanSeeFrontpage) {
+			return nil, nil
+		}
+		return data, nil
+	}
+}
+
+// configPermissions are the config keys, that are not public, and the
+// permission that is needed to see them. They are the keys of the OpenSlides 3
+// config groups "Participants/PDF export" and "Participants/Email".
+var configPermissions = map[string]string{
+	"users_pdf_welcometitle":    pCanManageUsers,
+	"users_pdf_welcometext":     pCanManageUsers,
+	"users_pdf_url":             pCanManageUsers,
+	"users_pdf_wlan_ssid":       pCanManageUsers,
+	"users_pdf_wlan_password":   pCanManageUsers,
+	"users_pdf_wlan_encryption": pCanManageUsers,
+	"users_email_sender":        pCanManageUsers,
+	"users_email_replyto":       pCanManageUsers,
+	"users_email_subject":       pCanManageUsers,
+	"users_email_body":          pCanManageUsers,
+}
+
+// RestrictConfig restricts core/config elements.
+//
+// All config values are visible for everybody, also for the anonymous user when
+// anonymous is disabled, because they are needed on the login page. Only the
+// keys in configPermissions need a permission.
+func RestrictConfig(r restricter.HasPermer) restricter.ElementFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		var config struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("decoding config: %w", err)
+		}
+
+		perm, ok := configPermissions[config.Key]
+		if !ok || r.HasPerm(uid, perm) {
+			return data, nil
+		}
+		return nil, nil
+	}
+}
+
+// projectorMessageFields are the fields of a projector message, that are
+// projected. All other fields are only visible for projector managers.
+var projectorMessageFields = []string{"id", "message"}
+
+// countdownFields are the fields of a countdown, that are projected.
+// default_time is only needed to edit the countdown and is only visible for
+// projector managers.
+var countdownFields = []string{"id", "title", "description", "countdown_time", "running"}
+
+// RestrictProjectorMessage restricts core/projector-message elements.
+//
+// A projector message is only visible for users that can see the projector.
+// Only projector managers see the fields that are not projected.
+func RestrictProjectorMessage(r restricter.HasPermer) restricter.ElementFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		if !r.HasPerm(uid, CanSeeProjector) {
+			return nil, nil
+		}
+
+		if r.HasPerm(uid, pCanManageProjector) {
+			return data, nil
+		}
+
+		return onlyFields(data, projectorMessageFields)
+	}
+}
+
+// RestrictCountdown restricts core/countdown elements.
+//
+// A countdown is visible for users that can see the projector. Only projector
+// managers see the fields that are not projected. Users that can manage the
+// projector, but can not see it, only see if the countdown is running.
+func RestrictCountdown(r restricter.HasPermer) restricter.ElementFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		canManage := r.HasPerm(uid, pCanManageProjector)
+
+		if r.HasPerm(uid, CanSeeProjector) {
+			if canManage {
+				return data, nil
+			}
+			return onlyFields(data, countdownFields)
+		}
+
+		if !canManage {
+			return nil, nil
+		}
+
+		return onlyFields(data, []string{"id", "running"})
+	}
+}
+
+// onlyFields returns the element with only the given fields. Fields that the
+// element does not have are left out.
+func onlyFields(data json.RawMessage, fields []string) (json.RawMessage, error) {
+	var element map[string]json.RawMessage
+	if err := json.Unmarshal(data, &element); err != nil {
+		return nil, fmt.Errorf("decoding element: %w", err)
+	}
+
+	filtered := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := element[field]; ok {
+			filtered[field] = value
+		}
+	}
+
+	restricted, err := json.Marshal(filtered)
+	if err != nil {
+		return nil, fmt.Errorf("encoding element: %w", err)
+	}
+	return restricted, nil
+}
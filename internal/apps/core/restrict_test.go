@@ -0,0 +1,195 @@
+package core_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/core"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestRestrict(t *testing.T) {
+	countdown := `{"id": 1, "title": "Speech", "description": "", "default_time": 60, "countdown_time": 1600000000.5, "running": true}`
+	message := `{"id": 1, "message": "Hello"}`
+	messageWithNote := `{"id": 1, "message": "Hello", "note": "internal"}`
+
+	for _, tt := range []struct {
+		name       string
+		restricter func(restricter.HasPermer) restricter.ElementFunc
+		perms      []string
+		element    string
+		expect     string
+	}{
+		{
+			"Message without permission",
+			core.RestrictProjectorMessage,
+			nil,
+			message,
+			"",
+		},
+		{
+			"Message with can manage projector",
+			core.RestrictProjectorMessage,
+			[]string{"core.can_manage_projector"},
+			message,
+			"",
+		},
+		{
+			"Message with can see projector",
+			core.RestrictProjectorMessage,
+			[]string{"core.can_see_projector"},
+			message,
+			message,
+		},
+		{
+			"Message with unknown field and can see projector",
+			core.RestrictProjectorMessage,
+			[]string{"core.can_see_projector"},
+			messageWithNote,
+			message,
+		},
+		{
+			"Message with unknown field and both permissions",
+			core.RestrictProjectorMessage,
+			[]string{"core.can_see_projector", "core.can_manage_projector"},
+			messageWithNote,
+			messageWithNote,
+		},
+		{
+			"Countdown without permission",
+			core.RestrictCountdown,
+			nil,
+			countdown,
+			"",
+		},
+		{
+			"Countdown with can manage projector",
+			core.RestrictCountdown,
+			[]string{"core.can_manage_projector"},
+			countdown,
+			`{"id": 1, "running": true}`,
+		},
+		{
+			"Countdown with can see projector",
+			core.RestrictCountdown,
+			[]string{"core.can_see_projector"},
+			countdown,
+			`{"id": 1, "title": "Speech", "description": "", "countdown_time": 1600000000.5, "running": true}`,
+		},
+		{
+			"Countdown with both permissions",
+			core.RestrictCountdown,
+			[]string{"core.can_see_projector", "core.can_manage_projector"},
+			countdown,
+			countdown,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms}
+
+			got, err := tt.restricter(permer).Restrict(1, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.expect == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.expect)
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.expect))
+		})
+	}
+}
+
+func TestRestrictConfig(t *testing.T) {
+	public := `{"id": 2, "key": "general_event_name", "value": "OpenSlides"}`
+	manager := `{"id": 46, "key": "users_pdf_wlan_password", "value": "secret"}`
+
+	for _, tt := range []struct {
+		name    string
+		uid     int
+		perms   []string
+		element string
+		visible bool
+	}{
+		{"Public key", 1, nil, public, true},
+		{"Public key anonymous", 0, nil, public, true},
+		{"Manager key", 1, nil, manager, false},
+		{"Manager key with can see", 1, []string{"users.can_see_name", "users.can_see_extra_data"}, manager, false},
+		{"Manager key with can manage", 1, []string{"users.can_manage"}, manager, true},
+		{"Manager key anonymous", 0, nil, manager, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms}
+
+			got, err := core.RestrictConfig(permer).Restrict(tt.uid, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if !tt.visible {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.element)
+			}
+			test.ExpectEqualJSON(t, got, []byte(tt.element))
+		})
+	}
+}
+
+func TestRestrictPublic(t *testing.T) {
+	tag := `{"id": 1, "name": "Finance"}`
+
+	for _, tt := range []struct {
+		name    string
+		uid     int
+		perms   []string
+		config  string
+		visible bool
+	}{
+		{"User", 1, []string{"core.can_see_frontpage"}, `{"id": 1, "key": "general_system_enable_anonymous", "value": false}`, true},
+		{"User without perm", 1, nil, `{"id": 1, "key": "general_system_enable_anonymous", "value": false}`, false},
+		{"Anonymous disabled", 0, []string{"core.can_see_frontpage"}, `{"id": 1, "key": "general_system_enable_anonymous", "value": false}`, false},
+		{"Anonymous enabled", 0, []string{"core.can_see_frontpage"}, `{"id": 1, "key": "general_system_enable_anonymous", "value": true}`, true},
+		{"Anonymous enabled without perm", 0, nil, `{"id": 1, "key": "general_system_enable_anonymous", "value": true}`, false},
+		{"Anonymous without config", 0, []string{"core.can_see_frontpage"}, "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]json.RawMessage{}
+			if tt.config != "" {
+				data["core/config:1"] = []byte(tt.config)
+			}
+			permer := &test.HasPermMock{Perms: tt.perms, Data: data}
+
+			got, err := core.RestrictPublic(permer, permer).Restrict(tt.uid, []byte(tag))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if !tt.visible {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tag)
+			}
+			test.ExpectEqualJSON(t, got, []byte(tag))
+		})
+	}
+}
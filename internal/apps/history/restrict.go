@@ -0,0 +1,39 @@
+package history
+
+import (
+	"encoding/json"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+)
+
+const (
+	pCanSee    = "core.can_see_history"
+	pCanManage = "users.can_manage"
+)
+
+// FieldRules are the fields of core/history, that are only visible with a
+// permission.
+//
+// The information of an entry can reveal data of elements the user can not
+// see.
+var FieldRules = []restricter.FieldRule{
+	{Collection: "core/history", Field: "information", RequiredPerm: pCanManage},
+}
+
+// Restrict restricts core/history elements.
+//
+// The history is visible for users with the permission to see the history or
+// to manage users. Only managers can see the information of an entry.
+func Restrict(r restricter.HasPermer) restricter.ElementFunc {
+	return restricter.MaskFields(r, "core/history", restrictEntry(r), FieldRules)
+}
+
+// restrictEntry decides, if the user can see the history entry at all.
+func restrictEntry(r restricter.HasPermer) restricter.ElementFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		if r.HasPerm(uid, pCanSee) || r.HasPerm(uid, pCanManage) {
+			return data, nil
+		}
+		return nil, nil
+	}
+}
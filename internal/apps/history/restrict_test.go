@@ -0,0 +1,61 @@
+package history_test
+
+import (
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/history"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestRestrict(t *testing.T) {
+	element := `{"id": 1, "element_id": "motions/motion:1", "now": 1600000000, "information": ["Motion created"], "user_id": 2}`
+
+	for _, tt := range []struct {
+		name   string
+		perms  []string
+		expect string
+	}{
+		{
+			"No permission",
+			nil,
+			"",
+		},
+		{
+			"Can see history",
+			[]string{"core.can_see_history"},
+			`{"id": 1, "element_id": "motions/motion:1", "now": 1600000000, "user_id": 2}`,
+		},
+		{
+			"Manager",
+			[]string{"users.can_manage"},
+			element,
+		},
+		{
+			"Manager with can see history",
+			[]string{"core.can_see_history", "users.can_manage"},
+			element,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms}
+
+			got, err := history.Restrict(permer).Restrict(1, []byte(element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.expect == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.expect)
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.expect))
+		})
+	}
+}
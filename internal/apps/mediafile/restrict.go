@@ -8,11 +8,31 @@ import (
 )
 
 const (
-	pCanSee = "mediafiles.can_see"
+	pCanSee    = "mediafiles.can_see"
+	pCanManage = "mediafiles.can_manage"
 )
 
+// FieldRules are the fields of mediafiles/mediafile, that are only visible
+// with a permission.
+//
+// The path and the stored file tell, how the files are saved on the server.
+// Users, that can not manage mediafiles, get the url of the file instead.
+var FieldRules = []restricter.FieldRule{
+	{Collection: "mediafiles/mediafile", Field: "path", RequiredPerm: pCanManage},
+	{Collection: "mediafiles/mediafile", Field: "mediafile", RequiredPerm: pCanManage},
+}
+
 // Restrict restricts a mediafile object.
+//
+// The mediafile is visible, if the user is in the inherited access groups.
+// Users without the permission to manage mediafiles do not see the fields of
+// FieldRules.
 func Restrict(r restricter.HasPermer) restricter.ElementFunc {
+	return restricter.MaskFields(r, "mediafiles/mediafile", addURL(r, restrictAccess(r)), FieldRules)
+}
+
+// restrictAccess decides, if the user can see the mediafile at all.
+func restrictAccess(r restricter.HasPermer) restricter.ElementFunc {
 	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
 		if !r.HasPerm(uid, pCanSee) {
 			return nil, nil
@@ -44,6 +64,46 @@ func Restrict(r restricter.HasPermer) restricter.ElementFunc {
 	}
 }
 
+// addURL adds the field url to files for users, that can not see the path.
+func addURL(r restricter.HasPermer, e restricter.Element) restricter.ElementFunc {
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		data, err := e.Restrict(uid, data)
+		if err != nil || data == nil || r.HasPerm(uid, pCanManage) {
+			return data, err
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("decoding mediafile: %w", err)
+		}
+
+		var file struct {
+			IsDirectory bool   `json:"is_directory"`
+			Prefix      string `json:"media_url_prefix"`
+			Path        string `json:"path"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("decoding mediafile: %w", err)
+		}
+
+		if file.IsDirectory || file.Path == "" {
+			return data, nil
+		}
+
+		url, err := json.Marshal(file.Prefix + file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("encoding url: %w", err)
+		}
+		fields["url"] = url
+
+		data, err = json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("encoding mediafile: %w", err)
+		}
+		return data, nil
+	}
+}
+
 type boolOrIntSlice struct {
 	b     bool
 	iList []int
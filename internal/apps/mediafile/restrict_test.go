@@ -0,0 +1,223 @@
+package mediafile_test
+
+import (
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/mediafile"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+const (
+	// publicFile is a file in a folder without access groups.
+	publicFile = `{
+		"id": 1,
+		"title": "public.pdf",
+		"is_directory": false,
+		"parent_id": 3,
+		"access_groups_id": [],
+		"inherited_access_groups_id": true
+	}`
+
+	// nestedFile is a file without own access groups inside a folder, that
+	// is restricted to group 3.
+	nestedFile = `{
+		"id": 2,
+		"title": "nested.pdf",
+		"is_directory": false,
+		"parent_id": 4,
+		"access_groups_id": [],
+		"inherited_access_groups_id": [3]
+	}`
+
+	// deniedFile is a file inside a folder, that has no common access groups
+	// with its parent.
+	deniedFile = `{
+		"id": 5,
+		"title": "denied.pdf",
+		"is_directory": false,
+		"parent_id": 4,
+		"access_groups_id": [4],
+		"inherited_access_groups_id": false
+	}`
+)
+
+func TestRestrict(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		perms      []string
+		groups     map[int]bool
+		superadmin bool
+		element    string
+		visible    bool
+	}{
+		{
+			"No read permission",
+			nil,
+			nil,
+			false,
+			publicFile,
+			false,
+		},
+		{
+			"Public file",
+			[]string{"mediafiles.can_see"},
+			nil,
+			false,
+			publicFile,
+			true,
+		},
+		{
+			"Nested file in group",
+			[]string{"mediafiles.can_see"},
+			map[int]bool{3: true},
+			false,
+			nestedFile,
+			true,
+		},
+		{
+			"Nested file not in group",
+			[]string{"mediafiles.can_see"},
+			map[int]bool{4: true},
+			false,
+			nestedFile,
+			false,
+		},
+		{
+			"Nested file with empty inherited groups",
+			[]string{"mediafiles.can_see"},
+			map[int]bool{4: true},
+			false,
+			deniedFile,
+			false,
+		},
+		{
+			"Nested file with empty inherited groups superadmin",
+			nil,
+			nil,
+			true,
+			deniedFile,
+			true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{
+				IsSuperuser: tt.superadmin,
+				Perms:       tt.perms,
+				Groups:      tt.groups,
+			}
+
+			got, err := mediafile.Restrict(permer).Restrict(1, []byte(tt.element))
+			if err != nil {
+				t.Errorf("Restrict returned unexpected error: %v ", err)
+			}
+
+			if !tt.visible {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Errorf("Restrict() returned nil, expected %s", tt.element)
+				return
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.element))
+		})
+	}
+}
+
+func TestRestrictPath(t *testing.T) {
+	const (
+		file = `{
+			"id": 2,
+			"title": "A.txt",
+			"media_url_prefix": "/media/",
+			"is_directory": false,
+			"path": "folder/A.txt",
+			"mediafile": "folder/A.txt",
+			"inherited_access_groups_id": [3]
+		}`
+
+		folder = `{
+			"id": 1,
+			"title": "folder",
+			"media_url_prefix": "/media/",
+			"is_directory": true,
+			"path": "folder/",
+			"inherited_access_groups_id": [3]
+		}`
+	)
+
+	for _, tt := range []struct {
+		name     string
+		perms    []string
+		groups   map[int]bool
+		element  string
+		expected string
+	}{
+		{
+			"Manager",
+			[]string{"mediafiles.can_see", "mediafiles.can_manage"},
+			map[int]bool{3: true},
+			file,
+			file,
+		},
+		{
+			"Normal user",
+			[]string{"mediafiles.can_see"},
+			map[int]bool{3: true},
+			file,
+			`{
+				"id": 2,
+				"title": "A.txt",
+				"media_url_prefix": "/media/",
+				"is_directory": false,
+				"url": "/media/folder/A.txt",
+				"inherited_access_groups_id": [3]
+			}`,
+		},
+		{
+			"Normal user folder",
+			[]string{"mediafiles.can_see"},
+			map[int]bool{3: true},
+			folder,
+			`{
+				"id": 1,
+				"title": "folder",
+				"media_url_prefix": "/media/",
+				"is_directory": true,
+				"inherited_access_groups_id": [3]
+			}`,
+		},
+		{
+			"Normal user not in group",
+			[]string{"mediafiles.can_see"},
+			map[int]bool{4: true},
+			file,
+			"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{
+				Perms:  tt.perms,
+				Groups: tt.groups,
+			}
+
+			got, err := mediafile.Restrict(permer).Restrict(1, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.expected == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.expected))
+		})
+	}
+}
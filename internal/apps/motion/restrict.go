@@ -2,6 +2,7 @@ package motion
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
@@ -18,59 +19,123 @@ const (
 
 	// CanManagePolls is the manage permission for motion polls
 	CanManagePolls = "motions.can_manage_polls"
+
+	// CanSeeChangeRecommendations is the permission to see the change
+	// recommendations of the motions.
+	CanSeeChangeRecommendations = "motions.can_see_change_recommendations"
 )
 
-type required interface {
-	restricter.HasPermer
-	InGroups(uid int, groups []int) bool
+// maxParentDepth is the maximum number of parent motions that are checked for
+// an amendment. It prevents an endless loop, if the parents are a cycle.
+const maxParentDepth = 10
+
+// restrictedMotion contains the fields of a motions/motion that are needed to
+// restrict it.
+type restrictedMotion struct {
+	ParentID   int `json:"parent_id"`
+	Submitters []struct {
+		UserID int `json:"user_id"`
+	} `json:"submitters"`
+	Restriction []string `json:"state_restriction"`
+	Comments    []struct {
+		SectionID  int   `json:"section_id"`
+		ReadGroups []int `json:"read_groups_id"`
+	} `json:"comments"`
 }
 
-// Restrict restricts motions/motion.
-func Restrict(r required) restricter.ElementFunc {
-	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
-		if !r.HasPerm(uid, CanSee) {
-			return nil, nil
+// visible tells, if the user can see the motion. The parent motion is not
+// checked.
+func (m restrictedMotion) visible(p *restricter.Permissions) bool {
+	if !p.HasPerm(CanSee) {
+		return false
+	}
+
+	if p.HasPerm(CanManage) || len(m.Restriction) == 0 {
+		return true
+	}
+
+	var isSumitter bool
+	for _, s := range m.Submitters {
+		if s.UserID == p.UID() {
+			isSumitter = true
+			break
+		}
+	}
+
+	for _, value := range m.Restriction {
+		if (value == pCanSeeInternal || value == pCanManageMeta || value == CanManage) && p.HasPerm(value) {
+			return true
+		}
+		if value == "is_submitter" && isSumitter {
+			return true
 		}
+	}
+	return false
+}
+
+// motionVisible tells, if the user can see the motion with the id. If the
+// motion is an amendment, its parents have to be visible too. A motion that
+// does not exist is not visible.
+//
+// depth is the number of parents, that were already checked.
+func motionVisible(r restricter.HasPermer, p *restricter.Permissions, id int, depth int) (bool, error) {
+	if depth > maxParentDepth {
+		return false, nil
+	}
 
-		var motion struct {
-			Submitters []struct {
-				UserID int `json:"user_id"`
-			} `json:"submitters"`
-			Restriction []string `json:"state_restriction"`
-			Comments    []struct {
-				ReadGroups []int `json:"read_groups_id"`
-			} `json:"comments"`
+	var motion restrictedMotion
+	if err := r.Get("motions/motion", id, &motion); err != nil {
+		var doesNotExist interface {
+			DoesNotExist() string
 		}
+		if errors.As(err, &doesNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting motion %d: %w", id, err)
+	}
 
+	if !motion.visible(p) {
+		return false, nil
+	}
+
+	if motion.ParentID == 0 {
+		return true, nil
+	}
+	return motionVisible(r, p, motion.ParentID, depth+1)
+}
+
+// Restrict restricts motions/motion.
+//
+// Amendments are motions with a parent motion. They are only visible, if the
+// user can also see the parent motion.
+//
+// The permissions of the user are only resolved once, when many motions are
+// restricted.
+func Restrict(r restricter.HasPermer) restricter.ManyElement {
+	return restricter.PermFunc(r, func(p *restricter.Permissions, data json.RawMessage) (json.RawMessage, error) {
+		if !p.HasPerm(CanSee) {
+			return nil, nil
+		}
+
+		var motion restrictedMotion
 		if err := json.Unmarshal(data, &motion); err != nil {
 			return nil, fmt.Errorf("decode motion: %w", err)
 		}
 
-		var isSumitter bool
-		for _, s := range motion.Submitters {
-			if s.UserID == uid {
-				isSumitter = true
-				break
-			}
+		if !motion.visible(p) {
+			return nil, nil
 		}
 
-		permission := r.HasPerm(uid, CanManage) || len(motion.Restriction) == 0
-
-		if !permission {
-			for _, value := range motion.Restriction {
-				if (value == pCanSeeInternal || value == pCanManageMeta || value == CanManage) && r.HasPerm(uid, value) {
-					permission = true
-					break
-				}
-				if value == "is_submitter" && isSumitter {
-					permission = true
-					break
-				}
+		if motion.ParentID != 0 {
+			// Amendments are only visible, if the parent motion is visible.
+			visible, err := motionVisible(r, p, motion.ParentID, 1)
+			if err != nil {
+				return nil, fmt.Errorf("checking parent motion %d: %w", motion.ParentID, err)
 			}
-		}
 
-		if !permission {
-			return nil, nil
+			if !visible {
+				return nil, nil
+			}
 		}
 
 		var motionData map[string]json.RawMessage
@@ -86,7 +151,25 @@ func Restrict(r required) restricter.ElementFunc {
 		newComments := make([]json.RawMessage, 0)
 
 		for i, c := range comments {
-			if r.InGroups(uid, motion.Comments[i].ReadGroups) {
+			if p.HasPerm(CanManage) || p.InGroups(motion.Comments[i].ReadGroups) {
+				newComments = append(newComments, c)
+				continue
+			}
+
+			// The comment only contains the read groups. The write groups
+			// have to be looked up in the section.
+			var section commentSection
+			if err := r.Get("motions/motion-comment-section", motion.Comments[i].SectionID, &section); err != nil {
+				var doesNotExist interface {
+					DoesNotExist() string
+				}
+				if errors.As(err, &doesNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("getting comment section %d: %w", motion.Comments[i].SectionID, err)
+			}
+
+			if section.visible(p) {
 				newComments = append(newComments, c)
 			}
 		}
@@ -104,7 +187,41 @@ func Restrict(r required) restricter.ElementFunc {
 		}
 
 		return data, nil
-	}
+	})
+}
+
+// WorkflowRestrict restricts motions/workflow.
+//
+// Workflows are visible for all users that can see motions. OpenSlides 3 has
+// no fields in a workflow that are only for managers.
+func WorkflowRestrict(r restricter.HasPermer) restricter.GroupFunc {
+	return restricter.BasePermission(r)(CanSee)
+}
+
+// StateRestrict restricts motions/state.
+//
+// States are visible for all users that can see motions, also if the user can
+// not see the motions in the state. The client needs all states of a workflow
+// to interpret the motions and their next states. The field restriction is
+// not removed for the same reason.
+func StateRestrict(r restricter.HasPermer) restricter.GroupFunc {
+	return restricter.BasePermission(r)(CanSee)
+}
+
+// CategoryRestrict restricts motions/category.
+//
+// Categories are visible for all users that can see motions. This includes
+// the parent categories, so the client can show the whole tree of categories.
+func CategoryRestrict(r restricter.HasPermer) restricter.GroupFunc {
+	return restricter.BasePermission(r)(CanSee)
+}
+
+// StatuteParagraphRestrict restricts motions/statute-paragraph.
+//
+// Statute paragraphs are visible for all users that can see motions. They are
+// needed to show statute amendments.
+func StatuteParagraphRestrict(r restricter.HasPermer) restricter.GroupFunc {
+	return restricter.BasePermission(r)(CanSee)
 }
 
 // BlockRestrict restricts motions/motion-block.
@@ -133,54 +250,81 @@ func BlockRestrict(r restricter.HasPermer) restricter.ElementFunc {
 	}
 }
 
+// commentSection contains the fields of a motions/motion-comment-section that
+// are needed to restrict it.
+type commentSection struct {
+	ReadGroups  []int `json:"read_groups_id"`
+	WriteGroups []int `json:"write_groups_id"`
+}
+
+// visible tells, if the user can see the comment section and the comments of
+// the section. Users that can write in a section can also read it.
+func (cs commentSection) visible(p *restricter.Permissions) bool {
+	return p.HasPerm(CanManage) || p.InGroups(cs.ReadGroups) || p.InGroups(cs.WriteGroups)
+}
+
 // CommentSectionRestrict restricts motions/motion-comment-section.
-func CommentSectionRestrict(r required) restricter.ElementFunc {
-	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
-		if !r.HasPerm(uid, CanSee) {
+//
+// A section is visible, if the user is in one of its read or write groups.
+// Motions restricted with Restrict only contain the comments of the visible
+// sections.
+func CommentSectionRestrict(r restricter.HasPermer) restricter.ManyElement {
+	return restricter.PermFunc(r, func(p *restricter.Permissions, data json.RawMessage) (json.RawMessage, error) {
+		if !p.HasPerm(CanSee) {
 			return nil, nil
 		}
 
-		if r.HasPerm(uid, CanManage) {
-			return data, nil
-		}
-
-		var cs struct {
-			ReadGroups []int `json:"read_groups_id"`
-		}
+		var cs commentSection
 		if err := json.Unmarshal(data, &cs); err != nil {
 			return nil, fmt.Errorf("decode comment section: %w", err)
 		}
 
-		if r.InGroups(uid, cs.ReadGroups) {
-			return data, nil
+		if !cs.visible(p) {
+			return nil, nil
 		}
-
-		return nil, nil
-	}
+		return data, nil
+	})
 }
 
 // ChangeRecommendationRestrict restricts motions/motion-change-recommendation.
-func ChangeRecommendationRestrict(r restricter.HasPermer) restricter.ElementFunc {
-	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
-		if !r.HasPerm(uid, CanSee) {
+//
+// Managers see all change recommendations. Other users need the permission
+// to see change recommendations and only see the ones, that are not internal
+// and that belong to a motion they can see.
+func ChangeRecommendationRestrict(r restricter.HasPermer) restricter.ManyElement {
+	return restricter.PermFunc(r, func(p *restricter.Permissions, data json.RawMessage) (json.RawMessage, error) {
+		if !p.HasPerm(CanSee) {
 			return nil, nil
 		}
 
-		if r.HasPerm(uid, CanManage) {
+		if p.HasPerm(CanManage) {
 			return data, nil
 		}
 
+		if !p.HasPerm(CanSeeChangeRecommendations) {
+			return nil, nil
+		}
+
 		var cr struct {
+			MotionID int  `json:"motion_id"`
 			Internal bool `json:"internal"`
 		}
 		if err := json.Unmarshal(data, &cr); err != nil {
 			return nil, fmt.Errorf("decode change recommendation: %w", err)
 		}
 
-		if !cr.Internal {
-			return data, nil
+		if cr.Internal {
+			return nil, nil
 		}
 
-		return nil, nil
-	}
+		visible, err := motionVisible(r, p, cr.MotionID, 0)
+		if err != nil {
+			return nil, fmt.Errorf("checking motion %d: %w", cr.MotionID, err)
+		}
+
+		if !visible {
+			return nil, nil
+		}
+		return data, nil
+	})
 }
@@ -0,0 +1,304 @@
+package motion_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/motion"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func benchmarkMotions(count int) []json.RawMessage {
+	motions := make([]json.RawMessage, count)
+	for i := range motions {
+		motions[i] = []byte(fmt.Sprintf(`{
+			"id": %d,
+			"title": "motion %d",
+			"submitters": [{"user_id": 5}],
+			"state_restriction": ["motions.can_see_internal", "is_submitter"],
+			"comments": [{"read_groups_id": [3]}, {"read_groups_id": [4]}]
+		}`, i+1, i+1))
+	}
+	return motions
+}
+
+func benchmarkPermer() *test.HasPermMock {
+	return &test.HasPermMock{
+		Perms:  []string{"motions.can_see", "motions.can_see_internal"},
+		Groups: map[int]bool{3: true},
+	}
+}
+
+func TestRestrictMany(t *testing.T) {
+	r := motion.Restrict(benchmarkPermer())
+	motions := benchmarkMotions(10)
+
+	many, err := r.RestrictMany(1, motions)
+	if err != nil {
+		t.Fatalf("RestrictMany returned unexpected error: %v", err)
+	}
+
+	if len(many) != len(motions) {
+		t.Fatalf("RestrictMany returned %d elements, expected %d", len(many), len(motions))
+	}
+
+	for i, m := range motions {
+		one, err := r.Restrict(1, m)
+		if err != nil {
+			t.Fatalf("Restrict returned unexpected error: %v", err)
+		}
+
+		test.ExpectEqualJSON(t, many[i], one)
+	}
+}
+
+func TestRestrictCommentSection(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"motions/motion-comment-section:1": []byte(`{"id":1,"read_groups_id":[2],"write_groups_id":[3]}`),
+	}
+	motionData := []byte(`{"id":1,"comments":[{"id":1,"section_id":1,"read_groups_id":[2]}]}`)
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		groups  map[int]bool
+		visible bool
+	}{
+		{"read group", []string{"motions.can_see"}, map[int]bool{2: true}, true},
+		{"write group", []string{"motions.can_see"}, map[int]bool{3: true}, true},
+		{"no group", []string{"motions.can_see"}, map[int]bool{4: true}, false},
+		{"manager", []string{"motions.can_see", "motions.can_manage"}, nil, true},
+		{"no can_see", nil, map[int]bool{2: true}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms, Groups: tt.groups, Data: data}
+
+			section, err := motion.CommentSectionRestrict(permer).Restrict(1, data["motions/motion-comment-section:1"])
+			if err != nil {
+				t.Fatalf("CommentSectionRestrict returned unexpected error: %v", err)
+			}
+
+			if (section != nil) != tt.visible {
+				t.Errorf("CommentSectionRestrict returned `%s`, expected visible: %t", section, tt.visible)
+			}
+
+			restricted, err := motion.Restrict(permer).Restrict(1, motionData)
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if restricted == nil {
+				if tt.perms != nil {
+					t.Errorf("Restrict returned nil, expected the motion")
+				}
+				return
+			}
+
+			var got struct {
+				Comments []json.RawMessage `json:"comments"`
+			}
+			if err := json.Unmarshal(restricted, &got); err != nil {
+				t.Fatalf("decoding restricted motion: %v", err)
+			}
+
+			if (len(got.Comments) == 1) != tt.visible {
+				t.Errorf("Restrict returned comments `%s`, expected visible: %t", got.Comments, tt.visible)
+			}
+		})
+	}
+}
+
+func BenchmarkRestrict(b *testing.B) {
+	r := motion.Restrict(benchmarkPermer())
+	motions := benchmarkMotions(10_000)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, m := range motions {
+			if _, err := r.Restrict(1, m); err != nil {
+				b.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkRestrictMany(b *testing.B) {
+	r := motion.Restrict(benchmarkPermer())
+	motions := benchmarkMotions(10_000)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := r.RestrictMany(1, motions); err != nil {
+			b.Fatalf("RestrictMany returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestRestrictWorkflowAndState(t *testing.T) {
+	workflow := `{"id": 1, "name": "Simple Workflow", "states_id": [1], "first_state_id": 1}`
+	state := `{"id": 1, "name": "submitted", "restriction": ["motions.can_see_internal", "motions.can_manage_metadata"], "next_states_id": [2], "workflow_id": 1}`
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		visible bool
+	}{
+		{"can see", []string{"motions.can_see"}, true},
+		{"manager", []string{"motions.can_see", "motions.can_manage"}, true},
+		{"no can_see", []string{"motions.can_manage"}, false},
+		{"no perms", nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms}
+
+			for _, element := range []struct {
+				name       string
+				restricter func(uid int, data json.RawMessage) (json.RawMessage, error)
+				data       string
+			}{
+				{"workflow", motion.WorkflowRestrict(permer), workflow},
+				{"state", motion.StateRestrict(permer), state},
+			} {
+				got, err := element.restricter(1, []byte(element.data))
+				if err != nil {
+					t.Fatalf("Restrict %s returned unexpected error: %v", element.name, err)
+				}
+
+				if !tt.visible {
+					if got != nil {
+						t.Errorf("Restrict %s returned `%s`, expected nil", element.name, got)
+					}
+					continue
+				}
+
+				if got == nil {
+					t.Fatalf("Restrict %s returned nil, expected %s", element.name, element.data)
+				}
+				test.ExpectEqualJSON(t, got, []byte(element.data))
+			}
+		})
+	}
+}
+
+func TestRestrictAmendment(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "parent_id": null, "submitters": [], "state_restriction": ["motions.can_see_internal"], "comments": []}`),
+		"motions/motion:2": []byte(`{"id": 2, "parent_id": 1, "submitters": [], "state_restriction": [], "comments": []}`),
+		"motions/motion:3": []byte(`{"id": 3, "parent_id": 2, "submitters": [], "state_restriction": [], "comments": []}`),
+		"motions/motion:4": []byte(`{"id": 4, "parent_id": 5, "submitters": [], "state_restriction": [], "comments": []}`),
+	}
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		key     string
+		visible bool
+	}{
+		{"amendment parent hidden", []string{motion.CanSee}, "motions/motion:2", false},
+		{"amendment of amendment parent hidden", []string{motion.CanSee}, "motions/motion:3", false},
+		{"amendment parent visible", []string{motion.CanSee, "motions.can_see_internal"}, "motions/motion:2", true},
+		{"amendment of amendment parent visible", []string{motion.CanSee, "motions.can_see_internal"}, "motions/motion:3", true},
+		{"amendment parent does not exist", []string{motion.CanSee, motion.CanManage}, "motions/motion:4", false},
+		{"amendment manager", []string{motion.CanSee, motion.CanManage}, "motions/motion:2", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms, Data: data}
+
+			got, err := motion.Restrict(permer).Restrict(1, data[tt.key])
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if (got != nil) != tt.visible {
+				t.Errorf("Restrict returned `%s`, expected visible: %t", got, tt.visible)
+			}
+		})
+	}
+}
+
+func TestRestrictChangeRecommendation(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "parent_id": null, "submitters": [], "state_restriction": [], "comments": []}`),
+		"motions/motion:2": []byte(`{"id": 2, "parent_id": null, "submitters": [], "state_restriction": ["motions.can_see_internal"], "comments": []}`),
+		"motions/motion:3": []byte(`{"id": 3, "parent_id": 2, "submitters": [], "state_restriction": [], "comments": []}`),
+	}
+
+	cr := func(motionID int, internal bool) json.RawMessage {
+		return []byte(fmt.Sprintf(`{"id": 1, "motion_id": %d, "internal": %t, "text": "new text"}`, motionID, internal))
+	}
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		element json.RawMessage
+		visible bool
+	}{
+		{"no can_see", []string{motion.CanSeeChangeRecommendations}, cr(1, false), false},
+		{"can_see only", []string{motion.CanSee}, cr(1, false), false},
+		{"can see change recommendations", []string{motion.CanSee, motion.CanSeeChangeRecommendations}, cr(1, false), true},
+		{"internal", []string{motion.CanSee, motion.CanSeeChangeRecommendations}, cr(1, true), false},
+		{"motion hidden", []string{motion.CanSee, motion.CanSeeChangeRecommendations}, cr(2, false), false},
+		{"amendment parent hidden", []string{motion.CanSee, motion.CanSeeChangeRecommendations}, cr(3, false), false},
+		{"motion does not exist", []string{motion.CanSee, motion.CanSeeChangeRecommendations}, cr(4, false), false},
+		{"manager internal", []string{motion.CanSee, motion.CanManage}, cr(1, true), true},
+		{"manager motion hidden", []string{motion.CanSee, motion.CanManage}, cr(2, false), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms, Data: data}
+
+			got, err := motion.ChangeRecommendationRestrict(permer).Restrict(1, tt.element)
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if (got != nil) != tt.visible {
+				t.Errorf("Restrict returned `%s`, expected visible: %t", got, tt.visible)
+			}
+		})
+	}
+}
+
+func TestRestrictCategoryAndStatuteParagraph(t *testing.T) {
+	categories := []string{
+		`{"id": 1, "name": "Main", "prefix": "A", "parent_id": null, "level": 0}`,
+		`{"id": 2, "name": "Sub", "prefix": "A1", "parent_id": 1, "level": 1}`,
+		`{"id": 3, "name": "Sub sub", "prefix": "A11", "parent_id": 2, "level": 2}`,
+	}
+	paragraph := `{"id": 1, "title": "Paragraph 1", "text": "<p>text</p>", "weight": 10000}`
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		visible bool
+	}{
+		{"motions viewer", []string{motion.CanSee}, true},
+		{"manager", []string{motion.CanSee, motion.CanManage}, true},
+		{"no can_see", []string{"agenda.can_see"}, false},
+		{"no perms", nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms}
+
+			for _, category := range categories {
+				got, err := motion.CategoryRestrict(permer).Restrict(1, []byte(category))
+				if err != nil {
+					t.Fatalf("CategoryRestrict returned unexpected error: %v", err)
+				}
+
+				if (got != nil) != tt.visible {
+					t.Errorf("CategoryRestrict returned `%s` for %s, expected visible: %t", got, category, tt.visible)
+				}
+			}
+
+			got, err := motion.StatuteParagraphRestrict(permer).Restrict(1, []byte(paragraph))
+			if err != nil {
+				t.Fatalf("StatuteParagraphRestrict returned unexpected error: %v", err)
+			}
+
+			if (got != nil) != tt.visible {
+				t.Errorf("StatuteParagraphRestrict returned `%s`, expected visible: %t", got, tt.visible)
+			}
+		})
+	}
+}
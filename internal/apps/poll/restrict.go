@@ -2,13 +2,23 @@ package poll
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
 )
 
-// StatePublished is the state of a poll, when it is published.
-const StatePublished = 4
+// The states of a poll.
+const (
+	StateCreated   = 1
+	StateStarted   = 2
+	StateFinished  = 3
+	StatePublished = 4
+)
+
+// TypePseudoanonymous is the type of a poll, where the votes are not linked to
+// the users.
+const TypePseudoanonymous = "pseudoanonymous"
 
 // RestrictPoll restricts an element for an assignment or motion poll.
 func RestrictPoll(r restricter.HasPermer, canSee, canManage string, restrictedFiels []string) restricter.ElementFunc {
@@ -120,48 +130,118 @@ func RestrictOption(r restricter.HasPermer, canSee, canManage string) restricter
 }
 
 // RestrictVote restricts an element for a poll vote.
-func RestrictVote(r restricter.HasPermer, canSee, canManage string) restricter.ElementFunc {
+//
+// Votes are only visible for managers, their user or after the poll is
+// published. The users of a vote of a pseudoanonymous poll are never shown,
+// not even to managers or the user of the vote. So a user does not see the own
+// vote of a pseudoanonymous poll before it is published.
+//
+// collection is the collection of the poll without the suffix, for example
+// motions/motion for motions/motion-poll.
+func RestrictVote(r restricter.HasPermer, canSee, canManage, collection string) restricter.ElementFunc {
 	return func(uid int, element json.RawMessage) (json.RawMessage, error) {
 		if !r.HasPerm(uid, canSee) {
 			return nil, nil
 		}
 
-		if r.HasPerm(uid, canManage) {
-			return element, nil
-		}
-
 		var vote map[string]json.RawMessage
 		if err := json.Unmarshal(element, &vote); err != nil {
 			return nil, fmt.Errorf("unmarshal vote: %w", err)
 		}
 
-		var userID int
-		if err := json.Unmarshal(vote["user_id"], &userID); err != nil {
-			return nil, fmt.Errorf("unmarshal user_id: %w", err)
+		pseudoanonymous, err := isPseudoanonymous(r, collection, vote["option_id"])
+		if err != nil {
+			return nil, fmt.Errorf("getting poll type: %w", err)
+		}
+
+		visible, err := voteVisible(r, uid, canManage, vote, pseudoanonymous)
+		if err != nil {
+			return nil, err
 		}
 
-		if userID == uid {
+		if !visible {
+			return nil, nil
+		}
+
+		if !pseudoanonymous {
 			return element, nil
 		}
 
+		vote["user_id"] = []byte("null")
+		vote["delegated_user_id"] = []byte("null")
+
+		data, err := json.Marshal(vote)
+		if err != nil {
+			return nil, fmt.Errorf("marshal vote: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// voteVisible tells, if the user can see the vote.
+func voteVisible(r restricter.HasPermer, uid int, canManage string, vote map[string]json.RawMessage, pseudoanonymous bool) (bool, error) {
+	if r.HasPerm(uid, canManage) {
+		return true, nil
+	}
+
+	if !pseudoanonymous {
+		var userID int
+		if err := json.Unmarshal(vote["user_id"], &userID); err != nil {
+			return false, fmt.Errorf("unmarshal user_id: %w", err)
+		}
+
 		var delegatedUserID int
 		if err := json.Unmarshal(vote["delegated_user_id"], &delegatedUserID); err != nil {
-			return nil, fmt.Errorf("unmarshal delegated_user_id: %w", err)
+			return false, fmt.Errorf("unmarshal delegated_user_id: %w", err)
 		}
 
-		if delegatedUserID == uid {
-			return element, nil
+		// Votes of analog polls have no user. They are not the votes of the
+		// anonymous user.
+		if uid != 0 && (userID == uid || delegatedUserID == uid) {
+			return true, nil
 		}
+	}
 
-		var state int
-		if err := json.Unmarshal(vote["pollstate"], &state); err != nil {
-			return nil, fmt.Errorf("unmarshal pollstate: %w", err)
-		}
+	var state int
+	if err := json.Unmarshal(vote["pollstate"], &state); err != nil {
+		return false, fmt.Errorf("unmarshal pollstate: %w", err)
+	}
 
-		if state == StatePublished {
-			return element, nil
+	return state == StatePublished, nil
+}
+
+// isPseudoanonymous tells, if the poll of the option is pseudoanonymous. If the
+// option or poll does not exist, it is handled as pseudoanonymous, so no user
+// is leaked.
+func isPseudoanonymous(r restricter.HasPermer, collection string, rawOptionID json.RawMessage) (bool, error) {
+	var optionID int
+	if err := json.Unmarshal(rawOptionID, &optionID); err != nil {
+		return false, fmt.Errorf("unmarshal option_id: %w", err)
+	}
+
+	var doesNotExist interface {
+		DoesNotExist() string
+	}
+
+	var option struct {
+		PollID int `json:"poll_id"`
+	}
+	if err := r.Get(collection+"-option", optionID, &option); err != nil {
+		if errors.As(err, &doesNotExist) {
+			return true, nil
 		}
+		return false, fmt.Errorf("getting option %d: %w", optionID, err)
+	}
 
-		return nil, nil
+	var poll struct {
+		Type string `json:"type"`
 	}
+	if err := r.Get(collection+"-poll", option.PollID, &poll); err != nil {
+		if errors.As(err, &doesNotExist) {
+			return true, nil
+		}
+		return false, fmt.Errorf("getting poll %d: %w", option.PollID, err)
+	}
+
+	return poll.Type == TypePseudoanonymous, nil
 }
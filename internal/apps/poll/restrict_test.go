@@ -0,0 +1,211 @@
+package poll_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/poll"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+const (
+	canSee    = "motions.can_see"
+	canManage = "motions.can_manage_polls"
+)
+
+func TestRestrictPollStates(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"users/user:1": []byte(`{"id": 1, "vote_delegated_from_users_id": []}`),
+	}
+
+	for _, state := range []int{poll.StateCreated, poll.StateStarted, poll.StateFinished, poll.StatePublished} {
+		for _, manager := range []bool{false, true} {
+			t.Run(fmt.Sprintf("state %d manager %t", state, manager), func(t *testing.T) {
+				perms := []string{canSee}
+				if manager {
+					perms = append(perms, canManage)
+				}
+				permer := &test.HasPermMock{Perms: perms, Data: data}
+
+				element := fmt.Sprintf(`{"id": 1, "state": %d, "type": "named", "votesvalid": "1.000000", "votesinvalid": "0.000000", "votescast": "1.000000", "voted_id": [1]}`, state)
+				got, err := poll.RestrictPoll(permer, canSee, canManage, nil).Restrict(1, []byte(element))
+				if err != nil {
+					t.Fatalf("Restrict returned unexpected error: %v", err)
+				}
+
+				if got == nil {
+					t.Fatalf("Restrict returned nil, the poll has always to be visible")
+				}
+
+				var restricted map[string]json.RawMessage
+				if err := json.Unmarshal(got, &restricted); err != nil {
+					t.Fatalf("Restrict returned invalid json: %v", err)
+				}
+
+				if restricted["state"] == nil {
+					t.Errorf("Restrict removed the state of the poll")
+				}
+
+				expectVisible := manager || state == poll.StatePublished
+				for _, field := range []string{"votesvalid", "votesinvalid", "votescast", "voted_id"} {
+					if _, ok := restricted[field]; ok != expectVisible {
+						t.Errorf("Field %s visible: %t, expected %t", field, ok, expectVisible)
+					}
+				}
+
+				if string(restricted["user_has_voted"]) != "true" {
+					t.Errorf("user_has_voted is %s, expected true", restricted["user_has_voted"])
+				}
+			})
+		}
+	}
+}
+
+func TestRestrictOptionStates(t *testing.T) {
+	for _, state := range []int{poll.StateCreated, poll.StateStarted, poll.StateFinished, poll.StatePublished} {
+		t.Run(fmt.Sprintf("state %d", state), func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: []string{canSee}}
+
+			element := fmt.Sprintf(`{"id": 1, "yes": "1.000000", "no": "0.000000", "abstain": "0.000000", "poll_id": 1, "pollstate": %d}`, state)
+			got, err := poll.RestrictOption(permer, canSee, canManage).Restrict(1, []byte(element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			var restricted map[string]json.RawMessage
+			if err := json.Unmarshal(got, &restricted); err != nil {
+				t.Fatalf("Restrict returned invalid json: %v", err)
+			}
+
+			expectVisible := state == poll.StatePublished
+			for _, field := range []string{"yes", "no", "abstain"} {
+				if _, ok := restricted[field]; ok != expectVisible {
+					t.Errorf("Field %s visible: %t, expected %t", field, ok, expectVisible)
+				}
+			}
+		})
+	}
+}
+
+func TestRestrictVote(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"motions/motion-poll:1":   []byte(`{"id": 1, "type": "named"}`),
+		"motions/motion-poll:2":   []byte(`{"id": 2, "type": "pseudoanonymous"}`),
+		"motions/motion-option:1": []byte(`{"id": 1, "poll_id": 1}`),
+		"motions/motion-option:2": []byte(`{"id": 2, "poll_id": 2}`),
+	}
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		element string
+		expect  string
+	}{
+		{
+			"Named started",
+			[]string{canSee},
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 1, "pollstate": 2}`,
+			"",
+		},
+		{
+			"Named started own vote",
+			[]string{canSee},
+			`{"id": 1, "user_id": 1, "delegated_user_id": null, "option_id": 1, "pollstate": 2}`,
+			`{"id": 1, "user_id": 1, "delegated_user_id": null, "option_id": 1, "pollstate": 2}`,
+		},
+		{
+			"Named finished",
+			[]string{canSee},
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 1, "pollstate": 3}`,
+			"",
+		},
+		{
+			"Named published",
+			[]string{canSee},
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 1, "pollstate": 4}`,
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 1, "pollstate": 4}`,
+		},
+		{
+			"Pseudoanonymous published",
+			[]string{canSee},
+			`{"id": 1, "user_id": 5, "delegated_user_id": 6, "option_id": 2, "pollstate": 4}`,
+			`{"id": 1, "user_id": null, "delegated_user_id": null, "option_id": 2, "pollstate": 4}`,
+		},
+		{
+			"Named started delegated vote",
+			[]string{canSee},
+			`{"id": 1, "user_id": 5, "delegated_user_id": 1, "option_id": 1, "pollstate": 2}`,
+			`{"id": 1, "user_id": 5, "delegated_user_id": 1, "option_id": 1, "pollstate": 2}`,
+		},
+		{
+			"Named started manager",
+			[]string{canSee, canManage},
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 1, "pollstate": 2}`,
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 1, "pollstate": 2}`,
+		},
+		{
+			"Pseudoanonymous started",
+			[]string{canSee},
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 2, "pollstate": 2}`,
+			"",
+		},
+		{
+			"Pseudoanonymous started own vote",
+			[]string{canSee},
+			`{"id": 1, "user_id": 1, "delegated_user_id": null, "option_id": 2, "pollstate": 2}`,
+			"",
+		},
+		{
+			"Pseudoanonymous published own vote",
+			[]string{canSee},
+			`{"id": 1, "user_id": 1, "delegated_user_id": null, "option_id": 2, "pollstate": 4}`,
+			`{"id": 1, "user_id": null, "delegated_user_id": null, "option_id": 2, "pollstate": 4}`,
+		},
+		{
+			"Pseudoanonymous started manager",
+			[]string{canSee, canManage},
+			`{"id": 1, "user_id": 5, "delegated_user_id": 6, "option_id": 2, "pollstate": 2}`,
+			`{"id": 1, "user_id": null, "delegated_user_id": null, "option_id": 2, "pollstate": 2}`,
+		},
+		{
+			"Pseudoanonymous published manager",
+			[]string{canSee, canManage},
+			`{"id": 1, "user_id": 5, "delegated_user_id": 6, "option_id": 2, "pollstate": 4}`,
+			`{"id": 1, "user_id": null, "delegated_user_id": null, "option_id": 2, "pollstate": 4}`,
+		},
+		{
+			"No permission",
+			nil,
+			`{"id": 1, "user_id": 1, "delegated_user_id": null, "option_id": 1, "pollstate": 4}`,
+			"",
+		},
+		{
+			"Unknown option",
+			[]string{canSee},
+			`{"id": 1, "user_id": 5, "delegated_user_id": null, "option_id": 404, "pollstate": 4}`,
+			`{"id": 1, "user_id": null, "delegated_user_id": null, "option_id": 404, "pollstate": 4}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms, Data: data}
+
+			got, err := poll.RestrictVote(permer, canSee, canManage, "motions/motion").Restrict(1, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.expect == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.expect)
+			}
+			test.ExpectEqualJSON(t, got, []byte(tt.expect))
+		})
+	}
+}
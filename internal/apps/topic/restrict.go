@@ -0,0 +1,100 @@
+package topic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/agenda"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/mediafile"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+)
+
+const pCanSee = "agenda.can_see"
+
+// Restrict restricts topics/topic elements.
+//
+// A topic is only visible, if the user can see its agenda item. Attachments
+// the user can not see are removed from attachments_id.
+func Restrict(r restricter.HasPermer) restricter.ElementFunc {
+	restrictItem := agenda.Restrict(r)
+	restrictMediafile := mediafile.Restrict(r)
+
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		if !r.HasPerm(uid, pCanSee) {
+			return nil, nil
+		}
+
+		var topic struct {
+			AgendaItemID  int   `json:"agenda_item_id"`
+			AttachmentsID []int `json:"attachments_id"`
+		}
+		if err := json.Unmarshal(data, &topic); err != nil {
+			return nil, fmt.Errorf("decoding topic: %w", err)
+		}
+
+		var item json.RawMessage
+		if err := r.Get("agenda/item", topic.AgendaItemID, &item); err != nil {
+			var doesNotExist interface {
+				DoesNotExist() string
+			}
+			if !errors.As(err, &doesNotExist) {
+				return nil, fmt.Errorf("getting agenda item %d: %w", topic.AgendaItemID, err)
+			}
+			// Without an agenda item, the topic can not be hidden.
+		} else {
+			restricted, err := restrictItem(uid, item)
+			if err != nil {
+				return nil, fmt.Errorf("restricting agenda item %d: %w", topic.AgendaItemID, err)
+			}
+
+			if restricted == nil {
+				return nil, nil
+			}
+		}
+
+		attachments := make([]int, 0, len(topic.AttachmentsID))
+		for _, id := range topic.AttachmentsID {
+			var mediafile json.RawMessage
+			if err := r.Get("mediafiles/mediafile", id, &mediafile); err != nil {
+				var doesNotExist interface {
+					DoesNotExist() string
+				}
+				if errors.As(err, &doesNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("getting mediafile %d: %w", id, err)
+			}
+
+			restricted, err := restrictMediafile(uid, mediafile)
+			if err != nil {
+				return nil, fmt.Errorf("restricting mediafile %d: %w", id, err)
+			}
+
+			if restricted != nil {
+				attachments = append(attachments, id)
+			}
+		}
+
+		if len(attachments) == len(topic.AttachmentsID) {
+			return data, nil
+		}
+
+		var topicData map[string]json.RawMessage
+		if err := json.Unmarshal(data, &topicData); err != nil {
+			return nil, fmt.Errorf("decoding topic data: %w", err)
+		}
+
+		rawAttachments, err := json.Marshal(attachments)
+		if err != nil {
+			return nil, fmt.Errorf("encoding attachments: %w", err)
+		}
+		topicData["attachments_id"] = rawAttachments
+
+		data, err = json.Marshal(topicData)
+		if err != nil {
+			return nil, fmt.Errorf("encoding topic data: %w", err)
+		}
+		return data, nil
+	}
+}
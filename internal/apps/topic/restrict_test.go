@@ -0,0 +1,109 @@
+package topic_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/topic"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestRestrict(t *testing.T) {
+	data := map[string]json.RawMessage{
+		"agenda/item:1":          []byte(`{"id": 1, "is_hidden": false, "is_internal": false}`),
+		"agenda/item:2":          []byte(`{"id": 2, "is_hidden": true, "is_internal": false}`),
+		"mediafiles/mediafile:1": []byte(`{"id": 1, "inherited_access_groups_id": true}`),
+		"mediafiles/mediafile:2": []byte(`{"id": 2, "inherited_access_groups_id": [3]}`),
+	}
+
+	for _, tt := range []struct {
+		name    string
+		perms   []string
+		groups  map[int]bool
+		element string
+		expect  string
+	}{
+		{
+			"No permission",
+			nil,
+			nil,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": []}`,
+			"",
+		},
+		{
+			"Visible item",
+			[]string{"agenda.can_see"},
+			nil,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": []}`,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": []}`,
+		},
+		{
+			"Hidden item",
+			[]string{"agenda.can_see"},
+			nil,
+			`{"id": 1, "agenda_item_id": 2, "attachments_id": []}`,
+			"",
+		},
+		{
+			"Hidden item with manage permission",
+			[]string{"agenda.can_see", "agenda.can_manage"},
+			nil,
+			`{"id": 1, "agenda_item_id": 2, "attachments_id": []}`,
+			`{"id": 1, "agenda_item_id": 2, "attachments_id": []}`,
+		},
+		{
+			"Attachments without mediafile permission",
+			[]string{"agenda.can_see"},
+			nil,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": [1, 2]}`,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": []}`,
+		},
+		{
+			"Restricted attachment",
+			[]string{"agenda.can_see", "mediafiles.can_see"},
+			map[int]bool{4: true},
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": [1, 2]}`,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": [1]}`,
+		},
+		{
+			"Attachments in group",
+			[]string{"agenda.can_see", "mediafiles.can_see"},
+			map[int]bool{3: true},
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": [1, 2]}`,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": [1, 2]}`,
+		},
+		{
+			"Unknown attachment",
+			[]string{"agenda.can_see", "mediafiles.can_see"},
+			nil,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": [1, 404]}`,
+			`{"id": 1, "agenda_item_id": 1, "attachments_id": [1]}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{
+				Perms:  tt.perms,
+				Groups: tt.groups,
+				Data:   data,
+			}
+
+			got, err := topic.Restrict(permer).Restrict(1, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.expect == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.expect)
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.expect))
+		})
+	}
+}
@@ -19,16 +19,24 @@ func Restrict(r restricter.HasPermer) restricter.ElementFunc {
 		"number",
 		"about_me",
 		"groups_id",
-		"is_present",
 		"is_committee",
 		"vote_weight",
 		"gender",
 	}
-	manyDataFields := append(littleDataFields, "email", "last_email_send", "comment", "is_active", "auth_type", "vote_delegated_to_id", "vote_delegated_from_users_id")
+
+	// presenceFields tell, if and when a user was around. They are only
+	// visible with users.can_see_extra_data and for the user itself.
+	presenceFields := []string{"is_present", "last_email_send"}
+
+	manyDataFields := append(append(littleDataFields, presenceFields...), "email", "comment", "is_active", "auth_type", "vote_delegated_to_id", "vote_delegated_from_users_id")
 	allDataFields := append(manyDataFields, "default_password")
-	ownDataFields := append(littleDataFields, "email", "gender", "vote_delegated_to_id", "vote_delegated_from_users_id")
+	ownDataFields := append(append(littleDataFields, presenceFields...), "email", "gender", "vote_delegated_to_id", "vote_delegated_from_users_id")
 
 	return func(uid int, element json.RawMessage) (json.RawMessage, error) {
+		if r.IsSuperadmin(uid) {
+			return filter(element, allDataFields)
+		}
+
 		var user struct {
 			ID int `json:"id"`
 		}
@@ -76,6 +84,9 @@ func Restrict(r restricter.HasPermer) restricter.ElementFunc {
 }
 
 // PersonalNoteRestrict is the restricter for users/personal_note.
+//
+// A personal note is only visible for its owner. There is no exception for
+// admins or managers. Notes without an user_id are visible for nobody.
 func PersonalNoteRestrict(uid int, data json.RawMessage) (json.RawMessage, error) {
 	if uid == 0 {
 		return nil, nil
@@ -95,6 +106,34 @@ func PersonalNoteRestrict(uid int, data json.RawMessage) (json.RawMessage, error
 	return data, nil
 }
 
+// RestrictGroup handels restrictions of users/group elements.
+//
+// Managers see all groups. All other users see only the id and the name of a
+// group, but the permissions of their own groups, so the client can calculate
+// their permissions. The anonymous user is in the default group.
+func RestrictGroup(r restricter.HasPermer) restricter.ElementFunc {
+	return func(uid int, element json.RawMessage) (json.RawMessage, error) {
+		if r.HasPerm(uid, "users.can_manage") {
+			return element, nil
+		}
+
+		var group struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(element, &group); err != nil {
+			return nil, fmt.Errorf("unmarshal group: %w", err)
+		}
+
+		for _, gid := range r.GroupIDs(uid) {
+			if gid == group.ID {
+				return element, nil
+			}
+		}
+
+		return filter(element, []string{"id", "name"})
+	}
+}
+
 func filter(value json.RawMessage, fields []string) (json.RawMessage, error) {
 	var allData map[string]json.RawMessage
 	if err := json.Unmarshal(value, &allData); err != nil {
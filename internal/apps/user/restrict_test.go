@@ -0,0 +1,316 @@
+package user_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/user"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+const (
+	fullUser = `{
+		"id": 2,
+		"username": "max",
+		"title": "",
+		"first_name": "Max",
+		"last_name": "Mustermann",
+		"structure_level": "",
+		"number": "",
+		"about_me": "",
+		"groups_id": [3],
+		"is_present": false,
+		"is_committee": false,
+		"vote_weight": "1.000000",
+		"gender": "",
+		"email": "max@example.com",
+		"last_email_send": null,
+		"comment": "secret comment",
+		"is_active": true,
+		"auth_type": "default",
+		"vote_delegated_to_id": null,
+		"vote_delegated_from_users_id": [],
+		"default_password": "secret",
+		"session_auth_hash": "hash"
+	}`
+
+	littleDataUser = `{
+		"id": 2,
+		"username": "max",
+		"title": "",
+		"first_name": "Max",
+		"last_name": "Mustermann",
+		"structure_level": "",
+		"number": "",
+		"about_me": "",
+		"groups_id": [3],
+		"is_committee": false,
+		"vote_weight": "1.000000",
+		"gender": ""
+	}`
+
+	ownDataUser = `{
+		"id": 2,
+		"username": "max",
+		"title": "",
+		"first_name": "Max",
+		"last_name": "Mustermann",
+		"structure_level": "",
+		"number": "",
+		"about_me": "",
+		"groups_id": [3],
+		"is_present": false,
+		"is_committee": false,
+		"vote_weight": "1.000000",
+		"gender": "",
+		"email": "max@example.com",
+		"last_email_send": null,
+		"vote_delegated_to_id": null,
+		"vote_delegated_from_users_id": []
+	}`
+
+	manyDataUser = `{
+		"id": 2,
+		"username": "max",
+		"title": "",
+		"first_name": "Max",
+		"last_name": "Mustermann",
+		"structure_level": "",
+		"number": "",
+		"about_me": "",
+		"groups_id": [3],
+		"is_present": false,
+		"is_committee": false,
+		"vote_weight": "1.000000",
+		"gender": "",
+		"email": "max@example.com",
+		"last_email_send": null,
+		"comment": "secret comment",
+		"is_active": true,
+		"auth_type": "default",
+		"vote_delegated_to_id": null,
+		"vote_delegated_from_users_id": []
+	}`
+
+	allDataUser = `{
+		"id": 2,
+		"username": "max",
+		"title": "",
+		"first_name": "Max",
+		"last_name": "Mustermann",
+		"structure_level": "",
+		"number": "",
+		"about_me": "",
+		"groups_id": [3],
+		"is_present": false,
+		"is_committee": false,
+		"vote_weight": "1.000000",
+		"gender": "",
+		"email": "max@example.com",
+		"last_email_send": null,
+		"comment": "secret comment",
+		"is_active": true,
+		"auth_type": "default",
+		"vote_delegated_to_id": null,
+		"vote_delegated_from_users_id": [],
+		"default_password": "secret"
+	}`
+)
+
+func TestRestrict(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		uid        int
+		perms      []string
+		superadmin bool
+		expected   string
+	}{
+		{
+			"No permission",
+			1,
+			nil,
+			false,
+			"",
+		},
+		{
+			"No permission own user",
+			2,
+			nil,
+			false,
+			ownDataUser,
+		},
+		{
+			"Can see name",
+			1,
+			[]string{"users.can_see_name"},
+			false,
+			littleDataUser,
+		},
+		{
+			"Can see name own user",
+			2,
+			[]string{"users.can_see_name"},
+			false,
+			ownDataUser,
+		},
+		{
+			"Can see extra data",
+			1,
+			[]string{"users.can_see_name", "users.can_see_extra_data"},
+			false,
+			manyDataUser,
+		},
+		{
+			"Can manage",
+			1,
+			[]string{"users.can_see_name", "users.can_see_extra_data", "users.can_manage"},
+			false,
+			allDataUser,
+		},
+		{
+			"Can manage own user",
+			2,
+			[]string{"users.can_see_name", "users.can_see_extra_data", "users.can_manage"},
+			false,
+			allDataUser,
+		},
+		{
+			"Superadmin",
+			1,
+			nil,
+			true,
+			allDataUser,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{
+				IsSuperuser: tt.superadmin,
+				Perms:       tt.perms,
+				Data: map[string]json.RawMessage{
+					"users/user:1": []byte(`{"id":1,"vote_delegated_from_users_id":[]}`),
+					"users/user:2": []byte(fullUser),
+				},
+			}
+
+			got, err := user.Restrict(permer).Restrict(tt.uid, []byte(fullUser))
+			if err != nil {
+				t.Errorf("Restrict returned unexpected error: %v ", err)
+			}
+
+			// Full restriction.
+			if tt.expected == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Errorf("Restrict() returned nil, expected %s", tt.expected)
+				return
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.expected))
+		})
+	}
+}
+
+func TestPersonalNoteRestrict(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		uid     int
+		element string
+		visible bool
+	}{
+		{"Own note", 1, `{"id": 1, "user_id": 1, "notes": {}}`, true},
+		{"Other note", 2, `{"id": 1, "user_id": 1, "notes": {}}`, false},
+		{"Admin with other note", 1, `{"id": 1, "user_id": 2, "notes": {}}`, false},
+		{"Anonymous", 0, `{"id": 1, "user_id": 1, "notes": {}}`, false},
+		{"Zero user_id", 1, `{"id": 1, "user_id": 0, "notes": {}}`, false},
+		{"Missing user_id", 1, `{"id": 1, "notes": {}}`, false},
+		{"Missing user_id anonymous", 0, `{"id": 1, "notes": {}}`, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := user.PersonalNoteRestrict(tt.uid, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("PersonalNoteRestrict returned unexpected error: %v", err)
+			}
+
+			if tt.visible != (got != nil) {
+				t.Errorf("PersonalNoteRestrict returned `%s`, expected visible: %t", got, tt.visible)
+			}
+		})
+	}
+}
+
+func TestRestrictGroup(t *testing.T) {
+	const (
+		defaultGroup = `{"id": 1, "name": "Default", "permissions": ["core.can_see_frontpage"]}`
+		staffGroup   = `{"id": 3, "name": "Staff", "permissions": ["users.can_see_name"]}`
+	)
+
+	for _, tt := range []struct {
+		name     string
+		perms    []string
+		groups   map[int]bool
+		element  string
+		expected string
+	}{
+		{
+			"Manager",
+			[]string{"users.can_manage"},
+			map[int]bool{2: true},
+			staffGroup,
+			staffGroup,
+		},
+		{
+			"Normal user",
+			nil,
+			map[int]bool{2: true},
+			staffGroup,
+			`{"id": 3, "name": "Staff"}`,
+		},
+		{
+			"Normal user own group",
+			nil,
+			map[int]bool{3: true},
+			staffGroup,
+			staffGroup,
+		},
+		{
+			"Normal user default group",
+			nil,
+			map[int]bool{3: true},
+			defaultGroup,
+			`{"id": 1, "name": "Default"}`,
+		},
+		{
+			"Anonymous default group",
+			nil,
+			map[int]bool{1: true},
+			defaultGroup,
+			defaultGroup,
+		},
+		{
+			"Anonymous other group",
+			nil,
+			map[int]bool{1: true},
+			staffGroup,
+			`{"id": 3, "name": "Staff"}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{
+				Perms:  tt.perms,
+				Groups: tt.groups,
+			}
+
+			got, err := user.RestrictGroup(permer).Restrict(1, []byte(tt.element))
+			if err != nil {
+				t.Fatalf("RestrictGroup returned unexpected error: %v", err)
+			}
+
+			test.ExpectEqualJSON(t, got, []byte(tt.expected))
+		})
+	}
+}
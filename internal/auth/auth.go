@@ -8,9 +8,11 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -27,7 +29,7 @@ type Backend interface {
 
 // Configer returns the value for a config name.
 type Configer interface {
-	ConfigValue(key string, v interface{}) error
+	Bool(key string) (bool, error)
 }
 
 // Auth authentivates a request using the whoami view.
@@ -36,6 +38,18 @@ type Auth struct {
 	key        []byte
 	backend    Backend
 	configer   Configer
+
+	// cacheTTL is the time a valid session is cached. 0 means no cache.
+	cacheTTL  time.Duration
+	mu        sync.Mutex
+	sessions  map[string]cachedSession
+	lastSweep time.Time
+}
+
+// cachedSession is the user id of a valid session.
+type cachedSession struct {
+	uid     int
+	expires time.Time
 }
 
 // New creates a new Auth instance.
@@ -49,6 +63,54 @@ func New(cookieName string, secret string, backend Backend, configer Configer) *
 	}
 }
 
+// SetCacheTTL sets the time, a valid session is cached. In this time, the
+// session is not read from the backend again. So a session, that was removed,
+// is valid up to this time. 0 disables the cache.
+func (a *Auth) SetCacheTTL(ttl time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cacheTTL = ttl
+}
+
+// cachedUserID returns the user id of a cached session.
+func (a *Auth) cachedUserID(sessionID string) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	session, ok := a.sessions[sessionID]
+	if !ok || time.Now().After(session.expires) {
+		return 0, false
+	}
+	return session.uid, true
+}
+
+// cacheSession saves the user id of a valid session. Expired sessions are
+// removed once in each ttl.
+func (a *Auth) cacheSession(sessionID string, uid int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cacheTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+	if a.sessions == nil {
+		a.sessions = make(map[string]cachedSession)
+	}
+
+	if now.Sub(a.lastSweep) > a.cacheTTL {
+		for id, session := range a.sessions {
+			if now.After(session.expires) {
+				delete(a.sessions, id)
+			}
+		}
+		a.lastSweep = now
+	}
+
+	a.sessions[sessionID] = cachedSession{uid: uid, expires: now.Add(a.cacheTTL)}
+}
+
 func (a *Auth) validateSessionData(original, value []byte) (bool, error) {
 	mac := hmac.New(sha1.New, a.key)
 	if _, err := mac.Write(value); err != nil {
@@ -76,7 +138,22 @@ func (a *Auth) userID(r *http.Request) (int, error) {
 		return 0, fmt.Errorf("loading session cookie: %w", err)
 	}
 
-	encodedSessionData, err := a.backend.GetSession(cookie.Value)
+	if uid, ok := a.cachedUserID(cookie.Value); ok {
+		return uid, nil
+	}
+
+	uid, err := a.sessionUserID(cookie.Value)
+	if err != nil {
+		return 0, err
+	}
+
+	a.cacheSession(cookie.Value, uid)
+	return uid, nil
+}
+
+// sessionUserID reads the session from the backend and returns its user id.
+func (a *Auth) sessionUserID(sessionID string) (int, error) {
+	encodedSessionData, err := a.backend.GetSession(sessionID)
 	if encodedSessionData == nil {
 		return 0, Error("Invalid session data")
 	}
@@ -89,6 +166,10 @@ func (a *Auth) userID(r *http.Request) (int, error) {
 	b64decoded = b64decoded[:n]
 
 	parts := bytes.SplitN(b64decoded, []byte(":"), 2)
+	if len(parts) != 2 {
+		return 0, Error("Invalid session data")
+	}
+
 	valid, err := a.validateSessionData(parts[0], parts[1])
 	if !valid {
 		return 0, Error("Invalid session data")
@@ -110,15 +191,22 @@ func (a *Auth) userID(r *http.Request) (int, error) {
 }
 
 // Authenticate returns an context that can be used by auth.FromContext().
+//
+// A request without a valid session is handled as anonymous user, if anonymous
+// is enabled.
 func (a *Auth) Authenticate(r *http.Request) (context.Context, error) {
 	uid, err := a.userID(r)
 	if err != nil {
-		return nil, fmt.Errorf("getting user id: %w", err)
+		var errAuth Error
+		if !errors.As(err, &errAuth) {
+			return nil, fmt.Errorf("getting user id: %w", err)
+		}
+		uid = 0
 	}
 
 	if uid == 0 {
-		var enabled bool
-		if err := a.configer.ConfigValue("general_system_enable_anonymous", &enabled); err != nil {
+		enabled, err := a.configer.Bool("general_system_enable_anonymous")
+		if err != nil {
 			return nil, fmt.Errorf("getting config value for anonymous: %w", err)
 		}
 		if !enabled {
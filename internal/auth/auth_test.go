@@ -1,9 +1,11 @@
 package auth_test
 
 import (
+	"errors"
 	"net/http"
-	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/auth"
 )
@@ -63,6 +65,36 @@ func TestAuth(t *testing.T) {
 			1,
 			false,
 		},
+
+		{
+			"invalid session anonymous allowed",
+			func() *http.Request {
+				r, err := http.NewRequest("GET", "openslides.com/service/autoupdate", nil)
+				r.AddCookie(&http.Cookie{Name: "test-auth-cookie", Value: "invalid"})
+				if err != nil {
+					t.Fatalf("Can not create request: %v", err)
+				}
+				return r
+			},
+			true,
+			0,
+			false,
+		},
+
+		{
+			"invalid session anonymous not allowed",
+			func() *http.Request {
+				r, err := http.NewRequest("GET", "openslides.com/service/autoupdate", nil)
+				r.AddCookie(&http.Cookie{Name: "test-auth-cookie", Value: "invalid"})
+				if err != nil {
+					t.Fatalf("Can not create request: %v", err)
+				}
+				return r
+			},
+			false,
+			0,
+			true,
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			anonymous.enabled = tt.anonymousEnabled
@@ -86,19 +118,121 @@ func TestAuth(t *testing.T) {
 	}
 }
 
+func TestAuthCache(t *testing.T) {
+	anonymous := &anonymousMock{enabled: true}
+	backend := &countingBackend{sessions: map[string]bool{"sessionID": true}}
+	a := auth.New("test-auth-cookie", testSecret, backend, anonymous)
+	a.SetCacheTTL(50 * time.Millisecond)
+
+	authenticate := func(sessionID string) int {
+		t.Helper()
+
+		r, err := http.NewRequest("GET", "openslides.com/service/autoupdate", nil)
+		if err != nil {
+			t.Fatalf("Can not create request: %v", err)
+		}
+		r.AddCookie(&http.Cookie{Name: "test-auth-cookie", Value: sessionID})
+
+		ctx, err := a.Authenticate(r)
+		if err != nil {
+			t.Fatalf("Auth returend unexpected err: %v", err)
+		}
+		return auth.FromContext(ctx)
+	}
+
+	t.Run("valid session", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if uid := authenticate("sessionID"); uid != 1 {
+				t.Errorf("Auth returned uid %d, expected 1", uid)
+			}
+		}
+
+		if got := backend.count("sessionID"); got != 1 {
+			t.Errorf("Backend was called %d times, expected 1", got)
+		}
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		backend.remove("sessionID")
+
+		if uid := authenticate("sessionID"); uid != 1 {
+			t.Errorf("Auth returned uid %d from the cache, expected 1", uid)
+		}
+
+		time.Sleep(60 * time.Millisecond)
+
+		if uid := authenticate("sessionID"); uid != 0 {
+			t.Errorf("Auth returned uid %d after the cache expired, expected 0", uid)
+		}
+
+		if got := backend.count("sessionID"); got != 2 {
+			t.Errorf("Backend was called %d times, expected 2", got)
+		}
+	})
+
+	t.Run("missing session", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if uid := authenticate("missing"); uid != 0 {
+				t.Errorf("Auth returned uid %d, expected 0", uid)
+			}
+		}
+
+		if got := backend.count("missing"); got != 2 {
+			t.Errorf("Backend was called %d times, expected 2. A missing session should not be cached", got)
+		}
+	})
+}
+
 type backendMock struct{}
 
 func (b *backendMock) GetSession(sessionID string) ([]byte, error) {
+	if sessionID == "invalid" {
+		return nil, errors.New("session does not exist")
+	}
+
 	// userID 1 decoded with secret "test"
 	return []byte("MDFmMDJjZWNlYWZhZTAxNzY5ZDA2NTY2NWM5NjAyOWI4ZDU0MDhjMzp7Il9hdXRoX3VzZXJfaWQiOiIxIiwiX2F1dGhfdXNlcl9iYWNrZW5kIjoiZGphbmdvLmNvbnRyaWIuYXV0aC5iYWNrZW5kcy5Nb2RlbEJhY2tlbmQiLCJfYXV0aF91c2VyX2hhc2giOiIyNWMyNGNkNTAzZDViYTc2MDI3MzQxZWUxOTA5YzM3N2U4NTgxMDU3In0="), nil
 }
 
+// countingBackend returns the session of user 1 for its sessions and counts
+// the calls for each session id.
+type countingBackend struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	calls    map[string]int
+}
+
+func (b *countingBackend) GetSession(sessionID string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.calls == nil {
+		b.calls = make(map[string]int)
+	}
+	b.calls[sessionID]++
+
+	if !b.sessions[sessionID] {
+		return nil, nil
+	}
+	return new(backendMock).GetSession(sessionID)
+}
+
+func (b *countingBackend) remove(sessionID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, sessionID)
+}
+
+func (b *countingBackend) count(sessionID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls[sessionID]
+}
+
 type anonymousMock struct {
 	enabled bool
 }
 
-func (a *anonymousMock) ConfigValue(key string, v interface{}) error {
-	rv := reflect.ValueOf(v)
-	rv.Elem().SetBool(a.enabled)
-	return nil
+func (a *anonymousMock) Bool(key string) (bool, error) {
+	return a.enabled, nil
 }
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 	"github.com/ostcar/topic"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/label"
 )
 
 // Autoupdate holds the state of the serice.
@@ -22,23 +25,49 @@ type Autoupdate struct {
 	restricter Restricter
 	closed     <-chan struct{}
 	topic      *topic.Topic
+	log        logger.Logger
 
 	pccMu                    sync.Mutex
 	projectorConnectionCount int
+
+	snapshots sharedSnapshots
+	fanout    fanout
+
+	aliveMu  sync.RWMutex
+	panicked bool
+
+	// lastIteration is the time of the last iteration of the update loop.
+	// stuck is set by the watchdog.
+	lastIteration time.Time
+	stuck         bool
 }
 
 // New create a new autoupdate instance.
-func New(datastore Datastore, restricter Restricter, closed <-chan struct{}) (*Autoupdate, error) {
+func New(datastore Datastore, restricter Restricter, log logger.Logger, closed <-chan struct{}) (*Autoupdate, error) {
 	a := &Autoupdate{
 		datastore:  datastore,
 		closed:     closed,
 		restricter: restricter,
+		log:        log,
 		topic:      topic.New(topic.WithClosed(closed), topic.WithStartID(uint64(datastore.CurrentID()))),
+		fanout:     fanout{buffer: DefaultSubscriberBuffer},
+
+		lastIteration: time.Now(),
 	}
 
 	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Update loop stopped", "error", r)
+				a.aliveMu.Lock()
+				a.panicked = true
+				a.aliveMu.Unlock()
+			}
+		}()
+
 		for {
 			keys, changeID, err := datastore.KeysChanged()
+			a.iterated()
 			if err != nil {
 				var closing interface {
 					Closing()
@@ -55,32 +84,38 @@ func New(datastore Datastore, restricter Restricter, closed <-chan struct{}) (*A
 					continue
 				}
 
-				log.Printf("Autoupdate: Can not receive new data: %v", err)
+				log.Error("Can not receive new data", "error", err)
 				time.Sleep(5 * time.Second)
 				continue
 			}
 
-			// When the received data is to new, all the missing data is
-			// received at once. If more then one change id was skipped, the
-			// missing ids have to be created in the topic with dummy items.
-			tid := a.topic.LastID()
-			for tid < uint64(changeID)-1 {
-				tid = a.topic.Publish()
-			}
-
-			tid = a.topic.Publish(keys...)
-
-			// if the topic id is different then the change id, then something
-			// is broken. There is no way to recover safely from this.
-			if tid != uint64(changeID) {
-				log.Panicf("topic id differs from change id. Topic: %d, changeid %d", tid, changeID)
-			}
+			a.publish(changeID, keys)
 		}
 	}()
 
 	return a, nil
 }
 
+// Alive tells, if the autoupdate service receives new data. Returns false, if
+// the update loop has stopped because of an unexpected error or the watchdog
+// found it stuck.
+func (a *Autoupdate) Alive() bool {
+	a.aliveMu.RLock()
+	defer a.aliveMu.RUnlock()
+
+	return !a.panicked && !a.stuck
+}
+
+// LowestID returns the lowest change id, for which the changed keys are known.
+func (a *Autoupdate) LowestID() int {
+	return a.datastore.LowestID()
+}
+
+// CurrentID returns the change id of the newest data.
+func (a *Autoupdate) CurrentID() int {
+	return int(a.topic.LastID())
+}
+
 // Receive returns all changed data and the new changeid since the given change
 // id. If there is no new data, then this method blocks until the context is
 // done, the service is closed or new data is received.
@@ -89,35 +124,214 @@ func New(datastore Datastore, restricter Restricter, closed <-chan struct{}) (*A
 //
 // The returned data is restricted for the given uid.
 func (a *Autoupdate) Receive(ctx context.Context, uid int, changeID int) (bool, map[string]json.RawMessage, int, error) {
+	return a.ReceiveCollections(ctx, uid, changeID, nil)
+}
+
+// ReceiveCollections is like Receive, but only returns data from the given
+// collections. It blocks until data of one of the collections has changed.
+//
+// If collections is empty, the data from all collections is returned.
+//
+// If the permissions of the user have changed, all data is returned, because
+// the user can see other elements.
+func (a *Autoupdate) ReceiveCollections(ctx context.Context, uid int, changeID int, collections []string) (bool, map[string]json.RawMessage, int, error) {
+	subscribed := make(map[string]bool, len(collections))
+	for _, collection := range collections {
+		subscribed[collection] = true
+	}
+
 	if changeID == 0 || changeID < a.datastore.LowestID() {
 		// The changeID is lower then the lowest change_id in redis. Return all data.
-		data := a.datastore.GetAll()
-		a.restricter.Restrict(uid, data)
-		return true, data, int(a.topic.LastID()), nil
+		//
+		// The change id is read before the data, so the data is never older
+		// then the change id.
+		currentID := int(a.topic.LastID())
+		return true, a.allData(ctx, uid, currentID, collections), currentID, nil
 	}
 
+	for {
+		newChangeID, changedKeys, err := a.changedKeys(ctx, changeID)
+		if err != nil {
+			return false, nil, 0, err
+		}
+
+		if a.permissionsChanged(uid, changeID, newChangeID) {
+			// The user can see other elements now. Only the changed keys are
+			// not enough.
+			return true, a.allData(ctx, uid, newChangeID, collections), newChangeID, nil
+		}
+
+		if len(subscribed) > 0 {
+			filtered := changedKeys[:0:0]
+			for _, key := range changedKeys {
+				if subscribed[keyCollection(key)] {
+					filtered = append(filtered, key)
+				}
+			}
+			changedKeys = filtered
+
+			if len(changedKeys) == 0 {
+				// Only other collections have changed. Do not wake the client.
+				changeID = newChangeID
+				continue
+			}
+		}
+
+		if len(changedKeys) == 0 {
+			return false, nil, newChangeID, nil
+		}
+
+		data := a.getMany(ctx, changedKeys)
+		a.restrict(ctx, uid, data)
+		return false, data, newChangeID, nil
+	}
+}
+
+// allData returns all data of the collections restricted for the user. If
+// collections is empty, the data of all collections is returned.
+func (a *Autoupdate) allData(ctx context.Context, uid, changeID int, collections []string) map[string]json.RawMessage {
+	data := a.getAll(ctx)
+	if len(collections) > 0 {
+		subscribed := make(map[string]bool, len(collections))
+		for _, collection := range collections {
+			subscribed[collection] = true
+		}
+
+		for key := range data {
+			if !subscribed[keyCollection(key)] {
+				delete(data, key)
+			}
+		}
+	}
+	a.restrictAll(ctx, uid, changeID, collections, data)
+	return data
+}
+
+// permissionsChanged tells, if the permissions of the user changed between the
+// two change ids. It is always false, if the datastore is not a
+// PermissionDatastore.
+func (a *Autoupdate) permissionsChanged(uid, from, to int) bool {
+	pd, ok := a.datastore.(PermissionDatastore)
+	return ok && pd.PermissionsChanged(uid, from, to)
+}
+
+// getMany reads the keys from the datastore. If the datastore is a
+// ContextDatastore, the read is added to the trace of the context.
+//
+// The keys are changed keys, so a key that does not exist anymore is returned
+// with a nil value.
+func (a *Autoupdate) getMany(ctx context.Context, keys []string) map[string]json.RawMessage {
+	var data map[string]json.RawMessage
+	if cd, ok := a.datastore.(ContextDatastore); ok {
+		data = cd.GetManyContext(ctx, keys)
+	} else {
+		data = a.datastore.GetMany(keys)
+	}
+	return withDeleted(data, keys)
+}
+
+// withDeleted adds all keys, that are not in data, with a nil value.
+//
+// A changed key that does not exist was deleted, maybe before the datastore
+// knew about it. The client has to remove it.
+func withDeleted(data map[string]json.RawMessage, keys []string) map[string]json.RawMessage {
+	for _, key := range keys {
+		if _, ok := data[key]; !ok {
+			data[key] = nil
+		}
+	}
+	return data
+}
+
+// getAll is like getMany but reads all data.
+func (a *Autoupdate) getAll(ctx context.Context) map[string]json.RawMessage {
+	if cd, ok := a.datastore.(ContextDatastore); ok {
+		return cd.GetAllContext(ctx)
+	}
+	return a.datastore.GetAll()
+}
+
+// restrict restricts the data for the user in a span of the context.
+func (a *Autoupdate) restrict(ctx context.Context, uid int, data map[string]json.RawMessage) {
+	_, span := otel.Tracer("openslides.org").Start(ctx, "autoupdate.restrict")
+	defer span.End()
+	span.SetAttributes(label.Int("user_id", uid), label.Int("elements", len(data)))
+
+	a.restricter.Restrict(uid, data)
+}
+
+// changedKeys returns the keys that changed since the change id. Blocks until
+// there are new keys.
+func (a *Autoupdate) changedKeys(ctx context.Context, changeID int) (int, []string, error) {
 	newChangeID, changedKeys, err := a.topic.Receive(ctx, uint64(changeID))
 	if err != nil {
 		var unknownID topic.UnknownIDError
 		if !errors.As(err, &unknownID) {
-			return false, nil, 0, fmt.Errorf("get changed keys from topic: %w", err)
+			return 0, nil, fmt.Errorf("get changed keys from topic: %w", err)
 		}
 
 		// ID is not in memory, ask redis.
 		newChangeID = unknownID.FirstID
 		changedKeys, err = a.datastore.ChangedKeys(changeID, int(newChangeID))
 		if err != nil {
-			return false, nil, 0, fmt.Errorf("get changed keys from redis: %w", err)
+			return 0, nil, fmt.Errorf("get changed keys from redis: %w", err)
 		}
 	}
+	return int(newChangeID), changedKeys, nil
+}
+
+// ChangedSince returns the data that changed since the change id until the
+// current change id. It does not block.
+//
+// If the change id is lower then the lowest change id, the changed keys are
+// not known anymore. The same is true, if the permissions of the user have
+// changed since the change id. In this case reset is true and no data is
+// returned.
+//
+// The returned data is restricted for the given uid. Elements the user can not
+// see anymore are returned with a nil value.
+func (a *Autoupdate) ChangedSince(uid, changeID int) (reset bool, data map[string]json.RawMessage, currentID int, err error) {
+	currentID = a.datastore.CurrentID()
+	if changeID < a.datastore.LowestID() {
+		return true, nil, currentID, nil
+	}
+
+	if changeID >= currentID {
+		return false, nil, currentID, nil
+	}
+
+	if a.permissionsChanged(uid, changeID, currentID) {
+		// The user can see other elements now. The client has to receive all
+		// data.
+		return true, nil, currentID, nil
+	}
 
-	if len(changedKeys) == 0 {
-		return false, nil, int(newChangeID), nil
+	keys, err := a.datastore.ChangedKeys(changeID, currentID)
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("get changed keys from %d to %d: %w", changeID, currentID, err)
+	}
+
+	// A key can change more then once in the change id window.
+	seen := make(map[string]bool, len(keys))
+	uniqueKeys := keys[:0:0]
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			uniqueKeys = append(uniqueKeys, key)
+		}
 	}
 
-	data := a.datastore.GetMany(changedKeys)
+	data = withDeleted(a.datastore.GetMany(uniqueKeys), uniqueKeys)
 	a.restricter.Restrict(uid, data)
-	return false, data, int(newChangeID), nil
+	return false, data, currentID, nil
+}
+
+// keyCollection returns the collection part of a key.
+func keyCollection(key string) string {
+	if i := strings.Index(key, ":"); i >= 0 {
+		return key[:i]
+	}
+	return key
 }
 
 // Projectors returns the renderd data for a list of projectors. The attribute
@@ -151,9 +365,15 @@ func (a *Autoupdate) Projectors(ctx context.Context, tid uint64, pids []int) (nt
 }
 
 func (a *Autoupdate) reset() {
+	a.fanout.mu.Lock()
+	defer a.fanout.mu.Unlock()
+
 	oldTopic := a.topic
 	a.topic = topic.New(topic.WithClosed(a.closed), topic.WithStartID(uint64(a.datastore.CurrentID())))
 
 	// Send an empty message on the old topic to wake up all clients.
 	oldTopic.Publish()
+
+	// The subscriptions have to send all data.
+	a.fanout.publish(change{changeID: a.datastore.CurrentID(), reset: true})
 }
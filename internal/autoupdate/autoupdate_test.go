@@ -1,11 +1,18 @@
 package autoupdate_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
 )
 
@@ -20,7 +27,7 @@ func TestAutoupdateReceiveNewData(t *testing.T) {
 	datastore.Change([]string{"user:1"})
 	restricter := new(test.RestricterMock)
 
-	a, err := autoupdate.New(datastore, restricter, closed)
+	a, err := autoupdate.New(datastore, restricter, logger.Noop, closed)
 	if err != nil {
 		t.Fatalf("autoupdate startup failed: %v", err)
 	}
@@ -60,7 +67,7 @@ func TestAutoupdateReceiveFirstData(t *testing.T) {
 
 	restricter := new(test.RestricterMock)
 
-	a, err := autoupdate.New(datastore, restricter, closed)
+	a, err := autoupdate.New(datastore, restricter, logger.Noop, closed)
 	if err != nil {
 		t.Fatalf("autoupdate startup failed: %v", err)
 	}
@@ -87,3 +94,418 @@ func TestAutoupdateReceiveFirstData(t *testing.T) {
 		t.Errorf("Receice returned user:2 = `%s`, expected `hello world2`", data["user:2"])
 	}
 }
+
+// groupDatastore is a DatastoreMock that knows the groups of the users.
+type groupDatastore struct {
+	*test.DatastoreMock
+	groups map[int][]int
+}
+
+func (d groupDatastore) GroupIDs(uid int) []int {
+	return d.groups[uid]
+}
+
+func TestAutoupdateSharedSnapshot(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := groupDatastore{
+		DatastoreMock: test.NewDatastoreMock(2, closed),
+		groups: map[int][]int{
+			1: {3, 4},
+			2: {4, 3},
+			3: {3},
+		},
+	}
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1":      []byte(`{"id":1}`),
+		"users/personal-note:1": []byte(`{"id":1}`),
+	}
+
+	var sharedCalls, personalCalls int
+	r := restricter.New(datastore, nil)
+	r.Register("motions/motion", restricter.GroupFunc(func(_ int, data json.RawMessage) (json.RawMessage, error) {
+		sharedCalls++
+		return data, nil
+	}))
+	r.Register("users/personal-note", restricter.ElementFunc(func(_ int, data json.RawMessage) (json.RawMessage, error) {
+		personalCalls++
+		return data, nil
+	}))
+
+	a, err := autoupdate.New(datastore, r, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		uid      int
+		shared   int
+		personal int
+	}{
+		{"first user", 1, 1, 1},
+		{"same groups", 2, 1, 2},
+		{"other groups", 3, 2, 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, data, _, err := a.Receive(context.Background(), tt.uid, 0)
+			if err != nil {
+				t.Fatalf("Receive returned an unexpected error: %v", err)
+			}
+
+			if len(data) != 2 || data["motions/motion:1"] == nil || data["users/personal-note:1"] == nil {
+				t.Errorf("Receive returned %v, expected both elements", data)
+			}
+
+			if sharedCalls != tt.shared {
+				t.Errorf("Shared collection was restricted %d times, expected %d", sharedCalls, tt.shared)
+			}
+
+			if personalCalls != tt.personal {
+				t.Errorf("Personal collection was restricted %d times, expected %d", personalCalls, tt.personal)
+			}
+		})
+	}
+}
+
+func TestAutoupdateWarmUp(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := groupDatastore{
+		DatastoreMock: test.NewDatastoreMock(2, closed),
+		groups: map[int][]int{
+			1: {3, 4},
+			2: {4, 3},
+			3: {3},
+		},
+	}
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1}`),
+		"users/user:1":     []byte(`{"id":1}`),
+		"users/user:2":     []byte(`{"id":2}`),
+		"users/user:3":     []byte(`{"id":3}`),
+	}
+
+	var sharedCalls int
+	r := restricter.New(datastore, nil)
+	r.Register("motions/motion", restricter.GroupFunc(func(_ int, data json.RawMessage) (json.RawMessage, error) {
+		sharedCalls++
+		return data, nil
+	}))
+	r.Register("users/user", restricter.ElementFunc(func(_ int, data json.RawMessage) (json.RawMessage, error) {
+		return data, nil
+	}))
+
+	a, err := autoupdate.New(datastore, r, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if got := a.WarmUp(ctx); got != 0 {
+			t.Errorf("WarmUp built %d snapshots with a done context, expected 0", got)
+		}
+	})
+
+	t.Run("warm up", func(t *testing.T) {
+		// One snapshot for the anonymous user, the groups 3,4 and the group 3.
+		if got := a.WarmUp(context.Background()); got != 3 {
+			t.Errorf("WarmUp built %d snapshots, expected 3", got)
+		}
+
+		if sharedCalls != 3 {
+			t.Errorf("Shared collection was restricted %d times, expected 3", sharedCalls)
+		}
+	})
+
+	t.Run("snapshots are used", func(t *testing.T) {
+		for _, uid := range []int{0, 1, 2, 3} {
+			_, data, _, err := a.Receive(context.Background(), uid, 0)
+			if err != nil {
+				t.Fatalf("Receive returned an unexpected error: %v", err)
+			}
+
+			if data["motions/motion:1"] == nil {
+				t.Errorf("Receive for user %d returned %v, expected the motion", uid, data)
+			}
+		}
+
+		if sharedCalls != 3 {
+			t.Errorf("Shared collection was restricted %d times after the warm up, expected 3", sharedCalls)
+		}
+	})
+}
+
+func TestAutoupdateReceiveCollections(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`"motion1"`),
+		"agenda/item:1":    []byte(`"item1"`),
+	}
+	restricter := new(test.RestricterMock)
+
+	a, err := autoupdate.New(datastore, restricter, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	all, data, id, err := a.ReceiveCollections(context.Background(), 1, 0, []string{"motions/motion"})
+	if err != nil {
+		t.Fatalf("Receive returned an unexpected error: %v", err)
+	}
+
+	if !all || len(data) != 1 || data["motions/motion:1"] == nil {
+		t.Errorf("ReceiveCollections returned %v, expected only motions/motion:1", data)
+	}
+
+	received := make(chan map[string]json.RawMessage)
+	go func() {
+		_, data, _, err := a.ReceiveCollections(context.Background(), 1, id, []string{"motions/motion"})
+		if err != nil {
+			t.Errorf("Receive returned an unexpected error: %v", err)
+		}
+		received <- data
+	}()
+
+	datastore.Change([]string{"agenda/item:1"})
+
+	timer := time.NewTimer(10 * time.Millisecond)
+	select {
+	case data := <-received:
+		t.Fatalf("ReceiveCollections returned %v after an agenda change", data)
+	case <-timer.C:
+	}
+
+	datastore.Change([]string{"agenda/item:1", "motions/motion:1"})
+
+	timer.Reset(time.Second)
+	select {
+	case data := <-received:
+		if len(data) != 1 || data["motions/motion:1"] == nil {
+			t.Errorf("ReceiveCollections returned %v, expected only motions/motion:1", data)
+		}
+	case <-timer.C:
+		t.Fatalf("ReceiveCollections did not return after a motion change")
+	}
+}
+
+func TestAutoupdatePermissionsChanged(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	redis := test.NewRedisMock()
+	redis.Max = 1
+	redis.ChangedKeysResult = []string{"users/group:3"}
+	redis.FD = map[string]json.RawMessage{
+		"users/group:3":    []byte(`{"id": 3, "permissions": []}`),
+		"users/group:4":    []byte(`{"id": 4, "permissions": []}`),
+		"users/user:1":     []byte(`{"id": 1, "groups_id": [3]}`),
+		"users/user:2":     []byte(`{"id": 2, "groups_id": [4]}`),
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+	ds, err := datastore.New(redis, nil, nil, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	a, err := autoupdate.New(ds, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	redis.SendChange(2, map[string]json.RawMessage{
+		"users/group:3": []byte(`{"id": 3, "permissions": ["motions.can_see"]}`),
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for a.CurrentID() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("autoupdate has change id %d after one second, expected 2", a.CurrentID())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Run("user in group", func(t *testing.T) {
+		all, data, id, err := a.Receive(context.Background(), 1, 1)
+		if err != nil {
+			t.Fatalf("Receive returned an unexpected error: %v", err)
+		}
+
+		if !all || id != 2 {
+			t.Errorf("Receive returned all %t with change id %d, expected all data with change id 2", all, id)
+		}
+
+		if len(data) != 5 || data["motions/motion:1"] == nil {
+			t.Errorf("Receive returned %v, expected all data", data)
+		}
+	})
+
+	t.Run("user in other group", func(t *testing.T) {
+		all, data, id, err := a.Receive(context.Background(), 2, 1)
+		if err != nil {
+			t.Fatalf("Receive returned an unexpected error: %v", err)
+		}
+
+		if all || id != 2 {
+			t.Errorf("Receive returned all %t with change id %d, expected only the changed data with change id 2", all, id)
+		}
+
+		if len(data) != 1 || data["users/group:3"] == nil {
+			t.Errorf("Receive returned %v, expected only users/group:3", data)
+		}
+	})
+
+	t.Run("catch up", func(t *testing.T) {
+		reset, _, _, err := a.ChangedSince(1, 1)
+		if err != nil {
+			t.Fatalf("ChangedSince returned an unexpected error: %v", err)
+		}
+		if !reset {
+			t.Errorf("ChangedSince returned reset false for a user in the group, expected true")
+		}
+
+		reset, data, _, err := a.ChangedSince(2, 1)
+		if err != nil {
+			t.Fatalf("ChangedSince returned an unexpected error: %v", err)
+		}
+		if reset || len(data) != 1 {
+			t.Errorf("ChangedSince returned reset %t with %v for a user in another group, expected only users/group:3", reset, data)
+		}
+	})
+}
+
+func TestAutoupdateChangedSince(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+	datastore := test.NewDatastoreMock(10, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`"motion1"`),
+		"agenda/item:1":    []byte(`"item1"`),
+	}
+	restricter := new(test.RestricterMock)
+
+	a, err := autoupdate.New(datastore, restricter, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	// 50 changes. Every change contains motions/motion:1. agenda/item:2 does
+	// not exist anymore.
+	for i := 0; i < 50; i++ {
+		keys := []string{"motions/motion:1"}
+		if i%10 == 0 {
+			keys = append(keys, "agenda/item:1")
+		}
+		if i == 25 {
+			keys = append(keys, "agenda/item:2")
+		}
+		datastore.Change(keys)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for datastore.CurrentID() != 60 {
+		if time.Now().After(deadline) {
+			t.Fatalf("datastore has change id %d after one second, expected 60", datastore.CurrentID())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Run("full window", func(t *testing.T) {
+		reset, data, currentID, err := a.ChangedSince(1, 10)
+		if err != nil {
+			t.Fatalf("ChangedSince returned an unexpected error: %v", err)
+		}
+
+		if reset || currentID != 60 {
+			t.Errorf("ChangedSince returned reset %t and change id %d, expected false and 60", reset, currentID)
+		}
+
+		if len(data) != 3 {
+			t.Errorf("ChangedSince returned %d elements, expected 3: %v", len(data), data)
+		}
+
+		if string(data["motions/motion:1"]) != `"motion1"` || string(data["agenda/item:1"]) != `"item1"` {
+			t.Errorf("ChangedSince returned %v", data)
+		}
+
+		if v, ok := data["agenda/item:2"]; !ok || v != nil {
+			t.Errorf("ChangedSince returned agenda/item:2 = `%s`, expected a deleted element", v)
+		}
+	})
+
+	t.Run("part of the window", func(t *testing.T) {
+		_, data, _, err := a.ChangedSince(1, 40)
+		if err != nil {
+			t.Fatalf("ChangedSince returned an unexpected error: %v", err)
+		}
+
+		if len(data) != 2 || data["motions/motion:1"] == nil || data["agenda/item:1"] == nil {
+			t.Errorf("ChangedSince returned %v, expected motions/motion:1 and agenda/item:1", data)
+		}
+	})
+
+	t.Run("current change id", func(t *testing.T) {
+		reset, data, _, err := a.ChangedSince(1, 60)
+		if err != nil {
+			t.Fatalf("ChangedSince returned an unexpected error: %v", err)
+		}
+
+		if reset || len(data) != 0 {
+			t.Errorf("ChangedSince returned reset %t and %v, expected nothing", reset, data)
+		}
+	})
+
+	t.Run("to old", func(t *testing.T) {
+		reset, data, currentID, err := a.ChangedSince(1, 9)
+		if err != nil {
+			t.Fatalf("ChangedSince returned an unexpected error: %v", err)
+		}
+
+		if !reset || data != nil || currentID != 60 {
+			t.Errorf("ChangedSince returned reset %t, %v and %d, expected reset", reset, data, currentID)
+		}
+	})
+}
+
+func TestAutoupdateAlive(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := &panicDatastore{test.NewDatastoreMock(1, closed)}
+
+	buf := new(bytes.Buffer)
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.New(buf, slog.LevelInfo), closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	for i := 0; i < 100 && a.Alive(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if a.Alive() {
+		t.Errorf("Alive() returned true after the update loop panicked")
+	}
+
+	got := buf.String()
+	for _, expect := range []string{`"level":"ERROR"`, "Update loop stopped", "something went wrong"} {
+		if !strings.Contains(got, expect) {
+			t.Errorf("Log `%s` does not contain `%s`", got, expect)
+		}
+	}
+}
+
+type panicDatastore struct {
+	*test.DatastoreMock
+}
+
+func (d *panicDatastore) KeysChanged() ([]string, int, error) {
+	panic("something went wrong")
+}
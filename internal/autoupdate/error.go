@@ -0,0 +1,23 @@
+package autoupdate
+
+import "fmt"
+
+// slowClientError is returned by Subscription.Next, if the client did not read
+// the changes fast enough.
+type slowClientError struct {
+	uid int
+}
+
+func (e slowClientError) Error() string {
+	return fmt.Sprintf("client of user %d is to slow to receive the changes", e.uid)
+}
+
+func (e slowClientError) SlowClient() {}
+
+type closingError struct{}
+
+func (e closingError) Error() string {
+	return "autoupdate service is closing"
+}
+
+func (e closingError) Closing() {}
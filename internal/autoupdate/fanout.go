@@ -0,0 +1,268 @@
+package autoupdate
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// DefaultSubscriberBuffer is the number of changes, that are buffered for each
+// subscription.
+const DefaultSubscriberBuffer = 100
+
+// change is one change the update loop received from the datastore.
+type change struct {
+	changeID int
+	keys     []string
+	reset    bool
+}
+
+// fanout sends each change from the update loop to all subscriptions.
+type fanout struct {
+	mu            sync.Mutex
+	buffer        int
+	subscriptions map[*Subscription]bool
+}
+
+// publish sends the change to all subscriptions. A subscription with a full
+// buffer is dropped.
+//
+// Has to be called with the lock.
+func (f *fanout) publish(c change) {
+	for s := range f.subscriptions {
+		select {
+		case s.changes <- c:
+		default:
+			delete(f.subscriptions, s)
+			close(s.dropped)
+		}
+	}
+}
+
+// SetSubscriberBuffer sets the number of changes, that are buffered for each
+// subscription. If a client does not read the changes fast enough, the buffer
+// is full and the subscription is dropped.
+//
+// It only affects subscriptions that are created afterwards.
+func (a *Autoupdate) SetSubscriberBuffer(n int) {
+	a.fanout.mu.Lock()
+	defer a.fanout.mu.Unlock()
+	a.fanout.buffer = n
+}
+
+// publish puts the keys into the topic and sends them to all subscriptions.
+func (a *Autoupdate) publish(changeID int, keys []string) {
+	a.fanout.mu.Lock()
+	defer a.fanout.mu.Unlock()
+
+	// When the received data is to new, all the missing data is received at
+	// once. If more then one change id was skipped, the missing ids have to be
+	// created in the topic with dummy items.
+	tid := a.topic.LastID()
+	for tid < uint64(changeID)-1 {
+		tid = a.topic.Publish()
+	}
+
+	tid = a.topic.Publish(keys...)
+
+	// if the topic id is different then the change id, then something is
+	// broken. There is no way to recover safely from this.
+	if tid != uint64(changeID) {
+		log.Panicf("topic id differs from change id. Topic: %d, changeid %d", tid, changeID)
+	}
+
+	a.fanout.publish(change{changeID: changeID, keys: keys})
+}
+
+// Subscription receives the changes of one client from the update loop.
+//
+// The subscription only holds the changed keys. The data is read and
+// restricted for the user when Next is called, so a slow client does not slow
+// down the update loop or the other clients.
+type Subscription struct {
+	a           *Autoupdate
+	uid         int
+	collections []string
+	subscribed  map[string]bool
+
+	changeID int
+	startID  int
+	started  bool
+
+	changes chan change
+	dropped chan struct{}
+}
+
+// Subscribe creates a subscription for a user, that knows the data until the
+// change id. If collections is not empty, only data of this collections is
+// returned.
+//
+// The subscription has to be closed with Close.
+func (a *Autoupdate) Subscribe(uid int, changeID int, collections []string) *Subscription {
+	subscribed := make(map[string]bool, len(collections))
+	for _, collection := range collections {
+		subscribed[collection] = true
+	}
+
+	a.fanout.mu.Lock()
+	defer a.fanout.mu.Unlock()
+
+	s := &Subscription{
+		a:           a,
+		uid:         uid,
+		collections: collections,
+		subscribed:  subscribed,
+		changeID:    changeID,
+		startID:     int(a.topic.LastID()),
+		changes:     make(chan change, a.fanout.buffer),
+		dropped:     make(chan struct{}),
+	}
+
+	if a.fanout.subscriptions == nil {
+		a.fanout.subscriptions = make(map[*Subscription]bool)
+	}
+	a.fanout.subscriptions[s] = true
+	return s
+}
+
+// Close removes the subscription from the update loop.
+func (s *Subscription) Close() {
+	s.a.fanout.mu.Lock()
+	defer s.a.fanout.mu.Unlock()
+	delete(s.a.fanout.subscriptions, s)
+}
+
+// Next returns the changed data since the last call. It is like
+// Autoupdate.ReceiveCollections, but the changed keys are received from the
+// update loop. It blocks until there is data of the subscribed collections,
+// the context is done or the service is closed.
+//
+// If the buffer of the subscription was full, an error with the method
+// SlowClient() is returned. The subscription can not be used anymore.
+func (s *Subscription) Next(ctx context.Context) (bool, map[string]json.RawMessage, int, error) {
+	if !s.started {
+		s.started = true
+
+		if s.changeID == 0 || s.changeID < s.a.datastore.LowestID() {
+			s.changeID = s.startID
+			return true, s.a.allData(ctx, s.uid, s.changeID, s.collections), s.changeID, nil
+		}
+
+		if s.changeID < s.startID {
+			// The client missed changes before the subscription was created.
+			// They are in the topic, so this does not block.
+			newChangeID, keys, err := s.a.changedKeys(ctx, s.changeID)
+			if err != nil {
+				return false, nil, 0, err
+			}
+
+			all, data, ok := s.changed(ctx, newChangeID, keys)
+			if ok {
+				return all, data, s.changeID, nil
+			}
+		}
+	}
+
+	for {
+		c, err := s.receive(ctx)
+		if err != nil {
+			return false, nil, 0, err
+		}
+
+		if c.reset {
+			s.changeID = c.changeID
+			return true, s.a.allData(ctx, s.uid, s.changeID, s.collections), s.changeID, nil
+		}
+
+		if c.changeID <= s.changeID {
+			// Already sent with the changes before the subscription.
+			continue
+		}
+
+		all, data, ok := s.changed(ctx, c.changeID, c.keys)
+		if ok {
+			return all, data, s.changeID, nil
+		}
+	}
+}
+
+// receive returns the next change. All other buffered changes are added to it,
+// so a client that is behind gets all changes at once.
+func (s *Subscription) receive(ctx context.Context) (change, error) {
+	// A dropped subscription has missed changes. The buffered changes are not
+	// enough.
+	select {
+	case <-s.dropped:
+		return change{}, slowClientError{uid: s.uid}
+	default:
+	}
+
+	var c change
+	select {
+	case <-s.dropped:
+		return change{}, slowClientError{uid: s.uid}
+	case <-s.a.closed:
+		return change{}, closingError{}
+	case <-ctx.Done():
+		return change{}, ctx.Err()
+	case c = <-s.changes:
+	}
+
+	for {
+		select {
+		case next := <-s.changes:
+			if next.reset {
+				c = next
+				continue
+			}
+			if !c.reset {
+				c.keys = append(c.keys[:len(c.keys):len(c.keys)], next.keys...)
+			}
+			c.changeID = next.changeID
+		default:
+			return c, nil
+		}
+	}
+}
+
+// changed returns the restricted data of the changed keys and sets the change
+// id of the subscription. Returns false, if none of the keys is in the
+// subscribed collections.
+func (s *Subscription) changed(ctx context.Context, newChangeID int, keys []string) (bool, map[string]json.RawMessage, bool) {
+	oldChangeID := s.changeID
+	s.changeID = newChangeID
+
+	if s.a.permissionsChanged(s.uid, oldChangeID, newChangeID) {
+		// The user can see other elements now. Only the changed keys are not
+		// enough.
+		return true, s.a.allData(ctx, s.uid, newChangeID, s.collections), true
+	}
+
+	if len(s.subscribed) > 0 {
+		filtered := keys[:0:0]
+		for _, key := range keys {
+			if s.subscribed[keyCollection(key)] {
+				filtered = append(filtered, key)
+			}
+		}
+		keys = filtered
+	}
+
+	if len(keys) == 0 {
+		return false, nil, false
+	}
+
+	seen := make(map[string]bool, len(keys))
+	uniqueKeys := keys[:0:0]
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			uniqueKeys = append(uniqueKeys, key)
+		}
+	}
+
+	data := s.a.getMany(ctx, uniqueKeys)
+	s.a.restrict(ctx, s.uid, data)
+	return false, data, true
+}
@@ -0,0 +1,136 @@
+package autoupdate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestSubscriptionFanout(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	for i := 1; i <= 5; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		datastore.FullData[key] = []byte(fmt.Sprintf(`"hello world%d"`, i))
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+	a.SetSubscriberBuffer(2)
+
+	fast := []*autoupdate.Subscription{
+		a.Subscribe(1, 1, nil),
+		a.Subscribe(2, 1, nil),
+	}
+	slow := a.Subscribe(3, 1, nil)
+	defer slow.Close()
+	for _, s := range fast {
+		defer s.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for i := 1; i <= 5; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		datastore.Change([]string{key})
+
+		for j, s := range fast {
+			all, data, changeID, err := s.Next(ctx)
+			if err != nil {
+				t.Fatalf("Next of consumer %d returned unexpected error: %v", j, err)
+			}
+
+			if all {
+				t.Errorf("Next returned all == true, expected false")
+			}
+
+			if changeID != i+1 {
+				t.Errorf("Next of consumer %d returned change id %d, expected %d", j, changeID, i+1)
+			}
+
+			if len(data) != 1 || data[key] == nil {
+				t.Errorf("Next of consumer %d returned %v, expected only %s", j, data, key)
+			}
+		}
+	}
+
+	_, _, _, err = slow.Next(ctx)
+	var slowClient interface {
+		SlowClient()
+	}
+	if !errors.As(err, &slowClient) {
+		t.Errorf("Next of the slow consumer returned error `%v`, expected a slow client error", err)
+	}
+}
+
+func TestSubscriptionCatchUp(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"user:1":   []byte(`"hello world1"`),
+		"user:2":   []byte(`"hello world2"`),
+		"motion:1": []byte(`"motion1"`),
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	datastore.Change([]string{"user:1"})
+	waitForChangeID(t, a, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	s := a.Subscribe(1, 1, []string{"user"})
+	defer s.Close()
+
+	_, data, changeID, err := s.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next returned unexpected error: %v", err)
+	}
+	if changeID != 2 || len(data) != 1 || data["user:1"] == nil {
+		t.Errorf("Next returned change id %d and data %v, expected 2 and user:1", changeID, data)
+	}
+
+	// Changes of other collections do not wake the subscription.
+	datastore.Change([]string{"motion:1"})
+	waitForChangeID(t, a, 3)
+	datastore.Change([]string{"user:2"})
+
+	_, data, changeID, err = s.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next returned unexpected error: %v", err)
+	}
+	if changeID != 4 || len(data) != 1 || data["user:2"] == nil {
+		t.Errorf("Next returned change id %d and data %v, expected 4 and user:2", changeID, data)
+	}
+}
+
+func waitForChangeID(t *testing.T, a *autoupdate.Autoupdate, changeID int) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for a.CurrentID() < changeID {
+		select {
+		case <-timeout:
+			t.Fatalf("Autoupdate did not reach change id %d", changeID)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
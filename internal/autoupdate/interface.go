@@ -16,7 +16,33 @@ type Datastore interface {
 	ProjectorData(ctx context.Context, tid uint64) (uint64, map[int]json.RawMessage, error)
 }
 
+// ContextDatastore is a Datastore with read methods, that add a span to the
+// trace of the context.
+type ContextDatastore interface {
+	Datastore
+	GetManyContext(ctx context.Context, keys []string) map[string]json.RawMessage
+	GetAllContext(ctx context.Context) map[string]json.RawMessage
+}
+
+// PermissionDatastore is a Datastore, that knows when the permissions of a
+// user have changed.
+type PermissionDatastore interface {
+	Datastore
+	PermissionsChanged(uid, from, to int) bool
+}
+
 // Restricter restricts data for one user.
 type Restricter interface {
 	Restrict(uid int, data map[string]json.RawMessage)
 }
+
+// SharedRestricter is a Restricter, that knows which restricted data can be
+// shared between users.
+//
+// Users with the same fingerprint see the same elements of the shared
+// collections. An empty fingerprint means, that nothing can be shared.
+type SharedRestricter interface {
+	Restricter
+	Fingerprint(uid int) string
+	Shared(collection string) bool
+}
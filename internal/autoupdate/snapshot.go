@@ -0,0 +1,154 @@
+package autoupdate
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sharedSnapshots holds the restricted data of the shared collections for each
+// fingerprint. The data is only valid for one change id.
+type sharedSnapshots struct {
+	mu        sync.Mutex
+	changeID  int
+	snapshots map[string]*sharedSnapshot
+}
+
+type sharedSnapshot struct {
+	once sync.Once
+	data map[string]json.RawMessage
+}
+
+// get returns the restricted data for the key and the change id. build is only
+// called once for each key and change id, also if get is called concurrently.
+//
+// The returned map must not be changed.
+func (s *sharedSnapshots) get(changeID int, key string, build func() map[string]json.RawMessage) map[string]json.RawMessage {
+	s.mu.Lock()
+	if changeID < s.changeID {
+		// The caller has old data. It can not be shared.
+		s.mu.Unlock()
+		return build()
+	}
+
+	if changeID > s.changeID || s.snapshots == nil {
+		s.changeID = changeID
+		s.snapshots = make(map[string]*sharedSnapshot)
+	}
+
+	snapshot, ok := s.snapshots[key]
+	if !ok {
+		snapshot = new(sharedSnapshot)
+		s.snapshots[key] = snapshot
+	}
+	s.mu.Unlock()
+
+	snapshot.once.Do(func() {
+		snapshot.data = build()
+	})
+	return snapshot.data
+}
+
+// restrictAll restricts all data of one change id for the user.
+//
+// If the restricter is a SharedRestricter, the elements of the shared
+// collections are only restricted once for all users with the same
+// fingerprint.
+func (a *Autoupdate) restrictAll(ctx context.Context, uid int, changeID int, collections []string, data map[string]json.RawMessage) {
+	sr, ok := a.restricter.(SharedRestricter)
+	if !ok {
+		a.restrict(ctx, uid, data)
+		return
+	}
+
+	fingerprint := sr.Fingerprint(uid)
+	if fingerprint == "" {
+		a.restrict(ctx, uid, data)
+		return
+	}
+
+	shared := make(map[string]json.RawMessage)
+	for key, value := range data {
+		if sr.Shared(keyCollection(key)) {
+			shared[key] = value
+			delete(data, key)
+		}
+	}
+
+	sorted := append(collections[:0:0], collections...)
+	sort.Strings(sorted)
+	snapshotKey := fingerprint + "|" + strings.Join(sorted, ",")
+
+	restricted := a.snapshots.get(changeID, snapshotKey, func() map[string]json.RawMessage {
+		a.restrict(ctx, uid, shared)
+		return shared
+	})
+
+	a.restrict(ctx, uid, data)
+	for key, value := range restricted {
+		data[key] = value
+	}
+}
+
+// WarmUp builds the shared snapshots for all groups, so the first users that
+// connect do not have to wait for them. It uses the anonymous user and the
+// first user of each fingerprint as representative. Only the snapshots for
+// clients without a collection filter are built.
+//
+// The warm-up stops, when the context is done. Returns the number of built
+// snapshots.
+func (a *Autoupdate) WarmUp(ctx context.Context) int {
+	sr, ok := a.restricter.(SharedRestricter)
+	if !ok {
+		return 0
+	}
+
+	changeID := a.CurrentID()
+	all := a.getAll(ctx)
+
+	uids := []int{0}
+	for key := range all {
+		if keyCollection(key) != "users/user" {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimPrefix(key, "users/user:"))
+		if err != nil {
+			continue
+		}
+		uids = append(uids, id)
+	}
+	sort.Ints(uids)
+
+	shared := make(map[string]json.RawMessage)
+	for key, value := range all {
+		if sr.Shared(keyCollection(key)) {
+			shared[key] = value
+		}
+	}
+
+	var count int
+	seen := make(map[string]bool)
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			break
+		}
+
+		fingerprint := sr.Fingerprint(uid)
+		if fingerprint == "" || seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+
+		data := make(map[string]json.RawMessage, len(shared))
+		for key, value := range shared {
+			data[key] = value
+		}
+		a.restrictAll(ctx, uid, changeID, nil, data)
+		count++
+	}
+	return count
+}
@@ -0,0 +1,88 @@
+package autoupdate
+
+import (
+	"time"
+)
+
+// ActivityDatastore is a Datastore, that tells when it read from redis the
+// last time and which change id redis has.
+type ActivityDatastore interface {
+	LastActivity() time.Time
+	RedisChangeID() (int, error)
+}
+
+// StartWatchdog checks, that the update loop is not stuck.
+//
+// The update loop is stuck, if there was no iteration of the loop and no read
+// from redis in the timeout, but redis has a higher change id then the service.
+// In this case, the error is logged and Alive returns false until the loop
+// works again. The watchdog only works, if the datastore is an
+// ActivityDatastore. Without it, a stuck loop can not be told from a time
+// without changes.
+//
+// The watchdog stops, when the service is closed.
+func (a *Autoupdate) StartWatchdog(timeout time.Duration) {
+	activity, ok := a.datastore.(ActivityDatastore)
+	if !ok {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.closed:
+				return
+			case <-ticker.C:
+			}
+
+			a.checkStuck(activity, timeout)
+		}
+	}()
+}
+
+// checkStuck sets the stuck flag of the update loop.
+func (a *Autoupdate) checkStuck(activity ActivityDatastore, timeout time.Duration) {
+	a.aliveMu.RLock()
+	last := a.lastIteration
+	wasStuck := a.stuck
+	a.aliveMu.RUnlock()
+
+	if read := activity.LastActivity(); read.After(last) {
+		last = read
+	}
+
+	var stuck bool
+	var redisChangeID int
+	if time.Since(last) > timeout {
+		var err error
+		redisChangeID, err = activity.RedisChangeID()
+		if err != nil {
+			// Without redis, the loop can not receive data. This is not a
+			// stuck loop.
+			a.log.Warn("Watchdog can not get the change id from redis", "error", err)
+		}
+		stuck = err == nil && redisChangeID > a.CurrentID()
+	}
+
+	if stuck && !wasStuck {
+		a.log.Error("Update loop is stuck", "since", last, "redis_change_id", redisChangeID, "change_id", a.CurrentID())
+	}
+
+	if !stuck && wasStuck {
+		a.log.Info("Update loop works again")
+	}
+
+	a.aliveMu.Lock()
+	a.stuck = stuck
+	a.aliveMu.Unlock()
+}
+
+// iterated tells the watchdog, that the update loop is working.
+func (a *Autoupdate) iterated() {
+	a.aliveMu.Lock()
+	defer a.aliveMu.Unlock()
+	a.lastIteration = time.Now()
+}
@@ -0,0 +1,118 @@
+package autoupdate_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestWatchdog(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		redisChangeID int
+		alive         bool
+	}{
+		{"stalled loop with changes in redis", 5, false},
+		{"no changes in redis", 1, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			closed := make(chan struct{})
+			defer close(closed)
+
+			// The mock never returns from KeysChanged, because no change is
+			// sent. This is like a read from redis that blocks forever.
+			datastore := &activityDatastore{
+				DatastoreMock: test.NewDatastoreMock(1, closed),
+				redisChangeID: tt.redisChangeID,
+			}
+
+			buf := new(bytes.Buffer)
+			a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.New(buf, slog.LevelInfo), closed)
+			if err != nil {
+				t.Fatalf("autoupdate startup failed: %v", err)
+			}
+			a.StartWatchdog(10 * time.Millisecond)
+
+			deadline := time.After(200 * time.Millisecond)
+			for a.Alive() {
+				select {
+				case <-deadline:
+					if !tt.alive {
+						t.Fatalf("Watchdog did not trip")
+					}
+					return
+				case <-time.After(time.Millisecond):
+				}
+			}
+
+			if tt.alive {
+				t.Errorf("Watchdog tripped, expected the service to be alive")
+			}
+
+			got := buf.String()
+			for _, expect := range []string{"Update loop is stuck", `"redis_change_id":5`, `"change_id":1`, `"since"`} {
+				if !strings.Contains(got, expect) {
+					t.Errorf("Log `%s` does not contain `%s`", got, expect)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchdogActivity(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := &activityDatastore{
+		DatastoreMock: test.NewDatastoreMock(1, closed),
+		redisChangeID: 5,
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+	a.StartWatchdog(20 * time.Millisecond)
+
+	// Reads from redis, for example heartbeats, keep the service alive.
+	for i := 0; i < 10; i++ {
+		datastore.read()
+		time.Sleep(5 * time.Millisecond)
+
+		if !a.Alive() {
+			t.Fatalf("Watchdog tripped while there was activity")
+		}
+	}
+}
+
+// activityDatastore is a DatastoreMock, that is also an ActivityDatastore.
+type activityDatastore struct {
+	*test.DatastoreMock
+	redisChangeID int
+
+	mu       sync.Mutex
+	lastRead time.Time
+}
+
+func (d *activityDatastore) read() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastRead = time.Now()
+}
+
+func (d *activityDatastore) LastActivity() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastRead
+}
+
+func (d *activityDatastore) RedisChangeID() (int, error) {
+	return d.redisChangeID, nil
+}
@@ -1,84 +1,109 @@
 package datastore
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"strconv"
-	"strings"
 	"sync"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 )
 
+// applauseDefaultTimeout is the applause timeout in seconds, if it is not set
+// in the config.
+const applauseDefaultTimeout = 5
+
 type applause struct {
-	c            *config
-	presentUsers map[int]bool
+	c  *config
+	ds interface {
+		Get(collection string, id int, v interface{}) error
+	}
+	log logger.Logger
 
-	mu          sync.RWMutex
-	waitSeconds int
-	base        int
+	mu           sync.RWMutex
+	presentUsers map[int]bool
+	level        int
+	updated      time.Time
 }
 
-func (a *applause) update(data map[string]json.RawMessage) error {
+// usersChanged updates the present users. It is subscribed to the collection
+// users/user.
+func (a *applause) usersChanged(changed []int, deleted []int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	if a.presentUsers == nil {
 		a.presentUsers = make(map[int]bool)
 	}
 
-	var applauseTimeout int
-	if err := a.c.ConfigValue("general_system_stream_applause_timeout", &applauseTimeout); err != nil {
-		var d doesNotExistError
-		if !errors.As(err, &d) {
-			return fmt.Errorf("getting applause timeout: %w", err)
-		}
-		applauseTimeout = 5
+	for _, userID := range deleted {
+		delete(a.presentUsers, userID)
 	}
 
-	for elementID, v := range data {
-		parts := strings.Split(elementID, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("key %s has wrong format. Expected one `:`", elementID)
+	for _, userID := range changed {
+		var user struct {
+			Present bool `json:"is_present"`
 		}
-
-		if parts[0] != "users/user" {
+		if err := a.ds.Get("users/user", userID, &user); err != nil {
+			a.log.Error("Can not update applause (active users)", "user_id", userID, "error", err)
 			continue
 		}
 
-		userID, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return fmt.Errorf("getting userID for %s: %w", parts[1], err)
-		}
-
-		if v == nil {
-			a.presentUsers[userID] = false
+		if !user.Present {
+			delete(a.presentUsers, userID)
 			continue
 		}
+		a.presentUsers[userID] = true
+	}
+}
 
-		var user struct {
-			Present bool `json:"is_present"`
-		}
-		if err := json.Unmarshal(v, &user); err != nil {
-			return fmt.Errorf("decoding user %d: %w", userID, err)
-		}
+func (a *applause) ApplauseConfig() (waitTime int, base int) {
+	applauseTimeout, err := Config{c: a.c}.Int("general_system_stream_applause_timeout")
+	if err != nil {
+		a.log.Error("Can not get applause timeout", "error", err)
+	}
+	if applauseTimeout <= 0 {
+		applauseTimeout = applauseDefaultTimeout
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return applauseTimeout, len(a.presentUsers)
+}
 
-		a.presentUsers[userID] = user.Present
+// ApplauseEnabled tells, if applause is enabled in the config.
+func (a *applause) ApplauseEnabled() bool {
+	enabled, err := Config{c: a.c}.Bool("general_system_applause_enable")
+	if err != nil {
+		a.log.Error("Can not get applause enabled", "error", err)
 	}
+	return enabled
+}
 
+// ApplauseReceived saves the current applause level. It is called by notify
+// each time the applause is calculated.
+func (a *applause) ApplauseReceived(level int) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.waitSeconds = applauseTimeout
+	a.level = level
+	a.updated = time.Now()
+}
 
-	a.base = 0
-	for _, present := range a.presentUsers {
-		if present {
-			a.base++
-		}
-	}
-	return nil
+// ResetApplause sets the applause level to 0 and the time to zero, like it was
+// never calculated.
+func (a *applause) ResetApplause() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.level = 0
+	a.updated = time.Time{}
 }
 
-func (a *applause) ApplauseConfig() (waitTime int, base int) {
+// CurrentApplause returns the last applause level and the time it was
+// calculated. The time is zero, if the applause was never calculated.
+func (a *applause) CurrentApplause() (level int, updated time.Time) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	return a.waitSeconds, a.base
+	return a.level, a.updated
 }
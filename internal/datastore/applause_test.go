@@ -3,35 +3,101 @@ package datastore
 import (
 	"encoding/json"
 	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 )
 
 func TestApplause(t *testing.T) {
-	a := &applause{c: new(config)}
+	c := new(cache)
+	a := &applause{c: new(config), ds: &Datastore{cache: c}, log: logger.Noop}
+	var s subscriptions
+	s.Subscribe("users/user", a.usersChanged)
 
 	t.Run("create user", func(t *testing.T) {
 		data := map[string]json.RawMessage{
 			"users/user:1": []byte(`{"is_present":true}`),
+			"users/user:2": []byte(`{"is_present":false}`),
 		}
-		if err := a.update(data); err != nil {
-			t.Fatalf("Got unexpected error: %v", err)
-		}
+		c.update(data, 1)
+		s.dispatch(data, logger.Noop)
 
 		if !a.presentUsers[1] {
 			t.Error("User is not present")
 		}
+
+		if _, base := a.ApplauseConfig(); base != 1 {
+			t.Errorf("Got applause base %d, expected 1", base)
+		}
 	})
 
 	t.Run("delete user", func(t *testing.T) {
 		data := map[string]json.RawMessage{
 			"users/user:1": nil,
 		}
-		if err := a.update(data); err != nil {
-			t.Fatalf("Got unexpected error: %v", err)
-		}
+		c.update(data, 2)
+		s.dispatch(data, logger.Noop)
 
 		if a.presentUsers[1] {
 			t.Error("User is present")
 		}
+
+		if _, base := a.ApplauseConfig(); base != 0 {
+			t.Errorf("Got applause base %d, expected 0", base)
+		}
 	})
 
+	t.Run("default timeout", func(t *testing.T) {
+		if waitTime, _ := a.ApplauseConfig(); waitTime != applauseDefaultTimeout {
+			t.Errorf("Got applause timeout %d, expected %d", waitTime, applauseDefaultTimeout)
+		}
+	})
+}
+
+func TestApplauseEnabled(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		config  string
+		enabled bool
+	}{
+		{"enabled", `{"key": "general_system_applause_enable", "value": true}`, true},
+		{"disabled", `{"key": "general_system_applause_enable", "value": false}`, false},
+		{"not set", `{"key": "general_event_name", "value": "OpenSlides"}`, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := new(config)
+			if err := c.update(map[string]json.RawMessage{"core/config:1": []byte(tt.config)}); err != nil {
+				t.Fatalf("Can not update config: %v", err)
+			}
+			a := &applause{c: c, log: logger.Noop}
+
+			if got := a.ApplauseEnabled(); got != tt.enabled {
+				t.Errorf("ApplauseEnabled returned %t, expected %t", got, tt.enabled)
+			}
+		})
+	}
+}
+
+func TestCurrentApplause(t *testing.T) {
+	a := &applause{c: new(config), log: logger.Noop}
+
+	if level, updated := a.CurrentApplause(); level != 0 || !updated.IsZero() {
+		t.Errorf("CurrentApplause returned %d, %s, expected 0 and zero time", level, updated)
+	}
+
+	a.ApplauseReceived(7)
+
+	if level, updated := a.CurrentApplause(); level != 7 || updated.IsZero() {
+		t.Errorf("CurrentApplause returned %d, %s, expected 7 and a time", level, updated)
+	}
+}
+
+func TestResetApplause(t *testing.T) {
+	a := &applause{c: new(config), log: logger.Noop}
+	a.ApplauseReceived(7)
+
+	a.ResetApplause()
+
+	if level, updated := a.CurrentApplause(); level != 0 || !updated.IsZero() {
+		t.Errorf("CurrentApplause returned %d, %s, expected 0 and zero time", level, updated)
+	}
 }
@@ -0,0 +1,118 @@
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// breaker stops the requests for missing data to redis and the worker for a
+// cooldown, after they failed threshold times in a row. Meanwhile the cache
+// is served with the old data.
+//
+// After the cooldown, one request is allowed as probe. If it succeeds, the
+// breaker is closed again. Otherwise it stays open for another cooldown.
+//
+// A threshold of 0 disables the breaker.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	// now returns the current time. It can be replaced in tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow returns a breakerOpenError, if no request should be sent at the
+// moment.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return nil
+	}
+
+	if wait := b.cooldown - b.now().Sub(b.openedAt); b.probing || wait > 0 {
+		return breakerOpenError{failures: b.failures, retryAfter: wait}
+	}
+
+	b.probing = true
+	return nil
+}
+
+// done has to be called with the result of each request that was allowed.
+func (b *breaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	var closing interface {
+		Closing()
+	}
+	if err == nil || errors.As(err, &closing) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// open tells, if the breaker stops the requests.
+func (b *breaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// breakerDone records the result of a request for the breaker and logs, when
+// the breaker opens or closes.
+func (d *Datastore) breakerDone(err error) {
+	wasOpen := d.breaker.open()
+	d.breaker.done(err)
+
+	switch isOpen := d.breaker.open(); {
+	case !wasOpen && isOpen:
+		d.log.Warn("Stop requesting missing data after too many failures", "failures", d.breaker.threshold, "cooldown", d.breaker.cooldown, "error", err)
+	case wasOpen && !isOpen:
+		d.log.Info("Requesting missing data works again")
+	}
+}
+
+// SetBreaker configures the circuit breaker for the requests of missing data
+// to redis and the worker. After threshold failed requests in a row, no
+// request is sent for the cooldown. A threshold of 0 disables the breaker. It
+// has to be called before KeysChanged.
+func (d *Datastore) SetBreaker(threshold int, cooldown time.Duration) {
+	d.breaker.threshold = threshold
+	d.breaker.cooldown = cooldown
+}
+
+// Degraded tells, if the datastore does not request missing data because of
+// too many failures. In this case, the cached data is probably outdated.
+func (d *Datastore) Degraded() bool {
+	return d.breaker.open()
+}
+
+// breakerOpenError is returned, when a request is stopped by the breaker.
+type breakerOpenError struct {
+	failures   int
+	retryAfter time.Duration
+}
+
+func (e breakerOpenError) Error() string {
+	if e.retryAfter <= 0 {
+		return fmt.Sprintf("requests stopped after %d failures, waiting for the probe", e.failures)
+	}
+	return fmt.Sprintf("requests stopped after %d failures, retry in %s", e.failures, e.retryAfter.Round(time.Millisecond))
+}
@@ -1,33 +1,167 @@
 package datastore
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
 type cache struct {
-	mu   sync.RWMutex
-	data map[string]json.RawMessage
+	mu       sync.RWMutex
+	data     map[string]json.RawMessage
+	changeID int
+
+	// counts is the number of elements of each collection.
+	counts map[string]int
+
+	// bytes is the sum of the length of all elements.
+	bytes int64
+
+	// deleted are the keys of deleted elements. They are kept until the
+	// cache is reset.
+	deleted map[string]bool
+
+	// keyChangeIDs is the change id of the last change of each key. This
+	// includes deleted keys.
+	keyChangeIDs map[string]int
+}
+
+// update updates the cache with the changed data of the change id.
+func (c *cache) update(changed map[string]json.RawMessage, changeID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(changed, changeID)
 }
 
-func (c *cache) update(changed map[string]json.RawMessage) {
+// replace removes all data from the cache and sets the new data.
+//
+// It is done with one lock, so a reader sees the old or the new data, but never
+// an empty cache.
+func (c *cache) replace(data map[string]json.RawMessage, changeID int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.data = nil
+	c.bytes = 0
+	c.set(data, changeID)
+}
+
+// set updates the data. It has to be called with the write lock.
+func (c *cache) set(changed map[string]json.RawMessage, changeID int) {
 	if c.data == nil {
 		c.data = make(map[string]json.RawMessage)
+		c.counts = make(map[string]int)
+		c.deleted = make(map[string]bool)
+		c.keyChangeIDs = make(map[string]int)
 	}
+	c.changeID = changeID
 
 	for k, v := range changed {
+		old, exists := c.data[k]
+		c.bytes -= int64(len(old))
 		if v == nil {
-			delete(c.data, k)
+			if exists || !c.deleted[k] {
+				c.keyChangeIDs[k] = changeID
+			}
+			if exists {
+				delete(c.data, k)
+				c.decrementCount(k)
+			}
+			c.deleted[k] = true
 			continue
 		}
 
+		// Redis sends all elements of a change, also the elements, that are
+		// saved again without a change.
+		if !exists || !bytes.Equal(old, v) {
+			c.keyChangeIDs[k] = changeID
+		}
+
+		if !exists {
+			c.counts[strings.Split(k, ":")[0]]++
+		}
 		c.data[k] = v
+		c.bytes += int64(len(v))
+		delete(c.deleted, k)
+	}
+}
+
+// decrementCount decrements the count of the collection of the key. It has to
+// be called with the write lock.
+func (c *cache) decrementCount(key string) {
+	collection := strings.Split(key, ":")[0]
+	c.counts[collection]--
+	if c.counts[collection] <= 0 {
+		delete(c.counts, collection)
 	}
 }
 
+// count returns the number of elements of the collection.
+func (c *cache) count(collection string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.counts[collection]
+}
+
+// allCounts returns the number of elements of each collection.
+func (c *cache) allCounts() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	counts := make(map[string]int, len(c.counts))
+	for collection, count := range c.counts {
+		counts[collection] = count
+	}
+	return counts
+}
+
+// len returns the number of elements. Deleted elements are not counted.
+func (c *cache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.data)
+}
+
+// bytesEstimate returns the sum of the length of all elements.
+func (c *cache) bytesEstimate() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.bytes
+}
+
+// deletedCount returns the number of deleted keys.
+func (c *cache) deletedCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.deleted)
+}
+
+// keyChangeID returns the change id of the last change of the key. Returns
+// false, if the key is unknown.
+func (c *cache) keyChangeID(key string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	changeID, ok := c.keyChangeIDs[key]
+	return changeID, ok
+}
+
+// raw returns one element from the cache and if the key is deleted.
+func (c *cache) raw(key string) (json.RawMessage, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.data[key], c.deleted[key]
+}
+
 // get returns one element from the cache.
 //
 // Creates NOT a copy of all data. TODO: Is it neccessary to make a copy?
@@ -40,8 +174,8 @@ func (c *cache) get(key string) json.RawMessage {
 
 // forkeys returns all data for the given keys.
 //
-// If a key does not exist in the cache, the returned data contains that key
-// with a nil value.
+// A deleted key is returned with a nil value. A key that never existed is not
+// in the returned data.
 //
 // Creates a copy of all data. TODO: Is it neccessary to make a copy?
 func (c *cache) forKeys(keys ...string) map[string]json.RawMessage {
@@ -50,12 +184,84 @@ func (c *cache) forKeys(keys ...string) map[string]json.RawMessage {
 
 	data := make(map[string]json.RawMessage, len(keys))
 	for _, key := range keys {
-		v := c.data[key]
+		v, ok := c.data[key]
+		if !ok {
+			if c.deleted[key] {
+				data[key] = nil
+			}
+			continue
+		}
 		data[key] = append(v[:0:0], v...)
 	}
 	return data
 }
 
+// forModels returns the elements for the given ids of each collection.
+//
+// Elements that do not exist are skipped. Each collection of models is in the
+// result, even if there are no elements.
+//
+// Creates a copy of all data. TODO: Is it neccessary to make a copy?
+func (c *cache) forModels(models map[string][]int) map[string][]json.RawMessage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data := make(map[string][]json.RawMessage, len(models))
+	for collection, ids := range models {
+		elements := make([]json.RawMessage, 0, len(ids))
+		for _, id := range ids {
+			v, ok := c.data[collection+":"+strconv.Itoa(id)]
+			if !ok {
+				continue
+			}
+			elements = append(elements, append(v[:0:0], v...))
+		}
+		data[collection] = elements
+	}
+	return data
+}
+
+// keys returns the sorted keys of all elements, for which filter returns true.
+// Keys that are not in the format collection:id are skipped.
+func (c *cache) keys(filter func(collection string, id int) bool) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []string
+	for key := range c.data {
+		parts := strings.Split(key, ":")
+		if len(parts) != 2 {
+			continue
+		}
+
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+
+		if filter(parts[0], id) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// snapshot returns all data and the change id of the data.
+//
+// Creates a copy of the map, but not of the values. The values are never
+// changed by the cache. They are only replaced.
+func (c *cache) snapshot() (map[string]json.RawMessage, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data := make(map[string]json.RawMessage, len(c.data))
+	for k, v := range c.data {
+		data[k] = v
+	}
+	return data, c.changeID
+}
+
 // all returns all data from the cache.
 //
 // Creates a copy of all data. TODO: Is it neccessary to make a copy?
@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -54,8 +55,61 @@ func (c *config) ConfigValue(key string, v interface{}) error {
 	defer c.mu.RUnlock()
 
 	if _, ok := c.values[key]; !ok {
-		return doesNotExistError(fmt.Sprintf("config `%s` does not exist.", key))
+		return DoesNotExistError(fmt.Sprintf("config `%s` does not exist.", key))
 	}
 
 	return json.Unmarshal(c.values[key], v)
 }
+
+// Config returns the typed config values.
+func (d *Datastore) Config() Config {
+	return Config{c: &d.config}
+}
+
+// Config reads config values with a specific type.
+//
+// If a config value does not exist or is null, the zero value of the type is
+// returned. If the config value has another type, a ConfigTypeError is
+// returned.
+type Config struct {
+	c *config
+}
+
+// Bool returns a config value of type bool.
+func (c Config) Bool(key string) (bool, error) {
+	var v bool
+	err := c.value(key, "bool", &v)
+	return v, err
+}
+
+// Int returns a config value of type int.
+func (c Config) Int(key string) (int, error) {
+	var v int
+	err := c.value(key, "int", &v)
+	return v, err
+}
+
+// String returns a config value of type string.
+func (c Config) String(key string) (string, error) {
+	var v string
+	err := c.value(key, "string", &v)
+	return v, err
+}
+
+func (c Config) value(key string, typeName string, v interface{}) error {
+	err := c.c.ConfigValue(key, v)
+	if err == nil {
+		return nil
+	}
+
+	var dErr DoesNotExistError
+	if errors.As(err, &dErr) {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return ConfigTypeError{Key: key, Expected: typeName, Got: typeErr.Value}
+	}
+	return fmt.Errorf("decoding config `%s`: %w", key, err)
+}
@@ -0,0 +1,84 @@
+package datastore_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestConfig(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"core/config:1": []byte(`{"key": "bool_value", "value": true}`),
+		"core/config:2": []byte(`{"key": "int_value", "value": 42}`),
+		"core/config:3": []byte(`{"key": "string_value", "value": "hello"}`),
+		"core/config:4": []byte(`{"key": "string_one", "value": "1"}`),
+		"core/config:5": []byte(`{"key": "null_value", "value": null}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+	config := ds.Config()
+
+	t.Run("Bool", func(t *testing.T) {
+		got, err := config.Bool("bool_value")
+		if err != nil || !got {
+			t.Errorf("Bool returned %t, %v, expected true", got, err)
+		}
+	})
+
+	t.Run("Int", func(t *testing.T) {
+		got, err := config.Int("int_value")
+		if err != nil || got != 42 {
+			t.Errorf("Int returned %d, %v, expected 42", got, err)
+		}
+	})
+
+	t.Run("String", func(t *testing.T) {
+		got, err := config.String("string_value")
+		if err != nil || got != "hello" {
+			t.Errorf("String returned %q, %v, expected hello", got, err)
+		}
+	})
+
+	t.Run("Missing keys", func(t *testing.T) {
+		if got, err := config.Bool("unknown"); err != nil || got {
+			t.Errorf("Bool returned %t, %v, expected false", got, err)
+		}
+
+		if got, err := config.Int("unknown"); err != nil || got != 0 {
+			t.Errorf("Int returned %d, %v, expected 0", got, err)
+		}
+
+		if got, err := config.String("unknown"); err != nil || got != "" {
+			t.Errorf("String returned %q, %v, expected an empty string", got, err)
+		}
+	})
+
+	t.Run("Null value", func(t *testing.T) {
+		if got, err := config.Int("null_value"); err != nil || got != 0 {
+			t.Errorf("Int returned %d, %v, expected 0", got, err)
+		}
+	})
+
+	t.Run("Type mismatch", func(t *testing.T) {
+		_, err := config.Bool("string_one")
+
+		var typeErr datastore.ConfigTypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("Bool returned error `%v`, expected a ConfigTypeError", err)
+		}
+
+		if typeErr.Key != "string_one" || typeErr.Expected != "bool" || typeErr.Got != "string" {
+			t.Errorf("Got error %v, expected key string_one, type bool and value string", typeErr)
+		}
+	})
+}
@@ -3,64 +3,202 @@ package datastore
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/projector"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+)
+
+var invalidElementCount, _ = global.GetMeterProvider().Meter("openslides.org").NewInt64Counter(
+	"openslides.invalid-element-count",
+	metric.WithDescription("elements from redis that were skipped, because they are not valid json"),
 )
 
 // Datastore holds the connection to OpenSlides and Redis.
 type Datastore struct {
-	redisConn   RedisConn
-	cache       *cache
-	minChangeID int
-	closed      <-chan struct{}
+	redisConn  RedisConn
+	worker     Worker
+	restricter Restricter
+	closed     <-chan struct{}
+	log        logger.Logger
+
+	// cache is created in New and never replaced, so it can be used without
+	// the lock of the datastore. It has its own lock.
+	cache *cache
+
+	// updateLog writes the message for each update. It can be sampled, so
+	// many updates do not flood the log.
+	updateLog logger.Logger
+
+	// coalesceWindow is the time to wait for more updates from redis after an
+	// update. 0 means, that each update is handled on its own.
+	coalesceWindow time.Duration
+
+	// maxChangeIDJump is the highest distance of a change id from redis to
+	// the current change id. 0 means no limit.
+	maxChangeIDJump int
+
+	// drifts receives the differences found by Reconcile. repairedKeys are
+	// the keys that were repaired and are returned with the next update.
+	drifts       chan drift
+	repairedKeys []string
+
+	// breaker stops receive and reset after too many failures.
+	breaker breaker
+
+	mu             sync.RWMutex
+	minChangeID    int
+	maxChangeID    int
+	redisConnected bool
+	resetting      bool
 
-	mu          sync.RWMutex
-	maxChangeID int
+	// changeIDChanged is closed and replaced, when maxChangeID is set.
+	changeIDChanged chan struct{}
+
+	// lastRead is the time, the last read from redis returned.
+	lastRead time.Time
 
 	hasPerm
 	requiredUser
 	*Projectors
 	config
 	*applause
+	subscriptions
+	watchers watchers
+
+	// plugins are the plugins, that were registered, when the datastore was
+	// created.
+	plugins []Plugin
+
+	// subsystems are updated on each update in this order.
+	subsystems []subsystem
+}
+
+// subsystem is a part of the datastore that has to be updated with the changed
+// data.
+type subsystem struct {
+	name   string
+	update func(data map[string]json.RawMessage) error
 }
 
 // New returns an initialized Datastore instance.
-func New(redisConn RedisConn, requiredUsers map[string]func(json.RawMessage) (map[int]bool, string, error), projectorSlides map[string]projector.Callable, closed <-chan struct{}) (*Datastore, error) {
-	fd, max, min, err := redisConn.FullData()
-	if err != nil {
-		return nil, fmt.Errorf("get startdata from redis: %w", err)
+func New(redisConn RedisConn, requiredUsers map[string]func(json.RawMessage) (map[int]bool, string, error), projectorSlides map[string]projector.Callable, log logger.Logger, closed <-chan struct{}) (*Datastore, error) {
+	d := newDatastore(redisConn, requiredUsers, projectorSlides, log, closed)
+
+	if err := d.loadFullData(); err != nil {
+		return nil, err
 	}
 
+	return d, nil
+}
+
+func newDatastore(redisConn RedisConn, requiredUsers map[string]func(json.RawMessage) (map[int]bool, string, error), projectorSlides map[string]projector.Callable, log logger.Logger, closed <-chan struct{}) *Datastore {
+	plugins := registeredPlugins()
+
 	d := &Datastore{
-		redisConn:    redisConn,
-		cache:        new(cache),
-		minChangeID:  min,
-		maxChangeID:  max,
-		requiredUser: requiredUser{callables: requiredUsers},
-		closed:       closed,
+		redisConn:      redisConn,
+		cache:          new(cache),
+		requiredUser:   requiredUser{callables: pluginRequiredUsers(requiredUsers, plugins, log)},
+		closed:         closed,
+		log:            log,
+		updateLog:      log,
+		redisConnected: true,
+		drifts:         make(chan drift),
+		plugins:        plugins,
 	}
+	d.breaker.now = time.Now
+	d.hasPerm.config = &d.config
 
-	d.applause = &applause{c: &d.config}
+	d.applause = &applause{c: &d.config, ds: d, log: log}
+	d.Subscribe("users/user", d.applause.usersChanged)
 
 	// TODO: fix circular dependency between datastore and projector.
-	d.Projectors = NewProjectors(d, projectorSlides, closed)
+	d.Projectors = NewProjectors(d, projectorSlides, log, closed)
+
+	d.subsystems = []subsystem{
+		{"user permissions", d.hasPerm.update},
+		{"required users", d.requiredUser.update},
+		{"config values", d.config.update},
+		{"projector slides", d.Projectors.Update},
+	}
+
+	for _, p := range plugins {
+		if p.Update != nil {
+			d.subsystems = append(d.subsystems, subsystem{"plugin " + p.Name, p.Update})
+		}
+	}
+
+	d.subsystems = append(d.subsystems, subsystem{"subscriptions", func(data map[string]json.RawMessage) error {
+		d.dispatch(data, d.log)
+		return nil
+	}})
+	return d
+}
 
+// loadFullData initializes the datastore with all data from redis.
+func (d *Datastore) loadFullData() error {
+	fd, max, min, err := d.redisConn.FullData()
+	if err != nil {
+		return fmt.Errorf("get startdata from redis: %w", err)
+	}
+
+	d.setMinChangeID(min)
 	if err := d.update(fd, max); err != nil {
-		return nil, fmt.Errorf("initial datastore update: %w", err)
+		return fmt.Errorf("initial datastore update: %w", err)
 	}
+	return nil
+}
 
-	return d, nil
+// SetWorker sets a worker, that is used when redis can not return the missing
+// data between two change ids. It has to be called before KeysChanged.
+func (d *Datastore) SetWorker(w Worker) {
+	d.worker = w
+}
+
+// SetCoalesceWindow sets the time to wait for more updates from redis after an
+// update. All updates in this time are merged to one update. It has to be
+// called before KeysChanged.
+func (d *Datastore) SetCoalesceWindow(window time.Duration) {
+	d.coalesceWindow = window
+}
+
+// SetMaxChangeIDJump sets the highest distance of a change id from redis to the
+// current change id. Messages with a higher change id are skipped. 0 means no
+// limit. It has to be called before KeysChanged.
+//
+// OpenSlides creates much higher change ids after redis was flushed. If redis
+// returns such a change id, the message is not skipped but the datastore is
+// reset.
+func (d *Datastore) SetMaxChangeIDJump(jump int) {
+	d.maxChangeIDJump = jump
+}
+
+// SetUpdateLogSampling samples the log message, that is written for each
+// update. It is written at most once per interval or for every n-th update. 0
+// for both values means, that the message is written for each update.
+//
+// Resets and errors are always logged.
+func (d *Datastore) SetUpdateLogSampling(interval time.Duration, every int) {
+	d.updateLog = logger.Sampled(d.log, interval, every)
 }
 
 // LowestID returns the lowest id in the datastore.
 func (d *Datastore) LowestID() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	return d.minChangeID
 }
 
@@ -72,72 +210,264 @@ func (d *Datastore) CurrentID() int {
 	return d.maxChangeID
 }
 
-// KeysChanged blocks until there is new data. It updates the internal cache and
-// returns the changed keys and the new change id.
+// WaitForChangeID blocks until the datastore has processed the change id.
+// Returns immediately if the id is already processed.
 //
-// If the datastore is closed then it return nil, 0, nil.
-func (d *Datastore) KeysChanged() ([]string, int, error) {
-	rawData, err := d.redisConn.Update(d.closed)
-	if err != nil {
-		return nil, 0, fmt.Errorf("get autoupdate data: %w", err)
-	}
-	if len(rawData) == 0 {
-		return nil, 0, fmt.Errorf("redis returnd empty data. This should never happen. Please cry for help")
+// Returns an error if the context is done or the datastore is closed before.
+func (d *Datastore) WaitForChangeID(ctx context.Context, id int) error {
+	for {
+		d.mu.Lock()
+		if d.maxChangeID >= id {
+			d.mu.Unlock()
+			return nil
+		}
+
+		if d.changeIDChanged == nil {
+			d.changeIDChanged = make(chan struct{})
+		}
+		changed := d.changeIDChanged
+		d.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for change id %d: %w", id, ctx.Err())
+		case <-d.closed:
+			return closingError{}
+		}
 	}
+}
 
-	var sData struct {
-		Elements map[string]json.RawMessage `json:"elements"`
-		ChangeID int                        `json:"change_id"`
+// setMaxChangeID sets the change id and wakes all calls to WaitForChangeID.
+//
+// Has to be called with the write lock.
+func (d *Datastore) setMaxChangeID(id int) {
+	d.maxChangeID = id
+	if d.changeIDChanged != nil {
+		close(d.changeIDChanged)
+		d.changeIDChanged = nil
 	}
+}
 
-	if err := json.Unmarshal(rawData, &sData); err != nil {
-		return nil, 0, fmt.Errorf("parse data from redis: %w", err)
+// LastActivity returns the time, the last read of the updates from redis
+// returned. It is the zero time, if there was no read yet.
+func (d *Datastore) LastActivity() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.lastRead
+}
+
+// RedisChangeID returns the newest change id in redis.
+func (d *Datastore) RedisChangeID() (int, error) {
+	max, _, err := d.redisConn.ChangeIDs()
+	if err != nil {
+		return 0, fmt.Errorf("get change ids from redis: %w", err)
 	}
+	return max, nil
+}
+
+// Ready tells, if the datastore has a connection to redis and is not reset at
+// the moment.
+func (d *Datastore) Ready() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	changeID := sData.ChangeID
-	keys := make([]string, 0, len(sData.Elements))
-	for k, v := range sData.Elements {
-		if bytes.Equal(v, []byte(`null`)) {
-			// Deleted elements.
-			sData.Elements[k] = nil
+	return d.redisConnected && !d.resetting
+}
+
+// KeysChanged blocks until there is new data. It updates the internal cache and
+// returns the changed keys and the new change id.
+//
+// If a coalesce window is set, all updates from redis in this window are
+// merged to one update.
+//
+// Elements repaired by Reconcile are returned with the next update.
+//
+// Empty reads from redis do not contain new data. KeysChanged waits for the
+// next update in this case. Messages with an invalid change id are logged and
+// skipped.
+//
+// If the datastore is closed then it return nil, 0, nil.
+func (d *Datastore) KeysChanged() ([]string, int, error) {
+	for {
+		rawData, err := d.nextUpdate()
+		if err != nil {
+			var closing interface {
+				Closing()
+			}
+			var reset interface {
+				Reset()
+			}
+			if errors.As(err, &reset) {
+				return nil, 0, err
+			}
+			if !errors.As(err, &closing) {
+				d.setRedisConnected(false)
+			}
+			return nil, 0, fmt.Errorf("get autoupdate data: %w", err)
 		}
-		keys = append(keys, k)
-	}
+		d.setRedisConnected(true)
 
-	if changeID > d.maxChangeID+1 {
-		// Data is to new. Get the data in between.
-		if changeID > d.maxChangeID+100 {
-			// Data is match to new. Probably redis was reset.
-			if err := d.reset(); err != nil {
-				return nil, 0, fmt.Errorf("reset: %w", err)
+		messages := [][]byte{rawData}
+		if d.coalesceWindow > 0 {
+			coalesced, err := d.coalesce()
+			if err != nil {
+				return nil, 0, err
 			}
-			return nil, 0, resetError{}
+			messages = append(messages, coalesced...)
 		}
 
-		data, err := d.receive(d.maxChangeID, changeID-1)
-		if err != nil {
-			return nil, 0, fmt.Errorf("receive missing data from %d to %d: %w", d.maxChangeID, changeID-1, err)
+		// A key can be changed in more then one message or in the missing data and
+		// in the new data. Each key is only returned once.
+		elements := make(map[string]json.RawMessage)
+		var keys []string
+		seen := make(map[string]bool)
+		addKey := func(key string) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
 		}
 
-		for k := range data {
-			keys = append(keys, k)
+		changeID := d.maxChangeID
+		for _, rawData := range messages {
+			if len(rawData) == 0 {
+				// Redis can return an empty read, for example after a timeout of
+				// the stream. There is no new data in it.
+				continue
+			}
+
+			var sData struct {
+				Elements map[string]json.RawMessage `json:"elements"`
+				ChangeID json.RawMessage            `json:"change_id"`
+			}
+
+			if err := json.Unmarshal(rawData, &sData); err != nil {
+				return nil, 0, fmt.Errorf("parse data from redis: %w", err)
+			}
+
+			newChangeID, err := d.parseChangeID(sData.ChangeID, changeID)
+			var jump changeIDJumpError
+			if errors.As(err, &jump) {
+				// The jump could be a flushed redis. In this case, redis has the
+				// new change id and the datastore has to be reset.
+				max, _, err := d.redisConn.ChangeIDs()
+				if err != nil {
+					return nil, 0, fmt.Errorf("get change ids from redis: %w", err)
+				}
+
+				if max >= jump.changeID {
+					d.log.Info("Redis has a change id over the jump limit, reset", "change_id", jump.changeID, "current", changeID)
+					if err := d.reset(); err != nil {
+						return nil, 0, fmt.Errorf("reset: %w", err)
+					}
+					return nil, 0, resetError{}
+				}
+			}
+
+			if err != nil {
+				// A broken message must not change the change id. Skip it.
+				d.log.Warn("Skipping message from redis with invalid change id", "change_id", string(sData.ChangeID), "error", err)
+				continue
+			}
+
+			if newChangeID < changeID+1 {
+				// Data already known. Try the next.
+				continue
+			}
+
+			for k, v := range sData.Elements {
+				if bytes.Equal(v, []byte(`null`)) {
+					// Deleted elements.
+					sData.Elements[k] = nil
+				}
+				addKey(k)
+			}
+
+			if newChangeID > changeID+1 {
+				// Data is to new. Get the data in between.
+				if newChangeID > changeID+100 {
+					// Data is match to new. Probably redis was reset.
+					if err := d.reset(); err != nil {
+						return nil, 0, fmt.Errorf("reset: %w", err)
+					}
+					return nil, 0, resetError{}
+				}
+
+				data, err := d.receive(changeID, newChangeID-1)
+				if err != nil {
+					return nil, 0, fmt.Errorf("receive missing data from %d to %d: %w", changeID, newChangeID-1, err)
+				}
+
+				for k, v := range data {
+					addKey(k)
+					elements[k] = v
+				}
+			}
+
+			for k, v := range sData.Elements {
+				elements[k] = v
+			}
+			changeID = newChangeID
 		}
 
-		if err := d.update(data, changeID-1); err != nil {
-			return nil, 0, fmt.Errorf("updating cache from %d to %d: %w", d.maxChangeID, changeID-1, err)
+		if changeID == d.maxChangeID {
+			// All data was already known or the reads were empty.
+			select {
+			case <-d.closed:
+				return nil, 0, closingError{}
+			default:
+			}
+			continue
 		}
-	}
 
-	if changeID < d.maxChangeID+1 {
-		// Data already known. Try the next.
-		return d.KeysChanged()
-	}
+		if err := d.update(elements, changeID); err != nil {
+			return nil, 0, fmt.Errorf("updating cache: %w", err)
+		}
 
-	if err := d.update(sData.Elements, changeID); err != nil {
-		return nil, 0, fmt.Errorf("updating cache: %w", err)
+		for _, key := range d.repairedKeys {
+			addKey(key)
+		}
+		d.repairedKeys = nil
+
+		return keys, changeID, nil
 	}
+}
 
-	return keys, changeID, nil
+// coalesce returns all updates from redis, that are received in the coalesce
+// window.
+func (d *Datastore) coalesce() ([][]byte, error) {
+	timer := time.NewTimer(d.coalesceWindow)
+	defer timer.Stop()
+
+	windowClosed := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(windowClosed)
+		select {
+		case <-timer.C:
+		case <-d.closed:
+		case <-done:
+		}
+	}()
+
+	var messages [][]byte
+	for {
+		rawData, err := d.redisConn.Update(windowClosed)
+		if err != nil {
+			var closing interface {
+				Closing()
+			}
+			if errors.As(err, &closing) {
+				return messages, nil
+			}
+			d.setRedisConnected(false)
+			return nil, fmt.Errorf("get autoupdate data: %w", err)
+		}
+		messages = append(messages, rawData)
+	}
 }
 
 // ChangedKeys returns the keys that have changed between from and to from
@@ -157,16 +487,117 @@ func (d *Datastore) ChangedKeys(from, to int) ([]string, error) {
 func (d *Datastore) Get(collection string, id int, v interface{}) error {
 	e := d.cache.get(fmt.Sprintf("%s:%d", collection, id))
 	if e == nil {
-		return doesNotExistError(fmt.Sprintf("%s:%d", collection, id))
+		return DoesNotExistError(fmt.Sprintf("%s:%d", collection, id))
 	}
 	return json.Unmarshal(e, v)
 }
 
+// Exists tells, if the element collection:id exists. Deleted elements do not
+// exist.
+func (d *Datastore) Exists(collection string, id int) bool {
+	return d.cache.get(fmt.Sprintf("%s:%d", collection, id)) != nil
+}
+
+// GetField sets v to the value of one field of the element collection:id. Only
+// the requested field is decoded.
+//
+// Returns an error with the method `DoesNotExist() string` if the element does
+// not exist and an error with the method `FieldDoesNotExist() string` if the
+// element does not have the field.
+//
+// v has to be a pointer.
+func (d *Datastore) GetField(collection string, id int, field string, v interface{}) error {
+	key := fmt.Sprintf("%s:%d", collection, id)
+	e := d.cache.get(key)
+	if e == nil {
+		return DoesNotExistError(key)
+	}
+
+	value, err := jsonField(e, field)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", key, err)
+	}
+
+	if value == nil {
+		return FieldDoesNotExistError{Key: key, Field: field}
+	}
+
+	return json.Unmarshal(value, v)
+}
+
+// GetRelated follows the reference in the field of the element
+// fromCollection:fromID and sets v to the referenced element of toCollection.
+//
+// If the field is a list of ids like `submitters_id`, v has to be a pointer to
+// a slice and is set to the referenced elements in the order of the ids. If
+// the field is a single id like `state_id`, v is set to the one element. If it
+// is null, v is not changed.
+//
+// Returns an error with the method `DoesNotExist() string` if the element or
+// one of the referenced elements does not exist. The error contains the key of
+// the missing element.
+func (d *Datastore) GetRelated(fromCollection string, fromID int, field string, toCollection string, v interface{}) error {
+	var ref json.RawMessage
+	if err := d.GetField(fromCollection, fromID, field, &ref); err != nil {
+		return err
+	}
+
+	if bytes.Equal(ref, []byte("null")) {
+		return nil
+	}
+
+	if len(ref) > 0 && ref[0] != '[' {
+		var id int
+		if err := json.Unmarshal(ref, &id); err != nil {
+			return fmt.Errorf("field %s of %s:%d is not an id: %w", field, fromCollection, fromID, err)
+		}
+		return d.Get(toCollection, id, v)
+	}
+
+	var ids []int
+	if err := json.Unmarshal(ref, &ids); err != nil {
+		return fmt.Errorf("field %s of %s:%d is not a list of ids: %w", field, fromCollection, fromID, err)
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = fmt.Sprintf("%s:%d", toCollection, id)
+	}
+
+	elements := d.cache.forKeys(keys...)
+	list := make([]json.RawMessage, len(keys))
+	for i, key := range keys {
+		if elements[key] == nil {
+			return DoesNotExistError(key)
+		}
+		list[i] = elements[key]
+	}
+
+	encoded, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("encoding related elements: %w", err)
+	}
+	return json.Unmarshal(encoded, v)
+}
+
 // GetMany returns the values for the given keys.
+//
+// A key of a deleted element is in the returned map with a nil value, which is
+// encoded as the json value null. A key that never existed is not in the
+// returned map. Deleted keys are known until the datastore is reset.
 func (d *Datastore) GetMany(keys []string) map[string]json.RawMessage {
 	return d.cache.forKeys(keys...)
 }
 
+// GetManyContext is like GetMany, but adds a span to the trace of the context.
+func (d *Datastore) GetManyContext(ctx context.Context, keys []string) map[string]json.RawMessage {
+	_, span := otel.Tracer("openslides.org").Start(ctx, "datastore.get-many")
+	defer span.End()
+	span.SetAttributes(label.Int("keys", len(keys)))
+
+	return d.GetMany(keys)
+}
+
 // GetCollection gets all elements of one collection.
 func (d *Datastore) GetCollection(collection string) []json.RawMessage {
 	// TODO: maybe build an index?
@@ -208,23 +639,157 @@ func (d *Datastore) GetModels(collection string, ids []int) []json.RawMessage {
 	return elements
 }
 
+// SetRestricter sets the restricter, that is used by GetModelsRestricted. It
+// has to be called before GetModelsRestricted.
+func (d *Datastore) SetRestricter(r Restricter) {
+	d.restricter = r
+}
+
+// GetModelsRestricted returns the elements of the collection with the ids
+// restricted for the user.
+//
+// The elements are returned in the order of the ids. Elements that do not
+// exist or that the user can not see are skipped.
+func (d *Datastore) GetModelsRestricted(uid int, collection string, ids []int) ([]json.RawMessage, error) {
+	if d.restricter == nil {
+		return nil, fmt.Errorf("datastore has no restricter")
+	}
+
+	keys := make([]string, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		keys = append(keys, collection+":"+strconv.Itoa(id))
+	}
+
+	data := d.cache.forKeys(keys...)
+	d.restricter.Restrict(uid, data)
+
+	elements := make([]json.RawMessage, 0, len(keys))
+	for _, key := range keys {
+		if value := data[key]; value != nil {
+			elements = append(elements, value)
+		}
+	}
+	return elements, nil
+}
+
+// GetManyModels returns the elements of many collections in one call. The keys
+// of models are the collections and the values are the ids.
+//
+// The elements are returned in the order of the ids. Elements that do not
+// exist are skipped. For a collection without elements, an empty slice is
+// returned.
+func (d *Datastore) GetManyModels(models map[string][]int) map[string][]json.RawMessage {
+	return d.cache.forModels(models)
+}
+
+// Keys returns the sorted keys of all elements, for which filter returns true.
+// The values are not copied.
+//
+// Deleted elements are removed from the cache, so their keys are not returned.
+func (d *Datastore) Keys(filter func(collection string, id int) bool) []string {
+	return d.cache.keys(filter)
+}
+
+// RawElement returns the cached bytes of an element without a restriction.
+//
+// If the element is deleted, deleted is true. If the key is not known, a
+// DoesNotExistError is returned.
+func (d *Datastore) RawElement(key string) (element json.RawMessage, deleted bool, err error) {
+	element, deleted = d.cache.raw(key)
+	if element == nil && !deleted {
+		return nil, false, DoesNotExistError(key)
+	}
+	return element, deleted, nil
+}
+
+// GetKeyChangeID returns the change id of the last change of the element
+// collection:id. A client can use it to validate its cached copy of the
+// element.
+//
+// The change id of a deleted element is the change id of the deletion. If the
+// element is not known, false is returned. The elements of the initial data
+// have the change id of the initial data.
+func (d *Datastore) GetKeyChangeID(collection string, id int) (int, bool) {
+	return d.cache.keyChangeID(collection + ":" + strconv.Itoa(id))
+}
+
+// CollectionCount returns the number of elements of the collection. Deleted
+// elements are not counted.
+func (d *Datastore) CollectionCount(collection string) int {
+	return d.cache.count(collection)
+}
+
+// CollectionCounts returns the number of elements of each collection, that has
+// at least one element.
+func (d *Datastore) CollectionCounts() map[string]int {
+	return d.cache.allCounts()
+}
+
+// Len returns the number of elements in the cache. Deleted elements are not
+// counted.
+func (d *Datastore) Len() int {
+	return d.cache.len()
+}
+
+// BytesEstimate returns the size of all elements in the cache in bytes. The
+// keys and the overhead of the cache are not counted.
+func (d *Datastore) BytesEstimate() int64 {
+	return d.cache.bytesEstimate()
+}
+
+// DeletedCount returns the number of deleted keys, that are kept in the cache.
+// They are kept until the datastore is reset.
+func (d *Datastore) DeletedCount() int {
+	return d.cache.deletedCount()
+}
+
 // GetAll returns all data.
 func (d *Datastore) GetAll() map[string]json.RawMessage {
 	return d.cache.all()
 }
 
+// GetAllContext is like GetAll, but adds a span to the trace of the context.
+func (d *Datastore) GetAllContext(ctx context.Context) map[string]json.RawMessage {
+	_, span := otel.Tracer("openslides.org").Start(ctx, "datastore.get-all")
+	defer span.End()
+
+	return d.GetAll()
+}
+
 // update updates the cache. It is not save for concourent use.
 func (d *Datastore) update(data map[string]json.RawMessage, changeID int) (err error) {
-	d.cache.update(data)
+	start := time.Now()
+
+	d.mu.RLock()
+	resetting := d.resetting
+	d.mu.RUnlock()
+
+	d.skipInvalid(data)
+
+	var changes []ElementChange
+	if !resetting {
+		changes = d.watchers.classify(d.cache, data, changeID)
+	}
+
+	if resetting {
+		// The data of a reset is all data. The cache is replaced in place,
+		// because the readers use it without a lock of the datastore.
+		d.cache.replace(data, changeID)
+	} else {
+		d.cache.update(data, changeID)
+	}
 
 	d.mu.Lock()
-	d.maxChangeID = changeID
+	d.setMaxChangeID(changeID)
 	d.mu.Unlock()
 
-	log.Println("Recieve data update to changeID: ", changeID)
-
 	defer func() {
-		var cErr conditionError
+		var cErr ConditionError
 		if !errors.As(err, &cErr) {
 			return
 		}
@@ -232,37 +797,100 @@ func (d *Datastore) update(data map[string]json.RawMessage, changeID int) (err e
 		err = fmt.Errorf("%v: %v", err, cErr.ConditionError())
 	}()
 
-	if err := d.hasPerm.update(data); err != nil {
-		log.Printf("Error updating user permissions in the data cache: %v", err)
+	for _, sub := range d.subsystems {
+		if err := updateSubsystem(sub, data); err != nil {
+			d.log.Error("Can not update the data cache", "subsystem", sub.name, "error", err)
+		}
 	}
 
-	if err := d.requiredUser.update(data); err != nil {
-		log.Printf("Error updating required users in the data cache: %v", err)
+	if count := d.hasPerm.commitChanged(changeID); count > 0 {
+		d.log.Debug("Permissions changed", "change_id", changeID, "users", count)
 	}
 
-	if err := d.config.update(data); err != nil {
-		log.Printf("Error updating config values in the data cache: %v", err)
-	}
+	d.watchers.send(changes)
+
+	d.updateLog.Debug("Received data update", "change_id", changeID, "elements", len(data), "duration", time.Since(start))
+
+	return nil
+}
+
+// skipInvalid removes the elements from data, that are not valid json. So a
+// broken element does not get into the cache and only fails later, when it is
+// decoded. A nil value is a deleted element and is valid.
+func (d *Datastore) skipInvalid(data map[string]json.RawMessage) {
+	for key, value := range data {
+		if value == nil || json.Valid(value) {
+			continue
+		}
 
-	if err := d.Projectors.Update(data); err != nil {
-		log.Printf("Error updating projector slides in the data cache: %v", err)
+		collection := strings.Split(key, ":")[0]
+		d.log.Warn("Skipping element from redis with invalid json", "key", key)
+		invalidElementCount.Add(context.Background(), 1, label.String("collection", collection))
+		delete(data, key)
 	}
+}
+
+// updateSubsystem calls the update function of the subsystem. A panic is
+// recovered and returned as error, so the other subsystems are still updated.
+func updateSubsystem(sub subsystem, data map[string]json.RawMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic with data from collections %v: %v", dataCollections(data), r)
+		}
+	}()
 
-	if err := d.applause.update(data); err != nil {
-		log.Printf("Error updating applause (active users) the data cache: %v", err)
+	return sub.update(data)
+}
+
+// dataCollections returns the sorted collection names of the keys in data.
+func dataCollections(data map[string]json.RawMessage) []string {
+	set := make(map[string]bool)
+	for key := range data {
+		set[strings.Split(key, ":")[0]] = true
 	}
 
-	return nil
+	collections := make([]string, 0, len(set))
+	for collection := range set {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	return collections
 }
 
 // receive is used to get missing data. It returns all data between higher
 // "from" and lower or equal "to".
+//
+// If redis does not have the data anymore and a worker is set, the data is
+// received from the worker.
+//
+// If the breaker is open, no request is sent and an error is returned.
 func (d *Datastore) receive(from, to int) (data map[string]json.RawMessage, err error) {
+	if err := d.breaker.allow(); err != nil {
+		return nil, err
+	}
+	defer func() { d.breakerDone(err) }()
+
 	keys, err := d.redisConn.ChangedKeys(from, to)
 	if err != nil {
 		return nil, fmt.Errorf("get changed keys: %w", err)
 	}
 
+	if d.worker != nil {
+		served, err := d.redisServes(from, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		if !served {
+			d.log.Info("Redis does not have the missing data. Using the worker", "from_change_id", from, "to_change_id", to)
+			data, err := d.worker.ChangedData(from, to)
+			if err != nil {
+				return nil, fmt.Errorf("get data from worker: %w", err)
+			}
+			return data, nil
+		}
+	}
+
 	if len(keys) == 0 {
 		return nil, nil
 	}
@@ -274,18 +902,104 @@ func (d *Datastore) receive(from, to int) (data map[string]json.RawMessage, err
 	return data, nil
 }
 
+// redisServes tells, if redis has the changed keys after the change id from.
+//
+// Redis can not return the keys, if it was trimmed after from. If it returned
+// no keys at all, the data is probably also lost.
+func (d *Datastore) redisServes(from int, keys []string) (bool, error) {
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	_, min, err := d.redisConn.ChangeIDs()
+	if err != nil {
+		return false, fmt.Errorf("get change ids from redis: %w", err)
+	}
+	return from >= min, nil
+}
+
+func (d *Datastore) setMinChangeID(min int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.minChangeID = min
+}
+
+func (d *Datastore) setRedisConnected(connected bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.redisConnected = connected
+}
+
+func (d *Datastore) setResetting(resetting bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.resetting = resetting
+}
+
+// parseChangeID parses the change id of a message from redis. A change id has
+// to be a positive number. If a maxChangeIDJump is set, it can not be more
+// then maxChangeIDJump after the current change id.
+func (d *Datastore) parseChangeID(raw json.RawMessage, current int) (int, error) {
+	var changeID int
+	if err := json.Unmarshal(raw, &changeID); err != nil {
+		return 0, fmt.Errorf("change id is not a number: %w", err)
+	}
+
+	if changeID <= 0 {
+		return 0, fmt.Errorf("change id %d is not positive", changeID)
+	}
+
+	if d.maxChangeIDJump > 0 && changeID-current > d.maxChangeIDJump {
+		return 0, changeIDJumpError{changeID: changeID, limit: d.maxChangeIDJump, current: current}
+	}
+	return changeID, nil
+}
+
+// ForceReset initializes the datastore again with the data from redis, like on
+// startup. It can be used, when redis was flushed or filled with other data.
+//
+// The reset is done by KeysChanged, so it does not run concurrently with an
+// update. KeysChanged returns a reset error afterwards, so connected clients
+// receive all data. ForceReset blocks until the reset is done.
+func (d *Datastore) ForceReset() error {
+	done := make(chan error, 1)
+	if err := d.sendDrift(drift{reset: true, done: done}); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-d.closed:
+		return closingError{}
+	}
+}
+
 // reset clears the datasotre and initializes it with new data.
+//
+// If the breaker is open, the old data is kept and an error is returned.
 func (d *Datastore) reset() error {
+	if err := d.breaker.allow(); err != nil {
+		return err
+	}
+
+	d.setResetting(true)
+	defer d.setResetting(false)
 
 	fd, max, min, err := d.redisConn.FullData()
+	d.breakerDone(err)
 	if err != nil {
 		return fmt.Errorf("get startdata from redis: %w", err)
 	}
 
-	d.cache = new(cache)
-	d.minChangeID = min
+	d.log.Info("Reset datastore", "change_id", max, "lowest_change_id", min, "elements", len(fd))
+
 	d.mu.Lock()
-	d.maxChangeID = max
+	d.minChangeID = min
+	d.setMaxChangeID(max)
 	d.mu.Unlock()
 
 	if err := d.update(fd, max); err != nil {
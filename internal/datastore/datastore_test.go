@@ -1,11 +1,23 @@
 package datastore_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
 )
 
@@ -15,7 +27,7 @@ func TestDatastoreLowestID(t *testing.T) {
 	}
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -37,7 +49,7 @@ func TestKeysChangedNormal(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -73,7 +85,7 @@ func TestKeysChangedDelete(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -98,6 +110,206 @@ func TestKeysChangedDelete(t *testing.T) {
 	}
 }
 
+func TestInvalidElement(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+		"motions/motion:2": []byte(`{"id": 2,`),
+		"users/user:1":     []byte(`{"id": 1}`),
+	}
+
+	buf := new(bytes.Buffer)
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.New(buf, slog.LevelDebug), closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	got := ds.GetAll()
+	if len(got) != 2 || got["motions/motion:1"] == nil || got["users/user:1"] == nil {
+		t.Errorf("GetAll returned %v, expected only the valid elements", got)
+	}
+
+	if !strings.Contains(buf.String(), "motions/motion:2") {
+		t.Errorf("The invalid element was not logged:\n%s", buf)
+	}
+}
+
+func TestKeysChangedEmptyRead(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	r.Send([]byte{})
+	r.Send([]byte(`{"change_id": 6, "elements": {"elements/element:1": {"id": 1}}}`))
+
+	keys, chID, err := ds.KeysChanged()
+	if err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	if chID != 6 {
+		t.Errorf("KeysChanged returned change_id %d, expected 6", chID)
+	}
+
+	if !test.CmpStrSlice(keys, []string{"elements/element:1"}) {
+		t.Errorf("KeysChanged returned keys %v, expected [elements/element:1]", keys)
+	}
+}
+
+func TestKeysChangedInvalidChangeID(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		changeID string
+	}{
+		{"zero", "0"},
+		{"negative", "-3"},
+		{"jump to far", "1006"},
+		{"string", `"7"`},
+		{"float", "6.5"},
+		{"missing", "null"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := test.NewRedisMock()
+			r.Max = 5
+
+			closing := make(chan struct{})
+			defer close(closing)
+			ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+			if err != nil {
+				t.Fatalf("Can not initialize datastore: %v", err)
+			}
+			ds.SetMaxChangeIDJump(1000)
+
+			r.Send([]byte(fmt.Sprintf(`{"change_id": %s, "elements": {"elements/element:1": {"id": 1}}}`, tt.changeID)))
+			r.Send([]byte(`{"change_id": 6, "elements": {"elements/element:2": {"id": 2}}}`))
+
+			keys, chID, err := ds.KeysChanged()
+			if err != nil {
+				t.Fatalf("KeysChanged returned unexpected error: %v", err)
+			}
+
+			if chID != 6 {
+				t.Errorf("KeysChanged returned change_id %d, expected 6", chID)
+			}
+
+			if !test.CmpStrSlice(keys, []string{"elements/element:2"}) {
+				t.Errorf("KeysChanged returned keys %v, expected [elements/element:2]", keys)
+			}
+
+			if got := ds.GetMany([]string{"elements/element:1"}); got["elements/element:1"] != nil {
+				t.Errorf("Datastore has the element of the invalid message: %s", got["elements/element:1"])
+			}
+		})
+	}
+}
+
+func TestKeysChangedJumpInLimit(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+	ds.SetMaxChangeIDJump(1000)
+
+	// A big jump in the limit is still a reset of redis.
+	r.Reset(map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)}, 1005, 900)
+	r.SendChange(1005, map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)})
+
+	_, _, err = ds.KeysChanged()
+	var reset interface {
+		Reset()
+	}
+	if !errors.As(err, &reset) {
+		t.Fatalf("KeysChanged returned error `%v`, expected a reset error", err)
+	}
+
+	if ds.CurrentID() != 1005 {
+		t.Errorf("Datastore has change id %d, expected 1005", ds.CurrentID())
+	}
+}
+
+func TestKeysChangedJumpOverLimitReset(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+	ds.SetMaxChangeIDJump(10)
+
+	// Redis was flushed and has the new change id, so the message is not
+	// skipped.
+	r.Reset(map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)}, 5000, 4990)
+	r.SendChange(5000, map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)})
+
+	_, _, err = ds.KeysChanged()
+	var reset interface {
+		Reset()
+	}
+	if !errors.As(err, &reset) {
+		t.Fatalf("KeysChanged returned error `%v`, expected a reset error", err)
+	}
+
+	if ds.CurrentID() != 5000 {
+		t.Errorf("Datastore has change id %d, expected 5000", ds.CurrentID())
+	}
+}
+
+func TestUpdateLogSampling(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+
+	buf := new(bytes.Buffer)
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.New(buf, slog.LevelDebug), closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+	ds.SetUpdateLogSampling(time.Hour, 0)
+	buf.Reset()
+
+	for id := 6; id <= 10; id++ {
+		r.SendChange(id, map[string]json.RawMessage{"users/user:1": []byte(fmt.Sprintf(`{"id": 1, "change": %d}`, id))})
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+	}
+
+	if got := strings.Count(buf.String(), "Received data update"); got != 1 {
+		t.Errorf("Update message was logged %d times, expected 1:\n%s", got, buf)
+	}
+
+	// A reset is always logged.
+	buf.Reset()
+	r.Reset(map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)}, 500, 400)
+	r.SendChange(500, map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)})
+	ds.KeysChanged()
+
+	if !strings.Contains(buf.String(), "Reset datastore") {
+		t.Errorf("Reset was not logged:\n%s", buf)
+	}
+
+	if strings.Contains(buf.String(), "Received data update") {
+		t.Errorf("Update message of the reset was logged, expected it to be sampled:\n%s", buf)
+	}
+}
+
 func TestKeysChangedSkippedChangeID(t *testing.T) {
 	data := []byte(`{
 		"change_id": 10,
@@ -114,7 +326,7 @@ func TestKeysChangedSkippedChangeID(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -135,6 +347,59 @@ func TestKeysChangedSkippedChangeID(t *testing.T) {
 	}
 }
 
+func TestKeysChangedGapDeduplicated(t *testing.T) {
+	data := []byte(`{
+		"change_id": 11,
+		"elements":  {
+			"elements/element:1": {"id": 1, "value": "new"},
+			"elements/element:3": {"id": 3}
+		}
+	}`)
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1, "value": "old"}`),
+		"elements/element:2": []byte(`{"id": 2}`),
+	}
+	r.Max = 5
+	// The change ids 6 to 10 are missing.
+	r.ChangedKeysResult = []string{"elements/element:1", "elements/element:2"}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	r.Send(data)
+	keys, chID, err := ds.KeysChanged()
+
+	if err != nil {
+		t.Errorf("KeysChanged returned unexpected err: %v", err)
+	}
+
+	if chID != 11 {
+		t.Errorf("KeysChanged returned change_id %d, expected 11", chID)
+	}
+
+	sort.Strings(keys)
+	expect := []string{"elements/element:1", "elements/element:2", "elements/element:3"}
+	if !test.CmpStrSlice(keys, expect) {
+		t.Errorf("KeysChanged returned keys %v, expected %v", keys, expect)
+	}
+
+	var element struct {
+		Value string `json:"value"`
+	}
+	if err := ds.Get("elements/element", 1, &element); err != nil {
+		t.Fatalf("Get returned unexpected error: %v", err)
+	}
+
+	if element.Value != "new" {
+		t.Errorf("elements/element:1 has value %q, expected the value from the new data", element.Value)
+	}
+}
+
 func TestKeysChangedBlocking(t *testing.T) {
 	data := []byte(`{
 		"change_id": 6,
@@ -147,7 +412,7 @@ func TestKeysChangedBlocking(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -182,7 +447,7 @@ func TestKeysChangedNilDoesNotUnblock(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -206,14 +471,22 @@ func TestKeysChangedNilDoesNotUnblock(t *testing.T) {
 	timer.Reset(time.Millisecond)
 	select {
 	case <-unblocked:
+		t.Fatalf("KeysChanged was done after sending nil")
 	case <-timer.C:
-		t.Errorf("KeysChanged was not done after sending nil")
 	}
 
-	if err == nil {
-		t.Errorf("Expected error that nil is not expected.")
+	r.Send([]byte(`{"change_id": 6, "elements": {}}`))
+
+	timer.Reset(time.Second)
+	select {
+	case <-unblocked:
+	case <-timer.C:
+		t.Fatalf("KeysChanged was not done after sending data")
 	}
 
+	if err != nil {
+		t.Errorf("KeysChanged returned unexpected error: %v", err)
+	}
 }
 
 func TestKeysChangedLowIDDoesNotUnblock(t *testing.T) {
@@ -228,7 +501,7 @@ func TestKeysChangedLowIDDoesNotUnblock(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -258,6 +531,220 @@ func TestKeysChangedLowIDDoesNotUnblock(t *testing.T) {
 	}
 }
 
+func TestWaitForChangeID(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	t.Run("already processed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := ds.WaitForChangeID(ctx, 5); err != nil {
+			t.Errorf("WaitForChangeID returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("processed later", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		done := make(chan error)
+		go func() {
+			done <- ds.WaitForChangeID(ctx, 7)
+		}()
+
+		for _, changeID := range []int{6, 7} {
+			r.Send([]byte(fmt.Sprintf(`{"change_id": %d, "elements": {"elements/element:1": {"id": 1}}}`, changeID)))
+			if _, _, err := ds.KeysChanged(); err != nil {
+				t.Fatalf("KeysChanged returned unexpected error: %v", err)
+			}
+
+			if changeID == 6 {
+				select {
+				case err := <-done:
+					t.Fatalf("WaitForChangeID returned `%v` after change id 6, expected to wait for 7", err)
+				case <-time.After(time.Millisecond):
+				}
+			}
+		}
+
+		if err := <-done; err != nil {
+			t.Errorf("WaitForChangeID returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		err := ds.WaitForChangeID(ctx, 100)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("WaitForChangeID returned `%v`, expected a deadline exceeded error", err)
+		}
+	})
+}
+
+func TestReady(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if !ds.Ready() {
+		t.Errorf("Ready() returned false after initial data, expected true")
+	}
+
+	r.SendError(errors.New("connection refused"))
+	if _, _, err := ds.KeysChanged(); err == nil {
+		t.Fatalf("KeysChanged did not return the redis error")
+	}
+
+	if ds.Ready() {
+		t.Errorf("Ready() returned true after redis error, expected false")
+	}
+
+	r.Send([]byte(`{"change_id": 6, "elements": {}}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	if !ds.Ready() {
+		t.Errorf("Ready() returned false after redis reconnected, expected true")
+	}
+}
+
+func TestGetManyDeleted(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+		"motions/motion:2": []byte(`{"id": 2}`),
+		"motions/motion:3": []byte(`{"id": 3}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	r.SendChange(6, map[string]json.RawMessage{
+		"motions/motion:2": nil,
+		"motions/motion:3": nil,
+	})
+	r.SendChange(7, map[string]json.RawMessage{
+		"motions/motion:3": []byte(`{"id": 3, "title": "again"}`),
+	})
+	for i := 0; i < 2; i++ {
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+	}
+
+	got := ds.GetMany([]string{"motions/motion:1", "motions/motion:2", "motions/motion:3", "motions/motion:4"})
+
+	if len(got) != 3 {
+		t.Errorf("GetMany returned %d keys, expected 3: %v", len(got), got)
+	}
+
+	if value, ok := got["motions/motion:2"]; !ok || value != nil {
+		t.Errorf("GetMany returned for the deleted key %q (in result: %t), expected nil", value, ok)
+	}
+
+	if _, ok := got["motions/motion:4"]; ok {
+		t.Errorf("GetMany returned the key, that never existed")
+	}
+
+	encoded, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Can not encode result: %v", err)
+	}
+	test.ExpectEqualJSON(t, encoded, []byte(`{"motions/motion:1": {"id": 1}, "motions/motion:2": null, "motions/motion:3": {"id": 3, "title": "again"}}`))
+}
+
+func TestGetRelated(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "state_id": 5, "submitters_id": [2, 1], "parent_id": null, "supporters_id": [1, 3]}`),
+		"motions/state:5":  []byte(`{"id": 5, "name": "submitted"}`),
+		"users/user:1":     []byte(`{"id": 1, "username": "admin"}`),
+		"users/user:2":     []byte(`{"id": 2, "username": "max"}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	t.Run("Scalar reference", func(t *testing.T) {
+		var state struct {
+			Name string `json:"name"`
+		}
+		if err := ds.GetRelated("motions/motion", 1, "state_id", "motions/state", &state); err != nil {
+			t.Fatalf("GetRelated returned unexpected error: %v", err)
+		}
+
+		if state.Name != "submitted" {
+			t.Errorf("GetRelated returned state %q, expected submitted", state.Name)
+		}
+	})
+
+	t.Run("List reference", func(t *testing.T) {
+		var users []struct {
+			Username string `json:"username"`
+		}
+		if err := ds.GetRelated("motions/motion", 1, "submitters_id", "users/user", &users); err != nil {
+			t.Fatalf("GetRelated returned unexpected error: %v", err)
+		}
+
+		if len(users) != 2 || users[0].Username != "max" || users[1].Username != "admin" {
+			t.Errorf("GetRelated returned %v, expected [max admin]", users)
+		}
+	})
+
+	t.Run("Null reference", func(t *testing.T) {
+		parent := json.RawMessage("unchanged")
+		if err := ds.GetRelated("motions/motion", 1, "parent_id", "motions/motion", &parent); err != nil {
+			t.Fatalf("GetRelated returned unexpected error: %v", err)
+		}
+
+		if string(parent) != "unchanged" {
+			t.Errorf("GetRelated changed the value to %s", parent)
+		}
+	})
+
+	t.Run("Missing target", func(t *testing.T) {
+		var users []json.RawMessage
+		err := ds.GetRelated("motions/motion", 1, "supporters_id", "users/user", &users)
+
+		var dErr interface {
+			DoesNotExist() string
+		}
+		if !errors.As(err, &dErr) {
+			t.Fatalf("GetRelated returned error `%v`, expected a DoesNotExist error", err)
+		}
+
+		if dErr.DoesNotExist() != "users/user:3" {
+			t.Errorf("Missing key is %s, expected users/user:3", dErr.DoesNotExist())
+		}
+	})
+}
+
 func TestGetMany(t *testing.T) {
 	r := test.NewRedisMock()
 	r.Max = 5
@@ -269,7 +756,7 @@ func TestGetMany(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -292,7 +779,7 @@ func TestGetAll(t *testing.T) {
 
 	closing := make(chan struct{})
 	defer close(closing)
-	ds, err := datastore.New(r, nil, nil, closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
 	if err != nil {
 		t.Fatalf("Can not initialize datastore: %v", err)
 	}
@@ -303,3 +790,1528 @@ func TestGetAll(t *testing.T) {
 		t.Errorf("GetAll returned %v, expected values from elements 1, 2 and 3", got)
 	}
 }
+
+func TestSnapshotCatchUp(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Min = 1
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1, "value": "new"}`),
+	}
+	r.ChangedKeysResult = []string{"elements/element:1", "elements/element:3"}
+
+	snapshot := snapshotWithChecksum(`{
+		"change_id": 3,
+		"data": {
+			"elements/element:1": {"id": 1, "value": "old"},
+			"elements/element:2": {"id": 2, "value": "unchanged"},
+			"elements/element:3": {"id": 3, "value": "deleted"}
+		}
+	}`)
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.NewFromSnapshot(r, snapshot, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if ds.CurrentID() != 5 {
+		t.Errorf("CurrentID() returned %d, expected 5", ds.CurrentID())
+	}
+
+	expectData(t, ds.GetAll(), map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1, "value": "new"}`),
+		"elements/element:2": []byte(`{"id": 2, "value": "unchanged"}`),
+	})
+}
+
+func TestSnapshotToOld(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Min = 4
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1, "value": "new"}`),
+	}
+
+	snapshot := snapshotWithChecksum(`{
+		"change_id": 3,
+		"data": {
+			"elements/element:2": {"id": 2, "value": "old"}
+		}
+	}`)
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.NewFromSnapshot(r, snapshot, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if ds.CurrentID() != 5 {
+		t.Errorf("CurrentID() returned %d, expected 5", ds.CurrentID())
+	}
+
+	expectData(t, ds.GetAll(), r.FD)
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1, "value": "hello"}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := ds.WriteSnapshot(buf); err != nil {
+		t.Fatalf("WriteSnapshot returned unexpected error: %v", err)
+	}
+
+	r.FD = nil
+	loaded, err := datastore.NewFromSnapshot(r, buf, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore from snapshot: %v", err)
+	}
+
+	expectData(t, loaded.GetAll(), map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1, "value": "hello"}`),
+	})
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1, "value": "hello"}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := ds.WriteSnapshot(buf); err != nil {
+		t.Fatalf("WriteSnapshot returned unexpected error: %v", err)
+	}
+	written := buf.String()
+
+	for _, tt := range []struct {
+		name     string
+		snapshot string
+	}{
+		{"truncated", written[:len(written)/2]},
+		{"without checksum", written[:strings.Index(written, "\n")+1]},
+		{"changed", strings.Replace(written, "hello", "hallo", 1)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r.FD = map[string]json.RawMessage{
+				"elements/element:1": []byte(`{"id": 1, "value": "from redis"}`),
+			}
+
+			loaded, err := datastore.NewFromSnapshot(r, strings.NewReader(tt.snapshot), nil, nil, logger.Noop, closing)
+			if err != nil {
+				t.Fatalf("Can not initialize datastore: %v", err)
+			}
+
+			expectData(t, loaded.GetAll(), r.FD)
+		})
+	}
+}
+
+// snapshotWithChecksum returns the snapshot content followed by its checksum
+// like it is written by WriteSnapshot.
+func snapshotWithChecksum(content string) io.Reader {
+	content += "\n"
+	return strings.NewReader(fmt.Sprintf("%s{\"sha256\": \"%x\"}\n", content, sha256.Sum256([]byte(content))))
+}
+
+func expectData(t *testing.T, got, expected map[string]json.RawMessage) {
+	t.Helper()
+
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Can not encode data: %v", err)
+	}
+
+	expectedJSON, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatalf("Can not encode expected data: %v", err)
+	}
+
+	test.ExpectEqualJSON(t, gotJSON, expectedJSON)
+}
+
+func TestGetField(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"users/user:1": []byte(`{"id": 1, "username": "admin", "groups_id": [2, 3]}`),
+		"users/user:2": []byte(`{"about_me": "a \"}\" b", "extra": {"groups_id": [1], "list": ["]"]}, "groups_id": [4]}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	t.Run("Field", func(t *testing.T) {
+		var groups []int
+		if err := ds.GetField("users/user", 1, "groups_id", &groups); err != nil {
+			t.Fatalf("GetField returned unexpected error: %v", err)
+		}
+
+		if len(groups) != 2 || groups[0] != 2 || groups[1] != 3 {
+			t.Errorf("GetField returned %v, expected [2 3]", groups)
+		}
+	})
+
+	t.Run("Field after nested values", func(t *testing.T) {
+		var groups []int
+		if err := ds.GetField("users/user", 2, "groups_id", &groups); err != nil {
+			t.Fatalf("GetField returned unexpected error: %v", err)
+		}
+
+		if len(groups) != 1 || groups[0] != 4 {
+			t.Errorf("GetField returned %v, expected [4]", groups)
+		}
+	})
+
+	t.Run("Missing element", func(t *testing.T) {
+		var groups []int
+		err := ds.GetField("users/user", 3, "groups_id", &groups)
+
+		var dErr interface {
+			DoesNotExist() string
+		}
+		if !errors.As(err, &dErr) {
+			t.Errorf("GetField returned error `%v`, expected a DoesNotExist error", err)
+		}
+	})
+
+	t.Run("Missing field", func(t *testing.T) {
+		var value string
+		err := ds.GetField("users/user", 1, "unknown", &value)
+
+		var fErr interface {
+			FieldDoesNotExist() string
+		}
+		if !errors.As(err, &fErr) {
+			t.Errorf("GetField returned error `%v`, expected a FieldDoesNotExist error", err)
+		}
+
+		var dErr interface {
+			DoesNotExist() string
+		}
+		if errors.As(err, &dErr) {
+			t.Errorf("GetField returned a DoesNotExist error for a missing field")
+		}
+	})
+}
+
+func wideUserDatastore(b *testing.B) *datastore.Datastore {
+	user := map[string]interface{}{"id": 1, "groups_id": []int{2, 3}}
+	for i := 0; i < 100; i++ {
+		user[fmt.Sprintf("field_%d", i)] = strings.Repeat("x", 100)
+	}
+	encoded, err := json.Marshal(user)
+	if err != nil {
+		b.Fatalf("Can not encode user: %v", err)
+	}
+
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{"users/user:1": encoded}
+	ds, err := datastore.New(r, nil, nil, logger.Noop, make(chan struct{}))
+	if err != nil {
+		b.Fatalf("Can not initialize datastore: %v", err)
+	}
+	return ds
+}
+
+func BenchmarkGet(b *testing.B) {
+	ds := wideUserDatastore(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var user struct {
+			GroupsID []int `json:"groups_id"`
+		}
+		if err := ds.Get("users/user", 1, &user); err != nil {
+			b.Fatalf("Get returned unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkGetField(b *testing.B) {
+	ds := wideUserDatastore(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var groups []int
+		if err := ds.GetField("users/user", 1, "groups_id", &groups); err != nil {
+			b.Fatalf("GetField returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1}`),
+		"elements/element:2": []byte(`{"id": 2}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	r.Send([]byte(`{"change_id": 6, "elements": {"elements/element:2": null}}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		id     int
+		expect bool
+	}{
+		{1, true},
+		{2, false},
+		{3, false},
+	} {
+		if got := ds.Exists("elements/element", tt.id); got != tt.expect {
+			t.Errorf("Exists(elements/element, %d) returned %t, expected %t", tt.id, got, tt.expect)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1}`),
+		"elements/element:2": []byte(`{"id": 2}`),
+		"other/element:1":    []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter func(collection string, id int) bool
+		expect []string
+	}{
+		{
+			"collection",
+			func(collection string, _ int) bool { return collection == "elements/element" },
+			[]string{"elements/element:1", "elements/element:2"},
+		},
+		{
+			"id",
+			func(_ string, id int) bool { return id == 1 },
+			[]string{"elements/element:1", "other/element:1"},
+		},
+		{
+			"nothing",
+			func(collection string, _ int) bool { return collection == "unknown/element" },
+			nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ds.Keys(tt.filter)
+
+			if !test.CmpStrSlice(got, tt.expect) {
+				t.Errorf("Keys returned %v, expected %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1}`),
+		"elements/element:2": []byte(`{"id": 2}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	var gotChanged, gotDeleted []int
+	var called int
+	ds.Subscribe("elements/element", func(changed []int, deleted []int) {
+		called++
+		gotChanged = changed
+		gotDeleted = deleted
+
+		// The callback can read from the datastore.
+		if len(changed) > 0 && !ds.Exists("elements/element", changed[0]) {
+			t.Errorf("Changed element %d does not exist in the callback", changed[0])
+		}
+	})
+
+	r.Send([]byte(`{
+		"change_id": 6,
+		"elements": {
+			"elements/element:1": {"id": 1, "value": "new"},
+			"elements/element:2": null,
+			"other/element:1": {"id": 1}
+		}
+	}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	if called != 1 {
+		t.Fatalf("Callback was called %d times, expected 1", called)
+	}
+
+	if !test.CmpIntSlice(gotChanged, []int{1}) {
+		t.Errorf("Got changed ids %v, expected [1]", gotChanged)
+	}
+
+	if !test.CmpIntSlice(gotDeleted, []int{2}) {
+		t.Errorf("Got deleted ids %v, expected [2]", gotDeleted)
+	}
+
+	r.Send([]byte(`{"change_id": 7, "elements": {"other/element:1": {"id": 1}}}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	if called != 1 {
+		t.Errorf("Callback was called after an update of another collection")
+	}
+}
+
+func TestGetModelsRestricted(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "public": true, "secret": "a"}`),
+		"motions/motion:2": []byte(`{"id": 2, "public": false, "secret": "b"}`),
+		"motions/motion:3": []byte(`{"id": 3, "public": true, "secret": "c"}`),
+		"users/user:1":     []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if _, err := ds.GetModelsRestricted(1, "motions/motion", []int{1}); err == nil {
+		t.Errorf("GetModelsRestricted without a restricter did not return an error")
+	}
+
+	// User 1 can see the public motions without the secret field.
+	restrict := restricter.ElementFunc(func(uid int, element json.RawMessage) (json.RawMessage, error) {
+		var motion struct {
+			ID     int  `json:"id"`
+			Public bool `json:"public"`
+		}
+		if err := json.Unmarshal(element, &motion); err != nil {
+			return nil, err
+		}
+		if uid != 1 || !motion.Public {
+			return nil, nil
+		}
+		return json.Marshal(motion)
+	})
+	rs := restricter.New(ds, map[string]restricter.Element{"motions/motion": restrict})
+	ds.SetRestricter(rs)
+
+	for _, tt := range []struct {
+		name string
+		uid  int
+		ids  []int
+	}{
+		{"all visible", 1, []int{3, 1}},
+		{"some hidden", 1, []int{1, 2, 3}},
+		{"not existing", 1, []int{404, 1}},
+		{"duplicates", 1, []int{1, 1}},
+		{"other user", 2, []int{1, 2, 3}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ds.GetModelsRestricted(tt.uid, "motions/motion", tt.ids)
+			if err != nil {
+				t.Fatalf("GetModelsRestricted returned unexpected error: %v", err)
+			}
+
+			// Fetch and restrict manually.
+			var expect []string
+			seen := make(map[int]bool)
+			for _, id := range tt.ids {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+
+				elements := ds.GetModels("motions/motion", []int{id})
+				if len(elements) == 0 {
+					continue
+				}
+				key := fmt.Sprintf("motions/motion:%d", id)
+				data := map[string]json.RawMessage{key: elements[0]}
+				rs.Restrict(tt.uid, data)
+				if data[key] != nil {
+					expect = append(expect, string(data[key]))
+				}
+			}
+
+			gotStrings := make([]string, len(got))
+			for i, e := range got {
+				gotStrings[i] = string(e)
+			}
+
+			if !test.CmpStrSlice(gotStrings, expect) {
+				t.Errorf("GetModelsRestricted returned %v, expected %v", gotStrings, expect)
+			}
+		})
+	}
+}
+
+func TestGetManyModels(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+		"motions/state:2":  []byte(`{"id": 2}`),
+		"users/user:3":     []byte(`{"id": 3}`),
+		"users/user:4":     []byte(`{"id": 4}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	got := ds.GetManyModels(map[string][]int{
+		"motions/motion":  {1},
+		"motions/state":   {2},
+		"users/user":      {4, 404, 3},
+		"unknown/unknown": {1},
+		"agenda/item":     nil,
+	})
+
+	expect := map[string][]string{
+		"motions/motion":  {`{"id": 1}`},
+		"motions/state":   {`{"id": 2}`},
+		"users/user":      {`{"id": 4}`, `{"id": 3}`},
+		"unknown/unknown": {},
+		"agenda/item":     {},
+	}
+
+	if len(got) != len(expect) {
+		t.Errorf("GetManyModels returned %d collections, expected %d", len(got), len(expect))
+	}
+
+	for collection, elements := range expect {
+		gotElements, ok := got[collection]
+		if !ok || gotElements == nil {
+			t.Errorf("GetManyModels returned no slice for %s", collection)
+			continue
+		}
+
+		gotStrings := make([]string, len(gotElements))
+		for i, e := range gotElements {
+			gotStrings[i] = string(e)
+		}
+
+		if !test.CmpStrSlice(gotStrings, elements) {
+			t.Errorf("GetManyModels returned %v for %s, expected %v", gotStrings, collection, elements)
+		}
+	}
+}
+
+func manyCollectionsDatastore(b *testing.B) *datastore.Datastore {
+	r := test.NewRedisMock()
+	r.FD = make(map[string]json.RawMessage)
+	for _, collection := range []string{"motions/motion", "motions/state", "users/user", "agenda/item", "core/tag"} {
+		for id := 1; id <= 1000; id++ {
+			r.FD[fmt.Sprintf("%s:%d", collection, id)] = []byte(fmt.Sprintf(`{"id": %d}`, id))
+		}
+	}
+
+	ds, err := datastore.New(r, nil, nil, logger.Noop, make(chan struct{}))
+	if err != nil {
+		b.Fatalf("Can not initialize datastore: %v", err)
+	}
+	return ds
+}
+
+var benchmarkModels = map[string][]int{
+	"motions/motion": {1},
+	"motions/state":  {2},
+	"users/user":     {3, 4, 5},
+}
+
+func BenchmarkGetModels(b *testing.B) {
+	ds := manyCollectionsDatastore(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for collection, ids := range benchmarkModels {
+			ds.GetModels(collection, ids)
+		}
+	}
+}
+
+func BenchmarkGetManyModels(b *testing.B) {
+	ds := manyCollectionsDatastore(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ds.GetManyModels(benchmarkModels)
+	}
+}
+
+type workerMock struct {
+	data   map[string]json.RawMessage
+	called bool
+}
+
+func (w *workerMock) ChangedData(from, to int) (map[string]json.RawMessage, error) {
+	w.called = true
+	return w.data, nil
+}
+
+func TestKeysChangedWorkerFallback(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		changedKeys []string
+		redisMin    int
+		useWorker   bool
+	}{
+		{"redis returns no keys", nil, 0, true},
+		{"redis was trimmed", []string{"elements/element:2"}, 7, true},
+		{"redis has the data", []string{"elements/element:2"}, 0, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := test.NewRedisMock()
+			r.FD = map[string]json.RawMessage{
+				"elements/element:2": []byte(`{"id": 2, "value": "from redis"}`),
+			}
+			r.Max = 5
+			r.ChangedKeysResult = tt.changedKeys
+
+			closing := make(chan struct{})
+			defer close(closing)
+			ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+			if err != nil {
+				t.Fatalf("Can not initialize datastore: %v", err)
+			}
+
+			worker := &workerMock{data: map[string]json.RawMessage{
+				"elements/element:2": []byte(`{"id": 2, "value": "from worker"}`),
+			}}
+			ds.SetWorker(worker)
+			r.Min = tt.redisMin
+
+			r.Send([]byte(`{"change_id": 10, "elements": {"elements/element:1": {"id": 1}}}`))
+			keys, chID, err := ds.KeysChanged()
+			if err != nil {
+				t.Fatalf("KeysChanged returned unexpected err: %v", err)
+			}
+
+			if chID != 10 {
+				t.Errorf("KeysChanged returned change_id %d, expected 10", chID)
+			}
+
+			sort.Strings(keys)
+			if !test.CmpStrSlice(keys, []string{"elements/element:1", "elements/element:2"}) {
+				t.Errorf("KeysChanged returned keys %v, expected [elements/element:1 elements/element:2]", keys)
+			}
+
+			if worker.called != tt.useWorker {
+				t.Errorf("Worker was called: %t, expected %t", worker.called, tt.useWorker)
+			}
+
+			expect := "from redis"
+			if tt.useWorker {
+				expect = "from worker"
+			}
+
+			var element struct {
+				Value string `json:"value"`
+			}
+			if err := ds.Get("elements/element", 2, &element); err != nil {
+				t.Fatalf("Get returned unexpected error: %v", err)
+			}
+
+			if element.Value != expect {
+				t.Errorf("elements/element:2 has value %q, expected %q", element.Value, expect)
+			}
+		})
+	}
+}
+
+func TestKeysChangedCoalesce(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+	ds.SetCoalesceWindow(100 * time.Millisecond)
+
+	var fanOuts int
+	ds.Subscribe("elements/element", func(changed, deleted []int) {
+		fanOuts++
+	})
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			r.Send([]byte(fmt.Sprintf(`{"change_id": %d, "elements": {"elements/element:%d": {"id": %d, "value": %d}}}`, 6+i, i%10, i%10, i)))
+		}
+	}()
+
+	keys, chID, err := ds.KeysChanged()
+	if err != nil {
+		t.Fatalf("KeysChanged returned unexpected err: %v", err)
+	}
+
+	if chID != 105 {
+		t.Errorf("KeysChanged returned change_id %d, expected 105", chID)
+	}
+
+	if ds.CurrentID() != 105 {
+		t.Errorf("CurrentID() returned %d, expected 105", ds.CurrentID())
+	}
+
+	if len(keys) != 10 {
+		t.Errorf("KeysChanged returned %d keys, expected 10: %v", len(keys), keys)
+	}
+
+	if fanOuts != 1 {
+		t.Errorf("Subscriber was called %d times, expected 1", fanOuts)
+	}
+
+	var element struct {
+		Value int `json:"value"`
+	}
+	if err := ds.Get("elements/element", 3, &element); err != nil {
+		t.Fatalf("Get returned unexpected error: %v", err)
+	}
+
+	if element.Value != 93 {
+		t.Errorf("elements/element:3 has value %d, expected the value of the last update 93", element.Value)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "title": "old"}`),
+		"users/user:1":     []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	snapshot := ds.Snapshot()
+
+	r.Send([]byte(`{"change_id": 6, "elements": {"motions/motion:1": {"id": 1, "title": "new"}, "users/user:1": null, "users/user:2": {"id": 2}}}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	if snapshot.ChangeID() != 5 {
+		t.Errorf("Snapshot has change id %d, expected 5", snapshot.ChangeID())
+	}
+
+	var motion struct {
+		Title string `json:"title"`
+	}
+	if err := snapshot.Get("motions/motion", 1, &motion); err != nil {
+		t.Fatalf("Get returned unexpected error: %v", err)
+	}
+	if motion.Title != "old" {
+		t.Errorf("Snapshot has motion title %q, expected %q", motion.Title, "old")
+	}
+
+	if users := snapshot.GetCollection("users/user"); len(users) != 1 || string(users[0]) != `{"id": 1}` {
+		t.Errorf("Snapshot has users %s, expected only users/user:1", users)
+	}
+
+	var user json.RawMessage
+	err = snapshot.Get("users/user", 2, &user)
+	var dErr interface {
+		DoesNotExist() string
+	}
+	if !errors.As(err, &dErr) {
+		t.Errorf("Get for a newer element returned error `%v`, expected a DoesNotExist error", err)
+	}
+
+	if got := ds.Snapshot(); got.ChangeID() != 6 {
+		t.Errorf("New snapshot has change id %d, expected 6", got.ChangeID())
+	}
+}
+
+func TestKeysChangedShards(t *testing.T) {
+	shardA := test.NewRedisMock()
+	shardA.Max = 1
+	shardA.FD = map[string]json.RawMessage{"motions/motion:1": []byte(`{"id": 1}`)}
+
+	shardB := test.NewRedisMock()
+	shardB.Max = 1
+	shardB.FD = map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(datastore.NewShards(closing, shardA, shardB), nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if got := len(ds.GetAll()); got != 2 {
+		t.Errorf("Datastore has %d elements, expected the 2 elements of both shards", got)
+	}
+
+	for _, step := range []struct {
+		name       string
+		send       func()
+		expectKeys []string
+		expectID   int
+	}{
+		{
+			"Update from shard A",
+			func() { shardA.Send([]byte(`{"change_id": 2, "elements": {"motions/motion:2": {"id": 2}}}`)) },
+			[]string{"motions/motion:2"},
+			2,
+		},
+		{
+			"Update from shard B",
+			func() { shardB.Send([]byte(`{"change_id": 3, "elements": {"users/user:3": {"id": 3}}}`)) },
+			[]string{"users/user:3"},
+			3,
+		},
+		{
+			"Shard A is faster then shard B",
+			func() {
+				shardB.ChangedKeysResult = []string{"users/user:4"}
+				shardB.FD["users/user:4"] = []byte(`{"id": 4}`)
+				shardA.Send([]byte(`{"change_id": 5, "elements": {"motions/motion:5": {"id": 5}}}`))
+			},
+			[]string{"motions/motion:5", "users/user:4"},
+			5,
+		},
+		{
+			"Late update from shard B is ignored",
+			func() {
+				shardB.Send([]byte(`{"change_id": 4, "elements": {"users/user:4": {"id": 4}}}`))
+				shardA.Send([]byte(`{"change_id": 6, "elements": {"motions/motion:6": {"id": 6}}}`))
+			},
+			[]string{"motions/motion:6"},
+			6,
+		},
+	} {
+		step.send()
+
+		keys, changeID, err := ds.KeysChanged()
+		if err != nil {
+			t.Fatalf("%s: KeysChanged returned unexpected error: %v", step.name, err)
+		}
+
+		if changeID != step.expectID {
+			t.Errorf("%s: KeysChanged returned change id %d, expected %d", step.name, changeID, step.expectID)
+		}
+
+		sort.Strings(keys)
+		if !test.CmpStrSlice(keys, step.expectKeys) {
+			t.Errorf("%s: KeysChanged returned keys %v, expected %v", step.name, keys, step.expectKeys)
+		}
+	}
+
+	var user json.RawMessage
+	if err := ds.Get("users/user", 4, &user); err != nil {
+		t.Errorf("Get users/user:4 returned unexpected error: %v", err)
+	}
+}
+
+func TestShardsNewestValue(t *testing.T) {
+	// motions/motion:1 was changed in shard B and later in shard A.
+	// motions/motion:2 was deleted in shard A after it was changed in shard B.
+	shardA := test.NewRedisMock()
+	shardA.FD = map[string]json.RawMessage{"motions/motion:1": []byte(`{"id": 1, "title": "new"}`)}
+	shardA.KeyChangeID = map[string]int{"motions/motion:1": 5, "motions/motion:2": 6}
+
+	shardB := test.NewRedisMock()
+	shardB.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "title": "old"}`),
+		"motions/motion:2": []byte(`{"id": 2}`),
+	}
+	shardB.KeyChangeID = map[string]int{"motions/motion:1": 2, "motions/motion:2": 4}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	shards := datastore.NewShards(closing, shardA, shardB)
+
+	t.Run("Data", func(t *testing.T) {
+		data, err := shards.Data([]string{"motions/motion:1", "motions/motion:2", "motions/motion:3"})
+		if err != nil {
+			t.Fatalf("Data returned unexpected error: %v", err)
+		}
+
+		expect := map[string]string{
+			"motions/motion:1": `{"id": 1, "title": "new"}`,
+			"motions/motion:2": "",
+			"motions/motion:3": "",
+		}
+		if len(data) != len(expect) {
+			t.Errorf("Got %d keys, expected %d", len(data), len(expect))
+		}
+		for key, value := range expect {
+			if got := string(data[key]); got != value {
+				t.Errorf("%s is `%s`, expected `%s`", key, got, value)
+			}
+		}
+	})
+
+	t.Run("FullData", func(t *testing.T) {
+		data, _, _, err := shards.FullData()
+		if err != nil {
+			t.Fatalf("FullData returned unexpected error: %v", err)
+		}
+
+		if got := string(data["motions/motion:1"]); got != `{"id": 1, "title": "new"}` {
+			t.Errorf("motions/motion:1 is `%s`, expected the value of shard A", got)
+		}
+
+		if value, ok := data["motions/motion:2"]; ok {
+			t.Errorf("motions/motion:2 is `%s`, expected it to be deleted", value)
+		}
+	})
+}
+
+func TestKeysChangedGapFill(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "title": "old"}`),
+		"motions/motion:2": []byte(`{"id": 2}`),
+	}
+
+	var gotFrom, gotTo int
+	r.ChangedKeysFunc = func(from, to int) ([]string, error) {
+		gotFrom, gotTo = from, to
+		return []string{"motions/motion:1", "motions/motion:2"}, nil
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	// Change 6 and 7 are lost. Redis still has the data.
+	r.FD["motions/motion:1"] = []byte(`{"id": 1, "title": "new"}`)
+	r.FD["motions/motion:2"] = nil
+	r.SendChange(8, map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)})
+
+	keys, changeID, err := ds.KeysChanged()
+	if err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	if gotFrom != 5 || gotTo != 7 {
+		t.Errorf("ChangedKeys was called from %d to %d, expected from 5 to 7", gotFrom, gotTo)
+	}
+
+	if changeID != 8 {
+		t.Errorf("KeysChanged returned change id %d, expected 8", changeID)
+	}
+
+	sort.Strings(keys)
+	expect := []string{"motions/motion:1", "motions/motion:2", "users/user:1"}
+	if !test.CmpStrSlice(keys, expect) {
+		t.Errorf("KeysChanged returned keys %v, expected %v", keys, expect)
+	}
+
+	var motion struct {
+		Title string `json:"title"`
+	}
+	if err := ds.Get("motions/motion", 1, &motion); err != nil || motion.Title != "new" {
+		t.Errorf("motions/motion:1 has title %q (error: %v), expected the title from the gap", motion.Title, err)
+	}
+
+	if err := ds.Get("motions/motion", 2, &motion); !errors.Is(err, datastore.ErrDoesNotExist) {
+		t.Errorf("Get motions/motion:2 returned error `%v`, expected it to be deleted", err)
+	}
+}
+
+func TestKeysChangedReset(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	// Redis was restarted with other data and change ids.
+	r.Reset(map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)}, 1000, 900)
+	r.SendChange(1001, map[string]json.RawMessage{"users/user:2": []byte(`{"id": 2}`)})
+
+	_, _, err = ds.KeysChanged()
+	var reset interface {
+		Reset()
+	}
+	if !errors.As(err, &reset) {
+		t.Fatalf("KeysChanged returned error `%v`, expected a reset error", err)
+	}
+
+	if ds.CurrentID() != 1000 || ds.LowestID() != 900 {
+		t.Errorf("Datastore has change ids %d to %d, expected 900 to 1000", ds.LowestID(), ds.CurrentID())
+	}
+
+	all := ds.GetAll()
+	if len(all) != 1 || all["users/user:1"] == nil {
+		t.Errorf("Datastore has data %v, expected only the data after the reset", all)
+	}
+}
+
+func TestForceReset(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	type result struct {
+		keys     []string
+		changeID int
+		err      error
+	}
+	results := make(chan result, 10)
+	go func() {
+		for {
+			keys, changeID, err := ds.KeysChanged()
+			select {
+			case results <- result{keys, changeID, err}:
+			case <-closing:
+				return
+			}
+		}
+	}()
+
+	// Redis was flushed and filled with other data.
+	r.Reset(map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)}, 20, 10)
+
+	// An update for the old data, that is processed before or after the reset.
+	go r.SendChange(6, map[string]json.RawMessage{"motions/motion:2": []byte(`{"id": 2}`)})
+
+	if err := ds.ForceReset(); err != nil {
+		t.Fatalf("ForceReset returned unexpected error: %v", err)
+	}
+
+	var reset interface {
+		Reset()
+	}
+	var gotReset bool
+	for !gotReset {
+		got := <-results
+		switch {
+		case errors.As(got.err, &reset):
+			gotReset = true
+		case got.err != nil:
+			t.Fatalf("KeysChanged returned unexpected error: %v", got.err)
+		case got.changeID != 6:
+			t.Fatalf("KeysChanged returned change id %d before the reset, expected 6", got.changeID)
+		}
+	}
+
+	if ds.CurrentID() != 20 || ds.LowestID() != 10 {
+		t.Errorf("Datastore has change ids %d to %d, expected 10 to 20", ds.LowestID(), ds.CurrentID())
+	}
+
+	r.SendChange(21, map[string]json.RawMessage{"users/user:2": []byte(`{"id": 2}`)})
+
+	got := <-results
+	if got.err != nil {
+		t.Fatalf("KeysChanged after the reset returned unexpected error: %v", got.err)
+	}
+
+	if got.changeID != 21 || !test.CmpStrSlice(got.keys, []string{"users/user:2"}) {
+		t.Errorf("KeysChanged returned %v with change id %d, expected [users/user:2] with 21", got.keys, got.changeID)
+	}
+
+	all := ds.GetAll()
+	if len(all) != 2 || all["users/user:1"] == nil || all["users/user:2"] == nil {
+		t.Errorf("Datastore has data %v, expected only the data after the reset", all)
+	}
+}
+
+func TestForceResetConcurrentReads(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-closing:
+				return
+			default:
+			}
+			ds.KeysChanged()
+		}
+	}()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// The motion is in the old and the new data. It is never
+				// missing during the reset.
+				var motion map[string]json.RawMessage
+				if err := ds.Get("motions/motion", 1, &motion); err != nil {
+					t.Errorf("Get during reset returned unexpected error: %v", err)
+					return
+				}
+				ds.GetAll()
+				ds.CollectionCounts()
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		r.Reset(map[string]json.RawMessage{
+			"motions/motion:1": []byte(`{"id": 1}`),
+			"users/user:1":     []byte(`{"id": 1}`),
+		}, 20+i, 10)
+
+		if err := ds.ForceReset(); err != nil {
+			t.Fatalf("ForceReset returned unexpected error: %v", err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestCollectionCount(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+		"motions/motion:2": []byte(`{"id": 2}`),
+		"users/user:1":     []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if got := ds.CollectionCount("motions/motion"); got != 2 {
+		t.Errorf("CollectionCount returned %d motions, expected 2", got)
+	}
+
+	r.Send([]byte(`{"change_id": 6, "elements": {"motions/motion:1": null, "motions/motion:2": {"id": 2, "title": "changed"}, "users/user:1": null, "users/user:5": null}}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	for collection, expect := range map[string]int{
+		"motions/motion": 1,
+		"users/user":     0,
+		"agenda/item":    0,
+	} {
+		if got := ds.CollectionCount(collection); got != expect {
+			t.Errorf("CollectionCount(%s) returned %d, expected %d", collection, got, expect)
+		}
+	}
+
+	counts := ds.CollectionCounts()
+	if len(counts) != 1 || counts["motions/motion"] != 1 {
+		t.Errorf("CollectionCounts returned %v, expected only 1 motion", counts)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1}`),
+		"motions/motion:2": []byte(`{"id":2}`),
+		"users/user:1":     []byte(`{"id":1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	expectStats := func(t *testing.T, length int, bytes int64, deleted int) {
+		t.Helper()
+		if got := ds.Len(); got != length {
+			t.Errorf("Len() returned %d, expected %d", got, length)
+		}
+		if got := ds.BytesEstimate(); got != bytes {
+			t.Errorf("BytesEstimate() returned %d, expected %d", got, bytes)
+		}
+		if got := ds.DeletedCount(); got != deleted {
+			t.Errorf("DeletedCount() returned %d, expected %d", got, deleted)
+		}
+	}
+
+	t.Run("initial data", func(t *testing.T) {
+		expectStats(t, 3, 24, 0)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		r.Send([]byte(`{"change_id": 6, "elements": {"motions/motion:2": {"id":2,"title":"changed"}, "motions/motion:3": {"id":3}}}`))
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+
+		// motions/motion:2 grows from 8 to 26 bytes, motions/motion:3 has 8 bytes.
+		expectStats(t, 4, 50, 0)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		r.Send([]byte(`{"change_id": 7, "elements": {"motions/motion:1": null, "users/user:5": null}}`))
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+
+		// A deleted key is counted, even if the element did not exist.
+		expectStats(t, 3, 42, 2)
+	})
+
+	t.Run("create deleted element again", func(t *testing.T) {
+		r.Send([]byte(`{"change_id": 8, "elements": {"motions/motion:1": {"id":1}}}`))
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+
+		expectStats(t, 4, 50, 1)
+	})
+}
+
+func TestGetKeyChangeID(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1}`),
+		"motions/motion:2": []byte(`{"id":2}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	expectChangeID := func(t *testing.T, id int, expect int, expectOK bool) {
+		t.Helper()
+		got, ok := ds.GetKeyChangeID("motions/motion", id)
+		if ok != expectOK {
+			t.Fatalf("GetKeyChangeID(motions/motion, %d) returned ok %t, expected %t", id, ok, expectOK)
+		}
+		if got != expect {
+			t.Errorf("GetKeyChangeID(motions/motion, %d) returned %d, expected %d", id, got, expect)
+		}
+	}
+
+	t.Run("initial data", func(t *testing.T) {
+		expectChangeID(t, 1, 5, true)
+		expectChangeID(t, 2, 5, true)
+		expectChangeID(t, 3, 0, false)
+	})
+
+	t.Run("changed element", func(t *testing.T) {
+		r.Send([]byte(`{"change_id": 6, "elements": {"motions/motion:1": {"id":1,"title":"changed"}, "motions/motion:2": {"id":2}}}`))
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+
+		expectChangeID(t, 1, 6, true)
+
+		// motions/motion:2 was sent without a change.
+		expectChangeID(t, 2, 5, true)
+	})
+
+	t.Run("created element", func(t *testing.T) {
+		r.Send([]byte(`{"change_id": 7, "elements": {"motions/motion:3": {"id":3}}}`))
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+
+		expectChangeID(t, 1, 6, true)
+		expectChangeID(t, 3, 7, true)
+	})
+
+	t.Run("deleted element", func(t *testing.T) {
+		r.Send([]byte(`{"change_id": 8, "elements": {"motions/motion:2": null}}`))
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+
+		expectChangeID(t, 2, 8, true)
+	})
+
+	t.Run("deleted again", func(t *testing.T) {
+		r.Send([]byte(`{"change_id": 9, "elements": {"motions/motion:2": null}}`))
+		if _, _, err := ds.KeysChanged(); err != nil {
+			t.Fatalf("KeysChanged returned unexpected error: %v", err)
+		}
+
+		expectChangeID(t, 2, 8, true)
+	})
+}
+
+func TestRawElement(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+		"motions/motion:2": []byte(`{"id": 2}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	r.Send([]byte(`{"change_id": 6, "elements": {"motions/motion:2": null}}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	t.Run("present", func(t *testing.T) {
+		element, deleted, err := ds.RawElement("motions/motion:1")
+		if err != nil {
+			t.Fatalf("RawElement returned unexpected error: %v", err)
+		}
+		if deleted || string(element) != `{"id": 1}` {
+			t.Errorf("Got element `%s` and deleted %t, expected the cached bytes", element, deleted)
+		}
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		element, deleted, err := ds.RawElement("motions/motion:2")
+		if err != nil {
+			t.Fatalf("RawElement returned unexpected error: %v", err)
+		}
+		if !deleted || element != nil {
+			t.Errorf("Got element `%s` and deleted %t, expected a deleted element", element, deleted)
+		}
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		_, _, err := ds.RawElement("motions/motion:3")
+		if !errors.Is(err, datastore.ErrDoesNotExist) {
+			t.Errorf("RawElement returned error %v, expected a DoesNotExistError", err)
+		}
+	})
+}
+
+func TestKeysChangedBreaker(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+
+	var calls int
+	fail := true
+	r.ChangedKeysFunc = func(from, to int) ([]string, error) {
+		calls++
+		if fail {
+			return nil, errors.New("redis is overloaded")
+		}
+		return []string{"motions/motion:1"}, nil
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+	ds.SetBreaker(2, 10*time.Millisecond)
+
+	// Each update has a gap to the last known change id, so the missing data
+	// has to be received.
+	changeID := 6
+	sendWithGap := func() error {
+		changeID += 2
+		r.Send([]byte(fmt.Sprintf(`{"change_id": %d, "elements": {}}`, changeID)))
+		_, _, err := ds.KeysChanged()
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := sendWithGap(); err == nil {
+			t.Fatalf("KeysChanged did not return the error from redis")
+		}
+	}
+
+	if !ds.Degraded() {
+		t.Errorf("Degraded() returned false after two failures, expected true")
+	}
+
+	if err := sendWithGap(); err == nil {
+		t.Fatalf("KeysChanged with open breaker did not return an error")
+	}
+	if calls != 2 {
+		t.Errorf("Redis was requested %d times, expected 2", calls)
+	}
+
+	if motions := ds.GetCollection("motions/motion"); len(motions) != 1 {
+		t.Errorf("Got %d motions while degraded, expected the cached one", len(motions))
+	}
+
+	fail = false
+	time.Sleep(20 * time.Millisecond)
+
+	if err := sendWithGap(); err != nil {
+		t.Fatalf("KeysChanged after the cooldown returned unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Redis was requested %d times, expected 3", calls)
+	}
+
+	if ds.Degraded() {
+		t.Errorf("Degraded() returned true after a successful probe, expected false")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"elements/element:1": []byte(`{"id": 1}`),
+		"elements/element:2": []byte(`{"id": 2}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	changes, stop := ds.Watch(10, "elements/element")
+	defer stop()
+
+	// The slow watcher can only hold one change. The others are dropped
+	// without blocking the update.
+	slow, stopSlow := ds.Watch(1)
+	defer stopSlow()
+
+	r.Send([]byte(`{
+		"change_id": 6,
+		"elements": {
+			"elements/element:1": {"id": 1, "value": "new"},
+			"elements/element:2": null,
+			"elements/element:3": {"id": 3},
+			"other/element:1": {"id": 1}
+		}
+	}`))
+	if _, _, err := ds.KeysChanged(); err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	var got []datastore.ElementChange
+	for i := 0; i < 3; i++ {
+		got = append(got, <-changes)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].ID < got[j].ID })
+
+	expect := []datastore.ElementChange{
+		{Collection: "elements/element", ID: 1, Operation: datastore.OperationUpdated, ChangeID: 6},
+		{Collection: "elements/element", ID: 2, Operation: datastore.OperationDeleted, ChangeID: 6},
+		{Collection: "elements/element", ID: 3, Operation: datastore.OperationCreated, ChangeID: 6},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expect) {
+		t.Errorf("Got changes %v, expected %v", got, expect)
+	}
+
+	select {
+	case change := <-changes:
+		t.Errorf("Got unexpected change %v", change)
+	default:
+	}
+
+	if len(slow) != 1 {
+		t.Errorf("Slow watcher has %d changes, expected 1", len(slow))
+	}
+
+	stop()
+	if _, ok := <-changes; ok {
+		t.Errorf("Channel is open after stop")
+	}
+}
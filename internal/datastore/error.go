@@ -6,16 +6,64 @@ import (
 	"strings"
 )
 
-type doesNotExistError string
+// ErrDoesNotExist can be used with errors.Is to check, if an error is a
+// DoesNotExistError.
+var ErrDoesNotExist = errors.New("does not exist")
 
-func (e doesNotExistError) Error() string {
+// DoesNotExistError is returned, when an element does not exist. The value is
+// the key of the element like motions/motion:1.
+type DoesNotExistError string
+
+func (e DoesNotExistError) Error() string {
 	return fmt.Sprintf("%s does not exist", string(e))
 }
 
-func (e doesNotExistError) DoesNotExist() string {
+// DoesNotExist returns the key of the element.
+func (e DoesNotExistError) DoesNotExist() string {
 	return string(e)
 }
 
+// Is tells, that the error is ErrDoesNotExist.
+func (e DoesNotExistError) Is(target error) bool {
+	return target == ErrDoesNotExist
+}
+
+// FieldDoesNotExistError is returned, when an element exists, but does not
+// have the requested field.
+type FieldDoesNotExistError struct {
+	Key   string
+	Field string
+}
+
+func (e FieldDoesNotExistError) Error() string {
+	return fmt.Sprintf("%s has no field %s", e.Key, e.Field)
+}
+
+// FieldDoesNotExist returns the name of the field.
+func (e FieldDoesNotExistError) FieldDoesNotExist() string {
+	return e.Field
+}
+
+// ConfigTypeError is returned, when a config value has another type then
+// requested.
+type ConfigTypeError struct {
+	Key      string
+	Expected string
+	Got      string
+}
+
+func (e ConfigTypeError) Error() string {
+	return fmt.Sprintf("config `%s` is a %s, expected a %s", e.Key, e.Got, e.Expected)
+}
+
+type closingError struct{}
+
+func (e closingError) Error() string {
+	return "closing"
+}
+
+func (e closingError) Closing() {}
+
 type resetError struct{}
 
 func (e resetError) Error() string {
@@ -24,29 +72,47 @@ func (e resetError) Error() string {
 
 func (e resetError) Reset() {}
 
-type conditionError struct {
+// changeIDJumpError is returned by parseChangeID, when a change id is more then
+// the limit after the current change id.
+type changeIDJumpError struct {
+	changeID int
+	limit    int
+	current  int
+}
+
+func (e changeIDJumpError) Error() string {
+	return fmt.Sprintf("change id %d is more then %d after the current change id %d", e.changeID, e.limit, e.current)
+}
+
+// ConditionError is an error, that happened under some conditions. It is
+// created with Condition.Error.
+type ConditionError struct {
 	condition *Condition
 	err       error
 }
 
-func (e conditionError) Error() string {
+func (e ConditionError) Error() string {
 	return e.err.Error()
 }
 
-func (e conditionError) Unwrap() error {
+func (e ConditionError) Unwrap() error {
 	return e.err
 }
 
-func (e conditionError) Conditions() []string {
+// Conditions returns the descriptions of all conditions, including the
+// conditions of wrapped ConditionErrors.
+func (e ConditionError) Conditions() []string {
 	con := e.condition.getConditions()
-	var sErr conditionError
+	var sErr ConditionError
 	if errors.As(e.err, &sErr) {
 		con = append(con, sErr.Conditions()...)
 	}
 	return con
 }
 
-func (e conditionError) ConditionError() error {
+// ConditionError returns an error that describes the missing element and the
+// conditions, under which it is required.
+func (e ConditionError) ConditionError() error {
 	var dErr interface {
 		Error() string
 		DoesNotExist() string
@@ -69,7 +135,7 @@ func (e conditionError) ConditionError() error {
 // To do this, create a Condition at the beginning of a function and after each
 // state-ceck, append the condition of the database.
 //
-// If an error happens, that could be a doesNotExistError, then wrap the error
+// If an error happens, that could be a DoesNotExistError, then wrap the error
 // with Condition.Error().
 //
 // Example:
@@ -103,12 +169,12 @@ func (c *Condition) Append(format string, a ...interface{}) {
 func (c *Condition) Error(format string, a ...interface{}) error {
 	err := fmt.Errorf(format, a...)
 
-	var sErr *conditionError
+	var sErr ConditionError
 	var dErr interface {
 		DoesNotExist() string
 	}
 	if errors.As(err, &sErr) || errors.As(err, &dErr) {
-		return conditionError{
+		return ConditionError{
 			condition: c,
 			err:       err,
 		}
@@ -0,0 +1,98 @@
+package datastore_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestErrors(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"users/user:1": []byte(`{"id": 1, "username": "admin"}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	t.Run("Get missing element", func(t *testing.T) {
+		var user json.RawMessage
+		err := ds.Get("users/user", 2, &user)
+
+		var dErr datastore.DoesNotExistError
+		if !errors.As(err, &dErr) {
+			t.Fatalf("Get returned error `%v`, expected a DoesNotExistError", err)
+		}
+
+		if string(dErr) != "users/user:2" {
+			t.Errorf("DoesNotExistError has key %s, expected users/user:2", dErr)
+		}
+
+		if !errors.Is(err, datastore.ErrDoesNotExist) {
+			t.Errorf("errors.Is(err, ErrDoesNotExist) returned false")
+		}
+	})
+
+	t.Run("GetField missing field", func(t *testing.T) {
+		var value string
+		err := ds.GetField("users/user", 1, "unknown", &value)
+
+		var fErr datastore.FieldDoesNotExistError
+		if !errors.As(err, &fErr) {
+			t.Fatalf("GetField returned error `%v`, expected a FieldDoesNotExistError", err)
+		}
+
+		if fErr.Key != "users/user:1" || fErr.Field != "unknown" {
+			t.Errorf("FieldDoesNotExistError is %v, expected key users/user:1 and field unknown", fErr)
+		}
+
+		if errors.Is(err, datastore.ErrDoesNotExist) {
+			t.Errorf("errors.Is(err, ErrDoesNotExist) returned true for a missing field")
+		}
+	})
+
+	t.Run("Condition", func(t *testing.T) {
+		con := new(datastore.Condition)
+		con.Append("user is present")
+
+		inner := new(datastore.Condition)
+		inner.Append("user is a delegate")
+
+		var user json.RawMessage
+		err := inner.Error("getting user: %w", ds.Get("users/user", 2, &user))
+		err = con.Error("restricting: %w", err)
+
+		var cErr datastore.ConditionError
+		if !errors.As(err, &cErr) {
+			t.Fatalf("Condition.Error returned `%v`, expected a ConditionError", err)
+		}
+
+		expect := []string{"user is present", "user is a delegate"}
+		if got := cErr.Conditions(); !test.CmpStrSlice(got, expect) {
+			t.Errorf("Got conditions %v, expected %v", got, expect)
+		}
+
+		if !errors.Is(err, datastore.ErrDoesNotExist) {
+			t.Errorf("errors.Is(err, ErrDoesNotExist) returned false for a wrapped DoesNotExistError")
+		}
+	})
+
+	t.Run("Condition with other error", func(t *testing.T) {
+		con := new(datastore.Condition)
+		err := con.Error("something: %w", fmt.Errorf("other error"))
+
+		var cErr datastore.ConditionError
+		if errors.As(err, &cErr) {
+			t.Errorf("Condition.Error returned a ConditionError for an error, that is not a DoesNotExistError")
+		}
+	})
+}
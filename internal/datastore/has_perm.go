@@ -3,6 +3,7 @@ package datastore
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,12 +12,60 @@ import (
 const (
 	groupDefaultPK = 1
 	groupAdminPK   = 2
+
+	configAnonymous = "general_system_enable_anonymous"
 )
 
 type hasPerm struct {
 	mu        sync.RWMutex
 	groupPerm map[int]map[string]bool
 	userGroup map[int][]int
+
+	// changed is the change id of the last permission change of each user.
+	// invalidated are the users, whose permissions changed in the current
+	// update. They get their change id with commitChanged.
+	changed     map[int]int
+	invalidated map[int]bool
+
+	// config is used to check, if anonymous is enabled. Without config,
+	// anonymous is disabled.
+	config *config
+}
+
+// PermissionsChanged tells, if the permissions of the user changed after the
+// change id from until the change id to.
+func (h *hasPerm) PermissionsChanged(uid, from, to int) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	changeID, ok := h.changed[uid]
+	return ok && changeID > from && changeID <= to
+}
+
+// commitChanged sets the change id for all users, whose permissions changed
+// since the last call. Returns the number of these users.
+func (h *hasPerm) commitChanged(changeID int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.changed == nil {
+		h.changed = make(map[int]int)
+	}
+
+	count := len(h.invalidated)
+	for uid := range h.invalidated {
+		h.changed[uid] = changeID
+	}
+	h.invalidated = nil
+	return count
+}
+
+// invalidate marks the permissions of the user as changed.
+func (h *hasPerm) invalidate(uid int) {
+	if h.invalidated == nil {
+		h.invalidated = make(map[int]bool)
+	}
+	h.invalidated[uid] = true
 }
 
 func (h *hasPerm) HasPerm(uid int, perm string) bool {
@@ -24,7 +73,7 @@ func (h *hasPerm) HasPerm(uid int, perm string) bool {
 	defer h.mu.RUnlock()
 
 	if uid == 0 {
-		return h.groupPerm[groupDefaultPK][perm]
+		return h.anonymousEnabled() && h.groupPerm[groupDefaultPK][perm]
 	}
 
 	for _, groupID := range h.userGroup[uid] {
@@ -58,6 +107,36 @@ func (h *hasPerm) InGroups(uid int, groups []int) bool {
 	return h.IsSuperadmin(uid)
 }
 
+// GroupIDs returns the ids of all groups the user is in.
+//
+// For the anonymous user the default group is returned, if anonymous is
+// enabled. Otherwise the anonymous user is in no group.
+func (h *hasPerm) GroupIDs(uid int) []int {
+	if uid == 0 {
+		if !h.anonymousEnabled() {
+			return nil
+		}
+		return []int{groupDefaultPK}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	groups := h.userGroup[uid]
+	return append(groups[:0:0], groups...)
+}
+
+// anonymousEnabled tells, if the config general_system_enable_anonymous is
+// true.
+func (h *hasPerm) anonymousEnabled() bool {
+	if h.config == nil {
+		return false
+	}
+
+	enabled, err := Config{c: h.config}.Bool(configAnonymous)
+	return err == nil && enabled
+}
+
 func (h *hasPerm) IsSuperadmin(uid int) bool {
 	for _, groupID := range h.userGroup[uid] {
 		if groupID == groupAdminPK {
@@ -68,10 +147,50 @@ func (h *hasPerm) IsSuperadmin(uid int) bool {
 	return false
 }
 
+// Permissions returns the sorted permissions of the user. These are the
+// permissions, for which HasPerm returns true. The anonymous user has no
+// permissions, if anonymous is disabled.
+//
+// Users in the admin group have all permissions. For them, the permissions of
+// all groups are returned.
+func (h *hasPerm) Permissions(uid int) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	groupIDs := h.userGroup[uid]
+	if uid == 0 && h.anonymousEnabled() {
+		groupIDs = []int{groupDefaultPK}
+	}
+
+	if h.IsSuperadmin(uid) {
+		groupIDs = groupIDs[:0:0]
+		for groupID := range h.groupPerm {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+
+	set := make(map[string]bool)
+	for _, groupID := range groupIDs {
+		for perm, ok := range h.groupPerm[groupID] {
+			if ok {
+				set[perm] = true
+			}
+		}
+	}
+
+	perms := make([]string, 0, len(set))
+	for perm := range set {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
 func (h *hasPerm) update(data map[string]json.RawMessage) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	changedGroups := make(map[int]bool)
 	for k, v := range data {
 		parts := strings.Split(k, ":")
 		if len(parts) != 2 {
@@ -83,35 +202,64 @@ func (h *hasPerm) update(data map[string]json.RawMessage) error {
 			return fmt.Errorf("key %s has wrong format. Expected id to be int not %s", k, parts[1])
 		}
 
+		var changed bool
 		switch parts[0] {
 		case "users/user":
-			err = h.updateUserGroup(id, v)
+			changed, err = h.updateUserGroup(id, v)
+			if changed {
+				h.invalidate(id)
+			}
 		case "users/group":
-			err = h.updateGroupPerm(id, v)
+			changed, err = h.updateGroupPerm(id, v)
+			if changed {
+				changedGroups[id] = true
+			}
 		}
 		if err != nil {
 			return fmt.Errorf("update %s: %w", k, err)
 		}
 	}
+
+	if len(changedGroups) == 0 {
+		return nil
+	}
+
+	// The anonymous user has the permissions of the default group.
+	if changedGroups[groupDefaultPK] {
+		h.invalidate(0)
+	}
+
+	for uid, groups := range h.userGroup {
+		for _, groupID := range groups {
+			if changedGroups[groupID] {
+				h.invalidate(uid)
+				break
+			}
+		}
+	}
 	return nil
 }
 
-func (h *hasPerm) updateGroupPerm(id int, data json.RawMessage) error {
+// updateGroupPerm updates the permissions of a group. Returns true, if the
+// permissions have changed.
+func (h *hasPerm) updateGroupPerm(id int, data json.RawMessage) (bool, error) {
 	if h.groupPerm == nil {
 		h.groupPerm = make(map[int]map[string]bool)
 	}
 
+	old, existed := h.groupPerm[id]
+
 	if data == nil {
 		// Group deleted.
 		delete(h.groupPerm, id)
-		return nil
+		return existed && id != groupAdminPK, nil
 	}
 
 	var group struct {
 		Permissions []string `json:"permissions"`
 	}
 	if err := json.Unmarshal(data, &group); err != nil {
-		return fmt.Errorf("unmarshal group: %w", err)
+		return false, fmt.Errorf("unmarshal group: %w", err)
 	}
 
 	// Reset perms. It would be faster to reuse the map
@@ -120,18 +268,40 @@ func (h *hasPerm) updateGroupPerm(id int, data json.RawMessage) error {
 	for _, perm := range group.Permissions {
 		h.groupPerm[id][perm] = true
 	}
-	return nil
+
+	// The admin group has all permissions.
+	if id == groupAdminPK {
+		return false, nil
+	}
+	return !samePerms(old, h.groupPerm[id]), nil
 }
 
-func (h *hasPerm) updateUserGroup(id int, data json.RawMessage) error {
+// samePerms tells, if both permission sets are equal.
+func samePerms(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for perm := range a {
+		if !b[perm] {
+			return false
+		}
+	}
+	return true
+}
+
+// updateUserGroup updates the groups of a user. Returns true, if the groups
+// have changed.
+func (h *hasPerm) updateUserGroup(id int, data json.RawMessage) (bool, error) {
 	if h.userGroup == nil {
 		h.userGroup = make(map[int][]int)
 	}
 
+	old, existed := h.userGroup[id]
+
 	if data == nil {
 		// User deleted.
 		delete(h.userGroup, id)
-		return nil
+		return existed, nil
 	}
 
 	var user struct {
@@ -140,7 +310,7 @@ func (h *hasPerm) updateUserGroup(id int, data json.RawMessage) error {
 	}
 
 	if err := json.Unmarshal(data, &user); err != nil {
-		return fmt.Errorf("unmarshal user: %w", err)
+		return false, fmt.Errorf("unmarshal user: %w", err)
 	}
 
 	h.userGroup[id] = user.GroupsID
@@ -148,5 +318,23 @@ func (h *hasPerm) updateUserGroup(id int, data json.RawMessage) error {
 	if len(user.GroupsID) == 0 {
 		h.userGroup[id] = []int{groupDefaultPK}
 	}
-	return nil
+	return !sameGroups(old, h.userGroup[id]), nil
+}
+
+// sameGroups tells, if both lists contain the same group ids.
+func sameGroups(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[int]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+	for _, id := range b {
+		if !set[id] {
+			return false
+		}
+	}
+	return true
 }
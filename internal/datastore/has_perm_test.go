@@ -62,6 +62,9 @@ func TestHasPerm(t *testing.T) {
 				1: {"my.perm": true},
 				3: {"other.perm": true},
 			},
+			config: &config{values: map[string]json.RawMessage{
+				"general_system_enable_anonymous": []byte(`true`),
+			}},
 		}
 
 		if !hp.HasPerm(0, "my.perm") {
@@ -72,6 +75,21 @@ func TestHasPerm(t *testing.T) {
 			t.Errorf("HasPerm(0, other.perm) returned true, expected false")
 		}
 	})
+
+	t.Run("anonymous disabled", func(t *testing.T) {
+		hp := &hasPerm{
+			groupPerm: map[int]map[string]bool{
+				1: {"my.perm": true},
+			},
+			config: &config{values: map[string]json.RawMessage{
+				"general_system_enable_anonymous": []byte(`false`),
+			}},
+		}
+
+		if hp.HasPerm(0, "my.perm") {
+			t.Errorf("HasPerm(0, my.perm) returned true, expected false")
+		}
+	})
 }
 
 func TestInGroups(t *testing.T) {
@@ -135,6 +153,121 @@ func TestIsSuperadmin(t *testing.T) {
 	}
 }
 
+func TestGroupIDs(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		uid       int
+		anonymous string
+		expect    []int
+	}{
+		{
+			"normal user",
+			2,
+			`false`,
+			[]int{3, 4},
+		},
+		{
+			"admin",
+			1,
+			`false`,
+			[]int{2},
+		},
+		{
+			"anonymous enabled",
+			0,
+			`true`,
+			[]int{1},
+		},
+		{
+			"anonymous disabled",
+			0,
+			`false`,
+			nil,
+		},
+		{
+			"unknown user",
+			3,
+			`true`,
+			nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := &hasPerm{
+				userGroup: map[int][]int{
+					1: {2},
+					2: {3, 4},
+				},
+				config: &config{values: map[string]json.RawMessage{
+					"general_system_enable_anonymous": []byte(tt.anonymous),
+				}},
+			}
+
+			if got := hp.GroupIDs(tt.uid); !test.CmpIntSlice(got, tt.expect) {
+				t.Errorf("GroupIDs(%d) returned %v, expected %v", tt.uid, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	hp := &hasPerm{
+		userGroup: map[int][]int{
+			1: {2},
+			2: {3, 4},
+			3: {4},
+		},
+		groupPerm: map[int]map[string]bool{
+			1: {"default.perm": true},
+			3: {"my.perm": true, "other.perm": true},
+			4: {"my.perm": true, "third.perm": true},
+		},
+		config: &config{values: map[string]json.RawMessage{
+			"general_system_enable_anonymous": []byte(`true`),
+		}},
+	}
+	allPerms := []string{"default.perm", "my.perm", "other.perm", "third.perm"}
+
+	for _, tt := range []struct {
+		name   string
+		uid    int
+		expect []string
+	}{
+		{"normal user", 2, []string{"my.perm", "other.perm", "third.perm"}},
+		{"one group", 3, []string{"my.perm", "third.perm"}},
+		{"admin", 1, allPerms},
+		{"anonymous", 0, []string{"default.perm"}},
+		{"unknown user", 4, []string{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hp.Permissions(tt.uid)
+			if !test.CmpStrSlice(got, tt.expect) {
+				t.Errorf("Permissions(%d) returned %v, expected %v", tt.uid, got, tt.expect)
+			}
+
+			// The permissions have to be the same as HasPerm.
+			listed := make(map[string]bool, len(got))
+			for _, perm := range got {
+				listed[perm] = true
+			}
+			for _, perm := range allPerms {
+				if hp.HasPerm(tt.uid, perm) != listed[perm] {
+					t.Errorf("HasPerm(%d, %s) returned %t, but Permissions lists it: %t", tt.uid, perm, !listed[perm], listed[perm])
+				}
+			}
+		})
+	}
+
+	t.Run("anonymous disabled", func(t *testing.T) {
+		hp.config = &config{values: map[string]json.RawMessage{
+			"general_system_enable_anonymous": []byte(`false`),
+		}}
+
+		if got := hp.Permissions(0); len(got) != 0 {
+			t.Errorf("Permissions(0) returned %v, expected []", got)
+		}
+	})
+}
+
 func TestHasPermUpdate(t *testing.T) {
 	hp := hasPerm{}
 
@@ -204,3 +337,82 @@ func TestHasPermUpdateDeletedUserAndGroup(t *testing.T) {
 		t.Errorf("hp.userGroup[3] == %v, expected nil", hp.userGroup[1])
 	}
 }
+
+func TestHasPermPermissionsChanged(t *testing.T) {
+	initial := map[string]json.RawMessage{
+		"users/group:1": []byte(`{"id": 1, "permissions": ["core.can_see_frontpage"]}`),
+		"users/group:3": []byte(`{"id": 3, "permissions": ["motions.can_see"]}`),
+		"users/group:4": []byte(`{"id": 4, "permissions": ["agenda.can_see"]}`),
+		"users/user:1":  []byte(`{"id": 1, "groups_id": [3]}`),
+		"users/user:2":  []byte(`{"id": 2, "groups_id": [4]}`),
+		"users/user:3":  []byte(`{"id": 3, "groups_id": [3, 4]}`),
+		"users/user:4":  []byte(`{"id": 4, "groups_id": []}`),
+	}
+
+	for _, tt := range []struct {
+		name    string
+		data    map[string]json.RawMessage
+		changed []int
+	}{
+		{
+			"group permissions",
+			map[string]json.RawMessage{"users/group:3": []byte(`{"id": 3, "permissions": ["motions.can_see", "motions.can_manage"]}`)},
+			[]int{1, 3},
+		},
+		{
+			"same group permissions",
+			map[string]json.RawMessage{"users/group:3": []byte(`{"id": 3, "name": "new name", "permissions": ["motions.can_see"]}`)},
+			nil,
+		},
+		{
+			"group deleted",
+			map[string]json.RawMessage{"users/group:4": nil},
+			[]int{2, 3},
+		},
+		{
+			"default group",
+			map[string]json.RawMessage{"users/group:1": []byte(`{"id": 1, "permissions": []}`)},
+			[]int{0, 4},
+		},
+		{
+			"user groups",
+			map[string]json.RawMessage{"users/user:2": []byte(`{"id": 2, "groups_id": [3]}`)},
+			[]int{2},
+		},
+		{
+			"same user groups",
+			map[string]json.RawMessage{"users/user:3": []byte(`{"id": 3, "username": "new", "groups_id": [4, 3]}`)},
+			nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := new(hasPerm)
+			if err := hp.update(initial); err != nil {
+				t.Fatalf("Initial update returned unexpected error: %v", err)
+			}
+			hp.commitChanged(1)
+
+			if err := hp.update(tt.data); err != nil {
+				t.Fatalf("update returned unexpected error: %v", err)
+			}
+			if count := hp.commitChanged(2); count != len(tt.changed) {
+				t.Errorf("commitChanged returned %d, expected %d", count, len(tt.changed))
+			}
+
+			expect := make(map[int]bool)
+			for _, uid := range tt.changed {
+				expect[uid] = true
+			}
+
+			for uid := 0; uid <= 4; uid++ {
+				if got := hp.PermissionsChanged(uid, 1, 2); got != expect[uid] {
+					t.Errorf("PermissionsChanged(%d, 1, 2) returned %t, expected %t", uid, got, expect[uid])
+				}
+
+				if hp.PermissionsChanged(uid, 2, 3) {
+					t.Errorf("PermissionsChanged(%d, 2, 3) returned true, expected false", uid)
+				}
+			}
+		})
+	}
+}
@@ -7,7 +7,26 @@ import (
 // RedisConn calls all needed redis commands.
 type RedisConn interface {
 	FullData() (data map[string]json.RawMessage, max int, min int, err error)
+	ChangeIDs() (max int, min int, err error)
 	Update(<-chan struct{}) ([]byte, error)
 	ChangedKeys(from, to int) ([]string, error)
 	Data(keys []string) (map[string]json.RawMessage, error)
 }
+
+// KeyChangeIDer is a RedisConn, that knows the change id of the last change of
+// each key. Shards use it to find the newest value of a key.
+type KeyChangeIDer interface {
+	// KeyChangeIDs returns the change ids of the keys. Unknown keys are not in
+	// the result.
+	KeyChangeIDs(keys []string) (map[string]int, error)
+}
+
+// Worker returns data that redis does not have anymore.
+type Worker interface {
+	ChangedData(from, to int) (map[string]json.RawMessage, error)
+}
+
+// Restricter restricts data for one user.
+type Restricter interface {
+	Restrict(uid int, data map[string]json.RawMessage)
+}
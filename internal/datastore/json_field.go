@@ -0,0 +1,132 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonField returns the raw value of a field of a json object. Returns nil, if
+// the object does not have the field.
+//
+// The object is only scanned until the field is found. The values of the
+// other fields are skipped without decoding them.
+func jsonField(data json.RawMessage, field string) (json.RawMessage, error) {
+	i := skipSpace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return nil, fmt.Errorf("value is not an object")
+	}
+	i++
+
+	for {
+		i = skipSpace(data, i)
+		if i >= len(data) {
+			return nil, fmt.Errorf("unexpected end of object")
+		}
+
+		switch data[i] {
+		case '}':
+			return nil, nil
+		case ',':
+			i++
+			continue
+		}
+
+		keyEnd, err := skipValue(data, i)
+		if err != nil {
+			return nil, fmt.Errorf("reading key: %w", err)
+		}
+		rawKey := data[i:keyEnd]
+
+		i = skipSpace(data, keyEnd)
+		if i >= len(data) || data[i] != ':' {
+			return nil, fmt.Errorf("missing colon after key %s", rawKey)
+		}
+		i = skipSpace(data, i+1)
+
+		valueEnd, err := skipValue(data, i)
+		if err != nil {
+			return nil, fmt.Errorf("reading value of %s: %w", rawKey, err)
+		}
+
+		var key string
+		if !bytes.ContainsRune(rawKey, '\\') {
+			key = string(rawKey[1 : len(rawKey)-1])
+		} else if err := json.Unmarshal(rawKey, &key); err != nil {
+			return nil, fmt.Errorf("decoding key %s: %w", rawKey, err)
+		}
+
+		if key == field {
+			return data[i:valueEnd], nil
+		}
+		i = valueEnd
+	}
+}
+
+// skipSpace returns the index of the first non whitespace character at or
+// after i.
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipValue returns the index after the json value that starts at i.
+func skipValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+
+	switch data[i] {
+	case '"':
+		for i++; i < len(data); i++ {
+			next := bytes.IndexAny(data[i:], `"\\`)
+			if next == -1 {
+				break
+			}
+			i += next
+
+			if data[i] == '"' {
+				return i + 1, nil
+			}
+			// Skip the escaped character.
+			i++
+		}
+		return 0, fmt.Errorf("unterminated string")
+
+	case '{', '[':
+		depth := 0
+		for i < len(data) {
+			switch data[i] {
+			case '"':
+				end, err := skipValue(data, i)
+				if err != nil {
+					return 0, err
+				}
+				i = end
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+			i++
+		}
+		return 0, fmt.Errorf("unterminated object or array")
+
+	default:
+		// Numbers, true, false and null.
+		start := i
+		for i < len(data) && !bytes.ContainsRune([]byte(",}] \t\n\r"), rune(data[i])) {
+			i++
+		}
+		if i == start {
+			return 0, fmt.Errorf("invalid character %q", data[i])
+		}
+		return i, nil
+	}
+}
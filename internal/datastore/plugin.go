@@ -0,0 +1,116 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+)
+
+// Plugin adds the collections of an OpenSlides plugin, for example a voting
+// plugin, to the service.
+type Plugin struct {
+	// Name of the plugin. It is used in the logs.
+	Name string
+
+	// Restricters are the restricters for the collections of the plugin. The
+	// key is the collection name. A collection without a restricter is hidden
+	// from every user.
+	Restricters func(ds restricter.HasPermer) map[string]restricter.Element
+
+	// RequiredUsers is optional. It works like the required users of the
+	// collections of OpenSlides, that are given to New.
+	RequiredUsers map[string]func(json.RawMessage) (map[int]bool, string, error)
+
+	// Update is optional. It is called with the changed data on each update
+	// like the subsystems of the datastore, for example to read config values.
+	Update func(data map[string]json.RawMessage) error
+}
+
+var (
+	pluginsMu sync.Mutex
+	plugins   = make(map[string]Plugin)
+)
+
+// RegisterPlugin registers a plugin. It has to be called before the datastore
+// is created, for example in an init function. Datastores, that are created
+// before, do not know the plugin.
+//
+// Panics, if the plugin has no name or a plugin with the same name is already
+// registered.
+func RegisterPlugin(p Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	if p.Name == "" {
+		panic("datastore: RegisterPlugin called without a name")
+	}
+
+	if _, ok := plugins[p.Name]; ok {
+		panic(fmt.Sprintf("datastore: RegisterPlugin called twice for plugin %s", p.Name))
+	}
+	plugins[p.Name] = p
+}
+
+// UnregisterPlugin removes a registered plugin. Like RegisterPlugin, it does
+// not change datastores, that are already created.
+func UnregisterPlugin(name string) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	delete(plugins, name)
+}
+
+// registeredPlugins returns the registered plugins sorted by name.
+func registeredPlugins() []Plugin {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	ps := make([]Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Name < ps[j].Name })
+	return ps
+}
+
+// PluginRestricters returns the restricters of all plugins, that were
+// registered when the datastore was created. They have to be registered at the
+// restricter.
+func (d *Datastore) PluginRestricters() map[string]restricter.Element {
+	restricters := make(map[string]restricter.Element)
+	for _, p := range d.plugins {
+		if p.Restricters == nil {
+			continue
+		}
+
+		for collection, e := range p.Restricters(d) {
+			restricters[collection] = e
+		}
+	}
+	return restricters
+}
+
+// pluginRequiredUsers returns the required users given to New together with
+// the required users of the plugins. A plugin can not replace the required
+// users of an existing collection.
+func pluginRequiredUsers(requiredUsers map[string]func(json.RawMessage) (map[int]bool, string, error), ps []Plugin, log logger.Logger) map[string]func(json.RawMessage) (map[int]bool, string, error) {
+	merged := make(map[string]func(json.RawMessage) (map[int]bool, string, error), len(requiredUsers))
+	for collection, fn := range requiredUsers {
+		merged[collection] = fn
+	}
+
+	for _, p := range ps {
+		for collection, fn := range p.RequiredUsers {
+			if _, ok := merged[collection]; ok {
+				log.Error("Plugin can not replace the required users of a collection", "plugin", p.Name, "collection", collection)
+				continue
+			}
+			merged[collection] = fn
+		}
+	}
+	return merged
+}
@@ -0,0 +1,92 @@
+package datastore_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestPlugin(t *testing.T) {
+	var updated []string
+	datastore.RegisterPlugin(datastore.Plugin{
+		Name: "test-voting",
+		Restricters: func(ds restricter.HasPermer) map[string]restricter.Element {
+			return map[string]restricter.Element{
+				"voting/ballot": restricter.ElementFunc(func(uid int, element json.RawMessage) (json.RawMessage, error) {
+					if !ds.HasPerm(uid, "voting.can_see") {
+						return nil, nil
+					}
+					return element, nil
+				}),
+			}
+		},
+		RequiredUsers: map[string]func(json.RawMessage) (map[int]bool, string, error){
+			"voting/ballot": func(json.RawMessage) (map[int]bool, string, error) {
+				return map[int]bool{3: true}, "voting.can_see", nil
+			},
+		},
+		Update: func(data map[string]json.RawMessage) error {
+			for key := range data {
+				updated = append(updated, key)
+			}
+			return nil
+		},
+	})
+	defer datastore.UnregisterPlugin("test-voting")
+
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{
+		"voting/ballot:1": []byte(`{"id":1}`),
+		"users/user:1":    []byte(`{"id":1,"groups_id":[2]}`),
+		"users/user:2":    []byte(`{"id":2,"groups_id":[3]}`),
+		"users/group:2":   []byte(`{"id":2,"permissions":["voting.can_see"]}`),
+		"users/group:3":   []byte(`{"id":3,"permissions":[]}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if len(updated) != len(r.FD) {
+		t.Errorf("Update of the plugin was called with %v, expected all keys", updated)
+	}
+
+	if got := ds.UserRequired(3); len(got) != 1 || got[0] != "voting.can_see" {
+		t.Errorf("UserRequired(3) returned %v, expected [voting.can_see]", got)
+	}
+
+	rs := restricter.New(ds, ds.PluginRestricters())
+	for _, tt := range []struct {
+		uid    int
+		expect string
+	}{
+		{1, `{"id":1}`},
+		{2, ``},
+	} {
+		data := map[string]json.RawMessage{"voting/ballot:1": []byte(`{"id":1}`)}
+		rs.Restrict(tt.uid, data)
+
+		if got := string(data["voting/ballot:1"]); got != tt.expect {
+			t.Errorf("User %d got `%s`, expected `%s`", tt.uid, got, tt.expect)
+		}
+	}
+}
+
+func TestRegisterPluginTwice(t *testing.T) {
+	datastore.RegisterPlugin(datastore.Plugin{Name: "test-twice"})
+	defer datastore.UnregisterPlugin("test-twice")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterPlugin did not panic")
+		}
+	}()
+	datastore.RegisterPlugin(datastore.Plugin{Name: "test-twice"})
+}
@@ -6,11 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/projector"
 	"github.com/ostcar/topic"
 )
@@ -19,15 +19,17 @@ import (
 type Projectors struct {
 	mu         sync.RWMutex
 	projectors map[int]json.RawMessage
+	elements   map[int][]projectorElementData
 	closed     <-chan struct{}
 	callables  map[string]projector.Callable
 	topic      *topic.Topic
 	ds         projector.Datastore
+	log        logger.Logger
 }
 
 // NewProjectors returns a new projector instance.
-func NewProjectors(ds projector.Datastore, ps map[string]projector.Callable, closed <-chan struct{}) *Projectors {
-	return &Projectors{ds: ds, callables: ps, closed: closed}
+func NewProjectors(ds projector.Datastore, ps map[string]projector.Callable, log logger.Logger, closed <-chan struct{}) *Projectors {
+	return &Projectors{ds: ds, callables: ps, log: log, closed: closed}
 }
 
 // ProjectorData returns the data of every changed projector.
@@ -52,6 +54,9 @@ func (p *Projectors) ProjectorData(ctx context.Context, tid uint64) (uint64, map
 }
 
 // Update updates the cache of the projector.
+//
+// A slide of a projector.Dependent is only built again, if the projector or
+// one of the collections of the slide is in data.
 func (p *Projectors) Update(data map[string]json.RawMessage) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -59,14 +64,18 @@ func (p *Projectors) Update(data map[string]json.RawMessage) error {
 	if p.topic == nil {
 		p.topic = topic.New(topic.WithClosed(p.closed))
 		p.projectors = make(map[int]json.RawMessage)
+		p.elements = make(map[int][]projectorElementData)
 	}
 
+	changedCollections := make(map[string]bool)
+	changedProjectors := make(map[int]bool)
 	for k, v := range data {
 		parts := strings.Split(k, ":")
 		if len(parts) != 2 {
 			return fmt.Errorf("key %s has wrong format. Expected one `:`", k)
 		}
 
+		changedCollections[parts[0]] = true
 		if parts[0] != "core/projector" {
 			continue
 		}
@@ -80,15 +89,19 @@ func (p *Projectors) Update(data map[string]json.RawMessage) error {
 			// Projector was deleted.
 			// TODO: How inform clients about a deleted projectors??
 			delete(p.projectors, id)
+			delete(p.elements, id)
 			continue
 		}
 
+		changedProjectors[id] = true
+
 		if _, ok := p.projectors[id]; !ok {
 			p.projectors[id] = nil
 		}
 	}
 
 	var changed []string
+	var changedIDs []int
 	for id := range p.projectors {
 		var elements struct {
 			Elements []json.RawMessage `json:"elements"`
@@ -122,6 +135,11 @@ func (p *Projectors) Update(data map[string]json.RawMessage) error {
 				continue
 			}
 
+			if old, ok := p.unchanged(id, i, element, c, changedProjectors, changedCollections); ok {
+				ped[i] = old
+				continue
+			}
+
 			data, err := c.Build(p.ds, element, id)
 			if err != nil {
 				if err := ped[i].setError(fmt.Errorf("building slide with %s: %w", namer.Name, err)); err != nil {
@@ -137,6 +155,7 @@ func (p *Projectors) Update(data map[string]json.RawMessage) error {
 		if err != nil {
 			return fmt.Errorf("decoding projector elements %w", err)
 		}
+		p.elements[id] = ped
 
 		if bytes.Equal(p.projectors[id], rendered) {
 			continue
@@ -144,6 +163,7 @@ func (p *Projectors) Update(data map[string]json.RawMessage) error {
 
 		p.projectors[id] = rendered
 		changed = append(changed, string(rune(id)))
+		changedIDs = append(changedIDs, id)
 	}
 
 	if len(changed) == 0 {
@@ -151,11 +171,32 @@ func (p *Projectors) Update(data map[string]json.RawMessage) error {
 		return nil
 	}
 
-	log.Println("Projector-Data changed: ", changed)
+	p.log.Debug("Projector data changed", "projector_ids", changedIDs)
 	p.topic.Publish(changed...)
 	return nil
 }
 
+// unchanged returns the element data from the last update, if the slide does
+// not have to be built again.
+func (p *Projectors) unchanged(pid, idx int, element json.RawMessage, c projector.Callable, changedProjectors map[int]bool, changedCollections map[string]bool) (projectorElementData, bool) {
+	d, ok := c.(projector.Dependent)
+	if !ok || changedProjectors[pid] {
+		return projectorElementData{}, false
+	}
+
+	old := p.elements[pid]
+	if idx >= len(old) || !bytes.Equal(old[idx].Element, element) {
+		return projectorElementData{}, false
+	}
+
+	for _, collection := range d.Dependencies() {
+		if changedCollections[collection] {
+			return projectorElementData{}, false
+		}
+	}
+	return old[idx], true
+}
+
 type projectorData struct {
 	elements []json.RawMessage
 	rendered json.RawMessage
@@ -167,6 +208,15 @@ type projectorElementData struct {
 }
 
 func (p *projectorElementData) setError(err error) error {
+	// A slide can reference an element that was deleted. This is not an
+	// internal error. The other slides of the projector are still built.
+	var doesNotExist interface {
+		DoesNotExist() string
+	}
+	if errors.As(err, &doesNotExist) {
+		err = projector.NewClientError("%s does not exist", doesNotExist.DoesNotExist())
+	}
+
 	var ce projector.ClientError
 	if !errors.As(err, &ce) {
 		p.Data = []byte(`{"error":"Internal error"}`)
@@ -184,5 +234,4 @@ func (p *projectorElementData) setError(err error) error {
 	}
 	p.Data = v
 	return nil
-
 }
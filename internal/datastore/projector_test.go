@@ -0,0 +1,126 @@
+package datastore_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/projector"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestProjectorTwoElements(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	ds := test.NewDatastoreMock(1, closed)
+	ds.FullData = map[string]json.RawMessage{
+		"core/projector:1": []byte(`{"id":1,"elements":[{"name":"motions/motion","id":1},{"name":"motions/motion","id":2}]}`),
+		"motions/motion:1": []byte(`{"id":1,"title":"first"}`),
+	}
+
+	callables := map[string]projector.Callable{
+		"motions/motion": projector.CallableFunc(func(ds projector.Datastore, element json.RawMessage, pid int) (json.RawMessage, error) {
+			var e struct {
+				ID int `json:"id"`
+			}
+			if err := json.Unmarshal(element, &e); err != nil {
+				return nil, fmt.Errorf("decoding element: %w", err)
+			}
+
+			var motion struct {
+				Title string `json:"title"`
+			}
+			if err := ds.Get("motions/motion", e.ID, &motion); err != nil {
+				return nil, fmt.Errorf("getting motion: %w", err)
+			}
+			return json.Marshal(motion)
+		}),
+	}
+
+	p := datastore.NewProjectors(ds, callables, logger.Noop, closed)
+	if err := p.Update(ds.FullData); err != nil {
+		t.Fatalf("Update returned unexpected error: %v", err)
+	}
+
+	tid, data, err := p.ProjectorData(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("ProjectorData returned unexpected error: %v", err)
+	}
+
+	expect := `[
+		{"element":{"name":"motions/motion","id":1},"data":{"title":"first"}},
+		{"element":{"name":"motions/motion","id":2},"data":{"error":"motions/motion:2 does not exist"}}
+	]`
+	test.ExpectEqualJSON(t, data[1], []byte(expect))
+
+	t.Run("update", func(t *testing.T) {
+		ds.FullData["motions/motion:2"] = []byte(`{"id":2,"title":"second"}`)
+		if err := p.Update(map[string]json.RawMessage{"motions/motion:2": ds.FullData["motions/motion:2"]}); err != nil {
+			t.Fatalf("Update returned unexpected error: %v", err)
+		}
+
+		_, data, err := p.ProjectorData(context.Background(), tid)
+		if err != nil {
+			t.Fatalf("ProjectorData returned unexpected error: %v", err)
+		}
+
+		expect := `[
+			{"element":{"name":"motions/motion","id":1},"data":{"title":"first"}},
+			{"element":{"name":"motions/motion","id":2},"data":{"title":"second"}}
+		]`
+		test.ExpectEqualJSON(t, data[1], []byte(expect))
+	})
+}
+
+func TestProjectorDependencies(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	ds := test.NewDatastoreMock(1, closed)
+	ds.FullData = map[string]json.RawMessage{
+		"core/projector:1":         []byte(`{"id":1,"elements":[{"name":"core/projector-message","id":1}]}`),
+		"core/projector-message:1": []byte(`{"id":1,"message":"hello"}`),
+		"motions/motion:1":         []byte(`{"id":1}`),
+	}
+
+	var builds int
+	callables := map[string]projector.Callable{
+		"core/projector-message": projector.DependsOn(projector.CallableFunc(func(ds projector.Datastore, element json.RawMessage, pid int) (json.RawMessage, error) {
+			builds++
+			var message json.RawMessage
+			if err := projector.ModelFromElement(ds, element, "core/projector-message", &message); err != nil {
+				return nil, err
+			}
+			return message, nil
+		}), "core/projector-message"),
+	}
+
+	p := datastore.NewProjectors(ds, callables, logger.Noop, closed)
+	if err := p.Update(ds.FullData); err != nil {
+		t.Fatalf("Update returned unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		key    string
+		builds int
+	}{
+		{"unrelated change", "motions/motion:1", 1},
+		{"dependency", "core/projector-message:1", 2},
+		{"projector", "core/projector:1", 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.Update(map[string]json.RawMessage{tt.key: ds.FullData[tt.key]}); err != nil {
+				t.Fatalf("Update returned unexpected error: %v", err)
+			}
+
+			if builds != tt.builds {
+				t.Errorf("Slide was built %d times, expected %d", builds, tt.builds)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+)
+
+var driftCount, _ = global.GetMeterProvider().Meter("openslides.org").NewInt64Counter(
+	"openslides.cache-drift-count",
+	metric.WithDescription("cached elements that differed from redis"),
+)
+
+// drift is a difference between the cache and redis found by Reconcile.
+type drift struct {
+	// elements are the values from redis for the keys, that differ. A nil
+	// value means, that the element does not exist in redis.
+	elements map[string]json.RawMessage
+
+	// changeID is the change id of the cache, before the elements were read
+	// from redis.
+	changeID int
+
+	// reset is true, if the cache has to be initialized again.
+	reset bool
+
+	// done receives the result of the reset, if it is not nil.
+	done chan<- error
+}
+
+// Reconcile compares the cache with redis and repairs the cache, if a change
+// message from redis was lost.
+//
+// If the change id of redis is lower then the change id of the cache, redis
+// was probably reset and the datastore is reset. Otherwise up to sampleSize
+// keys are compared. The keys are taken from the keys, that changed in redis,
+// and from the cache. The elements that differ are replaced with the values
+// from redis.
+//
+// The repair is done by KeysChanged, so Reconcile can be called from another
+// goroutine. Connected clients receive the repaired elements with the next
+// update. Reconcile blocks until KeysChanged received the repair.
+//
+// Returns the number of elements that differ.
+func (d *Datastore) Reconcile(sampleSize int) (int, error) {
+	max, min, err := d.redisConn.ChangeIDs()
+	if err != nil {
+		return 0, fmt.Errorf("get change ids from redis: %w", err)
+	}
+
+	if current := d.CurrentID(); max < current {
+		d.log.Info("Redis change id is lower then the cache change id", "redis_change_id", max, "change_id", current)
+		driftCount.Add(context.Background(), 1, label.String("type", "reset"))
+		return 1, d.sendDrift(drift{reset: true})
+	}
+
+	keys, err := d.sampleKeys(min, max, sampleSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	// An update between the read from redis and the read from the cache would
+	// look like a drift. The values from redis are older then the cache.
+	changeID := d.CurrentID()
+
+	values, err := d.redisConn.Data(keys)
+	if err != nil {
+		return 0, fmt.Errorf("get data: %w", err)
+	}
+
+	cached := d.cache.forKeys(keys...)
+	if current := d.CurrentID(); current != changeID {
+		d.log.Debug("Skipping reconcile, the cache was updated", "change_id", changeID, "new_change_id", current)
+		return 0, nil
+	}
+
+	changed := make(map[string]json.RawMessage)
+	for _, key := range keys {
+		if !jsonEqual(cached[key], values[key]) {
+			changed[key] = normalizeNull(values[key])
+		}
+	}
+
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	d.log.Info("Cache differs from redis", "elements", len(changed))
+	driftCount.Add(context.Background(), int64(len(changed)), label.String("type", "element"))
+	return len(changed), d.sendDrift(drift{elements: changed, changeID: changeID})
+}
+
+// sampleKeys returns up to size random keys. The keys that changed in redis
+// are preferred, because a lost change message can only be found with them.
+func (d *Datastore) sampleKeys(min, max, size int) ([]string, error) {
+	changedKeys, err := d.redisConn.ChangedKeys(min, max)
+	if err != nil {
+		return nil, fmt.Errorf("get changed keys: %w", err)
+	}
+	rand.Shuffle(len(changedKeys), func(i, j int) { changedKeys[i], changedKeys[j] = changedKeys[j], changedKeys[i] })
+
+	cachedKeys := d.cache.keys(func(string, int) bool { return true })
+	rand.Shuffle(len(cachedKeys), func(i, j int) { cachedKeys[i], cachedKeys[j] = cachedKeys[j], cachedKeys[i] })
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, key := range append(changedKeys, cachedKeys...) {
+		if len(keys) >= size {
+			break
+		}
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sendDrift sends the drift to KeysChanged.
+func (d *Datastore) sendDrift(dr drift) error {
+	select {
+	case d.drifts <- dr:
+		return nil
+	case <-d.closed:
+		return closingError{}
+	}
+}
+
+// nextUpdate returns the next update from redis. Drifts from Reconcile are
+// repaired while waiting.
+func (d *Datastore) nextUpdate() ([]byte, error) {
+	for {
+		interrupt := make(chan struct{})
+		stop := make(chan struct{})
+		var dr *drift
+		go func() {
+			defer close(interrupt)
+			select {
+			case <-d.closed:
+			case <-stop:
+			case received := <-d.drifts:
+				dr = &received
+			}
+		}()
+
+		rawData, err := d.redisConn.Update(interrupt)
+		close(stop)
+		<-interrupt
+
+		d.mu.Lock()
+		d.lastRead = time.Now()
+		d.mu.Unlock()
+
+		if dr == nil {
+			return rawData, err
+		}
+
+		if dr.reset {
+			err := d.reset()
+			if dr.done != nil {
+				dr.done <- err
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reset: %w", err)
+			}
+			return nil, resetError{}
+		}
+
+		if err := d.repair(*dr); err != nil {
+			return nil, fmt.Errorf("repair cache: %w", err)
+		}
+
+		var closing interface {
+			Closing()
+		}
+		if errors.As(err, &closing) {
+			// The update was interrupted by the drift.
+			continue
+		}
+		return rawData, err
+	}
+}
+
+// repair updates the cache with the elements of the drift without changing the
+// change id. The keys are returned with the next call of KeysChanged.
+//
+// If the cache was updated after the drift was found, the drift is dropped,
+// because the values from redis could be older then the cache. The next
+// Reconcile finds the drift again, if it still exists.
+func (d *Datastore) repair(dr drift) error {
+	if current := d.CurrentID(); current != dr.changeID {
+		d.log.Debug("Dropping drift, the cache was updated", "change_id", dr.changeID, "new_change_id", current)
+		return nil
+	}
+
+	for key := range dr.elements {
+		d.repairedKeys = append(d.repairedKeys, key)
+	}
+	return d.update(dr.elements, dr.changeID)
+}
+
+// normalizeNull returns nil, if the value is empty or json null.
+func normalizeNull(v json.RawMessage) json.RawMessage {
+	if len(v) == 0 || bytes.Equal(v, []byte("null")) {
+		return nil
+	}
+	return v
+}
+
+// jsonEqual tells, if the two values are the same json. The formatting and
+// the order of the keys are ignored.
+func jsonEqual(a, b json.RawMessage) bool {
+	a = normalizeNull(a)
+	b = normalizeNull(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if bytes.Equal(a, b) {
+		return true
+	}
+
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
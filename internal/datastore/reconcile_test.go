@@ -0,0 +1,215 @@
+package datastore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestReconcileMissingKey(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	type result struct {
+		keys     []string
+		changeID int
+		err      error
+	}
+	received := make(chan result, 1)
+	go func() {
+		keys, changeID, err := ds.KeysChanged()
+		received <- result{keys, changeID, err}
+	}()
+
+	// The change message of motions/motion:2 was lost.
+	r.Reset(map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+		"motions/motion:2": []byte(`{"id": 2}`),
+	}, 5, 0)
+	r.ChangedKeysFunc = func(from, to int) ([]string, error) {
+		return []string{"motions/motion:2"}, nil
+	}
+
+	count, err := ds.Reconcile(10)
+	if err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("Reconcile found %d drifted elements, expected 1", count)
+	}
+
+	// Send the next change, so the repaired key is returned.
+	r.SendChange(6, map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)})
+	got := <-received
+	if got.err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", got.err)
+	}
+
+	if !ds.Exists("motions/motion", 2) {
+		t.Errorf("motions/motion:2 was not repaired")
+	}
+
+	if got.changeID != 6 {
+		t.Errorf("KeysChanged returned change id %d, expected 6", got.changeID)
+	}
+
+	sort.Strings(got.keys)
+	expect := []string{"motions/motion:2", "users/user:1"}
+	if !test.CmpStrSlice(got.keys, expect) {
+		t.Errorf("KeysChanged returned keys %v, expected %v", got.keys, expect)
+	}
+}
+
+func TestReconcileNoDrift(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "title": "foo"}`),
+	}
+	r.ChangedKeysResult = []string{"motions/motion:1"}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	// Same json with other formatting.
+	r.Reset(map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"title":"foo","id":1}`),
+	}, 5, 0)
+
+	count, err := ds.Reconcile(10)
+	if err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	if count != 0 {
+		t.Errorf("Reconcile found %d drifted elements, expected 0", count)
+	}
+}
+
+func TestReconcileReset(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	received := make(chan error, 1)
+	go func() {
+		_, _, err := ds.KeysChanged()
+		received <- err
+	}()
+
+	// Redis was restarted and has a lower change id.
+	r.Reset(map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)}, 3, 1)
+
+	if _, err := ds.Reconcile(10); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	var reset interface {
+		Reset()
+	}
+	if err := <-received; !errors.As(err, &reset) {
+		t.Fatalf("KeysChanged returned error `%v`, expected a reset error", err)
+	}
+
+	if ds.CurrentID() != 3 {
+		t.Errorf("Datastore has change id %d, expected 3", ds.CurrentID())
+	}
+}
+
+func TestReconcileUpdateBetweenReads(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Max = 5
+	r.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "title": "old"}`),
+	}
+	r.ChangedKeysResult = []string{"motions/motion:1"}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	ds, err := datastore.New(r, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	type result struct {
+		keys     []string
+		changeID int
+	}
+	received := make(chan result, 10)
+	go func() {
+		for {
+			keys, changeID, err := ds.KeysChanged()
+			if err != nil {
+				return
+			}
+			received <- result{keys, changeID}
+		}
+	}()
+
+	// The cache is updated after redis returned the old value, but before the
+	// cache is read.
+	r.DataFunc = func([]string) {
+		r.DataFunc = nil
+		r.SendChange(6, map[string]json.RawMessage{"motions/motion:1": []byte(`{"id": 1, "title": "new"}`)})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := ds.WaitForChangeID(ctx, 6); err != nil {
+			t.Errorf("Waiting for change id 6: %v", err)
+		}
+	}
+
+	count, err := ds.Reconcile(10)
+	if err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	if count != 0 {
+		t.Errorf("Reconcile found %d drifted elements, expected 0", count)
+	}
+
+	if got := <-received; got.changeID != 6 {
+		t.Errorf("Got change id %d, expected 6", got.changeID)
+	}
+
+	r.SendChange(7, map[string]json.RawMessage{"users/user:1": []byte(`{"id": 1}`)})
+	got := <-received
+	if !test.CmpStrSlice(got.keys, []string{"users/user:1"}) {
+		t.Errorf("KeysChanged returned keys %v, expected only users/user:1", got.keys)
+	}
+
+	if value := string(ds.GetAll()["motions/motion:1"]); value != `{"id":1,"title":"new"}` {
+		t.Errorf("motions/motion:1 is `%s`, expected the new value", value)
+	}
+}
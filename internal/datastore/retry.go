@@ -0,0 +1,89 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+const (
+	// fullDataFirstWait is the time to wait after the first failed FullData.
+	// It is doubled after each failure until fullDataMaxWait.
+	fullDataFirstWait = 100 * time.Millisecond
+	fullDataMaxWait   = 5 * time.Second
+)
+
+// RetryFullData returns a RedisConn, that retries FullData with a backoff
+// until it succeeds or the timeout is exceeded. It can be given to New, so the
+// service waits for redis at startup.
+//
+// All other methods are not retried. If timeout is 0, conn is returned.
+func RetryFullData(conn RedisConn, timeout time.Duration, log logger.Logger, closed <-chan struct{}) RedisConn {
+	if timeout <= 0 {
+		return conn
+	}
+
+	return &retryFullData{
+		RedisConn: conn,
+		timeout:   timeout,
+		log:       log,
+		closed:    closed,
+	}
+}
+
+type retryFullData struct {
+	RedisConn
+	timeout time.Duration
+	log     logger.Logger
+	closed  <-chan struct{}
+}
+
+func (r *retryFullData) FullData() (map[string]json.RawMessage, int, int, error) {
+	start := time.Now()
+	wait := fullDataFirstWait
+	for {
+		data, max, min, err := r.RedisConn.FullData()
+		if err == nil {
+			return data, max, min, nil
+		}
+
+		remaining := r.timeout - time.Since(start)
+		if remaining <= 0 {
+			return nil, 0, 0, fullDataTimeoutError{timeout: r.timeout, err: err}
+		}
+
+		if wait > remaining {
+			wait = remaining
+		}
+
+		r.log.Warn("Can not get full data, retry", "error", err, "wait", wait)
+
+		select {
+		case <-time.After(wait):
+		case <-r.closed:
+			return nil, 0, 0, closingError{}
+		}
+
+		wait *= 2
+		if wait > fullDataMaxWait {
+			wait = fullDataMaxWait
+		}
+	}
+}
+
+// fullDataTimeoutError is returned by RetryFullData, when FullData does not
+// succeed in the timeout.
+type fullDataTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e fullDataTimeoutError) Error() string {
+	return fmt.Sprintf("full data not available after %s: %v", e.timeout, e.err)
+}
+
+func (e fullDataTimeoutError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,77 @@
+package datastore_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestRetryFullData(t *testing.T) {
+	r := test.NewRedisMock()
+	r.FD = map[string]json.RawMessage{"motions/motion:1": []byte(`{"id":1}`)}
+	r.Max = 5
+
+	var calls int
+	r.FullDataFunc = func() error {
+		calls++
+		if calls <= 2 {
+			return errors.New("redis is not ready")
+		}
+		return nil
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+
+	conn := datastore.RetryFullData(r, 10*time.Second, logger.Noop, closing)
+	ds, err := datastore.New(conn, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("FullData was called %d times, expected 3", calls)
+	}
+
+	if got := ds.CurrentID(); got != 5 {
+		t.Errorf("Datastore has change id %d, expected 5", got)
+	}
+
+	if got := string(ds.GetAll()["motions/motion:1"]); got != `{"id":1}` {
+		t.Errorf("Got motion `%s`, expected the motion from redis", got)
+	}
+}
+
+func TestRetryFullDataTimeout(t *testing.T) {
+	r := test.NewRedisMock()
+	r.Err = errors.New("redis is not ready")
+
+	closing := make(chan struct{})
+	defer close(closing)
+
+	conn := datastore.RetryFullData(r, 250*time.Millisecond, logger.Noop, closing)
+
+	start := time.Now()
+	_, err := datastore.New(conn, nil, nil, logger.Noop, closing)
+	if err == nil {
+		t.Fatalf("New returned no error")
+	}
+
+	if d := time.Since(start); d < 250*time.Millisecond || d > 2*time.Second {
+		t.Errorf("New returned after %s, expected after the timeout", d)
+	}
+
+	if !strings.Contains(err.Error(), "not available after 250ms") {
+		t.Errorf("Got error `%v`, expected the timeout", err)
+	}
+
+	if !errors.Is(err, r.Err) {
+		t.Errorf("Error `%v` does not wrap the error from redis", err)
+	}
+}
@@ -0,0 +1,256 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Shards combines many redis connections to one RedisConn.
+//
+// Each change id is written to the stream of one shard. The updates of all
+// shards are merged to one stream. An update can arrive before an update with
+// a lower change id from another shard. In this case, KeysChanged fills the gap
+// with the data of all shards and ignores the older update, when it arrives.
+//
+// Since each change id is written to one shard, a key, that was changed more
+// then once, can be in many shards. The value of the shard with the highest
+// change id of the key is used. Shards, that are not a KeyChangeIDer, lose
+// against shards, that know the change id. If no shard knows it, the value of
+// the last shard is used.
+type Shards struct {
+	conns  []RedisConn
+	closed <-chan struct{}
+
+	once    sync.Once
+	updates chan shardUpdate
+}
+
+// shardUpdate is one update of a shard.
+type shardUpdate struct {
+	data []byte
+	err  error
+}
+
+// NewShards initializes Shards. The update loops of the shards stop, when
+// closed is closed.
+func NewShards(closed <-chan struct{}, conns ...RedisConn) *Shards {
+	return &Shards{
+		conns:   conns,
+		closed:  closed,
+		updates: make(chan shardUpdate),
+	}
+}
+
+// FullData returns the data of all shards.
+//
+// The max change id is the highest change id of all shards. The min change id
+// is the highest min change id, since lower change ids are not known in all
+// shards.
+//
+// The keys are compared by change id like in Data. A key, that was deleted in
+// the shard with the highest change id of the key, is not returned.
+func (s *Shards) FullData() (map[string]json.RawMessage, int, int, error) {
+	shardData := make([]map[string]json.RawMessage, len(s.conns))
+	var keys []string
+	seen := make(map[string]bool)
+	var max, min int
+	for i, conn := range s.conns {
+		data, shardMax, shardMin, err := conn.FullData()
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("shard %d: %w", i, err)
+		}
+
+		for k, v := range data {
+			if v != nil && !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		shardData[i] = data
+		max, min = combineChangeIDs(i, max, min, shardMax, shardMin)
+	}
+
+	var compare []string
+	if len(s.conns) > 1 {
+		compare = keys
+	}
+
+	data, err := s.newest(shardData, compare)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	for k, v := range data {
+		if v == nil {
+			delete(data, k)
+		}
+	}
+	return data, max, min, nil
+}
+
+// ChangeIDs returns the max and min change id of all shards like FullData.
+func (s *Shards) ChangeIDs() (int, int, error) {
+	var max, min int
+	for i, conn := range s.conns {
+		shardMax, shardMin, err := conn.ChangeIDs()
+		if err != nil {
+			return 0, 0, fmt.Errorf("shard %d: %w", i, err)
+		}
+		max, min = combineChangeIDs(i, max, min, shardMax, shardMin)
+	}
+	return max, min, nil
+}
+
+func combineChangeIDs(i, max, min, shardMax, shardMin int) (int, int) {
+	if i == 0 || shardMax > max {
+		max = shardMax
+	}
+	if i == 0 || shardMin > min {
+		min = shardMin
+	}
+	return max, min
+}
+
+// Update returns the next update of any shard.
+//
+// Blocks until there is new data. If closing is closed before, the update is
+// kept for the next call.
+func (s *Shards) Update(closing <-chan struct{}) ([]byte, error) {
+	s.once.Do(func() {
+		for _, conn := range s.conns {
+			go s.updateLoop(conn)
+		}
+	})
+
+	select {
+	case u := <-s.updates:
+		return u.data, u.err
+	case <-closing:
+		return nil, closingError{}
+	}
+}
+
+// updateLoop reads the updates of one shard until the shards are closed.
+func (s *Shards) updateLoop(conn RedisConn) {
+	for {
+		data, err := conn.Update(s.closed)
+
+		select {
+		case <-s.closed:
+			return
+		default:
+		}
+
+		select {
+		case s.updates <- shardUpdate{data: data, err: err}:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// ChangedKeys returns the changed keys of all shards.
+func (s *Shards) ChangedKeys(from, to int) ([]string, error) {
+	var keys []string
+	for i, conn := range s.conns {
+		shardKeys, err := conn.ChangedKeys(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		keys = append(keys, shardKeys...)
+	}
+	return keys, nil
+}
+
+// Data returns the keys from the shards with the value of the shard with the
+// highest change id of the key. A key, that was deleted in this shard, is nil,
+// also if an older shard has a value. All keys, that do not exist in any shard,
+// are nil.
+func (s *Shards) Data(keys []string) (map[string]json.RawMessage, error) {
+	shardData := make([]map[string]json.RawMessage, len(s.conns))
+	var found []string
+	seen := make(map[string]bool)
+	for i, conn := range s.conns {
+		data, err := conn.Data(keys)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+
+		for key, value := range data {
+			if value != nil && !seen[key] {
+				seen[key] = true
+				found = append(found, key)
+			}
+		}
+		shardData[i] = data
+	}
+
+	var compare []string
+	if len(s.conns) > 1 {
+		compare = found
+	}
+
+	newest, err := s.newest(shardData, compare)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		data[key] = newest[key]
+	}
+	return data, nil
+}
+
+// newest merges the data of the shards. For the keys in compare, the value of
+// the shard with the highest change id of the key is used, also if it is nil
+// or the key is not in the data of the shard. For all other keys, the last
+// value, that is not nil, is used.
+func (s *Shards) newest(shardData []map[string]json.RawMessage, compare []string) (map[string]json.RawMessage, error) {
+	changeIDs := make([]map[string]int, len(s.conns))
+	if len(compare) > 0 {
+		for i, conn := range s.conns {
+			c, ok := conn.(KeyChangeIDer)
+			if !ok {
+				continue
+			}
+
+			ids, err := c.KeyChangeIDs(compare)
+			if err != nil {
+				return nil, fmt.Errorf("shard %d: get change ids: %w", i, err)
+			}
+			changeIDs[i] = ids
+		}
+	}
+
+	data := make(map[string]json.RawMessage)
+	best := make(map[string]int)
+	use := func(i int, key string, value json.RawMessage) {
+		changeID, known := changeIDs[i][key]
+		if !known && value == nil {
+			return
+		}
+
+		if bestID, ok := best[key]; ok && changeID < bestID {
+			return
+		}
+		best[key] = changeID
+		data[key] = value
+	}
+
+	for i, shard := range shardData {
+		for key, value := range shard {
+			use(i, key, value)
+		}
+
+		// A key, that was deleted in the shard, is not in the full data of the
+		// shard, but has a change id.
+		for key := range changeIDs[i] {
+			if _, ok := shard[key]; !ok {
+				use(i, key, nil)
+			}
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,202 @@
+package datastore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/projector"
+)
+
+// snapshotFile is the format of the cache on disk.
+//
+// It is followed by a line with the snapshotChecksum of everything before
+// this line.
+type snapshotFile struct {
+	ChangeID int                        `json:"change_id"`
+	Data     map[string]json.RawMessage `json:"data"`
+}
+
+// snapshotChecksum is the last line of a snapshot.
+type snapshotChecksum struct {
+	SHA256 string `json:"sha256"`
+}
+
+// NewFromSnapshot is like New, but initializes the datastore from a snapshot
+// that was written with WriteSnapshot.
+//
+// Only the data that changed since the snapshot is received from redis. If the
+// snapshot is nil, invalid or older then the lowest change id in redis, all
+// data is received from redis like in New.
+func NewFromSnapshot(redisConn RedisConn, r io.Reader, requiredUsers map[string]func(json.RawMessage) (map[int]bool, string, error), projectorSlides map[string]projector.Callable, log logger.Logger, closed <-chan struct{}) (*Datastore, error) {
+	d := newDatastore(redisConn, requiredUsers, projectorSlides, log, closed)
+
+	if r == nil {
+		if err := d.loadFullData(); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+
+	loaded, err := d.loadSnapshot(r)
+	if err != nil {
+		log.Error("Can not load snapshot", "error", err)
+	}
+
+	if !loaded {
+		// The datastore could be partly initialized from the snapshot.
+		d = newDatastore(redisConn, requiredUsers, projectorSlides, log, closed)
+		if err := d.loadFullData(); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// loadSnapshot initializes the datastore from the snapshot and receives the
+// missing data from redis.
+//
+// Returns false, if the snapshot can not be used.
+func (d *Datastore) loadSnapshot(r io.Reader) (bool, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return false, fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	payload, err := verifySnapshot(content)
+	if err != nil {
+		return false, err
+	}
+
+	var s snapshotFile
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return false, fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	max, min, err := d.redisConn.ChangeIDs()
+	if err != nil {
+		return false, fmt.Errorf("get change ids from redis: %w", err)
+	}
+
+	if s.ChangeID < min || s.ChangeID > max {
+		d.log.Info("Snapshot is outside of the redis change ids", "change_id", s.ChangeID, "lowest_change_id", min, "redis_change_id", max)
+		return false, nil
+	}
+
+	d.setMinChangeID(min)
+	if err := d.update(s.Data, s.ChangeID); err != nil {
+		return false, fmt.Errorf("update from snapshot: %w", err)
+	}
+
+	if s.ChangeID == max {
+		return true, nil
+	}
+
+	data, err := d.receive(s.ChangeID, max)
+	if err != nil {
+		return false, fmt.Errorf("receive missing data from %d to %d: %w", s.ChangeID, max, err)
+	}
+
+	if err := d.update(data, max); err != nil {
+		return false, fmt.Errorf("updating cache from %d to %d: %w", s.ChangeID, max, err)
+	}
+	return true, nil
+}
+
+// verifySnapshot checks the checksum in the last line of the snapshot and
+// returns the content before it.
+//
+// A snapshot that was not written completely or that was changed afterwards is
+// rejected.
+func verifySnapshot(content []byte) ([]byte, error) {
+	content = bytes.TrimRight(content, "\n")
+	idx := bytes.LastIndexByte(content, '\n')
+	if idx < 0 {
+		return nil, errors.New("snapshot has no checksum")
+	}
+	payload := content[:idx+1]
+
+	var checksum snapshotChecksum
+	if err := json.Unmarshal(content[idx+1:], &checksum); err != nil || checksum.SHA256 == "" {
+		return nil, errors.New("snapshot has no checksum, it is probably incomplete")
+	}
+
+	got := sha256.Sum256(payload)
+	if hex.EncodeToString(got[:]) != checksum.SHA256 {
+		return nil, errors.New("snapshot checksum does not match, the file is corrupt")
+	}
+	return payload, nil
+}
+
+// WriteSnapshot writes the cache and its change id to w. The last line is a
+// checksum, so an incomplete or corrupt snapshot is rejected on load.
+func (d *Datastore) WriteSnapshot(w io.Writer) error {
+	snapshot := d.Snapshot()
+	s := snapshotFile{
+		ChangeID: snapshot.changeID,
+		Data:     snapshot.data,
+	}
+
+	hash := sha256.New()
+	if err := json.NewEncoder(io.MultiWriter(w, hash)).Encode(s); err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	checksum := snapshotChecksum{SHA256: hex.EncodeToString(hash.Sum(nil))}
+	if err := json.NewEncoder(w).Encode(checksum); err != nil {
+		return fmt.Errorf("encoding snapshot checksum: %w", err)
+	}
+	return nil
+}
+
+// Snapshot is an immutable view of the data at one change id.
+//
+// Unlike the methods of the Datastore, all reads from a Snapshot see the same
+// data, even when the datastore is updated in between.
+type Snapshot struct {
+	changeID int
+	data     map[string]json.RawMessage
+}
+
+// Snapshot returns the current data of the datastore.
+func (d *Datastore) Snapshot() *Snapshot {
+	data, changeID := d.cache.snapshot()
+	return &Snapshot{changeID: changeID, data: data}
+}
+
+// ChangeID returns the change id of the data.
+func (s *Snapshot) ChangeID() int {
+	return s.changeID
+}
+
+// Get sets v to the value of collection:id. Returns an error with the method
+// `DoesNotExist() string` if the value does not exist.
+//
+// v has to be a pointer.
+func (s *Snapshot) Get(collection string, id int, v interface{}) error {
+	key := fmt.Sprintf("%s:%d", collection, id)
+	e, ok := s.data[key]
+	if !ok {
+		return DoesNotExistError(key)
+	}
+	return json.Unmarshal(e, v)
+}
+
+// GetCollection returns all elements of one collection.
+func (s *Snapshot) GetCollection(collection string) []json.RawMessage {
+	var elements []json.RawMessage
+	prefix := collection + ":"
+	for key, value := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			elements = append(elements, value)
+		}
+	}
+	return elements
+}
@@ -0,0 +1,85 @@
+package datastore
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+// subscriptions holds the callbacks that are called when the data of a
+// collection changes.
+type subscriptions struct {
+	mu        sync.RWMutex
+	callbacks map[string][]func(changed []int, deleted []int)
+}
+
+// Subscribe registers a function that is called after each update that changes
+// elements of the collection. It is called with the ids of the changed and of
+// the deleted elements.
+//
+// The function is called after the cache was updated and without holding any
+// lock of the datastore. So it can use methods like Get to fetch the changed
+// elements. It is called from the update loop, so it should return fast.
+func (s *subscriptions) Subscribe(collection string, fn func(changed []int, deleted []int)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.callbacks == nil {
+		s.callbacks = make(map[string][]func(changed []int, deleted []int))
+	}
+	s.callbacks[collection] = append(s.callbacks[collection], fn)
+}
+
+// dispatch calls the subscribed functions for the changed data.
+func (s *subscriptions) dispatch(data map[string]json.RawMessage, log logger.Logger) {
+	// Copy the callbacks, so the lock is not held while they are called.
+	s.mu.RLock()
+	callbacks := make(map[string][]func(changed []int, deleted []int), len(s.callbacks))
+	for collection, fns := range s.callbacks {
+		callbacks[collection] = fns
+	}
+	s.mu.RUnlock()
+
+	if len(callbacks) == 0 {
+		return
+	}
+
+	changed := make(map[string][]int)
+	deleted := make(map[string][]int)
+	for key, value := range data {
+		parts := strings.Split(key, ":")
+		if len(parts) != 2 {
+			log.Warn("Key has wrong format. Expected one `:`", "key", key)
+			continue
+		}
+
+		if _, ok := callbacks[parts[0]]; !ok {
+			continue
+		}
+
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Warn("Key has an invalid id", "key", key, "error", err)
+			continue
+		}
+
+		if value == nil {
+			deleted[parts[0]] = append(deleted[parts[0]], id)
+			continue
+		}
+		changed[parts[0]] = append(changed[parts[0]], id)
+	}
+
+	for collection, fns := range callbacks {
+		if len(changed[collection]) == 0 && len(deleted[collection]) == 0 {
+			continue
+		}
+
+		for _, fn := range fns {
+			fn(changed[collection], deleted[collection])
+		}
+	}
+}
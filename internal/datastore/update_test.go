@@ -0,0 +1,49 @@
+package datastore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestUpdateSubsystemPanic(t *testing.T) {
+	closing := make(chan struct{})
+	defer close(closing)
+	d, err := New(test.NewRedisMock(), nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	var called bool
+	d.subsystems = append(
+		[]subsystem{{"panicking", func(map[string]json.RawMessage) error {
+			panic("broken data")
+		}}},
+		append(d.subsystems, subsystem{"after", func(map[string]json.RawMessage) error {
+			called = true
+			return nil
+		}})...,
+	)
+
+	data := map[string]json.RawMessage{
+		"core/config:1": []byte(`{"key": "general_event_name", "value": "event"}`),
+	}
+	if err := d.update(data, 5); err != nil {
+		t.Fatalf("update returned unexpected error: %v", err)
+	}
+
+	if d.CurrentID() != 5 {
+		t.Errorf("CurrentID() returned %d, expected 5", d.CurrentID())
+	}
+
+	if !called {
+		t.Errorf("Subsystem after the panicking subsystem was not updated")
+	}
+
+	var name string
+	if err := d.ConfigValue("general_event_name", &name); err != nil || name != "event" {
+		t.Errorf("Config subsystem was not updated: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package datastore
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Operation tells, how an element was changed.
+type Operation string
+
+// The operations of an ElementChange.
+const (
+	OperationCreated Operation = "created"
+	OperationUpdated Operation = "updated"
+	OperationDeleted Operation = "deleted"
+)
+
+// ElementChange is the change of one element in an update.
+type ElementChange struct {
+	Collection string    `json:"collection"`
+	ID         int       `json:"id"`
+	Operation  Operation `json:"operation"`
+	ChangeID   int       `json:"change_id"`
+}
+
+// watchers holds the channels, that receive the changed elements.
+type watchers struct {
+	mu       sync.Mutex
+	watchers map[*watcher]bool
+}
+
+type watcher struct {
+	collections map[string]bool
+	changes     chan ElementChange
+}
+
+// Watch returns a channel, that receives each changed element of the
+// collections. If no collection is given, the elements of all collections are
+// received.
+//
+// The watch is best-effort. If the channel is full, the change is dropped. The
+// buffer is the capacity of the channel. A reset of the datastore creates no
+// changes.
+//
+// The returned function stops the watch and closes the channel.
+func (d *Datastore) Watch(buffer int, collections ...string) (<-chan ElementChange, func()) {
+	w := &watcher{
+		collections: make(map[string]bool, len(collections)),
+		changes:     make(chan ElementChange, buffer),
+	}
+	for _, collection := range collections {
+		w.collections[collection] = true
+	}
+
+	d.watchers.mu.Lock()
+	if d.watchers.watchers == nil {
+		d.watchers.watchers = make(map[*watcher]bool)
+	}
+	d.watchers.watchers[w] = true
+	d.watchers.mu.Unlock()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			d.watchers.mu.Lock()
+			defer d.watchers.mu.Unlock()
+			delete(d.watchers.watchers, w)
+			close(w.changes)
+		})
+	}
+	return w.changes, stop
+}
+
+// classify returns the changes of the data. It has to be called before the
+// cache is updated, so it can tell created from updated elements.
+//
+// Returns nil, if there are no watchers.
+func (ws *watchers) classify(c *cache, data map[string]json.RawMessage, changeID int) []ElementChange {
+	ws.mu.Lock()
+	watched := len(ws.watchers) > 0
+	ws.mu.Unlock()
+
+	if !watched {
+		return nil
+	}
+
+	var changes []ElementChange
+	for key, value := range data {
+		parts := strings.Split(key, ":")
+		if len(parts) != 2 {
+			continue
+		}
+
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+
+		operation := OperationDeleted
+		if value != nil {
+			operation = OperationCreated
+			if c.get(key) != nil {
+				operation = OperationUpdated
+			}
+		}
+
+		changes = append(changes, ElementChange{
+			Collection: parts[0],
+			ID:         id,
+			Operation:  operation,
+			ChangeID:   changeID,
+		})
+	}
+	return changes
+}
+
+// send sends the changes to the watchers without blocking.
+func (ws *watchers) send(changes []ElementChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for w := range ws.watchers {
+		for _, change := range changes {
+			if len(w.collections) > 0 && !w.collections[change.Collection] {
+				continue
+			}
+
+			select {
+			case w.changes <- change:
+			default:
+				// The watcher is to slow.
+			}
+		}
+	}
+}
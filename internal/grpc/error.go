@@ -0,0 +1,6 @@
+package grpc
+
+type closingError struct{}
+
+func (e closingError) Closing()      {}
+func (e closingError) Error() string { return "closing" }
@@ -0,0 +1,173 @@
+// Package grpc gets the data from the OpenSlides worker over grpc. It is an
+// alternative to redis for deployments, that do not expose redis.
+package grpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative -I pb pb/datastore.proto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/grpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// requestTimeout is the timeout for all requests, but FullData and
+	// Updates.
+	requestTimeout = 10 * time.Second
+
+	// fullDataTimeout is the timeout for FullData, that returns much more
+	// data then the other requests.
+	fullDataTimeout = time.Minute
+)
+
+// Client holds the connection to the worker.
+type Client struct {
+	conn   *grpc.ClientConn
+	client pb.DatastoreClient
+
+	// updates is the open stream of Updates. It is nil, before the first call
+	// of Update and after an error.
+	updates pb.Datastore_UpdatesClient
+
+	// pendingUpdate is the result of a read, that was started by Update, but
+	// not returned, because the closing channel was closed.
+	pendingUpdate chan updateResult
+}
+
+// New creates a Client to the worker at addr.
+//
+// The connection is created in the background. New does not fail, if the
+// worker is not reachable.
+func New(addr string) (*Client, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("creating grpc connection to %s: %w", addr, err)
+	}
+
+	return &Client{
+		conn:   conn,
+		client: pb.NewDatastoreClient(conn),
+	}, nil
+}
+
+// Close closes the connection to the worker.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// FullData gets all data from the worker with the max and min change id.
+func (c *Client) FullData() (data map[string]json.RawMessage, max int, min int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fullDataTimeout)
+	defer cancel()
+
+	resp, err := c.client.FullData(ctx, &pb.FullDataRequest{})
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("get full data from worker: %w", err)
+	}
+
+	data = make(map[string]json.RawMessage, len(resp.Elements))
+	for k, v := range resp.Elements {
+		data[k] = json.RawMessage(v)
+	}
+	return data, int(resp.MaxChangeId), int(resp.MinChangeId), nil
+}
+
+// ChangeIDs returns the max and min change id.
+func (c *Client) ChangeIDs() (max int, min int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := c.client.ChangeIDs(ctx, &pb.ChangeIDsRequest{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("get change ids from worker: %w", err)
+	}
+	return int(resp.MaxChangeId), int(resp.MinChangeId), nil
+}
+
+// updateResult is the result of one read from the update stream.
+type updateResult struct {
+	data []byte
+	err  error
+}
+
+// Update returns the next update from the worker.
+//
+// Blocks until there is new data. If closing is closed before, the read is
+// not canceled. The next call to Update returns its result. Update is not save
+// for concurrent use.
+//
+// After an error, the next call opens a new stream. Updates, that the worker
+// sends in between are lost. The datastore gets them with ChangedKeys.
+func (c *Client) Update(closing <-chan struct{}) ([]byte, error) {
+	if c.pendingUpdate == nil {
+		if c.updates == nil {
+			updates, err := c.client.Updates(context.Background(), &pb.UpdatesRequest{})
+			if err != nil {
+				return nil, fmt.Errorf("open update stream to worker: %w", err)
+			}
+			c.updates = updates
+		}
+
+		pending := make(chan updateResult, 1)
+		c.pendingUpdate = pending
+		go func(updates pb.Datastore_UpdatesClient) {
+			update, err := updates.Recv()
+			pending <- updateResult{update.GetData(), err}
+		}(c.updates)
+	}
+
+	var result updateResult
+	select {
+	case result = <-c.pendingUpdate:
+		c.pendingUpdate = nil
+	case <-closing:
+		return nil, closingError{}
+	}
+
+	if result.err != nil {
+		c.updates = nil
+		return nil, fmt.Errorf("read update from worker: %w", result.err)
+	}
+
+	return result.data, nil
+}
+
+// ChangedKeys returns all keys, that were changed after the change id from
+// until the change id to (inclusive).
+func (c *Client) ChangedKeys(from, to int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := c.client.ChangedKeys(ctx, &pb.ChangedKeysRequest{FromChangeId: int64(from), ToChangeId: int64(to)})
+	if err != nil {
+		return nil, fmt.Errorf("get changed keys from worker: %w", err)
+	}
+	return resp.Keys, nil
+}
+
+// Data returns the data for specific keys.
+//
+// If a key does not exist, the value in the returned dict is nil.
+func (c *Client) Data(keys []string) (map[string]json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := c.client.Data(ctx, &pb.DataRequest{Keys: keys})
+	if err != nil {
+		return nil, fmt.Errorf("get data for keys %v from worker: %w", keys, err)
+	}
+
+	data := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		data[key] = nil
+		if v, ok := resp.Elements[key]; ok {
+			data[key] = json.RawMessage(v)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,219 @@
+package grpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	autoupdategrpc "github.com/OpenSlides/openslides3-autoupdate-service/internal/grpc"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/grpc/pb"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"google.golang.org/grpc"
+)
+
+var _ datastore.RedisConn = (*autoupdategrpc.Client)(nil)
+
+// fakeWorker is a grpc server like the worker. The updates sent to the
+// channel updates are sent to the client.
+type fakeWorker struct {
+	pb.UnimplementedDatastoreServer
+
+	elements map[string][]byte
+	updates  chan []byte
+}
+
+func newFakeWorker(t *testing.T) (*fakeWorker, *autoupdategrpc.Client) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can not start fake worker: %v", err)
+	}
+
+	w := &fakeWorker{
+		elements: map[string][]byte{
+			"motions/motion:1": []byte(`{"id":1}`),
+			"motions/motion:2": []byte(`{"id":2}`),
+		},
+		updates: make(chan []byte),
+	}
+
+	srv := grpc.NewServer()
+	pb.RegisterDatastoreServer(srv, w)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	client, err := autoupdategrpc.New(l.Addr().String())
+	if err != nil {
+		t.Fatalf("Can not create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return w, client
+}
+
+func (w *fakeWorker) FullData(context.Context, *pb.FullDataRequest) (*pb.FullDataResponse, error) {
+	return &pb.FullDataResponse{Elements: w.elements, MaxChangeId: 5, MinChangeId: 1}, nil
+}
+
+func (w *fakeWorker) ChangeIDs(context.Context, *pb.ChangeIDsRequest) (*pb.ChangeIDsResponse, error) {
+	return &pb.ChangeIDsResponse{MaxChangeId: 5, MinChangeId: 1}, nil
+}
+
+func (w *fakeWorker) ChangedKeys(_ context.Context, req *pb.ChangedKeysRequest) (*pb.ChangedKeysResponse, error) {
+	if req.FromChangeId != 3 || req.ToChangeId != 5 {
+		return nil, errors.New("unexpected change ids")
+	}
+	return &pb.ChangedKeysResponse{Keys: []string{"motions/motion:1"}}, nil
+}
+
+func (w *fakeWorker) Data(_ context.Context, req *pb.DataRequest) (*pb.DataResponse, error) {
+	elements := make(map[string][]byte)
+	for _, key := range req.Keys {
+		if v, ok := w.elements[key]; ok {
+			elements[key] = v
+		}
+	}
+	return &pb.DataResponse{Elements: elements}, nil
+}
+
+func (w *fakeWorker) Updates(_ *pb.UpdatesRequest, stream pb.Datastore_UpdatesServer) error {
+	for {
+		select {
+		case data := <-w.updates:
+			if err := stream.Send(&pb.Update{Data: data}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	_, client := newFakeWorker(t)
+
+	t.Run("FullData", func(t *testing.T) {
+		data, max, min, err := client.FullData()
+		if err != nil {
+			t.Fatalf("FullData returned unexpected error: %v", err)
+		}
+
+		if max != 5 || min != 1 {
+			t.Errorf("Got change ids %d and %d, expected 5 and 1", max, min)
+		}
+
+		expect := map[string]json.RawMessage{
+			"motions/motion:1": []byte(`{"id":1}`),
+			"motions/motion:2": []byte(`{"id":2}`),
+		}
+		if !reflect.DeepEqual(data, expect) {
+			t.Errorf("Got %v, expected %v", data, expect)
+		}
+	})
+
+	t.Run("ChangeIDs", func(t *testing.T) {
+		max, min, err := client.ChangeIDs()
+		if err != nil {
+			t.Fatalf("ChangeIDs returned unexpected error: %v", err)
+		}
+
+		if max != 5 || min != 1 {
+			t.Errorf("Got change ids %d and %d, expected 5 and 1", max, min)
+		}
+	})
+
+	t.Run("ChangedKeys", func(t *testing.T) {
+		keys, err := client.ChangedKeys(3, 5)
+		if err != nil {
+			t.Fatalf("ChangedKeys returned unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(keys, []string{"motions/motion:1"}) {
+			t.Errorf("Got keys %v, expected [motions/motion:1]", keys)
+		}
+	})
+
+	t.Run("Data", func(t *testing.T) {
+		data, err := client.Data([]string{"motions/motion:1", "motions/motion:404"})
+		if err != nil {
+			t.Fatalf("Data returned unexpected error: %v", err)
+		}
+
+		expect := map[string]json.RawMessage{
+			"motions/motion:1":   []byte(`{"id":1}`),
+			"motions/motion:404": nil,
+		}
+		if !reflect.DeepEqual(data, expect) {
+			t.Errorf("Got %v, expected %v", data, expect)
+		}
+	})
+}
+
+func TestClientUpdateClosing(t *testing.T) {
+	w, client := newFakeWorker(t)
+
+	closing := make(chan struct{})
+	close(closing)
+
+	_, err := client.Update(closing)
+
+	var closingErr interface {
+		Closing()
+	}
+	if !errors.As(err, &closingErr) {
+		t.Fatalf("Update returned %v, expected a closing error", err)
+	}
+
+	// The read is not canceled. The next call returns the update.
+	go func() { w.updates <- []byte(`{"change_id":6,"elements":{}}`) }()
+	data, err := client.Update(make(chan struct{}))
+	if err != nil {
+		t.Fatalf("Update returned unexpected error: %v", err)
+	}
+
+	if string(data) != `{"change_id":6,"elements":{}}` {
+		t.Errorf("Got update %s, expected the update from the worker", data)
+	}
+}
+
+func TestDatastoreWithClient(t *testing.T) {
+	w, client := newFakeWorker(t)
+
+	closing := make(chan struct{})
+	defer close(closing)
+
+	ds, err := datastore.New(client, nil, nil, logger.Noop, closing)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	if got := ds.CurrentID(); got != 5 {
+		t.Errorf("Datastore has change id %d, expected 5", got)
+	}
+
+	go func() {
+		w.updates <- []byte(`{"change_id":6,"elements":{"motions/motion:1":{"id":1,"title":"changed"}}}`)
+	}()
+
+	keys, changeID, err := ds.KeysChanged()
+	if err != nil {
+		t.Fatalf("KeysChanged returned unexpected error: %v", err)
+	}
+
+	if changeID != 6 {
+		t.Errorf("Got change id %d, expected 6", changeID)
+	}
+
+	if !reflect.DeepEqual(keys, []string{"motions/motion:1"}) {
+		t.Errorf("Got keys %v, expected [motions/motion:1]", keys)
+	}
+
+	if got := string(ds.GetAll()["motions/motion:1"]); got != `{"id":1,"title":"changed"}` {
+		t.Errorf("Got motion %s, expected the changed motion", got)
+	}
+}
@@ -0,0 +1,301 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v25.1.0
+// source: datastore.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Datastore_FullData_FullMethodName    = "/openslides.datastore.Datastore/FullData"
+	Datastore_ChangeIDs_FullMethodName   = "/openslides.datastore.Datastore/ChangeIDs"
+	Datastore_ChangedKeys_FullMethodName = "/openslides.datastore.Datastore/ChangedKeys"
+	Datastore_Data_FullMethodName        = "/openslides.datastore.Datastore/Data"
+	Datastore_Updates_FullMethodName     = "/openslides.datastore.Datastore/Updates"
+)
+
+// DatastoreClient is the client API for Datastore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DatastoreClient interface {
+	// FullData returns all elements with the highest and lowest change id.
+	FullData(ctx context.Context, in *FullDataRequest, opts ...grpc.CallOption) (*FullDataResponse, error)
+	// ChangeIDs returns the highest and lowest change id.
+	ChangeIDs(ctx context.Context, in *ChangeIDsRequest, opts ...grpc.CallOption) (*ChangeIDsResponse, error)
+	// ChangedKeys returns the keys, that were changed after the change id from
+	// until the change id to (inclusive).
+	ChangedKeys(ctx context.Context, in *ChangedKeysRequest, opts ...grpc.CallOption) (*ChangedKeysResponse, error)
+	// Data returns the elements of the keys. A deleted element is not in the
+	// response.
+	Data(ctx context.Context, in *DataRequest, opts ...grpc.CallOption) (*DataResponse, error)
+	// Updates sends an update for each new change id. The data of an update is
+	// the message, that the worker writes to the autoupdate stream in redis.
+	Updates(ctx context.Context, in *UpdatesRequest, opts ...grpc.CallOption) (Datastore_UpdatesClient, error)
+}
+
+type datastoreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDatastoreClient(cc grpc.ClientConnInterface) DatastoreClient {
+	return &datastoreClient{cc}
+}
+
+func (c *datastoreClient) FullData(ctx context.Context, in *FullDataRequest, opts ...grpc.CallOption) (*FullDataResponse, error) {
+	out := new(FullDataResponse)
+	err := c.cc.Invoke(ctx, Datastore_FullData_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) ChangeIDs(ctx context.Context, in *ChangeIDsRequest, opts ...grpc.CallOption) (*ChangeIDsResponse, error) {
+	out := new(ChangeIDsResponse)
+	err := c.cc.Invoke(ctx, Datastore_ChangeIDs_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) ChangedKeys(ctx context.Context, in *ChangedKeysRequest, opts ...grpc.CallOption) (*ChangedKeysResponse, error) {
+	out := new(ChangedKeysResponse)
+	err := c.cc.Invoke(ctx, Datastore_ChangedKeys_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) Data(ctx context.Context, in *DataRequest, opts ...grpc.CallOption) (*DataResponse, error) {
+	out := new(DataResponse)
+	err := c.cc.Invoke(ctx, Datastore_Data_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) Updates(ctx context.Context, in *UpdatesRequest, opts ...grpc.CallOption) (Datastore_UpdatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Datastore_ServiceDesc.Streams[0], Datastore_Updates_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &datastoreUpdatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Datastore_UpdatesClient interface {
+	Recv() (*Update, error)
+	grpc.ClientStream
+}
+
+type datastoreUpdatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *datastoreUpdatesClient) Recv() (*Update, error) {
+	m := new(Update)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DatastoreServer is the server API for Datastore service.
+// All implementations must embed UnimplementedDatastoreServer
+// for forward compatibility
+type DatastoreServer interface {
+	// FullData returns all elements with the highest and lowest change id.
+	FullData(context.Context, *FullDataRequest) (*FullDataResponse, error)
+	// ChangeIDs returns the highest and lowest change id.
+	ChangeIDs(context.Context, *ChangeIDsRequest) (*ChangeIDsResponse, error)
+	// ChangedKeys returns the keys, that were changed after the change id from
+	// until the change id to (inclusive).
+	ChangedKeys(context.Context, *ChangedKeysRequest) (*ChangedKeysResponse, error)
+	// Data returns the elements of the keys. A deleted element is not in the
+	// response.
+	Data(context.Context, *DataRequest) (*DataResponse, error)
+	// Updates sends an update for each new change id. The data of an update is
+	// the message, that the worker writes to the autoupdate stream in redis.
+	Updates(*UpdatesRequest, Datastore_UpdatesServer) error
+	mustEmbedUnimplementedDatastoreServer()
+}
+
+// UnimplementedDatastoreServer must be embedded to have forward compatible implementations.
+type UnimplementedDatastoreServer struct {
+}
+
+func (UnimplementedDatastoreServer) FullData(context.Context, *FullDataRequest) (*FullDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FullData not implemented")
+}
+func (UnimplementedDatastoreServer) ChangeIDs(context.Context, *ChangeIDsRequest) (*ChangeIDsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangeIDs not implemented")
+}
+func (UnimplementedDatastoreServer) ChangedKeys(context.Context, *ChangedKeysRequest) (*ChangedKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangedKeys not implemented")
+}
+func (UnimplementedDatastoreServer) Data(context.Context, *DataRequest) (*DataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Data not implemented")
+}
+func (UnimplementedDatastoreServer) Updates(*UpdatesRequest, Datastore_UpdatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method Updates not implemented")
+}
+func (UnimplementedDatastoreServer) mustEmbedUnimplementedDatastoreServer() {}
+
+// UnsafeDatastoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DatastoreServer will
+// result in compilation errors.
+type UnsafeDatastoreServer interface {
+	mustEmbedUnimplementedDatastoreServer()
+}
+
+func RegisterDatastoreServer(s grpc.ServiceRegistrar, srv DatastoreServer) {
+	s.RegisterService(&Datastore_ServiceDesc, srv)
+}
+
+func _Datastore_FullData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FullDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).FullData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_FullData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).FullData(ctx, req.(*FullDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_ChangeIDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeIDsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).ChangeIDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_ChangeIDs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).ChangeIDs(ctx, req.(*ChangeIDsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_ChangedKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangedKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).ChangedKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_ChangedKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).ChangedKeys(ctx, req.(*ChangedKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_Data_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).Data(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_Data_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).Data(ctx, req.(*DataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_Updates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UpdatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DatastoreServer).Updates(m, &datastoreUpdatesServer{stream})
+}
+
+type Datastore_UpdatesServer interface {
+	Send(*Update) error
+	grpc.ServerStream
+}
+
+type datastoreUpdatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *datastoreUpdatesServer) Send(m *Update) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Datastore_ServiceDesc is the grpc.ServiceDesc for Datastore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Datastore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "openslides.datastore.Datastore",
+	HandlerType: (*DatastoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "FullData",
+			Handler:    _Datastore_FullData_Handler,
+		},
+		{
+			MethodName: "ChangeIDs",
+			Handler:    _Datastore_ChangeIDs_Handler,
+		},
+		{
+			MethodName: "ChangedKeys",
+			Handler:    _Datastore_ChangedKeys_Handler,
+		},
+		{
+			MethodName: "Data",
+			Handler:    _Datastore_Data_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Updates",
+			Handler:       _Datastore_Updates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "datastore.proto",
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ClientVersionHeader is the header, that tells the version of the client.
+const ClientVersionHeader = "X-Client-Version"
+
+// Aliases are the collection names, that are sent to the clients of each
+// version. The first key is the client version, the second the collection
+// name of the service.
+type Aliases map[string]map[string]string
+
+// ParseAliases parses the aliases from a string like
+// `3.3=motions/motion:motions/old-motion,agenda/item:agenda/old-item;3.2=...`.
+//
+// Returns nil, if the string is empty.
+func ParseAliases(raw string) (Aliases, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	aliases := make(Aliases)
+	for _, rawVersion := range strings.Split(raw, ";") {
+		parts := strings.SplitN(rawVersion, "=", 2)
+		version := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || version == "" {
+			return nil, fmt.Errorf("invalid aliases `%s`, expected something like `3.3=motions/motion:motions/old-motion`", rawVersion)
+		}
+
+		if aliases[version] == nil {
+			aliases[version] = make(map[string]string)
+		}
+
+		for _, rawAlias := range strings.Split(parts[1], ",") {
+			names := strings.Split(rawAlias, ":")
+			if len(names) != 2 || strings.TrimSpace(names[0]) == "" || strings.TrimSpace(names[1]) == "" {
+				return nil, fmt.Errorf("invalid alias `%s` for version %s, expected something like `motions/motion:motions/old-motion`", rawAlias, version)
+			}
+			aliases[version][strings.TrimSpace(names[0])] = strings.TrimSpace(names[1])
+		}
+	}
+	return aliases, nil
+}
+
+type aliasesKey struct{}
+
+// CollectionAliases looks up the aliases for the client version of the
+// request. The autoupdate routes send the data with these collection names.
+//
+// Clients without the header or with an unknown version receive the collection
+// names of the service.
+func CollectionAliases(next http.Handler, aliases Aliases) http.Handler {
+	if len(aliases) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cached responses depend on the client version.
+		w.Header().Add("Vary", ClientVersionHeader)
+
+		if versionAliases := aliases[r.Header.Get(ClientVersionHeader)]; versionAliases != nil {
+			r = r.WithContext(context.WithValue(r.Context(), aliasesKey{}, versionAliases))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// renameCollections renames the collections of the keys in data with the
+// aliases of the request.
+//
+// It has to be called after the fields are applied, because the fields use the
+// collection names of the service.
+func renameCollections(ctx context.Context, data map[string]json.RawMessage) {
+	aliases, _ := ctx.Value(aliasesKey{}).(map[string]string)
+	if aliases == nil {
+		return
+	}
+
+	renamed := make(map[string]json.RawMessage)
+	for key, value := range data {
+		collection := keyCollection(key)
+		alias, ok := aliases[collection]
+		if !ok {
+			continue
+		}
+
+		delete(data, key)
+		renamed[alias+key[len(collection):]] = value
+	}
+
+	for key, value := range renamed {
+		data[key] = value
+	}
+}
@@ -0,0 +1,130 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestParseAliases(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		raw    string
+		expect ahttp.Aliases
+		err    bool
+	}{
+		{"empty", "", nil, false},
+		{
+			"one version",
+			"3.3=motions/motion:motions/old-motion,agenda/item:agenda/old-item",
+			ahttp.Aliases{"3.3": {"motions/motion": "motions/old-motion", "agenda/item": "agenda/old-item"}},
+			false,
+		},
+		{
+			"two versions",
+			"3.3=motions/motion:motions/old-motion; 3.2=agenda/item:agenda/old-item",
+			ahttp.Aliases{"3.3": {"motions/motion": "motions/old-motion"}, "3.2": {"agenda/item": "agenda/old-item"}},
+			false,
+		},
+		{"without version", "motions/motion:motions/old-motion", nil, true},
+		{"without alias", "3.3=motions/motion", nil, true},
+		{"empty alias", "3.3=motions/motion:", nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ahttp.ParseAliases(tt.raw)
+
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseAliases did not return an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseAliases returned unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("Got %v, expected %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestCollectionAliases(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`"motion1"`),
+		"agenda/item:1":    []byte(`"item1"`),
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateSnapshot(mux, a, new(test.AutherMock), logger.Noop)
+	handler := ahttp.CollectionAliases(mux, ahttp.Aliases{"3.3": {"motions/motion": "motions/old-motion"}})
+
+	for _, tt := range []struct {
+		name    string
+		version string
+		expect  string
+	}{
+		{
+			"without version",
+			"",
+			`{"changed":{"agenda/item":["item1"],"motions/motion":["motion1"]},"deleted":{},"from_change_id":0,"to_change_id":1,"all_data":true}`,
+		},
+		{
+			"unknown version",
+			"3.4",
+			`{"changed":{"agenda/item":["item1"],"motions/motion":["motion1"]},"deleted":{},"from_change_id":0,"to_change_id":1,"all_data":true}`,
+		},
+		{
+			"old version",
+			"3.3",
+			`{"changed":{"agenda/item":["item1"],"motions/old-motion":["motion1"]},"deleted":{},"from_change_id":0,"to_change_id":1,"all_data":true}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/system/autoupdate/snapshot", nil)
+			if tt.version != "" {
+				req.Header.Set(ahttp.ClientVersionHeader, tt.version)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Got status %d, expected 200: %s", rec.Code, rec.Body.String())
+			}
+
+			if got := rec.Header().Values("Vary"); !contains(got, ahttp.ClientVersionHeader) {
+				t.Errorf("Got Vary header %v, expected it to contain %s", got, ahttp.ClientVersionHeader)
+			}
+
+			test.ExpectEqualJSON(t, []byte(tt.expect), rec.Body.Bytes())
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// The operations of a changeLogEntry.
+//
+// The service does not know, which elements a client has. So a created and an
+// updated element are both sent with opSet.
+const (
+	opSet    = "set"
+	opDelete = "delete"
+)
+
+// changeLogEntry is one changed element of the compact change log.
+type changeLogEntry struct {
+	Key   string          `json:"key"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// changeLog is the compact format of the catch-up route. It is a flat list of
+// the changed elements, so a client can apply the changes one by one.
+type changeLog struct {
+	FromChangeID int              `json:"from_change_id"`
+	ToChangeID   int              `json:"to_change_id"`
+	Changes      []changeLogEntry `json:"changes"`
+}
+
+// newChangeLog creates the change log from the restricted data. An element
+// with a nil value is deleted or the user can not see it anymore. The entries
+// are sorted by key.
+func newChangeLog(data map[string]json.RawMessage, fromChangeID, toChangeID int) changeLog {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := make([]changeLogEntry, 0, len(keys))
+	for _, key := range keys {
+		value := data[key]
+		if value == nil {
+			changes = append(changes, changeLogEntry{Key: key, Op: opDelete})
+			continue
+		}
+		changes = append(changes, changeLogEntry{Key: key, Op: opSet, Value: value})
+	}
+
+	return changeLog{
+		FromChangeID: fromChangeID,
+		ToChangeID:   toChangeID,
+		Changes:      changes,
+	}
+}
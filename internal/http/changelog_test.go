@@ -0,0 +1,123 @@
+package http_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestAutoupdateCatchUpChangeLog(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	// motions/motion:1 is updated, motions/motion:2 is deleted and
+	// motions/motion:3 is created.
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1,"title":"updated"}`),
+		"motions/motion:3": []byte(`{"id":3,"title":"created"}`),
+	}
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not create autoupdate service: %v", err)
+	}
+
+	datastore.Change([]string{"motions/motion:1", "motions/motion:2"})
+	datastore.Change([]string{"motions/motion:3"})
+	deadline := time.Now().Add(time.Second)
+	for datastore.CurrentID() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("datastore has change id %d after one second, expected 3", datastore.CurrentID())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateCatchUp(mux, a, new(test.AutherMock), logger.Noop)
+
+	expect := `{
+		"from_change_id": 1,
+		"to_change_id": 3,
+		"changes": [
+			{"key": "motions/motion:1", "op": "set", "value": {"id":1,"title":"updated"}},
+			{"key": "motions/motion:2", "op": "delete"},
+			{"key": "motions/motion:3", "op": "set", "value": {"id":3,"title":"created"}}
+		]
+	}`
+	var want interface{}
+	if err := json.Unmarshal([]byte(expect), &want); err != nil {
+		t.Fatalf("Invalid expected json: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		accept      string
+		contentType string
+		decode      func(body string) (interface{}, error)
+	}{
+		{
+			"json",
+			"",
+			"application/json",
+			func(body string) (interface{}, error) {
+				var v interface{}
+				err := json.Unmarshal([]byte(body), &v)
+				return v, err
+			},
+		},
+		{
+			"msgpack",
+			"application/msgpack",
+			"application/msgpack",
+			func(body string) (interface{}, error) {
+				return decodeMsgpack(bufio.NewReader(strings.NewReader(body)))
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/system/autoupdate/catchup?format=log", strings.NewReader(`{"change_id": 1}`))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Got status %d, expected 200: %s", rec.Code, rec.Body.String())
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Got content type %s, expected %s", got, tt.contentType)
+			}
+
+			got, err := tt.decode(rec.Body.String())
+			if err != nil {
+				t.Fatalf("Can not decode response: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Got %v, expected %v", got, want)
+			}
+		})
+	}
+
+	t.Run("unknown format", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/system/autoupdate/catchup?format=foo", strings.NewReader(`{"change_id": 1}`)))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Got status %d, expected 400", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// chunks splits the data into many messages, if the encoded data is bigger
+// then maxSize bytes. Each message is encoded.
+//
+// All messages have the same change ids. Only the last message has the flag
+// complete. The client has to apply the data after the last message. An element
+// is never split, so a message can be bigger then maxSize, if one element is
+// bigger.
+//
+// If maxSize is 0, the data is never split.
+func (f autoupdateFormat) chunks(maxSize int) ([][]byte, error) {
+	encoded, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("encode output data: %w", err)
+	}
+
+	if maxSize <= 0 || len(encoded) <= maxSize {
+		return [][]byte{encoded}, nil
+	}
+
+	// The collections are sorted, so the chunks are always the same.
+	collections := make([]string, 0, len(f.Changed))
+	for collection := range f.Changed {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	var chunks []autoupdateFormat
+	var size int
+	current := f.emptyChunk()
+	for _, collection := range collections {
+		for _, element := range f.Changed[collection] {
+			if size > 0 && size+len(element) > maxSize {
+				chunks = append(chunks, current)
+				current = f.emptyChunk()
+				size = 0
+			}
+			current.Changed[collection] = append(current.Changed[collection], element)
+			size += len(element)
+		}
+	}
+
+	// The deleted elements and the patches are small. They are sent with the
+	// last chunk.
+	current.Deleted = f.Deleted
+	current.Patched = f.Patched
+	chunks = append(chunks, current)
+
+	messages := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		complete := i == len(chunks)-1
+		chunk.Complete = &complete
+
+		messages[i], err = json.Marshal(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("encode output data: %w", err)
+		}
+	}
+	return messages, nil
+}
+
+// emptyChunk returns a autoupdateFormat with the same change ids but without
+// data.
+func (f autoupdateFormat) emptyChunk() autoupdateFormat {
+	return autoupdateFormat{
+		Changed:      make(map[string][]json.RawMessage),
+		Deleted:      make(map[string][]int),
+		FromChangeID: f.FromChangeID,
+		ToChangeID:   f.ToChangeID,
+		AllData:      f.AllData,
+	}
+}
@@ -0,0 +1,185 @@
+package http
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinSize is the minimum size of a response that is compressed. Smaller
+// responses are sent uncompressed, because the overhead of the compression is
+// bigger then the gain.
+const compressMinSize = 1400
+
+// compressHandler compresses the response with gzip or deflate, if the client
+// supports it.
+//
+// The response is compressed, when it is bigger then compressMinSize or when
+// the handler flushes the response. A flushed response is a stream (like the
+// autoupdate), that is usually big.
+func compressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			// Compressing does not work with an upgraded connection.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding returns the encoding to use for an Accept-Encoding header.
+// Returns an empty string if the response should not be compressed.
+func acceptedEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		values := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(values[0]))
+
+		enabled := true
+		for _, param := range values[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+
+			if q, err := strconv.ParseFloat(param[len("q="):], 64); err == nil && q == 0 {
+				enabled = false
+			}
+		}
+		accepted[name] = enabled
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+type encoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+// compressWriter is a http.ResponseWriter that compresses the written data.
+//
+// It buffers the data until it knows, that the response should be compressed.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder encoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = status
+	}
+
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		// Responses without a body.
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= compressMinSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends all written data to the client.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.start(true)
+	}
+
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends the buffered data and closes the compression.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// start writes the header and the buffered data.
+func (w *compressWriter) start(compress bool) error {
+	w.decided = true
+
+	if compress && w.Header().Get("Content-Encoding") == "" {
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+
+		switch w.encoding {
+		case "gzip":
+			w.encoder = gzip.NewWriter(w.ResponseWriter)
+		case "deflate":
+			w.encoder = zlib.NewWriter(w.ResponseWriter)
+		}
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
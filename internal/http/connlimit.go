@@ -0,0 +1,78 @@
+package http
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+// unlimitedPaths are the routes, that are not counted by LimitConnections. The
+// health checks and the metrics have to work, also if the service is full.
+var unlimitedPaths = map[string]bool{
+	"/system/health": true,
+	"/healthz":       true,
+	"/readyz":        true,
+	"/metrics":       true,
+}
+
+// maxRetryAfter is the longest time a rejected client is told to wait.
+const maxRetryAfter = 10 * time.Second
+
+// LimitConnections returns a handler, that allows at most max concurrent
+// requests to next. Long running requests like the autoupdate connections
+// hold their slot until they are closed.
+//
+// If all slots are used, the request is rejected with the status 503 and the
+// header Retry-After. The time to wait is random, so many rejected clients do
+// not reconnect at the same time.
+//
+// If max is 0, next is returned.
+func LimitConnections(next http.Handler, max int, log logger.Logger) http.Handler {
+	if max <= 0 {
+		return next
+	}
+
+	slots := make(chan struct{}, max)
+
+	limited := errHandler(func(w http.ResponseWriter, r *http.Request) error {
+		select {
+		case slots <- struct{}{}:
+		default:
+			retryAfter := time.Duration(1+rand.Int63n(int64(maxRetryAfter/time.Second))) * time.Second
+			return serviceUnavailableError{retryAfter: retryAfter}
+		}
+		defer func() { <-slots }()
+
+		next.ServeHTTP(w, r)
+		return nil
+	}, log)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlimitedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
+
+type serviceUnavailableError struct {
+	retryAfter time.Duration
+}
+
+func (e serviceUnavailableError) Error() string {
+	return fmt.Sprintf("Too many connections. Try again in %d seconds", int(e.retryAfter.Seconds()))
+}
+
+func (e serviceUnavailableError) ClientError() string {
+	return "service_unavailable"
+}
+
+func (e serviceUnavailableError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+func (e serviceUnavailableError) Unavailable() {}
@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+func TestLimitConnections(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/system/autoupdate", func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+	ahttp.Health(mux)
+
+	srv := httptest.NewServer(ahttp.LimitConnections(mux, 2, logger.Noop))
+	defer srv.Close()
+
+	get := func(path string) *http.Response {
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("Can not send request: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	done := make(chan int, 3)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- get("/system/autoupdate").StatusCode
+		}()
+		<-entered
+	}
+
+	t.Run("full", func(t *testing.T) {
+		resp := get("/system/autoupdate")
+
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Got status %s, expected %s", resp.Status, http.StatusText(http.StatusServiceUnavailable))
+		}
+
+		retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil || retryAfter <= 0 {
+			t.Errorf("Got Retry-After `%s`, expected a positive number", resp.Header.Get("Retry-After"))
+		}
+	})
+
+	t.Run("health while full", func(t *testing.T) {
+		if resp := get("/system/health"); resp.StatusCode != http.StatusOK {
+			t.Errorf("Got status %s, expected %s", resp.Status, http.StatusText(http.StatusOK))
+		}
+	})
+
+	t.Run("slot freed", func(t *testing.T) {
+		release <- struct{}{}
+		if status := <-done; status != http.StatusOK {
+			t.Errorf("First request got status %d, expected 200", status)
+		}
+
+		go func() {
+			done <- get("/system/autoupdate").StatusCode
+		}()
+		<-entered
+
+		close(release)
+		for i := 0; i < 2; i++ {
+			if status := <-done; status != http.StatusOK {
+				t.Errorf("Request got status %d, expected 200", status)
+			}
+		}
+	})
+}
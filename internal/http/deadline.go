@@ -0,0 +1,138 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+// WriteDeadline sets a deadline for each write of the response. A client that
+// does not receive the data in time, for example because its tcp window is
+// zero, is logged and the connection is closed. So a stalled client does not
+// block a goroutine forever.
+//
+// The deadline is set for each write and flush and not for the whole response.
+// So a stream like the autoupdate can be open for a long time.
+//
+// Websocket connections set their own deadlines after the upgrade.
+//
+// If timeout is 0, next is returned.
+func WriteDeadline(next http.Handler, timeout time.Duration, log logger.Logger) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		user := new(requestUser)
+		ctx = context.WithValue(ctx, requestUserKey{}, user)
+
+		dw := &deadlineWriter{
+			ResponseWriter: w,
+			timeout:        timeout,
+			timedOut: func() {
+				log.Warn("Closing slow client", "user_id", user.get(), "remote_addr", r.RemoteAddr, "path", r.URL.Path, "timeout", timeout)
+
+				// The handler returns, when the context is done. Afterwards
+				// the server closes the broken connection.
+				cancel()
+			},
+		}
+
+		next.ServeHTTP(dw, r.WithContext(ctx))
+	})
+}
+
+// requestUser is the user of a request. It is set by the auth middleware, so
+// WriteDeadline can log it.
+type requestUser struct {
+	uid atomic.Int64
+}
+
+type requestUserKey struct{}
+
+func (u *requestUser) get() int {
+	return int(u.uid.Load())
+}
+
+// setRequestUser tells WriteDeadline the user of the request.
+func setRequestUser(ctx context.Context, uid int) {
+	if user, ok := ctx.Value(requestUserKey{}).(*requestUser); ok {
+		user.uid.Store(int64(uid))
+	}
+}
+
+// deadlineWriter is a http.ResponseWriter, that sets a deadline for each write.
+type deadlineWriter struct {
+	http.ResponseWriter
+	timeout  time.Duration
+	timedOut func()
+
+	closed atomic.Bool
+}
+
+// withDeadline calls fn with the write deadline. If the deadline is exceeded,
+// timedOut is called and all later writes fail.
+//
+// Writers that do not support deadlines, like httptest.ResponseRecorder, are
+// written without a deadline.
+func (w *deadlineWriter) withDeadline(fn func() error) error {
+	if w.closed.Load() {
+		return errSlowClient{}
+	}
+
+	rc := http.NewResponseController(w.ResponseWriter)
+	deadline := time.Now().Add(w.timeout)
+	hasDeadline := rc.SetWriteDeadline(deadline) == nil
+
+	err := fn()
+
+	if hasDeadline {
+		if err != nil && (errors.Is(err, os.ErrDeadlineExceeded) || !time.Now().Before(deadline)) {
+			if !w.closed.Swap(true) {
+				w.timedOut()
+			}
+			return errSlowClient{}
+		}
+
+		// Without a deadline, the next write is not canceled, when the
+		// deadline for this write passed in between.
+		rc.SetWriteDeadline(time.Time{})
+	}
+	return err
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	var n int
+	err := w.withDeadline(func() error {
+		var err error
+		n, err = w.ResponseWriter.Write(p)
+		return err
+	})
+	return n, err
+}
+
+func (w *deadlineWriter) Flush() {
+	w.withDeadline(func() error {
+		return http.NewResponseController(w.ResponseWriter).Flush()
+	})
+}
+
+// Hijack is used by the websocket route.
+func (w *deadlineWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap is used by http.ResponseController.
+func (w *deadlineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
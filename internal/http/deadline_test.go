@@ -0,0 +1,130 @@
+package http_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/auth"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestWriteDeadline(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	a, err := autoupdate.New(test.NewDatastoreMock(1, closed), new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not create autoupdate service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.Autoupdate(mux, a, auth.Fake(5), logger.Noop)
+
+	buf := new(bytes.Buffer)
+	handler := ahttp.WriteDeadline(mux, 20*time.Millisecond, logger.New(buf, slog.LevelInfo))
+
+	w := &blockingWriter{header: make(http.Header)}
+	req := httptest.NewRequest(http.MethodGet, "/system/autoupdate", nil)
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Handler did not return after the write deadline")
+	}
+
+	if !w.deadlineSet() {
+		t.Errorf("No write deadline was set")
+	}
+
+	got := buf.String()
+	for _, expect := range []string{"Closing slow client", `"user_id":5`, req.RemoteAddr} {
+		if !strings.Contains(got, expect) {
+			t.Errorf("Log `%s` does not contain `%s`", got, expect)
+		}
+	}
+}
+
+func TestWriteDeadlineFastClient(t *testing.T) {
+	buf := new(bytes.Buffer)
+	handler := ahttp.WriteDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("event\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}), 5*time.Millisecond, logger.New(buf, slog.LevelInfo))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate", nil))
+
+	if got := rec.Body.String(); got != "event\nevent\nevent\n" {
+		t.Errorf("Got body `%s`, expected three events", got)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("Got log `%s`, expected no log", buf.String())
+	}
+}
+
+// blockingWriter is a http.ResponseWriter, that blocks each write until the
+// write deadline, like a client that does not read the data.
+type blockingWriter struct {
+	header http.Header
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (w *blockingWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *blockingWriter) WriteHeader(int) {}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	deadline := w.deadline
+	w.mu.Unlock()
+
+	if deadline.IsZero() {
+		// Block forever, the test fails with a timeout.
+		select {}
+	}
+
+	time.Sleep(time.Until(deadline))
+	return 0, os.ErrDeadlineExceeded
+}
+
+func (w *blockingWriter) Flush() {}
+
+func (w *blockingWriter) SetWriteDeadline(deadline time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !deadline.IsZero() {
+		w.deadline = deadline
+	}
+	return nil
+}
+
+func (w *blockingWriter) deadlineSet() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.deadline.IsZero()
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// patchOperation is one operation of a json patch (RFC 6902).
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// deltaEncoder remembers the elements that were sent on one connection. It is
+// used to send json patches instead of the full elements. It is not save for
+// concurrent use.
+type deltaEncoder struct {
+	sent map[string]json.RawMessage
+}
+
+func newDeltaEncoder() *deltaEncoder {
+	return &deltaEncoder{sent: make(map[string]json.RawMessage)}
+}
+
+// split returns the elements that have to be sent in full and the patches for
+// the elements that the client already knows.
+//
+// A patch is only used, if it is smaller then the element. If all is true, the
+// client does not have any data and all elements are sent in full. If d is
+// nil, all data is returned without patches.
+func (d *deltaEncoder) split(all bool, data map[string]json.RawMessage) (map[string]json.RawMessage, map[string]map[int][]patchOperation, error) {
+	if d == nil {
+		return data, nil, nil
+	}
+
+	if all {
+		d.sent = make(map[string]json.RawMessage, len(data))
+	}
+
+	full := make(map[string]json.RawMessage, len(data))
+	patched := make(map[string]map[int][]patchOperation)
+	for key, value := range data {
+		old, known := d.sent[key]
+		if value == nil {
+			delete(d.sent, key)
+			full[key] = nil
+			continue
+		}
+		d.sent[key] = value
+
+		if !known || all {
+			full[key] = value
+			continue
+		}
+
+		patch, err := jsonDiff(old, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("diff %s: %w", key, err)
+		}
+
+		if len(patch) == 0 {
+			// The client already has this version.
+			continue
+		}
+
+		encoded, err := json.Marshal(patch)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encoding patch for %s: %w", key, err)
+		}
+
+		if len(encoded) >= len(value) {
+			full[key] = value
+			continue
+		}
+
+		collection, id, err := splitKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if patched[collection] == nil {
+			patched[collection] = make(map[int][]patchOperation)
+		}
+		patched[collection][id] = patch
+	}
+
+	if len(patched) == 0 {
+		patched = nil
+	}
+	return full, patched, nil
+}
+
+// jsonDiff returns the json patch that changes a to b.
+//
+// Objects are compared field by field. All other values, including lists, are
+// replaced when they differ.
+func jsonDiff(a, b json.RawMessage) ([]patchOperation, error) {
+	return diffValue("", a, b)
+}
+
+func diffValue(path string, a, b json.RawMessage) ([]patchOperation, error) {
+	if bytes.Equal(a, b) {
+		return nil, nil
+	}
+
+	if !isJSONObject(a) || !isJSONObject(b) {
+		return []patchOperation{{Op: "replace", Path: path, Value: b}}, nil
+	}
+
+	var aFields, bFields map[string]json.RawMessage
+	if err := json.Unmarshal(a, &aFields); err != nil {
+		return nil, fmt.Errorf("decoding old value: %w", err)
+	}
+	if err := json.Unmarshal(b, &bFields); err != nil {
+		return nil, fmt.Errorf("decoding new value: %w", err)
+	}
+
+	// The fields are sorted, so the patch is always the same.
+	fields := make([]string, 0, len(aFields)+len(bFields))
+	for field := range aFields {
+		fields = append(fields, field)
+	}
+	for field := range bFields {
+		if _, ok := aFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var ops []patchOperation
+	for _, field := range fields {
+		fieldPath := path + "/" + escapePointer(field)
+		aValue, inA := aFields[field]
+		bValue, inB := bFields[field]
+
+		switch {
+		case !inB:
+			ops = append(ops, patchOperation{Op: "remove", Path: fieldPath})
+
+		case !inA:
+			ops = append(ops, patchOperation{Op: "add", Path: fieldPath, Value: bValue})
+
+		default:
+			fieldOps, err := diffValue(fieldPath, aValue, bValue)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, fieldOps...)
+		}
+	}
+	return ops, nil
+}
+
+func isJSONObject(v json.RawMessage) bool {
+	v = bytes.TrimSpace(v)
+	return len(v) > 0 && v[0] == '{'
+}
+
+// escapePointer escapes a field name for a json pointer (RFC 6901).
+func escapePointer(field string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(field, "~", "~0"), "/", "~1")
+}
+
+// splitKey splits a key like motions/motion:1 into the collection and the id.
+func splitKey(key string) (string, int, error) {
+	parts := strings.Split(key, ":")
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("invalid key %s, expected exacly one `:`", key)
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid key %s, id is not a number", key)
+	}
+	return parts[0], id, nil
+}
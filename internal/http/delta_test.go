@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONDiff(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		a      string
+		b      string
+		expect string
+	}{
+		{
+			"equal",
+			`{"a": 1}`,
+			`{"a": 1}`,
+			`null`,
+		},
+		{
+			"replace field",
+			`{"a": 1, "b": 2}`,
+			`{"a": 1, "b": 3}`,
+			`[{"op": "replace", "path": "/b", "value": 3}]`,
+		},
+		{
+			"add and remove",
+			`{"a": 1}`,
+			`{"b": null}`,
+			`[{"op": "remove", "path": "/a"}, {"op": "add", "path": "/b", "value": null}]`,
+		},
+		{
+			"nested object",
+			`{"a": {"b": 1, "c": 2}}`,
+			`{"a": {"b": 1, "c": 3}}`,
+			`[{"op": "replace", "path": "/a/c", "value": 3}]`,
+		},
+		{
+			"list",
+			`{"a": [1, 2]}`,
+			`{"a": [1, 3]}`,
+			`[{"op": "replace", "path": "/a", "value": [1, 3]}]`,
+		},
+		{
+			"escaped field",
+			`{"a/b~c": 1}`,
+			`{"a/b~c": 2}`,
+			`[{"op": "replace", "path": "/a~1b~0c", "value": 2}]`,
+		},
+		{
+			"no object",
+			`"foo"`,
+			`"bar"`,
+			`[{"op": "replace", "path": "", "value": "bar"}]`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := jsonDiff([]byte(tt.a), []byte(tt.b))
+			if err != nil {
+				t.Fatalf("jsonDiff returned unexpected error: %v", err)
+			}
+
+			got, err := json.Marshal(patch)
+			if err != nil {
+				t.Fatalf("Can not encode patch: %v", err)
+			}
+
+			var gotV, expectV interface{}
+			json.Unmarshal(got, &gotV)
+			json.Unmarshal([]byte(tt.expect), &expectV)
+			if !reflect.DeepEqual(gotV, expectV) {
+				t.Errorf("Got patch %s, expected %s", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestDeltaEncoderSize(t *testing.T) {
+	longText := strings.Repeat("A long motion text. ", 500)
+	motion := func(title string) json.RawMessage {
+		return []byte(fmt.Sprintf(`{"id":1,"title":"%s","text":"%s","state_id":1}`, title, longText))
+	}
+
+	delta := newDeltaEncoder()
+
+	first, err := newAutoupdateFormat(true, map[string]json.RawMessage{"motions/motion:1": motion("first")}, 0, 1, delta)
+	if err != nil {
+		t.Fatalf("newAutoupdateFormat returned unexpected error: %v", err)
+	}
+
+	if first.Patched != nil || len(first.Changed["motions/motion"]) != 1 {
+		t.Errorf("First data has patches %v, expected the full element", first.Patched)
+	}
+
+	data := map[string]json.RawMessage{"motions/motion:1": motion("second")}
+	withDelta, err := newAutoupdateFormat(false, data, 1, 2, delta)
+	if err != nil {
+		t.Fatalf("newAutoupdateFormat returned unexpected error: %v", err)
+	}
+
+	withoutDelta, err := newAutoupdateFormat(false, data, 1, 2, nil)
+	if err != nil {
+		t.Fatalf("newAutoupdateFormat returned unexpected error: %v", err)
+	}
+
+	encodedDelta, _ := json.Marshal(withDelta)
+	encodedFull, _ := json.Marshal(withoutDelta)
+
+	if len(encodedDelta)*10 > len(encodedFull) {
+		t.Errorf("Delta data has %d bytes, full data has %d bytes, expected the delta to be much smaller", len(encodedDelta), len(encodedFull))
+	}
+
+	if len(withDelta.Changed) != 0 {
+		t.Errorf("Delta data contains changed elements, expected only a patch")
+	}
+
+	patch := withDelta.Patched["motions/motion"][1]
+	if len(patch) != 1 || patch[0].Path != "/title" || string(patch[0].Value) != `"second"` {
+		t.Errorf("Got patch %v, expected to replace the title", patch)
+	}
+
+	// A small element is sent in full, because the patch would be bigger.
+	small := map[string]json.RawMessage{"core/tag:1": []byte(`{"a":1}`)}
+	if _, err := newAutoupdateFormat(false, small, 2, 3, delta); err != nil {
+		t.Fatalf("newAutoupdateFormat returned unexpected error: %v", err)
+	}
+	small["core/tag:1"] = []byte(`{"a":2}`)
+	smallFormat, err := newAutoupdateFormat(false, small, 3, 4, delta)
+	if err != nil {
+		t.Fatalf("newAutoupdateFormat returned unexpected error: %v", err)
+	}
+
+	if smallFormat.Patched != nil || len(smallFormat.Changed["core/tag"]) != 1 {
+		t.Errorf("Small element was sent as patch %v, expected the full element", smallFormat.Patched)
+	}
+}
@@ -1,6 +1,9 @@
 package http
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type invalidRequestError struct {
 	err error
@@ -45,3 +48,31 @@ func (e authRequiredError) Error() string {
 func (e authRequiredError) ClientError() string {
 	return "auth_required"
 }
+
+type permissionDeniedError struct {
+	perm string
+}
+
+func (e permissionDeniedError) Error() string {
+	return fmt.Sprintf("You need the permission %s", e.perm)
+}
+
+func (e permissionDeniedError) ClientError() string {
+	return "permission_denied"
+}
+
+type tooManyRequestsError struct {
+	retryAfter time.Duration
+}
+
+func (e tooManyRequestsError) Error() string {
+	return fmt.Sprintf("Too many requests. Try again in %d seconds", int(e.retryAfter.Seconds()))
+}
+
+func (e tooManyRequestsError) ClientError() string {
+	return "too_many_requests"
+}
+
+func (e tooManyRequestsError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
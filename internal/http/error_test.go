@@ -0,0 +1,30 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+func TestErrHandlerDoesNotExist(t *testing.T) {
+	handler := errHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("restricting: %w", datastore.DoesNotExistError("motions/motion:5"))
+	}, logger.Noop)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Got status %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+
+	expect := `{"error": {"type": "does_not_exist", "msg": "motions/motion:5 does not exist"}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != expect {
+		t.Errorf("Got body `%s`, expected `%s`", got, expect)
+	}
+}
@@ -7,14 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/auth"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/notify"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/global"
@@ -22,14 +24,64 @@ import (
 
 var meter = global.GetMeterProvider().Meter("openslides.org")
 
+// Services are the services used by the routes registered with RegisterAll.
+type Services struct {
+	Auth       Auther
+	Autoupdate *autoupdate.Autoupdate
+	Notify     *notify.Notify
+
+	// Limiter is used for the autoupdate connections and the catch up. It can
+	// be nil.
+	Limiter *RateLimiter
+
+	// MaxMessageSize is the size, after that autoupdate messages over
+	// server-sent events or websocket are split. 0 means no limit.
+	MaxMessageSize int
+
+	// Keepalive is the interval of the keepalive comment of server-sent events
+	// connections, if there was no event.
+	Keepalive time.Duration
+
+	Readier          Readier
+	Counter          Counter
+	Resetter         Resetter
+	Superadminer     Superadminer
+	Explainer        Explainer
+	HasPermer        HasPermer
+	PermissionLister PermissionLister
+	RawElementer     RawElementer
+	Applauser        Applauser
+	ApplauseResetter ApplauseResetter
+
+	Log logger.Logger
+}
+
 // RegisterAll registers all routes.
-func RegisterAll(mux *http.ServeMux, auth Auther, a *autoupdate.Autoupdate, n *notify.Notify) {
+func RegisterAll(mux *http.ServeMux, s Services) {
+	limited := RateLimit(s.Auth, s.Limiter)
+	log := s.Log
+
 	Health(mux)
-	Autoupdate(mux, a, auth)
-	Projector(mux, a, auth)
-	Notify(mux, n, auth)
-	NotifySend(mux, n, auth)
-	NotifyApplause(mux, n, auth)
+	Liveness(mux, s.Autoupdate)
+	Readiness(mux, s.Readier, log)
+	Autoupdate(mux, s.Autoupdate, limited, log)
+	AutoupdateSSE(mux, s.Autoupdate, s.MaxMessageSize, s.Keepalive, limited, log)
+	AutoupdateWebsocket(mux, s.Autoupdate, s.MaxMessageSize, limited, log)
+	ChangeIDs(mux, s.Readier, s.Auth, log)
+	Stats(mux, s.Counter, s.Auth, log)
+	DatastoreReset(mux, s.Resetter, s.Superadminer, s.Auth, log)
+	AutoupdateCatchUp(mux, s.Autoupdate, limited, log)
+	AutoupdateSnapshot(mux, s.Autoupdate, limited, log)
+	Projector(mux, s.Autoupdate, s.Auth, log)
+	ProjectorByID(mux, s.Autoupdate, s.Auth, log)
+	DebugRestrict(mux, s.Explainer, s.HasPermer, s.Auth, log)
+	DebugPermissions(mux, s.PermissionLister, s.HasPermer, s.Auth, log)
+	DebugRaw(mux, s.RawElementer, s.HasPermer, s.Auth, log)
+	Notify(mux, s.Notify, s.Auth, log)
+	NotifySend(mux, s.Notify, s.Auth, log)
+	NotifyApplause(mux, s.Notify, s.Auth, log)
+	ApplauseStatus(mux, s.Applauser, s.Auth, log)
+	ApplauseReset(mux, s.ApplauseResetter, s.HasPermer, s.Auth, log)
 }
 
 // Health registers the health route.
@@ -40,22 +92,81 @@ func Health(mux *http.ServeMux) {
 	})
 }
 
+// Liveness registers the liveness route.
+//
+// It fails, if the update loop of the autoupdate service has stopped or is
+// stuck. If the service is deadlocked, the request does not return.
+func Liveness(mux *http.ServeMux, l Liver) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !l.Alive() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, `{"alive": false}`)
+			return
+		}
+		fmt.Fprintln(w, `{"alive": true}`)
+	})
+}
+
+// Readiness registers the readiness route.
+//
+// It fails, if the datastore has no connection to redis or is reset. The flag
+// degraded is set, if the datastore serves old data, because requests for
+// missing data failed too often.
+func Readiness(mux *http.ServeMux, ready Readier, log logger.Logger) {
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		out := struct {
+			Ready          bool `json:"ready"`
+			Degraded       bool `json:"degraded,omitempty"`
+			LowestChangeID int  `json:"lowest_change_id"`
+			ChangeID       int  `json:"change_id"`
+		}{
+			ready.Ready(),
+			ready.Degraded(),
+			ready.LowestID(),
+			ready.CurrentID(),
+		}
+
+		if !out.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			log.Error("Can not send readiness", "error", err)
+		}
+	})
+}
+
 // Autoupdate registers the autoupdate route.
-func Autoupdate(mux *http.ServeMux, auto *autoupdate.Autoupdate, auther Auther) {
+func Autoupdate(mux *http.ServeMux, auto *autoupdate.Autoupdate, auther Auther, log logger.Logger) {
 	count := newConnectionCount("autoupdate")
 
 	handler := func(w http.ResponseWriter, r *http.Request) error {
 		uid := auth.FromContext(r.Context())
 
 		n := count.Add()
-		log.Printf("Got autoupdate connection. User %d. Connection count: %d", uid, n)
+		log.Info("Got autoupdate connection", "route", "autoupdate", "user_id", uid, "connections", n)
 
 		defer func() {
 			n := count.Sub()
-			log.Printf("Lost autoupdate connection. User %d. Connection count: %d", uid, n)
+			log.Info("Lost autoupdate connection", "route", "autoupdate", "user_id", uid, "connections", n)
 		}()
 
-		w.Header().Set("Content-Type", "application/octet-stream")
+		encode, contentType := negotiateEncoder(r)
+		w.Header().Set("Content-Type", contentType)
+
+		delta, err := deltaMode(r)
+		if err != nil {
+			return err
+		}
+
+		fields, err := parseProjection(r.URL.Query()["fields"])
+		if err != nil {
+			return err
+		}
 
 		rawChangeID := r.URL.Query().Get("change_id")
 		var changeID int
@@ -68,94 +179,652 @@ func Autoupdate(mux *http.ServeMux, auto *autoupdate.Autoupdate, auther Auther)
 		}
 
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintln(w, `{"connected":true}`)
+		if err := encode(w, map[string]bool{"connected": true}); err != nil {
+			return noStatusCodeError{err}
+		}
 		w.(http.Flusher).Flush()
 
-		// Retrive uid from request. 0 for anonymous.
-		log.Printf("connect user %d with change_id %d", uid, changeID)
+		log.Debug("Connect user", "user_id", uid, "change_id", changeID)
+
+		collections := collectionNames(r.URL.Query().Get("collections"))
+
+		sub := auto.Subscribe(uid, changeID, collections)
+		defer sub.Close()
 
 		for {
-			all, data, newChangeID, err := auto.Receive(r.Context(), uid, changeID)
+			ctx, span := startDelivery(r.Context(), "autoupdate", uid, changeID)
+			all, data, newChangeID, err := sub.Next(ctx)
 			if err != nil {
+				span.End()
 				return noStatusCodeError{err}
 			}
 
 			if len(data) == 0 {
+				span.End()
 				continue
 			}
 
-			if err := sendAutoupdateData(w, all, data, changeID, newChangeID); err != nil {
+			err = traced(ctx, "autoupdate.serialize", func() error {
+				if err := fields.apply(data); err != nil {
+					return err
+				}
+				renameCollections(ctx, data)
+				return sendAutoupdateData(w, encode, all, data, changeID, newChangeID, delta)
+			})
+			span.End()
+			if err != nil {
 				return noStatusCodeError{err}
 			}
 			changeID = newChangeID
 		}
 	}
 
-	mux.Handle("/system/autoupdate", errHandleFunc(middleware(handler, auther)))
+	mux.Handle("/system/autoupdate", compressHandler(errHandler(middleware(handler, auther), log)))
 }
 
-// Projector registers the projector route.
-func Projector(mux *http.ServeMux, auto *autoupdate.Autoupdate, auth Auther) {
-	count := newConnectionCount("projector")
+// AutoupdateSSE registers the autoupdate route that sends the data as
+// server-sent events.
+//
+// The id of each event is the change id of the data. A reconnecting client can
+// send it back with the Last-Event-ID header to receive only the changed data.
+// If the changes since this id are not known anymore, an event with the type
+// `reset` is sent and the next event contains all data.
+//
+// Data that is bigger then maxMessageSize bytes is split into many events. 0
+// means no limit.
+//
+// If no event was sent for the keepalive interval, the comment `: keepalive`
+// is sent, so idle connections are not closed by proxies. 0 means no comments.
+//
+// When the server shuts down, an event with the type `closing` is sent.
+func AutoupdateSSE(mux *http.ServeMux, auto *autoupdate.Autoupdate, maxMessageSize int, keepaliveInterval time.Duration, auther Auther, log logger.Logger) {
+	count := newConnectionCount("autoupdate-sse")
 
 	handler := func(w http.ResponseWriter, r *http.Request) error {
+		uid := auth.FromContext(r.Context())
+
 		n := count.Add()
-		log.Println("Got projector connection. Connection count: ", n)
+		log.Info("Got autoupdate connection", "route", "autoupdate-sse", "user_id", uid, "connections", n)
 
 		defer func() {
 			n := count.Sub()
-			log.Println("Lost projector connection. Connection count: ", n)
+			log.Info("Lost autoupdate connection", "route", "autoupdate-sse", "user_id", uid, "connections", n)
 		}()
 
-		w.Header().Set("Content-Type", "application/json")
+		rawChangeID := r.Header.Get("Last-Event-ID")
+		if rawChangeID == "" {
+			rawChangeID = r.URL.Query().Get("change_id")
+		}
 
-		projectorIDs, err := projectorIDs(r.URL.Query().Get("projector_ids"))
+		var changeID int
+		if rawChangeID != "" {
+			var err error
+			changeID, err = strconv.Atoi(rawChangeID)
+			if err != nil {
+				return invalidRequestError{fmt.Errorf("Change id has to be a number not %s", rawChangeID)}
+			}
+		}
+
+		delta, err := deltaMode(r)
 		if err != nil {
 			return err
 		}
 
+		fields, err := parseProjection(r.URL.Query()["fields"])
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
 		w.WriteHeader(http.StatusOK)
 		w.(http.Flusher).Flush()
 
-		encoder := json.NewEncoder(w)
-		var tid uint64
+		log.Debug("Connect user", "user_id", uid, "change_id", changeID)
+
+		collections := collectionNames(r.URL.Query().Get("collections"))
+
+		k := startKeepalive(w, keepaliveInterval)
+		defer k.stop()
+
+		changeID, reason := resumeFrom(auto, changeID)
+		if reason != "" {
+			log.Debug("Reset client", "user_id", uid, "reason", reason)
+			if err := k.send(func(w io.Writer) error { return sendResetEvent(w, reason) }); err != nil {
+				return noStatusCodeError{err}
+			}
+		}
+
+		sub := auto.Subscribe(uid, changeID, collections)
+		defer sub.Close()
+
 		for {
-			ntid, data, cid, err := auto.Projectors(r.Context(), tid, projectorIDs)
+			ctx, span := startDelivery(r.Context(), "autoupdate-sse", uid, changeID)
+			all, data, newChangeID, err := sub.Next(ctx)
 			if err != nil {
+				span.End()
+				var closing interface {
+					Closing()
+				}
+				if errors.As(err, &closing) {
+					if err := k.send(sendClosingEvent); err != nil {
+						return noStatusCodeError{err}
+					}
+				}
 				return noStatusCodeError{err}
 			}
 
-			out := struct {
-				CID  int                     `json:"change_id"`
-				Data map[int]json.RawMessage `json:"data"`
-			}{
-				cid,
-				data,
+			if len(data) == 0 {
+				span.End()
+				changeID = newChangeID
+				continue
 			}
 
-			if err := encoder.Encode(out); err != nil {
+			err = traced(ctx, "autoupdate.serialize", func() error {
+				if err := fields.apply(data); err != nil {
+					return err
+				}
+				renameCollections(ctx, data)
+				return k.send(func(w io.Writer) error {
+					return sendAutoupdateEvent(w, all, data, changeID, newChangeID, maxMessageSize, delta)
+				})
+			})
+			span.End()
+			if err != nil {
 				return noStatusCodeError{err}
 			}
-			w.(http.Flusher).Flush()
-			tid = ntid
+			changeID = newChangeID
+		}
+	}
+
+	mux.Handle("/system/autoupdate/sse", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// AutoupdateCatchUp registers the route that returns all changed data since a
+// change id in one response.
+//
+// The client has to send a POST request with a body like `{"change_id": 123}`.
+// If the change id is to old, the response is `{"reset": true}` and the client
+// has to receive all data with the autoupdate route.
+//
+// With the query `format=log`, the data is returned as a compact change log. In
+// this format, the response can be MessagePack encoded with the Accept header.
+func AutoupdateCatchUp(mux *http.ServeMux, auto *autoupdate.Autoupdate, auther Auther, log logger.Logger) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodPost {
+			return invalidRequestError{fmt.Errorf("Only POST requests are supported")}
+		}
+
+		var body struct {
+			ChangeID int `json:"change_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return invalidRequestError{fmt.Errorf("Invalid body: %v", err)}
+		}
+
+		fields, err := parseProjection(r.URL.Query()["fields"])
+		if err != nil {
+			return err
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "log" {
+			return invalidRequestError{fmt.Errorf("Unknown format `%s`, only `log` is supported", format)}
+		}
+
+		uid := auth.FromContext(r.Context())
+		reset, data, currentID, err := auto.ChangedSince(uid, body.ChangeID)
+		if err != nil {
+			return fmt.Errorf("get changed data: %w", err)
+		}
+
+		if err := fields.apply(data); err != nil {
+			return fmt.Errorf("apply fields: %w", err)
+		}
+		renameCollections(r.Context(), data)
+
+		encode, contentType := outputEncoder(encodeJSON), "application/json"
+		if format == "log" {
+			if e, ct := negotiateEncoder(r); ct == contentTypeMsgpack {
+				encode, contentType = e, ct
+			}
+		}
+		w.Header().Set("Content-Type", contentType)
+
+		if reset {
+			out := struct {
+				Reset      bool `json:"reset"`
+				ToChangeID int  `json:"to_change_id"`
+			}{true, currentID}
+
+			if err := encode(w, out); err != nil {
+				return noStatusCodeError{fmt.Errorf("encoding reset: %w", err)}
+			}
+			return nil
+		}
+
+		if format == "log" {
+			if err := encode(w, newChangeLog(data, body.ChangeID, currentID)); err != nil {
+				return noStatusCodeError{fmt.Errorf("encoding change log: %w", err)}
+			}
+			return nil
+		}
+
+		return sendAutoupdateData(w, encodeJSON, false, data, body.ChangeID, currentID, nil)
+	}
+
+	mux.Handle("/system/autoupdate/catchup", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// AutoupdateSnapshot registers the route that returns all data in one
+// response.
+//
+// The ETag of the response is the change id of the data. A client that sends
+// it back with If-None-Match receives the status 304, if the data did not
+// change since.
+func AutoupdateSnapshot(mux *http.ServeMux, auto *autoupdate.Autoupdate, auther Auther, log logger.Logger) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		// The data depends on the user.
+		w.Header().Add("Vary", "Cookie")
+
+		if etagMatches(r.Header.Get("If-None-Match"), auto.CurrentID()) {
+			w.Header().Set("ETag", changeIDETag(auto.CurrentID()))
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+
+		uid := auth.FromContext(r.Context())
+		collections := collectionNames(r.URL.Query().Get("collections"))
+		_, data, changeID, err := auto.ReceiveCollections(r.Context(), uid, 0, collections)
+		if err != nil {
+			return fmt.Errorf("get all data: %w", err)
+		}
+
+		renameCollections(r.Context(), data)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", changeIDETag(changeID))
+		return sendAutoupdateData(w, encodeJSON, true, data, 0, changeID, nil)
+	}
+
+	mux.Handle("/system/autoupdate/snapshot", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// changeIDETag returns the ETag for a change id.
+func changeIDETag(changeID int) string {
+	return fmt.Sprintf(`"%d"`, changeID)
+}
+
+// etagMatches tells, if the value of an If-None-Match header contains the ETag
+// of the change id.
+func etagMatches(ifNoneMatch string, changeID int) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag := changeIDETag(changeID)
+	for _, value := range strings.Split(ifNoneMatch, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeIDs registers the route that returns the lowest and the current change
+// id.
+//
+// If the client sends its last known change id and it is lower then the lowest
+// change id, the flag full_reload_required is set. In this case, the client
+// can not receive the changed data and has to reload all data.
+func ChangeIDs(mux *http.ServeMux, ids ChangeIDer, auther Auther, log logger.Logger) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		var clientChangeID int
+		if rawChangeID := r.URL.Query().Get("change_id"); rawChangeID != "" {
+			var err error
+			clientChangeID, err = strconv.Atoi(rawChangeID)
+			if err != nil {
+				return invalidRequestError{fmt.Errorf("Change id has to be a number not %s", rawChangeID)}
+			}
+		}
+
+		out := struct {
+			LowestChangeID     int  `json:"lowest_change_id"`
+			CurrentChangeID    int  `json:"current_change_id"`
+			FullReloadRequired bool `json:"full_reload_required,omitempty"`
+		}{
+			LowestChangeID:  ids.LowestID(),
+			CurrentChangeID: ids.CurrentID(),
+		}
+		out.FullReloadRequired = clientChangeID != 0 && clientChangeID < out.LowestChangeID
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return noStatusCodeError{fmt.Errorf("encoding change ids: %w", err)}
+		}
+		return nil
+	}
+
+	mux.Handle("/system/autoupdate/change_ids", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// Stats registers the route that returns the number of elements of each
+// collection and the size of the cache.
+//
+// The numbers are not restricted, so only logged in users can use the route.
+func Stats(mux *http.ServeMux, counter Counter, auther Auther, log logger.Logger) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		if auth.FromContext(r.Context()) == 0 {
+			return authRequiredError{"You have to be logged in to see the stats."}
+		}
+
+		stats := struct {
+			Collections map[string]int `json:"collections"`
+			Elements    int            `json:"elements"`
+			Bytes       int64          `json:"bytes"`
+			Deleted     int            `json:"deleted"`
+		}{
+			Collections: counter.CollectionCounts(),
+			Elements:    counter.Len(),
+			Bytes:       counter.BytesEstimate(),
+			Deleted:     counter.DeletedCount(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			return noStatusCodeError{fmt.Errorf("encoding stats: %w", err)}
+		}
+		return nil
+	}
+
+	mux.Handle("/system/autoupdate/stats", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// DatastoreReset registers the route that initializes the datastore again with
+// the data from redis. All connected clients receive all data afterwards.
+//
+// The route needs a POST request. Only users in the admin group can use it.
+func DatastoreReset(mux *http.ServeMux, resetter Resetter, admin Superadminer, auther Auther, log logger.Logger) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodPost {
+			return invalidRequestError{fmt.Errorf("Only POST requests are supported")}
+		}
+
+		uid := auth.FromContext(r.Context())
+		if !admin.IsSuperadmin(uid) {
+			return permissionDeniedError{perm: "superadmin"}
+		}
+
+		log.Info("Reset datastore", "user_id", uid)
+		if err := resetter.ForceReset(); err != nil {
+			return fmt.Errorf("reset datastore: %w", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"reset": true}`)
+		return nil
+	}
+
+	mux.Handle("/system/autoupdate/reset", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// DebugRestrict registers the route that shows an element like another user
+// sees it.
+//
+// The route needs the query parameters user_id and key, for example
+// `?user_id=5&key=motions/motion:1`. If the user can not see the element, the
+// response contains the reason. Only users with the permission to manage users
+// can use the route.
+func DebugRestrict(mux *http.ServeMux, explainer Explainer, permer HasPermer, auther Auther, log logger.Logger) {
+	const managePerm = "users.can_manage"
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		if !permer.HasPerm(auth.FromContext(r.Context()), managePerm) {
+			return permissionDeniedError{perm: managePerm}
+		}
+
+		rawUID := r.URL.Query().Get("user_id")
+		uid, err := strconv.Atoi(rawUID)
+		if err != nil {
+			return invalidRequestError{fmt.Errorf("User id has to be a number not %s", rawUID)}
+		}
+
+		key := r.URL.Query().Get("key")
+		if _, _, err := splitKey(key); err != nil {
+			return invalidRequestError{err}
+		}
+
+		element, reason := explainer.Explain(uid, key)
+
+		out := struct {
+			UserID  int             `json:"user_id"`
+			Key     string          `json:"key"`
+			Visible bool            `json:"visible"`
+			Element json.RawMessage `json:"element,omitempty"`
+			Reason  string          `json:"reason,omitempty"`
+		}{
+			UserID:  uid,
+			Key:     key,
+			Visible: element != nil,
+			Element: element,
+			Reason:  reason,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return noStatusCodeError{fmt.Errorf("encoding debug data: %w", err)}
+		}
+		return nil
+	}
+
+	mux.Handle("/system/autoupdate/debug", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// DebugPermissions registers the route that shows the permissions and groups
+// of a user. The user id is the last part of the path, for example
+// /system/autoupdate/debug/permissions/5.
+//
+// The permissions are the same, that the restricters use. Only users with the
+// permission to manage users can use the route.
+func DebugPermissions(mux *http.ServeMux, lister PermissionLister, permer HasPermer, auther Auther, log logger.Logger) {
+	const managePerm = "users.can_manage"
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		if !permer.HasPerm(auth.FromContext(r.Context()), managePerm) {
+			return permissionDeniedError{perm: managePerm}
+		}
+
+		rawUID := strings.TrimPrefix(r.URL.Path, "/system/autoupdate/debug/permissions/")
+		uid, err := strconv.Atoi(rawUID)
+		if err != nil {
+			return invalidRequestError{fmt.Errorf("User id has to be a number not %s", rawUID)}
+		}
+
+		out := struct {
+			UserID      int      `json:"user_id"`
+			Superadmin  bool     `json:"superadmin"`
+			GroupIDs    []int    `json:"group_ids"`
+			Permissions []string `json:"permissions"`
+		}{
+			UserID:      uid,
+			Superadmin:  lister.IsSuperadmin(uid),
+			GroupIDs:    lister.GroupIDs(uid),
+			Permissions: lister.Permissions(uid),
+		}
+
+		if out.GroupIDs == nil {
+			out.GroupIDs = []int{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return noStatusCodeError{fmt.Errorf("encoding permissions: %w", err)}
+		}
+		return nil
+	}
+
+	mux.Handle("/system/autoupdate/debug/permissions/", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// DebugRaw registers the route that returns the cached bytes of an element
+// without a restriction. The key is the last part of the path, for example
+// /system/autoupdate/debug/raw/motions/motion/5.
+//
+// The element is returned as it is in the cache. A deleted element is returned
+// with the status 410 and the body `{"deleted": true}`. Only users with the
+// permission to manage users can use the route.
+//
+// Votes are never returned, because they contain the users of pseudoanonymous
+// polls. Personal notes are only returned to their owner.
+func DebugRaw(mux *http.ServeMux, raw RawElementer, permer HasPermer, auther Auther, log logger.Logger) {
+	const managePerm = "users.can_manage"
+
+	hiddenCollections := map[string]bool{
+		"motions/motion-vote":         true,
+		"assignments/assignment-vote": true,
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		uid := auth.FromContext(r.Context())
+		if !permer.HasPerm(uid, managePerm) {
+			return permissionDeniedError{perm: managePerm}
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/system/autoupdate/debug/raw/")
+		sep := strings.LastIndex(path, "/")
+		if sep == -1 {
+			return invalidRequestError{fmt.Errorf("Path has to be like /system/autoupdate/debug/raw/motions/motion/5")}
+		}
+
+		key := path[:sep] + ":" + path[sep+1:]
+		collection, _, err := splitKey(key)
+		if err != nil {
+			return invalidRequestError{err}
+		}
+
+		if hiddenCollections[collection] {
+			return invalidRequestError{fmt.Errorf("Elements of %s can not be read raw", collection)}
+		}
+
+		element, deleted, err := raw.RawElement(key)
+		if err != nil {
+			return fmt.Errorf("getting element: %w", err)
+		}
+
+		if collection == "users/personal-note" && !deleted {
+			var note struct {
+				UserID int `json:"user_id"`
+			}
+			if err := json.Unmarshal(element, &note); err != nil {
+				return fmt.Errorf("decoding personal note: %w", err)
+			}
+
+			if note.UserID != uid {
+				return invalidRequestError{fmt.Errorf("Personal note %s belongs to another user", key)}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if deleted {
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprintln(w, `{"deleted": true}`)
+			return nil
+		}
+
+		if _, err := w.Write(element); err != nil {
+			return noStatusCodeError{fmt.Errorf("writing element: %w", err)}
+		}
+		return nil
+	}
+
+	mux.Handle("/system/autoupdate/debug/raw/", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// Projector registers the projector route.
+func Projector(mux *http.ServeMux, auto *autoupdate.Autoupdate, auth Auther, log logger.Logger) {
+	count := newConnectionCount("projector")
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		projectorIDs, err := projectorIDs(r.URL.Query().Get("projector_ids"))
+		if err != nil {
+			return err
+		}
+
+		return streamProjectors(w, r, auto, count, projectorIDs, log)
+	}
+	mux.Handle("/system/projector", compressHandler(errHandler(middleware(handler, auth), log)))
+}
+
+// ProjectorByID registers the route for one projector. The projector id is
+// the last part of the path, for example /system/projector/1.
+func ProjectorByID(mux *http.ServeMux, auto *autoupdate.Autoupdate, auth Auther, log logger.Logger) {
+	count := newConnectionCount("projector-by-id")
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		rawID := strings.TrimPrefix(r.URL.Path, "/system/projector/")
+		id, err := strconv.Atoi(rawID)
+		if err != nil {
+			return invalidRequestError{fmt.Errorf("projector id has to be an int not `%s`", rawID)}
+		}
+
+		return streamProjectors(w, r, auto, count, []int{id}, log)
+	}
+	mux.Handle("/system/projector/", compressHandler(errHandler(middleware(handler, auth), log)))
+}
+
+// streamProjectors sends the rendered data of the projectors and every time
+// one of them changes.
+func streamProjectors(w http.ResponseWriter, r *http.Request, auto *autoupdate.Autoupdate, count *connectionCount, projectorIDs []int, log logger.Logger) error {
+	n := count.Add()
+	log.Info("Got projector connection", "connections", n)
+
+	defer func() {
+		n := count.Sub()
+		log.Info("Lost projector connection", "connections", n)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	encoder := json.NewEncoder(w)
+	var tid uint64
+	for {
+		ntid, data, cid, err := auto.Projectors(r.Context(), tid, projectorIDs)
+		if err != nil {
+			return noStatusCodeError{err}
 		}
+
+		out := struct {
+			CID  int                     `json:"change_id"`
+			Data map[int]json.RawMessage `json:"data"`
+		}{
+			cid,
+			data,
+		}
+
+		if err := encoder.Encode(out); err != nil {
+			return noStatusCodeError{err}
+		}
+		w.(http.Flusher).Flush()
+		tid = ntid
 	}
-	mux.Handle("/system/projector", errHandleFunc(middleware(handler, auth)))
 }
 
 // Notify registers the notify route.
-func Notify(mux *http.ServeMux, n *notify.Notify, auther Auther) {
+func Notify(mux *http.ServeMux, n *notify.Notify, auther Auther, log logger.Logger) {
 	count := newConnectionCount("notify")
 
 	handler := func(w http.ResponseWriter, r *http.Request) error {
 		uid := auth.FromContext(r.Context())
 
 		nu := count.Add()
-		log.Printf("Got autoupdate connection. User %d. Connection count: %d", uid, nu)
+		log.Info("Got notify connection", "user_id", uid, "connections", nu)
 
 		defer func() {
 			nu := count.Sub()
-			log.Printf("Lost autoupdate connection. User %d. Connection count: %d", uid, nu)
+			log.Info("Lost notify connection", "user_id", uid, "connections", nu)
 		}()
 
 		w.Header().Set("Content-Type", "application/octet-stream")
@@ -185,11 +854,11 @@ func Notify(mux *http.ServeMux, n *notify.Notify, auther Auther) {
 			w.(http.Flusher).Flush()
 		}
 	}
-	mux.Handle("/system/notify", errHandleFunc(middleware(handler, auther)))
+	mux.Handle("/system/notify", compressHandler(errHandler(middleware(handler, auther), log)))
 }
 
 // NotifySend registers the notify/send route.
-func NotifySend(mux *http.ServeMux, n *notify.Notify, auther Auther) {
+func NotifySend(mux *http.ServeMux, n *notify.Notify, auther Auther, log logger.Logger) {
 	counter, _ := meter.NewInt64Counter(
 		"openslides.notify-send-requests",
 		metric.WithDescription("request count to notify send"),
@@ -219,11 +888,11 @@ func NotifySend(mux *http.ServeMux, n *notify.Notify, auther Auther) {
 
 		return n.Send(buf.Bytes(), userID)
 	}
-	mux.Handle("/system/notify/send", errHandleFunc(middleware(handler, auther)))
+	mux.Handle("/system/notify/send", compressHandler(errHandler(middleware(handler, auther), log)))
 }
 
 // NotifyApplause registers the notify/applause route.
-func NotifyApplause(mux *http.ServeMux, n *notify.Notify, auther Auther) {
+func NotifyApplause(mux *http.ServeMux, n *notify.Notify, auther Auther, log logger.Logger) {
 	counter, _ := meter.NewInt64Counter(
 		"openslides.notify-applause-requests",
 		metric.WithDescription("request count to applause send"),
@@ -238,14 +907,76 @@ func NotifyApplause(mux *http.ServeMux, n *notify.Notify, auther Auther) {
 
 		return n.AddApplause(userID)
 	}
-	mux.Handle("/system/applause", errHandleFunc(middleware(handler, auther)))
+	mux.Handle("/system/applause", compressHandler(errHandler(middleware(handler, auther), log)))
 }
 
-// errHandleFunc is like a http.Handler, but has a error as return value.
+// ApplauseStatus registers the route that returns the current applause.
 //
-// If the returned error implements the clientError interface, then the error
-// message is sent to the client. In other cases the error is interpredet as an
-// internal error and logged to stdout.
+// If applause is disabled in the config, the response is `{"enabled": false}`.
+func ApplauseStatus(mux *http.ServeMux, applauser Applauser, auther Auther, log logger.Logger) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !applauser.ApplauseEnabled() {
+			fmt.Fprintln(w, `{"enabled": false}`)
+			return nil
+		}
+
+		level, updated := applauser.CurrentApplause()
+		_, presentUsers := applauser.ApplauseConfig()
+
+		out := struct {
+			Enabled      bool  `json:"enabled"`
+			Level        int   `json:"level"`
+			PresentUsers int   `json:"present_users"`
+			Updated      int64 `json:"updated"`
+		}{
+			Enabled:      true,
+			Level:        level,
+			PresentUsers: presentUsers,
+		}
+		if !updated.IsZero() {
+			out.Updated = updated.Unix()
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return noStatusCodeError{fmt.Errorf("encoding applause: %w", err)}
+		}
+		return nil
+	}
+
+	mux.Handle("/system/applause/status", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// ApplauseReset registers the route that sets the current applause to 0.
+//
+// The route needs a POST request. Only users with the permission to manage the
+// config can use it.
+func ApplauseReset(mux *http.ServeMux, resetter ApplauseResetter, permer HasPermer, auther Auther, log logger.Logger) {
+	const managePerm = "core.can_manage_config"
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodPost {
+			return invalidRequestError{fmt.Errorf("Only POST requests are supported")}
+		}
+
+		uid := auth.FromContext(r.Context())
+		if !permer.HasPerm(uid, managePerm) {
+			return permissionDeniedError{perm: managePerm}
+		}
+
+		log.Info("Reset applause", "user_id", uid)
+		resetter.ResetApplause()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"reset": true}`)
+		return nil
+	}
+
+	mux.Handle("/system/applause/reset", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+// errHandleFunc is like a http.HandlerFunc, but has a error as return value.
 type errHandleFunc func(w http.ResponseWriter, r *http.Request) error
 
 var errCount, _ = meter.NewInt64Counter(
@@ -253,8 +984,18 @@ var errCount, _ = meter.NewInt64Counter(
 	metric.WithDescription("500er send to the client"),
 )
 
-func (f errHandleFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if err := f(w, r); err != nil {
+// errHandler converts an errHandleFunc to a http.Handler.
+//
+// If the returned error implements the clientError interface, then the error
+// message is sent to the client. In other cases the error is interpredet as an
+// internal error and logged.
+func errHandler(f errHandleFunc, log logger.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := f(w, r)
+		if err == nil {
+			return
+		}
+
 		var closing interface {
 			Closing()
 		}
@@ -276,13 +1017,44 @@ func (f errHandleFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			status = true
 		}
 
+		var doesNotExist interface {
+			DoesNotExist() string
+		}
+		if errors.As(err, &doesNotExist) {
+			if status {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			fmt.Fprintf(
+				w,
+				`{"error": {"type": "does_not_exist", "msg": "%s does not exist"}}`,
+				doesNotExist.DoesNotExist(),
+			)
+			fmt.Fprintln(w)
+			return
+		}
+
 		var clientError interface {
 			ClientError() string
 			Error() string
 		}
 		if errors.As(err, &clientError) {
 			if status {
-				w.WriteHeader(http.StatusBadRequest)
+				var retry interface {
+					RetryAfter() time.Duration
+				}
+				var unavailable interface {
+					Unavailable()
+				}
+				switch {
+				case errors.As(err, &unavailable) && errors.As(err, &retry):
+					w.Header().Set("Retry-After", strconv.Itoa(int(retry.RetryAfter().Seconds())))
+					w.WriteHeader(http.StatusServiceUnavailable)
+				case errors.As(err, &retry):
+					w.Header().Set("Retry-After", strconv.Itoa(int(retry.RetryAfter().Seconds())))
+					w.WriteHeader(http.StatusTooManyRequests)
+				default:
+					w.WriteHeader(http.StatusBadRequest)
+				}
 			}
 			fmt.Fprintf(
 				w,
@@ -297,10 +1069,10 @@ func (f errHandleFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if status {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
-		log.Printf("Internal Error: %v", err)
+		log.Error("Internal error", "path", r.URL.Path, "error", err)
 		fmt.Fprintln(w, `{"error": {"type": "InternalError", "msg": "Ups, something went wrong!"}}`)
 		errCount.Add(r.Context(), 1)
-	}
+	})
 }
 
 func getOrPOSTMiddleware(next errHandleFunc) errHandleFunc {
@@ -329,23 +1101,47 @@ func authMiddleware(next errHandleFunc, auther Auther) errHandleFunc {
 			}
 			return fmt.Errorf("authenticate request: %w", err)
 		}
+		setRequestUser(ctx, auth.FromContext(ctx))
 		return next(w, r.WithContext(ctx))
 	}
 }
 
-func sendAutoupdateData(w io.Writer, all bool, data map[string]json.RawMessage, fromChangeID, toChangeID int) error {
+// autoupdateFormat is the data format that is send to the client.
+type autoupdateFormat struct {
+	Changed map[string][]json.RawMessage `json:"changed"`
+
+	// Deleted are the ids of the elements, that the client has to remove. These
+	// are the deleted elements and the elements, that the user can not see
+	// anymore. It is empty, if AllData is true.
+	Deleted map[string][]int `json:"deleted"`
+
+	FromChangeID int  `json:"from_change_id"`
+	ToChangeID   int  `json:"to_change_id"`
+	AllData      bool `json:"all_data"`
+
+	// Patched contains json patches for elements the client already knows.
+	// It is only used in the delta mode.
+	Patched map[string]map[int][]patchOperation `json:"patched,omitempty"`
+
+	// Complete is only set, if the data is split into many messages. It is
+	// true in the last message.
+	Complete *bool `json:"complete,omitempty"`
+}
+
+// newAutoupdateFormat creates the data for the client. If delta is not nil,
+// patches are used for elements that the client already knows.
+func newAutoupdateFormat(all bool, data map[string]json.RawMessage, fromChangeID, toChangeID int, delta *deltaEncoder) (autoupdateFormat, error) {
+	data, patched, err := delta.split(all, data)
+	if err != nil {
+		return autoupdateFormat{}, fmt.Errorf("creating patches: %w", err)
+	}
+
 	changed := make(map[string][]json.RawMessage)
 	deleted := make(map[string][]int)
 	for k := range data {
-		parts := strings.Split(k, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid key %s, expected exacly one `:`", k)
-		}
-
-		collection := parts[0]
-		id, err := strconv.Atoi(parts[1])
+		collection, id, err := splitKey(k)
 		if err != nil {
-			return fmt.Errorf("invalid key %s, id is not a number", k)
+			return autoupdateFormat{}, err
 		}
 
 		if data[k] == nil {
@@ -358,27 +1154,96 @@ func sendAutoupdateData(w io.Writer, all bool, data map[string]json.RawMessage,
 		changed[collection] = append(changed[collection], data[k])
 	}
 
-	format := struct {
-		Changed      map[string][]json.RawMessage `json:"changed"`
-		Deleted      map[string][]int             `json:"deleted"`
-		FromChangeID int                          `json:"from_change_id"`
-		ToChangeID   int                          `json:"to_change_id"`
-		AllData      bool                         `json:"all_data"`
-	}{
-		changed,
-		deleted,
-		fromChangeID,
-		toChangeID,
-		all,
+	for _, ids := range deleted {
+		sort.Ints(ids)
 	}
 
-	if err := json.NewEncoder(w).Encode(format); err != nil {
+	return autoupdateFormat{
+		Changed:      changed,
+		Deleted:      deleted,
+		FromChangeID: fromChangeID,
+		ToChangeID:   toChangeID,
+		AllData:      all,
+		Patched:      patched,
+	}, nil
+}
+
+// sendAutoupdateData writes one autoupdate message with the given encoder.
+func sendAutoupdateData(w io.Writer, encode outputEncoder, all bool, data map[string]json.RawMessage, fromChangeID, toChangeID int, delta *deltaEncoder) error {
+	format, err := newAutoupdateFormat(all, data, fromChangeID, toChangeID, delta)
+	if err != nil {
+		return err
+	}
+
+	if err := encode(w, format); err != nil {
 		return fmt.Errorf("encode and send output data, error tyoe %T: %w", err, err)
 	}
 	w.(http.Flusher).Flush()
 	return nil
 }
 
+// sendAutoupdateEvent sends the data as server-sent events.
+//
+// If the data is bigger then maxSize, it is split into many events. Only the
+// last event has an id, so a reconnecting client receives all events again.
+func sendAutoupdateEvent(w io.Writer, all bool, data map[string]json.RawMessage, fromChangeID, toChangeID, maxSize int, delta *deltaEncoder) error {
+	format, err := newAutoupdateFormat(all, data, fromChangeID, toChangeID, delta)
+	if err != nil {
+		return err
+	}
+
+	chunks, err := format.chunks(maxSize)
+	if err != nil {
+		return err
+	}
+
+	for i, encoded := range chunks {
+		if i == len(chunks)-1 {
+			if _, err := fmt.Fprintf(w, "id: %d\n", toChangeID); err != nil {
+				return fmt.Errorf("send output data: %w", err)
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", encoded); err != nil {
+			return fmt.Errorf("send output data: %w", err)
+		}
+	}
+	w.(http.Flusher).Flush()
+	return nil
+}
+
+// deltaMode returns a deltaEncoder, if the client requested the delta mode with
+// the query parameter delta. Returns nil, if the delta mode is not used.
+func deltaMode(r *http.Request) (*deltaEncoder, error) {
+	raw := r.URL.Query().Get("delta")
+	if raw == "" {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, invalidRequestError{fmt.Errorf("delta has to be a boolean not %s", raw)}
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+	return newDeltaEncoder(), nil
+}
+
+// collectionNames parses the comma separated collections query parameter.
+// Returns nil, if no collection is given.
+func collectionNames(raw string) []string {
+	var collections []string
+	for _, collection := range strings.Split(raw, ",") {
+		collection = strings.TrimSpace(collection)
+		if collection != "" {
+			collections = append(collections, collection)
+		}
+	}
+	return collections
+}
+
 func projectorIDs(raw string) ([]int, error) {
 	parts := strings.Split(raw, ",")
 	ids := make([]int, len(parts))
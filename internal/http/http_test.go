@@ -1,17 +1,28 @@
 package http_test
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/auth"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
 	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
 	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+	"github.com/gorilla/websocket"
 )
 
 func TestAutoupdateFirstData(t *testing.T) {
@@ -27,13 +38,13 @@ func TestAutoupdateFirstData(t *testing.T) {
 
 	restricter := new(test.RestricterMock)
 
-	a, err := autoupdate.New(datastore, restricter, closed)
+	a, err := autoupdate.New(datastore, restricter, logger.Noop, closed)
 	if err != nil {
 		t.Fatalf("autoupdate startup failed: %v", err)
 	}
 
 	mux := http.NewServeMux()
-	ahttp.Autoupdate(mux, a, auther)
+	ahttp.Autoupdate(mux, a, auther, logger.Noop)
 	srv := httptest.NewServer(mux)
 	defer srv.Close()
 
@@ -60,3 +71,1305 @@ func TestAutoupdateFirstData(t *testing.T) {
 		t.Errorf("Handler returned status %s: `%s`, expected 200, %s", resp.Status, body, http.StatusText(200))
 	}
 }
+
+func TestAutoupdateDeleted(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1}`),
+		"motions/motion:2": []byte(`{"id":2}`),
+		"motions/motion:3": []byte(`{"id":3}`),
+	}
+
+	r := restricter.New(datastore, nil)
+	r.Register("motions/motion", restricter.ElementFunc(func(_ int, element json.RawMessage) (json.RawMessage, error) {
+		var motion struct {
+			Hidden bool `json:"hidden"`
+		}
+		if err := json.Unmarshal(element, &motion); err != nil {
+			return nil, err
+		}
+		if motion.Hidden {
+			return nil, nil
+		}
+		return element, nil
+	}))
+
+	a, err := autoupdate.New(datastore, r, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.Autoupdate(mux, a, new(test.AutherMock), logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/autoupdate", nil)
+	if err != nil {
+		t.Fatalf("Can not create request: %v", err)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Can not send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	readLine := func() []byte {
+		if !scanner.Scan() {
+			t.Fatalf("Stream closed: %v", scanner.Err())
+		}
+		return scanner.Bytes()
+	}
+
+	// The first line is `{"connected":true}`, the second line all data.
+	readLine()
+	var data autoupdateData
+	if err := json.Unmarshal(readLine(), &data); err != nil {
+		t.Fatalf("Can not decode first data: %v", err)
+	}
+	if len(data.Changed["motions/motion"]) != 3 {
+		t.Fatalf("First data has %v, expected three motions", data)
+	}
+
+	// motions/motion:1 is deleted and motions/motion:2 is hidden afterwards.
+	// The client has both elements and has to remove them.
+	delete(datastore.FullData, "motions/motion:1")
+	datastore.FullData["motions/motion:2"] = []byte(`{"id":2,"hidden":true}`)
+	datastore.Change([]string{"motions/motion:1", "motions/motion:2"})
+
+	test.ExpectEqualJSON(t, []byte(`{"changed":{},"deleted":{"motions/motion":[1,2]},"from_change_id":1,"to_change_id":2,"all_data":false}`), readLine())
+}
+
+func TestAutoupdateSSE(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	auther := new(test.AutherMock)
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"user/user:1": []byte(`"hello world1"`),
+		"user/user:2": []byte(`"hello world2"`),
+	}
+
+	restricter := new(test.RestricterMock)
+
+	a, err := autoupdate.New(datastore, restricter, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateSSE(mux, a, 0, 0, auther, logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/autoupdate/sse", nil)
+	if err != nil {
+		t.Fatalf("Can not create request: %v", err)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Can not send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Got content type `%s`, expected `text/event-stream`", got)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	readEvent := func() (id string, data autoupdateData) {
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				return id, data
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+					t.Fatalf("Can not decode event data: %v", err)
+				}
+			}
+		}
+		t.Fatalf("Stream closed: %v", scanner.Err())
+		return "", data
+	}
+
+	id, data := readEvent()
+	if id != "1" {
+		t.Errorf("First event has id %s, expected 1", id)
+	}
+	if !data.AllData || len(data.Changed["user/user"]) != 2 {
+		t.Errorf("First event has data %v, expected all data", data)
+	}
+
+	datastore.Change([]string{"user/user:1"})
+
+	id, data = readEvent()
+	if id != "2" {
+		t.Errorf("Second event has id %s, expected 2", id)
+	}
+	if data.AllData || data.FromChangeID != 1 || len(data.Changed["user/user"]) != 1 {
+		t.Errorf("Second event has data %v, expected user/user:1 from change id 1", data)
+	}
+}
+
+func TestAutoupdateSSEChunked(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	for i := 1; i <= 5; i++ {
+		datastore.FullData[fmt.Sprintf("motions/motion:%d", i)] = []byte(fmt.Sprintf(`{"id":%d,"text":"%s"}`, i, strings.Repeat("x", 100)))
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateSSE(mux, a, 250, 0, new(test.AutherMock), logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/autoupdate/sse", nil)
+	if err != nil {
+		t.Fatalf("Can not create request: %v", err)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Can not send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Read the events like a client and apply the data after the last chunk.
+	scanner := bufio.NewScanner(resp.Body)
+	var chunks int
+	var lastID string
+	received := make(map[string][]json.RawMessage)
+	for complete := false; !complete; {
+		var id string
+		var data autoupdateData
+		for scanner.Scan() && scanner.Text() != "" {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+					t.Fatalf("Can not decode event data: %v", err)
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("Can not read stream: %v", err)
+		}
+
+		if data.Complete == nil {
+			t.Fatalf("Event %d has no complete flag", chunks+1)
+		}
+		if data.ToChangeID != 1 || !data.AllData {
+			t.Errorf("Event %d has to_change_id %d and all_data %t, expected 1 and true", chunks+1, data.ToChangeID, data.AllData)
+		}
+
+		for collection, elements := range data.Changed {
+			received[collection] = append(received[collection], elements...)
+		}
+		chunks++
+		complete = *data.Complete
+		lastID = id
+
+		if !complete && id != "" {
+			t.Errorf("Event %d has id %s, expected only the last event to have an id", chunks, id)
+		}
+	}
+
+	if chunks < 2 {
+		t.Errorf("Data was sent in %d events, expected more", chunks)
+	}
+
+	if lastID != "1" {
+		t.Errorf("Last event has id `%s`, expected 1", lastID)
+	}
+
+	if got := len(received["motions/motion"]); got != 5 {
+		t.Errorf("Got %d motions, expected 5", got)
+	}
+}
+
+func TestAutoupdateSSEGzip(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	auther := new(test.AutherMock)
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"user/user:1": []byte(`"hello world1"`),
+		"user/user:2": []byte(`"hello world2"`),
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateSSE(mux, a, 0, 0, auther, logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/autoupdate/sse", nil)
+	if err != nil {
+		t.Fatalf("Can not create request: %v", err)
+	}
+	req.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Can not send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Got content encoding `%s`, expected `gzip`", got)
+	}
+
+	gzipReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Can not read gzip header: %v", err)
+	}
+
+	scanner := bufio.NewScanner(gzipReader)
+	for _, expectID := range []string{"1", "2"} {
+		var id string
+		for scanner.Scan() && scanner.Text() != "" {
+			if strings.HasPrefix(scanner.Text(), "id: ") {
+				id = strings.TrimPrefix(scanner.Text(), "id: ")
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("Can not read event: %v", err)
+		}
+
+		if id != expectID {
+			t.Errorf("Got event with id `%s`, expected %s", id, expectID)
+		}
+
+		datastore.Change([]string{"user/user:1"})
+	}
+}
+
+func TestSmallResponseNotCompressed(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.Autoupdate(mux, a, new(test.AutherMock), logger.Noop)
+
+	req := httptest.NewRequest(http.MethodGet, "/system/autoupdate?change_id=invalid", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Got status %d, expected %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Got content encoding `%s`, expected none", got)
+	}
+
+	if !strings.Contains(rec.Body.String(), "invalid_request") {
+		t.Errorf("Got body `%s`, expected an invalid_request error", rec.Body.String())
+	}
+}
+
+func TestAutoupdateWebsocket(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	auther := new(test.AutherMock)
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"user/user:1": []byte(`"hello world1"`),
+		"user/user:2": []byte(`"hello world2"`),
+	}
+
+	restricter := new(test.RestricterMock)
+
+	a, err := autoupdate.New(datastore, restricter, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateWebsocket(mux, a, 0, auther, logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/system/autoupdate/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Can not connect to websocket: %v", err)
+	}
+	defer conn.Close()
+
+	var data autoupdateData
+	if err := conn.ReadJSON(&data); err != nil {
+		t.Fatalf("Can not read first message: %v", err)
+	}
+	if !data.AllData || len(data.Changed["user/user"]) != 2 {
+		t.Errorf("First message has data %v, expected all data", data)
+	}
+
+	datastore.Change([]string{"user/user:1"})
+
+	data = autoupdateData{}
+	if err := conn.ReadJSON(&data); err != nil {
+		t.Fatalf("Can not read second message: %v", err)
+	}
+	if data.AllData || data.FromChangeID != 1 || data.ToChangeID != 2 || len(data.Changed["user/user"]) != 1 {
+		t.Errorf("Second message has data %v, expected user/user:1 from change id 1", data)
+	}
+
+	// Request all data again.
+	if err := conn.WriteJSON(map[string]int{"change_id": 0}); err != nil {
+		t.Fatalf("Can not send change id: %v", err)
+	}
+
+	data = autoupdateData{}
+	if err := conn.ReadJSON(&data); err != nil {
+		t.Fatalf("Can not read third message: %v", err)
+	}
+	if !data.AllData {
+		t.Errorf("Third message has data %v, expected all data", data)
+	}
+
+	// The new subscription receives the next changes.
+	datastore.Change([]string{"user/user:2"})
+
+	data = autoupdateData{}
+	if err := conn.ReadJSON(&data); err != nil {
+		t.Fatalf("Can not read fourth message: %v", err)
+	}
+	if data.AllData || data.FromChangeID != 2 || data.ToChangeID != 3 || len(data.Changed["user/user"]) != 1 {
+		t.Errorf("Fourth message has data %v, expected user/user:2 from change id 2", data)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	closed := make(chan struct{})
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"user/user:1": []byte(`"hello world1"`),
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateSSE(mux, a, 0, 0, new(test.AutherMock), logger.Noop)
+	ahttp.AutoupdateWebsocket(mux, a, 0, new(test.AutherMock), logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/system/autoupdate/sse")
+	if err != nil {
+		t.Fatalf("Can not send request: %v", err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+
+	// readEvent returns the event type and the data of the next event.
+	readEvent := func() (event string, data string) {
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				return event, data
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		t.Fatalf("Stream closed: %v", scanner.Err())
+		return "", ""
+	}
+
+	// Wait for the first data, so the client is connected.
+	if event, _ := readEvent(); event != "" {
+		t.Fatalf("First event has type %s, expected a data event", event)
+	}
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/system/autoupdate/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Can not connect to websocket: %v", err)
+	}
+	defer conn.Close()
+
+	var data autoupdateData
+	if err := conn.ReadJSON(&data); err != nil {
+		t.Fatalf("Can not read first message: %v", err)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- ahttp.Shutdown(srv.Config, func() { close(closed) }, time.Second, logger.Noop)
+	}()
+
+	event, eventData := readEvent()
+	if event != "closing" {
+		t.Errorf("Got event `%s`, expected `closing`", event)
+	}
+	test.ExpectEqualJSON(t, []byte(`{"reason": "server closing"}`), []byte(eventData))
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("Got error %v from websocket, expected a close message", err)
+	}
+	if closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "server closing" {
+		t.Errorf("Got close message %d `%s`, expected %d `server closing`", closeErr.Code, closeErr.Text, websocket.CloseGoingAway)
+	}
+
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Errorf("Shutdown returned unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Shutdown did not return")
+	}
+
+	if _, err := srv.Client().Get(srv.URL + "/system/autoupdate/sse"); err == nil {
+		t.Errorf("Server accepted a new connection after shutdown")
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	ready := &readierMock{lowest: 1, current: 5}
+
+	mux := http.NewServeMux()
+	ahttp.Readiness(mux, ready, logger.Noop)
+
+	for _, tt := range []struct {
+		name     string
+		ready    bool
+		degraded bool
+		status   int
+	}{
+		{"not ready", false, false, http.StatusServiceUnavailable},
+		{"ready", true, false, http.StatusOK},
+		{"degraded", true, true, http.StatusOK},
+		{"not ready again", false, false, http.StatusServiceUnavailable},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ready.ready = tt.ready
+			ready.degraded = tt.degraded
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Got status %d, expected %d", rec.Code, tt.status)
+			}
+
+			var body struct {
+				Ready    bool `json:"ready"`
+				Degraded bool `json:"degraded"`
+				ChangeID int  `json:"change_id"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Can not decode body: %v", err)
+			}
+
+			if body.Ready != tt.ready || body.Degraded != tt.degraded || body.ChangeID != 5 {
+				t.Errorf("Got body `%s`", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestChangeIDs(t *testing.T) {
+	ids := &readierMock{lowest: 10, current: 20}
+
+	mux := http.NewServeMux()
+	ahttp.ChangeIDs(mux, ids, new(test.AutherMock), logger.Noop)
+
+	for _, tt := range []struct {
+		name       string
+		query      string
+		fullReload bool
+	}{
+		{"without change id", "", false},
+		{"below lowest", "?change_id=9", true},
+		{"lowest", "?change_id=10", false},
+		{"current", "?change_id=20", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate/change_ids"+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Got status %d, expected %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			var body struct {
+				Lowest     int  `json:"lowest_change_id"`
+				Current    int  `json:"current_change_id"`
+				FullReload bool `json:"full_reload_required"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Can not decode body: %v", err)
+			}
+
+			if body.Lowest != 10 || body.Current != 20 || body.FullReload != tt.fullReload {
+				t.Errorf("Got body `%s`, expected full_reload_required: %t", rec.Body.String(), tt.fullReload)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	counter := counterMock{"motions/motion": 2, "users/user": 5}
+
+	t.Run("logged in", func(t *testing.T) {
+		mux := http.NewServeMux()
+		ahttp.Stats(mux, counter, auth.Fake(1), logger.Noop)
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate/stats", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Got status %d, expected %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+
+		test.ExpectEqualJSON(t, rec.Body.Bytes(), []byte(`{"collections": {"motions/motion": 2, "users/user": 5}, "elements": 7, "bytes": 700, "deleted": 3}`))
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		mux := http.NewServeMux()
+		ahttp.Stats(mux, counter, new(test.AutherMock), logger.Noop)
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate/stats", nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Got status %d, expected %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestDatastoreReset(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		method string
+		admin  bool
+		status int
+		reset  bool
+	}{
+		{"admin", http.MethodPost, true, http.StatusOK, true},
+		{"no admin", http.MethodPost, false, http.StatusBadRequest, false},
+		{"get", http.MethodGet, true, http.StatusBadRequest, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resetter := new(resetterMock)
+			mux := http.NewServeMux()
+			ahttp.DatastoreReset(mux, resetter, superadminerMock(tt.admin), auth.Fake(1), logger.Noop)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, "/system/autoupdate/reset", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Got status %d, expected %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			if resetter.called != tt.reset {
+				t.Errorf("ForceReset called: %t, expected %t", resetter.called, tt.reset)
+			}
+		})
+	}
+}
+
+func TestAutoupdateCatchUp(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`"motion1"`),
+	}
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not create autoupdate service: %v", err)
+	}
+
+	datastore.Change([]string{"motions/motion:1"})
+	datastore.Change([]string{"motions/motion:1", "motions/motion:2"})
+	deadline := time.Now().Add(time.Second)
+	for datastore.CurrentID() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("datastore has change id %d after one second, expected 3", datastore.CurrentID())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateCatchUp(mux, a, new(test.AutherMock), logger.Noop)
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		body   string
+		status int
+		expect string
+	}{
+		{
+			"changed data",
+			http.MethodPost,
+			`{"change_id": 1}`,
+			http.StatusOK,
+			`{"changed":{"motions/motion":["motion1"]},"deleted":{"motions/motion":[2]},"from_change_id":1,"to_change_id":3,"all_data":false}`,
+		},
+		{
+			"to old",
+			http.MethodPost,
+			`{"change_id": 0}`,
+			http.StatusOK,
+			`{"reset":true,"to_change_id":3}`,
+		},
+		{
+			"invalid body",
+			http.MethodPost,
+			`{"change_id": "foo"}`,
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"get request",
+			http.MethodGet,
+			"",
+			http.StatusBadRequest,
+			"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, "/system/autoupdate/catchup", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("Got status %d, expected %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			if tt.expect != "" {
+				test.ExpectEqualJSON(t, []byte(tt.expect), rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestAutoupdateSnapshot(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(5, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`"motion1"`),
+	}
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not create autoupdate service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateSnapshot(mux, a, new(test.AutherMock), logger.Noop)
+
+	for _, tt := range []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+		expect      string
+	}{
+		{
+			"without etag",
+			"",
+			http.StatusOK,
+			`{"changed":{"motions/motion":["motion1"]},"deleted":{},"from_change_id":0,"to_change_id":5,"all_data":true}`,
+		},
+		{
+			"current etag",
+			`"5"`,
+			http.StatusNotModified,
+			"",
+		},
+		{
+			"weak etag in list",
+			`"3", W/"5"`,
+			http.StatusNotModified,
+			"",
+		},
+		{
+			"stale etag",
+			`"4"`,
+			http.StatusOK,
+			`{"changed":{"motions/motion":["motion1"]},"deleted":{},"from_change_id":0,"to_change_id":5,"all_data":true}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/system/autoupdate/snapshot", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("Got status %d, expected %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			if got := rec.Header().Get("ETag"); got != `"5"` {
+				t.Errorf("Got ETag %s, expected \"5\"", got)
+			}
+
+			if tt.expect == "" {
+				if rec.Body.Len() != 0 {
+					t.Errorf("Got body `%s`, expected no body", rec.Body.String())
+				}
+				return
+			}
+			test.ExpectEqualJSON(t, []byte(tt.expect), rec.Body.Bytes())
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	a, err := autoupdate.New(test.NewDatastoreMock(1, closed), new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not create autoupdate service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	limiter := ahttp.NewRateLimiter(2, 1)
+	ahttp.AutoupdateCatchUp(mux, a, ahttp.RateLimit(auth.Fake(5), limiter), logger.Noop)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/system/autoupdate/catchup", strings.NewReader(`{"change_id": 1}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Request %d returned status %d, expected %d: %s", i+1, rec.Code, http.StatusOK, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/system/autoupdate/catchup", strings.NewReader(`{"change_id": 1}`)))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Third request returned status %d, expected %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Got header Retry-After `%s`, expected `30`", got)
+	}
+}
+
+func TestDebugRestrict(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1}`),
+	}
+
+	r := restricter.New(datastore, nil)
+	r.Register("motions/motion", restricter.BasePermission(new(test.HasPermMock))("motions.can_see"))
+	r.Register("topics/topic", restricter.ForAll)
+
+	for _, tt := range []struct {
+		name   string
+		perms  []string
+		query  string
+		status int
+		expect string
+	}{
+		{
+			"Hidden element",
+			[]string{"users.can_manage"},
+			"?user_id=2&key=motions/motion:1",
+			http.StatusOK,
+			`{"user_id":2,"key":"motions/motion:1","visible":false,"reason":"the restricter for the collection motions/motion hides the element for user 2"}`,
+		},
+		{
+			"Missing element",
+			[]string{"users.can_manage"},
+			"?user_id=2&key=topics/topic:1",
+			http.StatusOK,
+			`{"user_id":2,"key":"topics/topic:1","visible":false,"reason":"topics/topic:1 does not exist"}`,
+		},
+		{
+			"Invalid user id",
+			[]string{"users.can_manage"},
+			"?user_id=max&key=motions/motion:1",
+			http.StatusBadRequest,
+			`{"error": {"type": "invalid_request", "msg": "Invalid request: User id has to be a number not max"}}`,
+		},
+		{
+			"No manager",
+			nil,
+			"?user_id=2&key=motions/motion:1",
+			http.StatusBadRequest,
+			`{"error": {"type": "permission_denied", "msg": "You need the permission users.can_manage"}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			ahttp.DebugRestrict(mux, r, &test.HasPermMock{Perms: tt.perms}, auth.Fake(1), logger.Noop)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate/debug"+tt.query, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Got status %d, expected %d", rec.Code, tt.status)
+			}
+
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.expect {
+				t.Errorf("Got body `%s`, expected `%s`", got, tt.expect)
+			}
+		})
+	}
+
+	t.Run("Visible element", func(t *testing.T) {
+		r := restricter.New(datastore, nil)
+		r.Register("motions/motion", restricter.ForAll)
+
+		mux := http.NewServeMux()
+		ahttp.DebugRestrict(mux, r, &test.HasPermMock{Perms: []string{"users.can_manage"}}, auth.Fake(1), logger.Noop)
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate/debug?user_id=2&key=motions/motion:1", nil))
+
+		expect := `{"user_id":2,"key":"motions/motion:1","visible":true,"element":{"id":1}}`
+		if got := strings.TrimSpace(rec.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+}
+
+func TestApplauseStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		applauser *applauserMock
+		expect    string
+	}{
+		{
+			"Disabled",
+			&applauserMock{enabled: false, level: 5, presentUsers: 10},
+			`{"enabled": false}`,
+		},
+		{
+			"Enabled",
+			&applauserMock{enabled: true, level: 5, presentUsers: 10, updated: time.Unix(1600000000, 0)},
+			`{"enabled":true,"level":5,"present_users":10,"updated":1600000000}`,
+		},
+		{
+			"Enabled without applause",
+			&applauserMock{enabled: true, presentUsers: 10},
+			`{"enabled":true,"level":0,"present_users":10,"updated":0}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			ahttp.ApplauseStatus(mux, tt.applauser, new(test.AutherMock), logger.Noop)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/applause/status", nil))
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("Got status %d, expected %d", rec.Code, http.StatusOK)
+			}
+
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.expect {
+				t.Errorf("Got body `%s`, expected `%s`", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestApplauseReset(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		method string
+		perms  []string
+		status int
+		reset  bool
+	}{
+		{"manager", http.MethodPost, []string{"core.can_manage_config"}, http.StatusOK, true},
+		{"no manager", http.MethodPost, nil, http.StatusBadRequest, false},
+		{"get", http.MethodGet, []string{"core.can_manage_config"}, http.StatusBadRequest, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			applauser := &applauserMock{enabled: true, level: 5, updated: time.Unix(1600000000, 0)}
+			mux := http.NewServeMux()
+			ahttp.ApplauseReset(mux, applauser, &test.HasPermMock{Perms: tt.perms}, auth.Fake(1), logger.Noop)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, "/system/applause/reset", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Got status %d, expected %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			if reset := applauser.level == 0 && applauser.updated.IsZero(); reset != tt.reset {
+				t.Errorf("Applause was reset: %t, expected %t", reset, tt.reset)
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	a, err := autoupdate.New(test.NewDatastoreMock(1, closed), new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.Liveness(mux, a)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Got status %d, expected %d", rec.Code, http.StatusOK)
+	}
+}
+
+type readierMock struct {
+	ready    bool
+	degraded bool
+	lowest   int
+	current  int
+}
+
+func (r *readierMock) Degraded() bool {
+	return r.degraded
+}
+
+func (r *readierMock) Ready() bool {
+	return r.ready
+}
+
+func (r *readierMock) LowestID() int {
+	return r.lowest
+}
+
+func (r *readierMock) CurrentID() int {
+	return r.current
+}
+
+type counterMock map[string]int
+
+type resetterMock struct {
+	called bool
+}
+
+func (r *resetterMock) ForceReset() error {
+	r.called = true
+	return nil
+}
+
+type superadminerMock bool
+
+func (s superadminerMock) IsSuperadmin(int) bool {
+	return bool(s)
+}
+
+func (c counterMock) CollectionCounts() map[string]int {
+	return c
+}
+
+func (c counterMock) Len() int {
+	var n int
+	for _, count := range c {
+		n += count
+	}
+	return n
+}
+
+func (c counterMock) BytesEstimate() int64 {
+	return int64(c.Len()) * 100
+}
+
+func (c counterMock) DeletedCount() int {
+	return 3
+}
+
+type applauserMock struct {
+	enabled      bool
+	level        int
+	presentUsers int
+	updated      time.Time
+}
+
+func (a *applauserMock) ApplauseEnabled() bool {
+	return a.enabled
+}
+
+func (a *applauserMock) ApplauseConfig() (int, int) {
+	return 5, a.presentUsers
+}
+
+func (a *applauserMock) CurrentApplause() (int, time.Time) {
+	return a.level, a.updated
+}
+
+func (a *applauserMock) ResetApplause() {
+	a.level = 0
+	a.updated = time.Time{}
+}
+
+type autoupdateData struct {
+	Changed      map[string][]json.RawMessage `json:"changed"`
+	Deleted      map[string][]int             `json:"deleted"`
+	FromChangeID int                          `json:"from_change_id"`
+	ToChangeID   int                          `json:"to_change_id"`
+	AllData      bool                         `json:"all_data"`
+	Complete     *bool                        `json:"complete"`
+}
+
+func TestProjectorByIDInvalid(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	a, err := autoupdate.New(test.NewDatastoreMock(1, closed), new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.ProjectorByID(mux, a, new(test.AutherMock), logger.Noop)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/projector/abc", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Got status %d, expected %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+}
+
+func TestDebugPermissions(t *testing.T) {
+	lister := &test.HasPermMock{
+		Perms:  []string{"motions.can_see", "agenda.can_see"},
+		Groups: map[int]bool{3: true, 4: true},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		perms  []string
+		path   string
+		status int
+		expect string
+	}{
+		{
+			"Permissions",
+			[]string{"users.can_manage"},
+			"5",
+			http.StatusOK,
+			`{"user_id":5,"superadmin":false,"group_ids":[3,4],"permissions":["agenda.can_see","motions.can_see"]}`,
+		},
+		{
+			"Invalid user id",
+			[]string{"users.can_manage"},
+			"max",
+			http.StatusBadRequest,
+			`{"error": {"type": "invalid_request", "msg": "Invalid request: User id has to be a number not max"}}`,
+		},
+		{
+			"No manager",
+			nil,
+			"5",
+			http.StatusBadRequest,
+			`{"error": {"type": "permission_denied", "msg": "You need the permission users.can_manage"}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			ahttp.DebugPermissions(mux, lister, &test.HasPermMock{Perms: tt.perms}, auth.Fake(1), logger.Noop)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate/debug/permissions/"+tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Got status %d, expected %d", rec.Code, tt.status)
+			}
+
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.expect {
+				t.Errorf("Got body `%s`, expected `%s`", got, tt.expect)
+			}
+
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Permissions []string `json:"permissions"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Can not decode body: %v", err)
+			}
+
+			for _, perm := range got.Permissions {
+				if !lister.HasPerm(5, perm) {
+					t.Errorf("Route returned permission %s, but HasPerm is false", perm)
+				}
+			}
+		})
+	}
+}
+
+func TestDebugRaw(t *testing.T) {
+	raw := rawElementerMock{
+		elements: map[string]json.RawMessage{
+			"motions/motion:1":      []byte(`{"id":1,  "title":"spaces"}`),
+			"motions/motion-vote:1": []byte(`{"id":1,"user_id":2}`),
+			"users/personal-note:1": []byte(`{"id":1,"user_id":1}`),
+			"users/personal-note:2": []byte(`{"id":2,"user_id":2}`),
+		},
+		deleted: map[string]bool{"motions/motion:2": true},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		perms  []string
+		path   string
+		status int
+		expect string
+	}{
+		{
+			"Present",
+			[]string{"users.can_manage"},
+			"motions/motion/1",
+			http.StatusOK,
+			`{"id":1,  "title":"spaces"}`,
+		},
+		{
+			"Deleted",
+			[]string{"users.can_manage"},
+			"motions/motion/2",
+			http.StatusGone,
+			`{"deleted": true}`,
+		},
+		{
+			"Absent",
+			[]string{"users.can_manage"},
+			"motions/motion/3",
+			http.StatusNotFound,
+			`{"error": {"type": "does_not_exist", "msg": "motions/motion:3 does not exist"}}`,
+		},
+		{
+			"Invalid id",
+			[]string{"users.can_manage"},
+			"motions/motion/max",
+			http.StatusBadRequest,
+			`{"error": {"type": "invalid_request", "msg": "Invalid request: invalid key motions/motion:max, id is not a number"}}`,
+		},
+		{
+			"No manager",
+			nil,
+			"motions/motion/1",
+			http.StatusBadRequest,
+			`{"error": {"type": "permission_denied", "msg": "You need the permission users.can_manage"}}`,
+		},
+		{
+			"Vote",
+			[]string{"users.can_manage"},
+			"motions/motion-vote/1",
+			http.StatusBadRequest,
+			`{"error": {"type": "invalid_request", "msg": "Invalid request: Elements of motions/motion-vote can not be read raw"}}`,
+		},
+		{
+			"Own personal note",
+			[]string{"users.can_manage"},
+			"users/personal-note/1",
+			http.StatusOK,
+			`{"id":1,"user_id":1}`,
+		},
+		{
+			"Personal note of other user",
+			[]string{"users.can_manage"},
+			"users/personal-note/2",
+			http.StatusBadRequest,
+			`{"error": {"type": "invalid_request", "msg": "Invalid request: Personal note users/personal-note:2 belongs to another user"}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			ahttp.DebugRaw(mux, raw, &test.HasPermMock{Perms: tt.perms}, auth.Fake(1), logger.Noop)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/system/autoupdate/debug/raw/"+tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Got status %d, expected %d", rec.Code, tt.status)
+			}
+
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.expect {
+				t.Errorf("Got body `%s`, expected `%s`", got, tt.expect)
+			}
+		})
+	}
+}
+
+type rawElementerMock struct {
+	elements map[string]json.RawMessage
+	deleted  map[string]bool
+}
+
+func (r rawElementerMock) RawElement(key string) (json.RawMessage, bool, error) {
+	if r.deleted[key] {
+		return nil, true, nil
+	}
+
+	element, ok := r.elements[key]
+	if !ok {
+		return nil, false, datastore.DoesNotExistError(key)
+	}
+	return element, false, nil
+}
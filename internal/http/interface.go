@@ -2,10 +2,87 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"time"
 )
 
 // Auther authenticates a request.
 type Auther interface {
 	Authenticate(r *http.Request) (context.Context, error)
 }
+
+// Liver tells, if the service is alive.
+type Liver interface {
+	Alive() bool
+}
+
+// ChangeIDer returns the change ids the datastore knows about.
+type ChangeIDer interface {
+	LowestID() int
+	CurrentID() int
+}
+
+// Readier tells, if the datastore is ready to handle requests.
+type Readier interface {
+	ChangeIDer
+	Ready() bool
+
+	// Degraded tells, that the datastore serves old data, because it can not
+	// receive missing data.
+	Degraded() bool
+}
+
+// Counter returns the number of elements of each collection and the size of
+// the cache.
+type Counter interface {
+	CollectionCounts() map[string]int
+	Len() int
+	BytesEstimate() int64
+	DeletedCount() int
+}
+
+// Explainer restricts one element and tells, why the user can not see it.
+type Explainer interface {
+	Explain(uid int, key string) (json.RawMessage, string)
+}
+
+// Resetter initializes the datastore again.
+type Resetter interface {
+	ForceReset() error
+}
+
+// Superadminer tells, if a user is in the admin group.
+type Superadminer interface {
+	IsSuperadmin(uid int) bool
+}
+
+// PermissionLister returns the permissions and groups of a user, like the
+// restricters see them.
+type PermissionLister interface {
+	Permissions(uid int) []string
+	GroupIDs(uid int) []int
+	IsSuperadmin(uid int) bool
+}
+
+// RawElementer returns the cached bytes of an element.
+type RawElementer interface {
+	RawElement(key string) (element json.RawMessage, deleted bool, err error)
+}
+
+// HasPermer tells, if a user has a permission.
+type HasPermer interface {
+	HasPerm(uid int, perm string) bool
+}
+
+// Applauser returns the current applause.
+type Applauser interface {
+	ApplauseEnabled() bool
+	ApplauseConfig() (waitTime int, base int)
+	CurrentApplause() (level int, updated time.Time)
+}
+
+// ApplauseResetter resets the current applause.
+type ApplauseResetter interface {
+	ResetApplause()
+}
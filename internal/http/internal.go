@@ -0,0 +1,61 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+// RawDataer returns the unrestricted data.
+type RawDataer interface {
+	GetAll() map[string]json.RawMessage
+	GetCollection(collection string) []json.RawMessage
+}
+
+// RegisterInternal registers the routes for the internal listener.
+//
+// These routes return the data without restriction and without
+// authentication. They must only be registered on a mux, that is served on an
+// address that can not be reached from the public network. They are never
+// registered with RegisterAll.
+func RegisterInternal(mux *http.ServeMux, raw RawDataer, log logger.Logger) {
+	RawData(mux, raw, log)
+}
+
+// RawData registers the route that returns the unrestricted data.
+//
+// /internal/autoupdate/data returns all data as a map from the keys to the
+// elements. A collection can be added to the path, for example
+// /internal/autoupdate/data/motions/motion, to get a list of the elements of
+// this collection.
+func RawData(mux *http.ServeMux, raw RawDataer, log logger.Logger) {
+	const path = "/internal/autoupdate/data"
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		collection := strings.Trim(strings.TrimPrefix(r.URL.Path, path), "/")
+
+		var out interface{}
+		if collection == "" {
+			out = raw.GetAll()
+		} else {
+			elements := raw.GetCollection(collection)
+			if elements == nil {
+				elements = []json.RawMessage{}
+			}
+			out = elements
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return noStatusCodeError{fmt.Errorf("encoding raw data: %w", err)}
+		}
+		return nil
+	}
+
+	h := compressHandler(errHandler(getOrPOSTMiddleware(handler), log))
+	mux.Handle(path, h)
+	mux.Handle(path+"/", h)
+}
@@ -0,0 +1,99 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestRawData(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(5, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1}`),
+		"topics/topic:1":   []byte(`{"id":1}`),
+	}
+
+	// The user can not see motions.
+	r := restricter.New(datastore, nil)
+	r.Register("motions/motion", restricter.BasePermission(new(test.HasPermMock))("motions.can_see"))
+	r.Register("topics/topic", restricter.ForAll)
+
+	a, err := autoupdate.New(datastore, r, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not create autoupdate service: %v", err)
+	}
+
+	public := http.NewServeMux()
+	ahttp.RegisterAll(public, ahttp.Services{Auth: new(test.AutherMock), Autoupdate: a, Log: logger.Noop})
+
+	internal := http.NewServeMux()
+	ahttp.RegisterInternal(internal, datastore, logger.Noop)
+
+	for _, tt := range []struct {
+		name   string
+		mux    *http.ServeMux
+		path   string
+		status int
+		expect string
+	}{
+		{
+			"internal all data",
+			internal,
+			"/internal/autoupdate/data",
+			http.StatusOK,
+			`{"motions/motion:1":{"id":1},"topics/topic:1":{"id":1}}`,
+		},
+		{
+			"internal collection",
+			internal,
+			"/internal/autoupdate/data/motions/motion",
+			http.StatusOK,
+			`[{"id":1}]`,
+		},
+		{
+			"internal unknown collection",
+			internal,
+			"/internal/autoupdate/data/unknown/collection",
+			http.StatusOK,
+			`[]`,
+		},
+		{
+			"public snapshot is restricted",
+			public,
+			"/system/autoupdate/snapshot",
+			http.StatusOK,
+			`{"changed":{"topics/topic":[{"id":1}]},"deleted":{},"from_change_id":0,"to_change_id":5,"all_data":true}`,
+		},
+		{
+			"public has no internal route",
+			public,
+			"/internal/autoupdate/data",
+			http.StatusNotFound,
+			"404 page not found",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Got status %d, expected %d", rec.Code, tt.status)
+			}
+
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.expect {
+				t.Errorf("Got body `%s`, expected `%s`", got, tt.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// keepaliveComment is sent to server-sent events clients, when no event was
+// sent for an interval. Clients ignore comments, so it does not change the
+// last event id.
+const keepaliveComment = ": keepalive\n\n"
+
+// keepalive sends comments to a server-sent events connection, so proxies and
+// load balancers do not close it when there is no data for a long time.
+//
+// All events have to be written with send, so a comment is never written in
+// the middle of an event.
+type keepalive struct {
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	active bool
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// startKeepalive starts sending a comment every interval, if no event was sent
+// during this interval. An interval of 0 disables the comments.
+//
+// stop has to be called before the handler returns.
+func startKeepalive(w http.ResponseWriter, interval time.Duration) *keepalive {
+	k := &keepalive{
+		w:       w,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	if interval <= 0 {
+		close(k.stopped)
+		return k
+	}
+
+	go func() {
+		defer close(k.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-k.done:
+				return
+			case <-ticker.C:
+			}
+
+			if err := k.beat(); err != nil {
+				// The connection is broken. The handler notices it on the
+				// next event or when the request context is canceled.
+				return
+			}
+		}
+	}()
+	return k
+}
+
+// beat writes the comment, if nothing was sent since the last beat.
+func (k *keepalive) beat() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.active {
+		k.active = false
+		return nil
+	}
+
+	if _, err := io.WriteString(k.w, keepaliveComment); err != nil {
+		return err
+	}
+	k.w.(http.Flusher).Flush()
+	return nil
+}
+
+// send calls f with the writer of the connection. No comment is written while
+// f is running.
+func (k *keepalive) send(f func(w io.Writer) error) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.active = true
+	return f(k.w)
+}
+
+// stop stops sending comments. It waits until the last comment is written.
+func (k *keepalive) stop() {
+	close(k.done)
+	<-k.stopped
+}
@@ -0,0 +1,126 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+// syncRecorder is a http.ResponseWriter that can be read while the handler is
+// running.
+type syncRecorder struct {
+	header http.Header
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *syncRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *syncRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *syncRecorder) WriteHeader(int) {}
+
+func (r *syncRecorder) Flush() {}
+
+func (r *syncRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.String()
+}
+
+// waitFor waits until the output of r contains s.
+func (r *syncRecorder) waitFor(t *testing.T, s string) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for !strings.Contains(r.String(), s) {
+		select {
+		case <-timeout:
+			t.Fatalf("Output does not contain %q after one second, got: %q", s, r.String())
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestAutoupdateSSEKeepalive(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"user/user:1": []byte(`"hello world1"`),
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	interval := 10 * time.Millisecond
+	mux := http.NewServeMux()
+	ahttp.AutoupdateSSE(mux, a, 0, interval, new(test.AutherMock), logger.Noop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/system/autoupdate/sse", nil).WithContext(ctx)
+	rec := &syncRecorder{header: make(http.Header)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.ServeHTTP(rec, req)
+	}()
+
+	rec.waitFor(t, "id: 1\n")
+
+	t.Run("heartbeat while quiet", func(t *testing.T) {
+		rec.waitFor(t, "\n\n: keepalive\n\n: keepalive\n\n")
+	})
+
+	t.Run("event after heartbeat", func(t *testing.T) {
+		datastore.Change([]string{"user/user:1"})
+		rec.waitFor(t, "\n\nid: 2\ndata: ")
+
+		for _, event := range strings.Split(rec.String(), "\n\n") {
+			if strings.Contains(event, "keepalive") && event != ": keepalive" {
+				t.Errorf("Got keepalive inside an event: %q", event)
+			}
+		}
+	})
+
+	t.Run("stop on disconnect", func(t *testing.T) {
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Handler did not return after disconnect")
+		}
+
+		written := rec.String()
+		time.Sleep(5 * interval)
+
+		if got := rec.String(); got != written {
+			t.Errorf("Got output after disconnect: %q", strings.TrimPrefix(got, written))
+		}
+	})
+}
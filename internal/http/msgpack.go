@@ -0,0 +1,178 @@
+package http
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	contentTypeJSON    = "application/octet-stream"
+	contentTypeMsgpack = "application/msgpack"
+)
+
+// outputEncoder writes one message of the autoupdate stream.
+type outputEncoder func(w io.Writer, v interface{}) error
+
+// encodeJSON writes v as one line of json.
+func encodeJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// encodeMsgpack writes v as MessagePack. The messages in a stream are not
+// separated, because each MessagePack value knows its length.
+func encodeMsgpack(w io.Writer, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding json: %w", err)
+	}
+
+	converted, err := jsonToMsgpack(encoded)
+	if err != nil {
+		return fmt.Errorf("converting json to msgpack: %w", err)
+	}
+
+	_, err = w.Write(converted)
+	return err
+}
+
+// negotiateEncoder returns the encoder and the content type for the Accept
+// header of the request. The default is json.
+func negotiateEncoder(r *http.Request) (outputEncoder, string) {
+	for _, value := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(value, ";")[0])
+		if mediaType == contentTypeMsgpack || mediaType == "application/x-msgpack" {
+			return encodeMsgpack, contentTypeMsgpack
+		}
+	}
+	return encodeJSON, contentTypeJSON
+}
+
+// jsonToMsgpack converts a json value to MessagePack.
+//
+// Numbers without a fraction are encoded as integers, all other numbers as
+// float64. The keys of objects are sorted.
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding json: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeMsgpack writes a value decoded by json.Decoder with UseNumber.
+func writeMsgpack(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			writeMsgpackInt(buf, i)
+			return nil
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("invalid number %s: %w", v, err)
+		}
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+
+	case string:
+		writeMsgpackHeader(buf, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+
+	case []interface{}:
+		writeMsgpackHeader(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			if err := writeMsgpack(buf, e); err != nil {
+				return err
+			}
+		}
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeMsgpackHeader(buf, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			if err := writeMsgpack(buf, key); err != nil {
+				return err
+			}
+			if err := writeMsgpack(buf, v[key]); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+// writeMsgpackInt writes an integer with the smallest MessagePack type.
+func writeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		buf.WriteByte(byte(i))
+	case i >= -32 && i < 0:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// writeMsgpackHeader writes the type and length of a string, array or map.
+//
+// fixType is used for lengths lower then fixMax. type8 is used for lengths
+// that fit in one byte, if it is not 0.
+func writeMsgpackHeader(buf *bytes.Buffer, length int, fixType byte, fixMax int, type8, type16, type32 byte) {
+	switch {
+	case length < fixMax:
+		buf.WriteByte(fixType | byte(length))
+	case type8 != 0 && length <= math.MaxUint8:
+		buf.WriteByte(type8)
+		buf.WriteByte(byte(length))
+	case length <= math.MaxUint16:
+		buf.WriteByte(type16)
+		binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(type32)
+		binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+}
@@ -0,0 +1,215 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestAutoupdateMsgpack(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1,"title":"motion","weight":-1000,"score":1.5,"submitters_id":[1,300,70000],"closed":false,"parent_id":null}`),
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.Autoupdate(mux, a, new(test.AutherMock), logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/autoupdate", nil)
+	if err != nil {
+		t.Fatalf("Can not create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/msgpack")
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Can not send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "application/msgpack" {
+		t.Errorf("Got content type %s, expected application/msgpack", got)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for i, expect := range []string{
+		`{"connected":true}`,
+		`{
+			"changed": {"motions/motion": [{"id":1,"title":"motion","weight":-1000,"score":1.5,"submitters_id":[1,300,70000],"closed":false,"parent_id":null}]},
+			"deleted": {},
+			"from_change_id": 0,
+			"to_change_id": 1,
+			"all_data": true
+		}`,
+	} {
+		got, err := decodeMsgpack(r)
+		if err != nil {
+			t.Fatalf("Can not decode message %d: %v", i, err)
+		}
+
+		var want interface{}
+		if err := json.Unmarshal([]byte(expect), &want); err != nil {
+			t.Fatalf("Invalid expected json: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Message %d is %v, expected %v", i, got, want)
+		}
+	}
+}
+
+// decodeMsgpack decodes one MessagePack value to the same types as
+// json.Unmarshal into an interface{}.
+func decodeMsgpack(r *bufio.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	readLength := func(size int) (int, error) {
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, err
+		}
+		switch size {
+		case 1:
+			return int(buf[0]), nil
+		case 2:
+			return int(binary.BigEndian.Uint16(buf)), nil
+		default:
+			return int(binary.BigEndian.Uint32(buf)), nil
+		}
+	}
+
+	readString := func(length int) (interface{}, error) {
+		buf := make([]byte, length)
+		_, err := io.ReadFull(r, buf)
+		return string(buf), err
+	}
+
+	readArray := func(length int) (interface{}, error) {
+		array := make([]interface{}, length)
+		for i := range array {
+			v, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = v
+		}
+		return array, nil
+	}
+
+	readMap := func(length int) (interface{}, error) {
+		m := make(map[string]interface{}, length)
+		for i := 0; i < length; i++ {
+			key, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			m[key.(string)] = value
+		}
+		return m, nil
+	}
+
+	readInt := func(v interface{}) (interface{}, error) {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case *int8:
+			return float64(*v), nil
+		case *int16:
+			return float64(*v), nil
+		case *int32:
+			return float64(*v), nil
+		case *int64:
+			return float64(*v), nil
+		}
+		return nil, fmt.Errorf("unknown int type %T", v)
+	}
+
+	switch {
+	case b <= 0x7f:
+		return float64(b), nil
+	case b >= 0xe0:
+		return float64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return readString(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return readArray(int(b & 0x0f))
+	case b&0xf0 == 0x80:
+		return readMap(int(b & 0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcb:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+	case 0xd0:
+		return readInt(new(int8))
+	case 0xd1:
+		return readInt(new(int16))
+	case 0xd2:
+		return readInt(new(int32))
+	case 0xd3:
+		return readInt(new(int64))
+	case 0xd9, 0xda, 0xdb:
+		length, err := readLength(map[byte]int{0xd9: 1, 0xda: 2, 0xdb: 4}[b])
+		if err != nil {
+			return nil, err
+		}
+		return readString(length)
+	case 0xdc, 0xdd:
+		length, err := readLength(map[byte]int{0xdc: 2, 0xdd: 4}[b])
+		if err != nil {
+			return nil, err
+		}
+		return readArray(length)
+	case 0xde, 0xdf:
+		length, err := readLength(map[byte]int{0xde: 2, 0xdf: 4}[b])
+		if err != nil {
+			return nil, err
+		}
+		return readMap(length)
+	}
+	return nil, fmt.Errorf("unknown msgpack type 0x%x", b)
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// projection are the fields of each collection, that the client wants to
+// receive. Collections that are not in the projection are sent with all
+// fields.
+type projection map[string]map[string]bool
+
+// parseProjection parses the values of the query parameter fields.
+//
+// Each value is a collection followed by `:` and a comma separated list of
+// fields, for example `motions/motion:title,state_id`. The parameter can be
+// used once for each collection.
+//
+// Returns nil, if there are no values.
+func parseProjection(values []string) (projection, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	p := make(projection)
+	for _, value := range values {
+		collection, rawFields := value, ""
+		if i := strings.Index(value, ":"); i >= 0 {
+			collection, rawFields = value[:i], value[i+1:]
+		}
+
+		collection = strings.TrimSpace(collection)
+		fields := collectionNames(rawFields)
+		if collection == "" || len(fields) == 0 {
+			return nil, invalidRequestError{fmt.Errorf("Invalid fields `%s`. Expected something like `motions/motion:title,state_id`", value)}
+		}
+
+		if p[collection] == nil {
+			p[collection] = make(map[string]bool)
+		}
+		for _, field := range fields {
+			p[collection][field] = true
+		}
+	}
+	return p, nil
+}
+
+// apply removes all fields from the elements, that are not in the projection.
+//
+// It has to be called after the data is restricted. Fields are only removed,
+// never added, so a field that the restricter removed stays absent. The field
+// id is always kept. Deleted elements are not changed.
+func (p projection) apply(data map[string]json.RawMessage) error {
+	if p == nil {
+		return nil
+	}
+
+	for key, value := range data {
+		fields, ok := p[keyCollection(key)]
+		if !ok || value == nil {
+			continue
+		}
+
+		var element map[string]json.RawMessage
+		if err := json.Unmarshal(value, &element); err != nil {
+			return fmt.Errorf("decoding element %s: %w", key, err)
+		}
+
+		for field := range element {
+			if field != "id" && !fields[field] {
+				delete(element, field)
+			}
+		}
+
+		projected, err := json.Marshal(element)
+		if err != nil {
+			return fmt.Errorf("encoding element %s: %w", key, err)
+		}
+		data[key] = projected
+	}
+	return nil
+}
+
+// keyCollection returns the collection of a key like `motions/motion:1`.
+func keyCollection(key string) string {
+	if i := strings.Index(key, ":"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
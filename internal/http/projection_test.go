@@ -0,0 +1,117 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+// fieldRestricter removes the field secret from all elements.
+type fieldRestricter struct{}
+
+func (fieldRestricter) Restrict(uid int, data map[string]json.RawMessage) {
+	for key, value := range data {
+		var element map[string]json.RawMessage
+		if err := json.Unmarshal(value, &element); err != nil {
+			continue
+		}
+		delete(element, "secret")
+		data[key], _ = json.Marshal(element)
+	}
+}
+
+func TestAutoupdateFields(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	datastore := test.NewDatastoreMock(1, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1, "title": "motion1", "text": "long text", "secret": "hidden"}`),
+		"agenda/item:1":    []byte(`{"id": 1, "item_number": "1", "comment": "comment"}`),
+	}
+	a, err := autoupdate.New(datastore, fieldRestricter{}, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not create autoupdate service: %v", err)
+	}
+
+	datastore.Change([]string{"motions/motion:1", "agenda/item:1"})
+	deadline := time.Now().Add(time.Second)
+	for datastore.CurrentID() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("datastore has change id %d after one second, expected 2", datastore.CurrentID())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateCatchUp(mux, a, new(test.AutherMock), logger.Noop)
+
+	for _, tt := range []struct {
+		name   string
+		fields string
+		status int
+		expect string
+	}{
+		{
+			"no projection",
+			"",
+			http.StatusOK,
+			`{"changed":{"motions/motion":[{"id":1,"title":"motion1","text":"long text"}],"agenda/item":[{"id":1,"item_number":"1","comment":"comment"}]},"deleted":{},"from_change_id":1,"to_change_id":2,"all_data":false}`,
+		},
+		{
+			"one collection",
+			"motions/motion:title",
+			http.StatusOK,
+			`{"changed":{"motions/motion":[{"id":1,"title":"motion1"}],"agenda/item":[{"id":1,"item_number":"1","comment":"comment"}]},"deleted":{},"from_change_id":1,"to_change_id":2,"all_data":false}`,
+		},
+		{
+			"restricted field",
+			"motions/motion:title,secret",
+			http.StatusOK,
+			`{"changed":{"motions/motion":[{"id":1,"title":"motion1"}],"agenda/item":[{"id":1,"item_number":"1","comment":"comment"}]},"deleted":{},"from_change_id":1,"to_change_id":2,"all_data":false}`,
+		},
+		{
+			"many collections",
+			"motions/motion:text&fields=agenda/item:item_number",
+			http.StatusOK,
+			`{"changed":{"motions/motion":[{"id":1,"text":"long text"}],"agenda/item":[{"id":1,"item_number":"1"}]},"deleted":{},"from_change_id":1,"to_change_id":2,"all_data":false}`,
+		},
+		{
+			"same collection twice",
+			"motions/motion:title&fields=motions/motion:text",
+			http.StatusOK,
+			`{"changed":{"motions/motion":[{"id":1,"title":"motion1","text":"long text"}],"agenda/item":[{"id":1,"item_number":"1","comment":"comment"}]},"deleted":{},"from_change_id":1,"to_change_id":2,"all_data":false}`,
+		},
+		{
+			"no fields",
+			"motions/motion",
+			http.StatusBadRequest,
+			"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			url := "/system/autoupdate/catchup"
+			if tt.fields != "" {
+				url += "?fields=" + tt.fields
+			}
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url, strings.NewReader(`{"change_id": 1}`)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("Got status %d, expected %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			if tt.expect != "" {
+				test.ExpectEqualJSON(t, []byte(tt.expect), rec.Body.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/auth"
+)
+
+// RateLimiter limits the requests per user with a token bucket for each user.
+//
+// All anonymous users share one bucket.
+type RateLimiter struct {
+	perMinute          int
+	anonymousPerMinute int
+	now                func() time.Time
+
+	mu      sync.Mutex
+	buckets map[int]*bucket
+}
+
+// NewRateLimiter initializes a RateLimiter. perMinute is the number of requests
+// one user can send in one minute and anonymousPerMinute the number of requests
+// for all anonymous users together. A value of 0 disables the limit.
+func NewRateLimiter(perMinute, anonymousPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		perMinute:          perMinute,
+		anonymousPerMinute: anonymousPerMinute,
+		now:                time.Now,
+		buckets:            make(map[int]*bucket),
+	}
+}
+
+// allow tells, if the user can send a request. If not, it returns the time
+// until the next request is allowed.
+func (l *RateLimiter) allow(uid int) (bool, time.Duration) {
+	limit := l.perMinute
+	if uid == 0 {
+		limit = l.anonymousPerMinute
+	}
+
+	if limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[uid]
+	if !ok {
+		b = &bucket{tokens: float64(limit), updated: now}
+		l.buckets[uid] = b
+	}
+
+	return b.take(float64(limit), now)
+}
+
+// bucket is a token bucket. It is not save for concurrent use.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills the bucket and takes one token. If the bucket is empty, it
+// returns the time until the next token is available.
+//
+// The bucket holds at most perMinute tokens and is refilled in one minute.
+func (b *bucket) take(perMinute float64, now time.Time) (bool, time.Duration) {
+	perSecond := perMinute / 60
+	b.tokens = math.Min(perMinute, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / perSecond
+	return false, time.Duration(math.Ceil(wait)) * time.Second
+}
+
+// rateLimitAuther is an Auther that checks the rate limit of the authenticated
+// user.
+type rateLimitAuther struct {
+	auther  Auther
+	limiter *RateLimiter
+}
+
+// RateLimit returns an Auther that authenticates the request with auther and
+// returns an error, when the user has sent to many requests.
+//
+// If limiter is nil, auther is returned.
+func RateLimit(auther Auther, limiter *RateLimiter) Auther {
+	if limiter == nil {
+		return auther
+	}
+	return rateLimitAuther{auther: auther, limiter: limiter}
+}
+
+// Authenticate authenticates the request and checks the rate limit.
+func (a rateLimitAuther) Authenticate(r *http.Request) (context.Context, error) {
+	ctx, err := a.auther.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, retryAfter := a.limiter.allow(auth.FromContext(ctx)); !ok {
+		return nil, tooManyRequestsError{retryAfter: retryAfter}
+	}
+	return ctx, nil
+}
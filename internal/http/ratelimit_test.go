@@ -0,0 +1,82 @@
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(6, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 6; i++ {
+		if ok, _ := limiter.allow(1); !ok {
+			t.Fatalf("Request %d was not allowed, expected 6 requests", i+1)
+		}
+	}
+
+	ok, retryAfter := limiter.allow(1)
+	if ok {
+		t.Fatalf("Seventh request was allowed, expected the bucket to be empty")
+	}
+
+	if retryAfter != 10*time.Second {
+		t.Errorf("Got retry after %s, expected 10s", retryAfter)
+	}
+
+	if ok, _ := limiter.allow(2); !ok {
+		t.Errorf("Request of another user was not allowed")
+	}
+
+	// With 6 requests per minute, one token is refilled every 10 seconds.
+	now = now.Add(10 * time.Second)
+	if ok, _ := limiter.allow(1); !ok {
+		t.Errorf("Request after 10 seconds was not allowed")
+	}
+	if ok, _ := limiter.allow(1); ok {
+		t.Errorf("Second request after 10 seconds was allowed")
+	}
+
+	// The bucket is full after one minute, but does not hold more tokens.
+	now = now.Add(time.Hour)
+	for i := 0; i < 6; i++ {
+		if ok, _ := limiter.allow(1); !ok {
+			t.Fatalf("Request %d after refill was not allowed", i+1)
+		}
+	}
+	if ok, _ := limiter.allow(1); ok {
+		t.Errorf("Seventh request after refill was allowed")
+	}
+}
+
+func TestRateLimiterAnonymous(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(6, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow(0); !ok {
+			t.Fatalf("Anonymous request %d was not allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := limiter.allow(0)
+	if ok {
+		t.Fatalf("Third anonymous request was allowed, expected the limit of 2")
+	}
+
+	if retryAfter != 30*time.Second {
+		t.Errorf("Got retry after %s, expected 30s", retryAfter)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := NewRateLimiter(0, 0)
+
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.allow(i % 2); !ok {
+			t.Fatalf("Request %d was not allowed, expected no limit", i+1)
+		}
+	}
+}
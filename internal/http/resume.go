@@ -0,0 +1,53 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// resumeFrom validates the change id of a reconnecting client. It returns the
+// change id to continue from.
+//
+// If the change id is lower then the lowest change id, the changed keys are not
+// known anymore. In this case 0 is returned, so the client receives all data,
+// and reason tells why the client has to reset its data. A change id higher
+// then the current change id is handled as up to date, so the client receives
+// the next changes.
+//
+// 0 is a new client, that receives all data without a reset.
+func resumeFrom(ids ChangeIDer, changeID int) (resumeID int, reason string) {
+	if changeID == 0 {
+		return 0, ""
+	}
+
+	if lowest := ids.LowestID(); changeID < lowest {
+		return 0, fmt.Sprintf("change id %d is lower then the lowest change id %d", changeID, lowest)
+	}
+
+	if current := ids.CurrentID(); changeID > current {
+		return current, ""
+	}
+	return changeID, ""
+}
+
+// resetMessage returns the json message, that tells the client to reset its
+// data.
+func resetMessage(reason string) []byte {
+	msg, _ := json.Marshal(struct {
+		Reset  bool   `json:"reset"`
+		Reason string `json:"reason"`
+	}{true, reason})
+	return msg
+}
+
+// sendResetEvent tells a server-sent events client, that it has to reset its
+// data. The next event contains all data.
+func sendResetEvent(w io.Writer, reason string) error {
+	if _, err := fmt.Fprintf(w, "event: reset\ndata: %s\n\n", resetMessage(reason)); err != nil {
+		return fmt.Errorf("send reset event: %w", err)
+	}
+	w.(http.Flusher).Flush()
+	return nil
+}
@@ -0,0 +1,173 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+	"github.com/gorilla/websocket"
+)
+
+// resumeAutoupdate returns an autoupdate service with the lowest change id 3
+// and the current change id 5.
+func resumeAutoupdate(t *testing.T, closed chan struct{}) (*autoupdate.Autoupdate, *test.DatastoreMock) {
+	t.Helper()
+
+	datastore := test.NewDatastoreMock(3, closed)
+	datastore.FullData = map[string]json.RawMessage{
+		"user/user:1": []byte(`"hello world1"`),
+		"user/user:2": []byte(`"hello world2"`),
+	}
+
+	a, err := autoupdate.New(datastore, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	datastore.Change([]string{"user/user:1"})
+	datastore.Change([]string{"user/user:2"})
+	for a.CurrentID() < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	return a, datastore
+}
+
+func TestAutoupdateSSEResume(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		lastEventID string
+		reset       bool
+		expectFrom  int
+		expectTo    int
+		expectAll   bool
+	}{
+		{"valid resume", "4", false, 4, 5, false},
+		{"too old", "2", true, 0, 5, true},
+		{"future id", "10", false, 5, 6, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			closed := make(chan struct{})
+			defer close(closed)
+
+			a, datastore := resumeAutoupdate(t, closed)
+
+			mux := http.NewServeMux()
+			ahttp.AutoupdateSSE(mux, a, 0, 0, new(test.AutherMock), logger.Noop)
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/autoupdate/sse", nil)
+			if err != nil {
+				t.Fatalf("Can not create request: %v", err)
+			}
+			req.Header.Set("Last-Event-ID", tt.lastEventID)
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Can not send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			events := make(chan [2]string)
+			go func() {
+				defer close(events)
+				scanner := bufio.NewScanner(resp.Body)
+				var event, data string
+				for scanner.Scan() {
+					line := scanner.Text()
+					switch {
+					case line == "":
+						events <- [2]string{event, data}
+						event, data = "", ""
+					case strings.HasPrefix(line, "event: "):
+						event = strings.TrimPrefix(line, "event: ")
+					case strings.HasPrefix(line, "data: "):
+						data = strings.TrimPrefix(line, "data: ")
+					}
+				}
+			}()
+
+			if tt.expectFrom == 5 {
+				// The client is up to date. Nothing is sent until the next
+				// change.
+				select {
+				case event := <-events:
+					t.Fatalf("Got event %v, expected nothing", event)
+				case <-time.After(20 * time.Millisecond):
+				}
+				datastore.Change([]string{"user/user:1"})
+			}
+
+			event := <-events
+			if tt.reset {
+				if event[0] != "reset" {
+					t.Fatalf("Got event %v, expected a reset event", event)
+				}
+				event = <-events
+			}
+
+			if event[0] != "" {
+				t.Fatalf("Got event with type %s, expected data", event[0])
+			}
+
+			var data autoupdateData
+			if err := json.Unmarshal([]byte(event[1]), &data); err != nil {
+				t.Fatalf("Can not decode event data: %v", err)
+			}
+
+			if data.AllData != tt.expectAll || data.FromChangeID != tt.expectFrom || data.ToChangeID != tt.expectTo {
+				t.Errorf("Got data %+v, expected all data %t from %d to %d", data, tt.expectAll, tt.expectFrom, tt.expectTo)
+			}
+		})
+	}
+}
+
+func TestAutoupdateWebsocketResetOldChangeID(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	a, _ := resumeAutoupdate(t, closed)
+
+	mux := http.NewServeMux()
+	ahttp.AutoupdateWebsocket(mux, a, 0, new(test.AutherMock), logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/system/autoupdate/ws?change_id=2"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Can not connect to websocket: %v", err)
+	}
+	defer conn.Close()
+
+	var reset struct {
+		Reset  bool   `json:"reset"`
+		Reason string `json:"reason"`
+	}
+	if err := conn.ReadJSON(&reset); err != nil {
+		t.Fatalf("Can not read first message: %v", err)
+	}
+	if !reset.Reset || reset.Reason == "" {
+		t.Errorf("First message is %+v, expected a reset", reset)
+	}
+
+	var data autoupdateData
+	if err := conn.ReadJSON(&data); err != nil {
+		t.Fatalf("Can not read second message: %v", err)
+	}
+	if !data.AllData || data.ToChangeID != 5 {
+		t.Errorf("Second message has data %+v, expected all data until change id 5", data)
+	}
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+)
+
+// closingReason is sent to the clients, when the server shuts down.
+const closingReason = "server closing"
+
+// Shutdown stops the server gracefully.
+//
+// The server stops accepting new connections and afterwards calls
+// closeService. closeService has to close the channel, that was given to the
+// autoupdate service. The open connections send a closing event to their
+// clients and return. Connections that are still open after the grace period
+// are closed.
+func Shutdown(srv *http.Server, closeService func(), gracePeriod time.Duration, log logger.Logger) error {
+	// The onShutdown functions are called after the listeners are closed.
+	srv.RegisterOnShutdown(closeService)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("shutdown http server: %w", err)
+	}
+
+	log.Warn("Connections are still open after the grace period. Closing them", "grace_period", gracePeriod)
+	if err := srv.Close(); err != nil {
+		return fmt.Errorf("close http server: %w", err)
+	}
+	return nil
+}
+
+// sendClosingEvent tells a server-sent events client, that the server shuts
+// down.
+func sendClosingEvent(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "event: closing\ndata: {\"reason\": %q}\n\n", closingReason); err != nil {
+		return fmt.Errorf("send closing event: %w", err)
+	}
+	w.(http.Flusher).Flush()
+	return nil
+}
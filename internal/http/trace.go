@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startDelivery starts the span for one message of an autoupdate route. The
+// spans of the datastore and the restricter are created as children with the
+// returned context.
+//
+// If no tracer provider is configured, the span does nothing.
+func startDelivery(ctx context.Context, route string, uid, changeID int) (context.Context, trace.Span) {
+	return otel.Tracer("openslides.org").Start(
+		ctx,
+		"autoupdate.deliver",
+		trace.WithAttributes(
+			label.String("route", route),
+			label.Int("user_id", uid),
+			label.Int("from_change_id", changeID),
+		),
+	)
+}
+
+// traced calls f in a span with the given name. An error of f is recorded in
+// the span.
+func traced(ctx context.Context, name string, f func() error) error {
+	_, span := otel.Tracer("openslides.org").Start(ctx, name)
+	defer span.End()
+
+	if err := f(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/datastore"
+	ahttp "github.com/OpenSlides/openslides3-autoupdate-service/internal/http"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/oteltest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAutoupdateTrace(t *testing.T) {
+	recorder := new(oteltest.StandardSpanRecorder)
+	otel.SetTracerProvider(oteltest.NewTracerProvider(oteltest.WithSpanRecorder(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	closed := make(chan struct{})
+	defer close(closed)
+
+	redis := test.NewRedisMock()
+	redis.Max = 1
+	redis.FD = map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id": 1}`),
+	}
+	ds, err := datastore.New(redis, nil, nil, logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("Can not initialize datastore: %v", err)
+	}
+
+	a, err := autoupdate.New(ds, new(test.RestricterMock), logger.Noop, closed)
+	if err != nil {
+		t.Fatalf("autoupdate startup failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	ahttp.Autoupdate(mux, a, new(test.AutherMock), logger.Noop)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/system/autoupdate", nil)
+	if err != nil {
+		t.Fatalf("Can not create request: %v", err)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Can not send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Read the connected message and the data.
+	scanner := bufio.NewScanner(resp.Body)
+	for i := 0; i < 2; i++ {
+		if !scanner.Scan() {
+			t.Fatalf("Can not read message %d: %v", i, scanner.Err())
+		}
+	}
+
+	var deliver *oteltest.Span
+	timeout := time.After(time.Second)
+	for deliver == nil {
+		for _, span := range recorder.Completed() {
+			if span.Name() == "autoupdate.deliver" {
+				deliver = span
+				break
+			}
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("Delivery span was not completed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if deliver.ParentSpanID().IsValid() {
+		t.Errorf("Delivery span has a parent, expected a root span")
+	}
+
+	children := make(map[string]bool)
+	for _, span := range recorder.Completed() {
+		if span.ParentSpanID() == deliver.SpanContext().SpanID {
+			children[span.Name()] = true
+		}
+	}
+
+	for _, name := range []string{"datastore.get-all", "autoupdate.restrict", "autoupdate.serialize"} {
+		if !children[name] {
+			t.Errorf("Delivery span has no child %s, got %v", name, children)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/auth"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/autoupdate"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsPingInterval is the time between two ping frames.
+	wsPingInterval = 30 * time.Second
+
+	// wsWriteWait is the time a client has to receive one frame.
+	wsWriteWait = 10 * time.Second
+
+	// wsSendBuffer is the number of messages that are buffered for a client.
+	// If a client is slower, the connection is closed.
+	wsSendBuffer = 16
+)
+
+// AutoupdateWebsocket registers the autoupdate route that sends the data over a
+// websocket connection.
+//
+// The client can send a message like `{"change_id": 123}` to receive all data
+// since this change id. If the changes since this id are not known anymore,
+// the client receives the message `{"reset": true, "reason": "..."}` and
+// afterwards all data.
+//
+// With the collections query parameter, only data of these collections is sent.
+// With the delta query parameter, json patches are sent for known elements.
+// With the fields query parameter, only some fields of the elements are sent.
+//
+// Data that is bigger then maxMessageSize bytes is split into many messages. 0
+// means no limit.
+//
+// When the server shuts down, the connection is closed with the status going
+// away.
+func AutoupdateWebsocket(mux *http.ServeMux, auto *autoupdate.Autoupdate, maxMessageSize int, auther Auther, log logger.Logger) {
+	count := newConnectionCount("autoupdate-websocket")
+	var upgrader websocket.Upgrader
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		uid := auth.FromContext(r.Context())
+
+		rawChangeID := r.URL.Query().Get("change_id")
+		var changeID int
+		if rawChangeID != "" {
+			var err error
+			changeID, err = strconv.Atoi(rawChangeID)
+			if err != nil {
+				return invalidRequestError{fmt.Errorf("Change id has to be a number not %s", rawChangeID)}
+			}
+		}
+
+		collections := collectionNames(r.URL.Query().Get("collections"))
+
+		delta, err := deltaMode(r)
+		if err != nil {
+			return err
+		}
+
+		fields, err := parseProjection(r.URL.Query()["fields"])
+		if err != nil {
+			return err
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade already sent an error to the client.
+			log.Warn("Can not upgrade websocket connection", "error", err)
+			return nil
+		}
+		defer conn.Close()
+
+		n := count.Add()
+		log.Info("Got autoupdate connection", "route", "autoupdate-websocket", "user_id", uid, "connections", n)
+
+		defer func() {
+			n := count.Sub()
+			log.Info("Lost autoupdate connection", "route", "autoupdate-websocket", "user_id", uid, "connections", n)
+		}()
+
+		// Errors can not be sent as http response after the upgrade.
+		if err := websocketAutoupdate(r.Context(), conn, auto, uid, changeID, collections, maxMessageSize, delta, fields); err != nil {
+			var closing interface {
+				Closing()
+			}
+			if errors.As(err, &closing) || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			log.Error("Websocket error", "user_id", uid, "error", err)
+		}
+		return nil
+	}
+
+	mux.Handle("/system/autoupdate/ws", compressHandler(errHandler(middleware(handler, auther), log)))
+}
+
+type errSlowClient struct{}
+
+func (errSlowClient) Error() string {
+	return "client is to slow"
+}
+
+// websocketAutoupdate sends the autoupdate data to the websocket connection
+// until the client closes the connection or the service is closed.
+func websocketAutoupdate(ctx context.Context, conn *websocket.Conn, auto *autoupdate.Autoupdate, uid, changeID int, collections []string, maxSize int, delta *deltaEncoder, fields projection) error {
+	// The request context is not canceled after the upgrade. The reader
+	// cancels the context, when the client closes the connection.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	requests := make(chan int)
+	go func() {
+		defer cancel()
+		for {
+			var msg struct {
+				ChangeID int `json:"change_id"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+
+			select {
+			case requests <- msg.ChangeID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan []byte, wsSendBuffer)
+	closeCode := websocket.CloseNormalClosure
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		defer cancel()
+
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case msg, ok := <-out:
+				if !ok {
+					var reason string
+					if closeCode == websocket.CloseGoingAway {
+						reason = closingReason
+					}
+					closeMsg := websocket.FormatCloseMessage(closeCode, reason)
+					conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(wsWriteWait))
+					return
+				}
+
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+					return
+				}
+
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	err := websocketSend(ctx, out, requests, auto, uid, changeID, collections, maxSize, delta, fields)
+
+	var closing interface {
+		Closing()
+	}
+	var slowSubscription interface {
+		SlowClient()
+	}
+	switch {
+	case errors.As(err, &closing):
+		closeCode = websocket.CloseGoingAway
+	case errors.Is(err, errSlowClient{}), errors.As(err, &slowSubscription):
+		closeCode = websocket.ClosePolicyViolation
+	}
+	close(out)
+	<-writerDone
+	return err
+}
+
+// websocketSend receives the autoupdate data and writes it to out.
+//
+// The data is received with a subscription. When the client requests the data
+// since another change id, the subscription is closed and a new one is
+// created.
+//
+// The client is to slow, if out is full, when new data is received. The other
+// chunks of the same data wait for the writer.
+func websocketSend(ctx context.Context, out chan<- []byte, requests <-chan int, auto *autoupdate.Autoupdate, uid, changeID int, collections []string, maxSize int, delta *deltaEncoder, fields projection) error {
+	type received struct {
+		all         bool
+		data        map[string]json.RawMessage
+		newChangeID int
+		err         error
+	}
+
+	// resume validates a change id from the client. If the client has to reset
+	// its data, it receives a reset message before all data.
+	resume := func(changeID int) (int, error) {
+		resumeID, reason := resumeFrom(auto, changeID)
+		if reason != "" {
+			select {
+			case out <- resetMessage(reason):
+			default:
+				return 0, errSlowClient{}
+			}
+		}
+		return resumeID, nil
+	}
+
+	// subscribe creates a subscription and calls Next in a goroutine, so a
+	// request of the client can be read at the same time. stop has to be
+	// called, before the next subscription is created.
+	subscribe := func(changeID int) (<-chan received, func()) {
+		sub := auto.Subscribe(uid, changeID, collections)
+		subCtx, cancelSub := context.WithCancel(ctx)
+
+		results := make(chan received)
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			for {
+				all, data, newChangeID, err := sub.Next(subCtx)
+				select {
+				case results <- received{all, data, newChangeID, err}:
+				case <-subCtx.Done():
+					return
+				}
+
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		return results, func() {
+			cancelSub()
+			<-stopped
+			sub.Close()
+		}
+	}
+
+	changeID, err := resume(changeID)
+	if err != nil {
+		return err
+	}
+
+	results, stop := subscribe(changeID)
+	defer func() { stop() }()
+
+	for {
+		var res received
+		select {
+		case requested := <-requests:
+			// The client requested data since another change id.
+			changeID, err = resume(requested)
+			if err != nil {
+				return err
+			}
+
+			stop()
+			results, stop = subscribe(changeID)
+			continue
+
+		case res = <-results:
+		}
+
+		if res.err != nil {
+			return res.err
+		}
+
+		fromChangeID := changeID
+		changeID = res.newChangeID
+		if len(res.data) == 0 {
+			continue
+		}
+
+		if err := fields.apply(res.data); err != nil {
+			return err
+		}
+		renameCollections(ctx, res.data)
+
+		format, err := newAutoupdateFormat(res.all, res.data, fromChangeID, res.newChangeID, delta)
+		if err != nil {
+			return err
+		}
+
+		chunks, err := format.chunks(maxSize)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case out <- chunks[0]:
+		default:
+			return errSlowClient{}
+		}
+
+		for _, msg := range chunks[1:] {
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package logger contains the leveled, structured logger of the service.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Logger writes leveled log messages. The args are alternating keys and values
+// like in log/slog, for example:
+//
+//	log.Info("Got connection", "user_id", uid, "connections", n)
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// New returns a Logger that writes json lines to w. Messages below the level
+// are discarded.
+func New(w io.Writer, level slog.Level) Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// ParseLevel returns the level for a name like debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %s: %w", name, err)
+	}
+	return level, nil
+}
+
+// Noop is a Logger that discards all messages. It can be used in tests.
+var Noop Logger = noop{}
+
+type noop struct{}
+
+func (noop) Debug(string, ...interface{}) {}
+func (noop) Info(string, ...interface{})  {}
+func (noop) Warn(string, ...interface{})  {}
+func (noop) Error(string, ...interface{}) {}
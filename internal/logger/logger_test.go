@@ -0,0 +1,98 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/logger"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	log := logger.New(buf, slog.LevelInfo)
+
+	log.Debug("hidden", "change_id", 1)
+	log.Info("Received data update", "change_id", 5)
+
+	var got struct {
+		Level    string `json:"level"`
+		Msg      string `json:"msg"`
+		ChangeID int    `json:"change_id"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Logger did not write one json object: %v\n%s", err, buf)
+	}
+
+	if got.Level != "INFO" || got.Msg != "Received data update" || got.ChangeID != 5 {
+		t.Errorf("Got log entry %+v, expected the info message with change_id 5", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		expect slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := logger.ParseLevel(tt.name)
+			if err != nil {
+				t.Fatalf("ParseLevel returned unexpected error: %v", err)
+			}
+
+			if got != tt.expect {
+				t.Errorf("ParseLevel returned %v, expected %v", got, tt.expect)
+			}
+		})
+	}
+
+	if _, err := logger.ParseLevel("unknown"); err == nil {
+		t.Errorf("ParseLevel did not return an error for an unknown level")
+	}
+}
+
+func TestSampled(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		interval time.Duration
+		every    int
+		expect   []int
+	}{
+		{"interval", time.Hour, 0, []int{1}},
+		{"every", 0, 3, []int{1, 4, 7, 10}},
+		{"disabled", 0, 0, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			log := logger.Sampled(logger.New(buf, slog.LevelDebug), tt.interval, tt.every)
+
+			for i := 1; i <= 10; i++ {
+				log.Debug("Received data update", "change_id", i)
+			}
+
+			var got []int
+			decoder := json.NewDecoder(buf)
+			for decoder.More() {
+				var entry struct {
+					ChangeID int `json:"change_id"`
+				}
+				if err := decoder.Decode(&entry); err != nil {
+					t.Fatalf("Logger wrote invalid json: %v", err)
+				}
+				got = append(got, entry.ChangeID)
+			}
+
+			if !test.CmpIntSlice(got, tt.expect) {
+				t.Errorf("Logged change ids %v, expected %v", got, tt.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampled returns a Logger, that only writes some messages to log. This is
+// meant for messages that are written very often, for example on each update.
+//
+// A message is written, if the interval has passed since the last written
+// message or if it is the every-th message since then. The first message is
+// always written. A written message gets the number of the messages, that were
+// suppressed before, as the attribute `suppressed`.
+//
+// If interval and every are 0, all messages are written.
+func Sampled(log Logger, interval time.Duration, every int) Logger {
+	if interval <= 0 && every <= 0 {
+		return log
+	}
+	return &sampled{log: log, interval: interval, every: every}
+}
+
+type sampled struct {
+	log      Logger
+	interval time.Duration
+	every    int
+
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+// sample tells, if the message should be written. If so, it returns the args
+// with the number of suppressed messages.
+func (s *sampled) sample(args []interface{}) ([]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	write := s.last.IsZero() ||
+		(s.interval > 0 && now.Sub(s.last) >= s.interval) ||
+		(s.every > 0 && s.suppressed+1 >= s.every)
+
+	if !write {
+		s.suppressed++
+		return nil, false
+	}
+
+	if s.suppressed > 0 {
+		args = append(args[:len(args):len(args)], "suppressed", s.suppressed)
+	}
+	s.last = now
+	s.suppressed = 0
+	return args, true
+}
+
+func (s *sampled) Debug(msg string, args ...interface{}) {
+	if args, ok := s.sample(args); ok {
+		s.log.Debug(msg, args...)
+	}
+}
+
+func (s *sampled) Info(msg string, args ...interface{}) {
+	if args, ok := s.sample(args); ok {
+		s.log.Info(msg, args...)
+	}
+}
+
+func (s *sampled) Warn(msg string, args ...interface{}) {
+	if args, ok := s.sample(args); ok {
+		s.log.Warn(msg, args...)
+	}
+}
+
+func (s *sampled) Error(msg string, args ...interface{}) {
+	if args, ok := s.sample(args); ok {
+		s.log.Error(msg, args...)
+	}
+}
@@ -15,7 +15,14 @@ type Auther interface {
 	Middleware(func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error
 }
 
-// Applauser returns the relevant data for applause.
+// Applauser returns the relevant data for applause and saves the calculated
+// applause level.
 type Applauser interface {
 	ApplauseConfig() (waitTime int, base int)
+	ApplauseReceived(level int)
+}
+
+// Permer tells, if a user has a permission.
+type Permer interface {
+	HasPerm(uid int, perm string) bool
 }
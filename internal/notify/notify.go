@@ -20,8 +20,14 @@ type Notify struct {
 	cIDGen           cIDGen
 	applauser        Applauser
 	applauseInterval int
+
+	permer      Permer
+	receivePerm string
 }
 
+// serverChannelID is the sender of messages that are created by the server.
+const serverChannelID ChannelID = "Server"
+
 // New returns an initializes Notify object.
 func New(backend Backend, applauser Applauser, applauseInterval int, closed <-chan struct{}) *Notify {
 	n := &Notify{
@@ -101,7 +107,7 @@ func (n *Notify) applauseLoop() {
 			ToAll:   true,
 			Name:    "applause",
 			Message: b,
-			From:    "Server",
+			From:    serverChannelID,
 		})
 		if err != nil {
 			log.Printf("Notify: Can not encode applause message: %v", err)
@@ -113,6 +119,23 @@ func (n *Notify) applauseLoop() {
 	}
 }
 
+// SetReceivePermission sets the permission, a user needs to receive notify
+// messages. If perm is empty, every user can receive messages.
+//
+// It has to be called before Receive is used.
+func (n *Notify) SetReceivePermission(permer Permer, perm string) {
+	n.permer = permer
+	n.receivePerm = perm
+}
+
+// canReceive tells, if the user is allowed to receive any message.
+func (n *Notify) canReceive(uid int) bool {
+	if n.receivePerm == "" || n.permer == nil {
+		return true
+	}
+	return n.permer.HasPerm(uid, n.receivePerm)
+}
+
 // Receive recieces a notify message and sends it to the writer.
 func (n *Notify) Receive(ctx context.Context, w io.Writer, tid uint64, uid int, cid ChannelID, encoder *json.Encoder) (uint64, error) {
 	var rMails []string
@@ -122,6 +145,10 @@ func (n *Notify) Receive(ctx context.Context, w io.Writer, tid uint64, uid int,
 		return 0, fmt.Errorf("receiving message: %w", err)
 	}
 
+	if !n.canReceive(uid) {
+		return tid, nil
+	}
+
 	for _, rMail := range rMails {
 		var m mail
 		if err := json.Unmarshal([]byte(rMail), &m); err != nil {
@@ -208,6 +235,7 @@ func (n *Notify) receiceApplause() (int, int, error) {
 	if err != nil {
 		return 0, 0, fmt.Errorf("getting applause: %w", err)
 	}
+	n.applauser.ApplauseReceived(a)
 	return a, base, nil
 }
 
@@ -222,7 +250,9 @@ type mail struct {
 
 func (m mail) forMe(uid int, cID ChannelID) bool {
 	if m.ToAll {
-		return true
+		// Messages of users to all are only sent to logged in users. Messages
+		// of the server, like the applause, are sent to everyone.
+		return uid != 0 || m.From == serverChannelID
 	}
 
 	for _, toUID := range m.ToUsers {
@@ -1,13 +1,20 @@
 package notify
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
 
 func TestApplause(t *testing.T) {
 	backend := new(backendMock)
 	closed := make(chan struct{})
 	defer close(closed)
 
-	n := New(backend, new(applauserMock), 1, closed)
+	applauser := new(applauserMock)
+	n := New(backend, applauser, 1000, closed)
 
 	t.Run("no applause send", func(t *testing.T) {
 		a, b, err := n.receiceApplause()
@@ -31,10 +38,80 @@ func TestApplause(t *testing.T) {
 		if a != 5 || b != 100 {
 			t.Errorf("receiceApplause returned %d, %d, expect 5, 100", a, b)
 		}
+
+		if applauser.level != 5 {
+			t.Errorf("Applauser received level %d, expected 5", applauser.level)
+		}
 	})
 
 }
 
+func TestReceive(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	n := New(new(blockingBackend), new(applauserMock), 1000, closed)
+	n.SetReceivePermission(permerMock{1: true, 3: true, 0: true}, "core.can_see_frontpage")
+
+	otherChannel := n.GenerateChannelID(3)
+	for _, m := range []mail{
+		{From: "host:2:0", ToAll: true, Name: "broadcast"},
+		{From: "host:2:0", ToUsers: []int{1}, Name: "to_user_1"},
+		{From: "host:2:0", ToChannels: []string{otherChannel.String()}, Name: "to_channel"},
+		{From: serverChannelID, ToAll: true, Name: "server"},
+	} {
+		bs, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Can not encode mail: %v", err)
+		}
+		n.topic.Publish(string(bs))
+	}
+
+	for _, tt := range []struct {
+		name   string
+		uid    int
+		cid    ChannelID
+		expect []string
+	}{
+		{"targeted user", 1, n.GenerateChannelID(1), []string{"broadcast", "to_user_1", "server"}},
+		{"targeted channel", 3, otherChannel, []string{"broadcast", "to_channel", "server"}},
+		{"other channel of same user", 3, n.GenerateChannelID(3), []string{"broadcast", "server"}},
+		{"anonymous", 0, n.GenerateChannelID(0), []string{"server"}},
+		{"without permission", 2, n.GenerateChannelID(2), nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			buf := new(bytes.Buffer)
+			if _, err := n.Receive(ctx, buf, 0, tt.uid, tt.cid, json.NewEncoder(buf)); err != nil {
+				t.Fatalf("Receive returned unexpected error: %v", err)
+			}
+
+			var got []string
+			decoder := json.NewDecoder(buf)
+			for decoder.More() {
+				var m struct {
+					Name string `json:"name"`
+				}
+				if err := decoder.Decode(&m); err != nil {
+					t.Fatalf("Can not decode message: %v", err)
+				}
+				got = append(got, m.Name)
+			}
+
+			if len(got) != len(tt.expect) {
+				t.Fatalf("Received %v, expected %v", got, tt.expect)
+			}
+			for i := range got {
+				if got[i] != tt.expect[i] {
+					t.Errorf("Received %v, expected %v", got, tt.expect)
+				}
+			}
+		})
+	}
+}
+
 type backendMock struct {
 	a int
 }
@@ -55,8 +132,38 @@ func (m *backendMock) GetApplause(since int64) (int, error) {
 	return m.a, nil
 }
 
-type applauserMock struct{}
+type applauserMock struct {
+	level int
+}
 
 func (a *applauserMock) ApplauseConfig() (waitTime int, base int) {
 	return 5, 100
 }
+
+func (a *applauserMock) ApplauseReceived(level int) {
+	a.level = level
+}
+
+// blockingBackend does not receive any notify message.
+type blockingBackend struct {
+	backendMock
+}
+
+func (m *blockingBackend) ReceiveNotify(closing <-chan struct{}) (mail string, err error) {
+	<-closing
+	return "", closingError{}
+}
+
+type closingError struct{}
+
+func (closingError) Closing() {}
+
+func (closingError) Error() string {
+	return "closing"
+}
+
+type permerMock map[int]bool
+
+func (p permerMock) HasPerm(uid int, perm string) bool {
+	return p[uid]
+}
@@ -22,3 +22,28 @@ type CallableFunc func(ds Datastore, element json.RawMessage, pid int) (json.Raw
 func (f CallableFunc) Build(ds Datastore, element json.RawMessage, pid int) (json.RawMessage, error) {
 	return f(ds, element, pid)
 }
+
+// Dependent is a Callable that knows the collections its slide depends on.
+//
+// A slide of a Dependent is only built again, when an element of one of its
+// collections or the projector changes. A Callable that does not implement
+// Dependent is built on every change.
+type Dependent interface {
+	Callable
+	Dependencies() []string
+}
+
+// DependsOn returns a Dependent that builds the slide with c and depends on
+// the given collections.
+func DependsOn(c Callable, collections ...string) Dependent {
+	return dependent{Callable: c, collections: collections}
+}
+
+type dependent struct {
+	Callable
+	collections []string
+}
+
+func (d dependent) Dependencies() []string {
+	return d.collections
+}
@@ -47,36 +47,64 @@ type Redis struct {
 	lastAutoupdateID string
 	lastNotifyID     string
 
+	// pendingUpdate is the result of a read, that was started by Update, but
+	// not returned, because the closing channel was closed.
+	pendingUpdate chan streamResult
+
 	sessionPrefix string
+
+	username string
+	password string
 }
 
 // New create a new Redis instance.
 func New(readAddr, writeAddr, sessionPrefix string) *Redis {
-	readPool := &redis.Pool{
+	r := &Redis{
+		sessionPrefix: sessionPrefix,
+	}
+
+	r.readPool = &redis.Pool{
 		MaxActive:   100,
 		Wait:        true,
 		MaxIdle:     10,
 		IdleTimeout: 240 * time.Second,
-		Dial:        func() (redis.Conn, error) { return redis.Dial("tcp", readAddr) },
+		Dial:        func() (redis.Conn, error) { return r.dial(readAddr) },
 	}
 
-	writePool := readPool
+	r.writePool = r.readPool
 	if readAddr != writeAddr {
-		writePool = &redis.Pool{
+		r.writePool = &redis.Pool{
 			MaxActive:   100,
 			Wait:        true,
 			MaxIdle:     10,
 			IdleTimeout: 240 * time.Second,
-			Dial:        func() (redis.Conn, error) { return redis.Dial("tcp", writeAddr) },
+			Dial:        func() (redis.Conn, error) { return r.dial(writeAddr) },
 		}
 	}
+	return r
+}
 
-	r := &Redis{
-		readPool:      readPool,
-		writePool:     writePool,
-		sessionPrefix: sessionPrefix,
+// SetAuth sets the credentials, that are used for new connections. It has to
+// be called before the first command is sent.
+//
+// If the password is empty, no AUTH command is sent. The username is only
+// used with a password. It is the user for redis ACLs. If the username is
+// empty, only the password is sent, which is the setup before redis 6.
+func (r *Redis) SetAuth(username, password string) {
+	r.username = username
+	r.password = password
+}
+
+// dial creates a new connection to the address and authenticates it.
+func (r *Redis) dial(addr string) (redis.Conn, error) {
+	var options []redis.DialOption
+	if r.password != "" {
+		options = append(options, redis.DialPassword(r.password))
+		if r.username != "" {
+			options = append(options, redis.DialUsername(r.username))
+		}
 	}
-	return r
+	return redis.Dial("tcp", addr, options...)
 }
 
 // TestReadConn sends a ping command to redis. Does not return the response, but an
@@ -184,42 +212,101 @@ func (r *Redis) FullData() (data map[string]json.RawMessage, max int, min int, e
 	return data, maxChangeID, minChangeID, nil
 }
 
-// Update returns changed keys.
-//
-// Blocks until there is new data.
-func (r *Redis) Update(closing <-chan struct{}) ([]byte, error) {
+// ChangeIDs returns the max and min change id in a atomic way.
+func (r *Redis) ChangeIDs() (max int, min int, err error) {
 	conn := r.readPool.Get()
 	defer conn.Close()
 
-	id := r.lastAutoupdateID
-	if id == "" {
-		id = "$"
+	if err := conn.Send("MULTI"); err != nil {
+		return 0, 0, fmt.Errorf("send MULTI to redis: %w", err)
 	}
 
-	var data []byte
-	var err error
-	received := make(chan struct{})
+	if err := conn.Send("ZREVRANGEBYSCORE", changeIDKey, "+inf", "-inf", "WITHSCORES", "LIMIT", "0", "1"); err != nil {
+		return 0, 0, fmt.Errorf("send ZREVRANGEBYSCORE to redis: %w", err)
+	}
 
-	go func() {
-		id, data, err = stream(conn.Do("XREAD", "COUNT", 1, "BLOCK", "0", "STREAMS", autoupdateKey, id))
-		close(received)
-	}()
+	if err := conn.Send("ZSCORE", changeIDKey, lowestChangeIDField); err != nil {
+		return 0, 0, fmt.Errorf("send ZSCORE to redis: %w", err)
+	}
+
+	resp, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("executing multi redis commands: %w", err)
+	}
+
+	if len(resp) != 2 {
+		return 0, 0, fmt.Errorf("invalid number of multi response. Got %d, expected 2", len(resp))
+	}
+
+	maxChangeIDResp, err := redis.Strings(resp[0], nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get max change id: %w", err)
+	}
 
+	if len(maxChangeIDResp) != 2 {
+		return 0, 0, fmt.Errorf("invalid values in max change id response, got %d, expected 2", len(maxChangeIDResp))
+	}
+
+	max, err = strconv.Atoi(maxChangeIDResp[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value in max change id response, got %s, expected int", maxChangeIDResp[1])
+	}
+
+	min, err = redis.Int(resp[1], nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get min change id: %w", err)
+	}
+
+	return max, min, nil
+}
+
+// streamResult is the result of one read from a redis stream.
+type streamResult struct {
+	id   string
+	data []byte
+	err  error
+}
+
+// Update returns changed keys.
+//
+// Blocks until there is new data. If closing is closed before, the read is
+// not canceled. The next call to Update returns its result. Update is not save
+// for concurrent use.
+func (r *Redis) Update(closing <-chan struct{}) ([]byte, error) {
+	if r.pendingUpdate == nil {
+		id := r.lastAutoupdateID
+		if id == "" {
+			id = "$"
+		}
+
+		pending := make(chan streamResult, 1)
+		r.pendingUpdate = pending
+		go func() {
+			conn := r.readPool.Get()
+			defer conn.Close()
+
+			id, data, err := stream(conn.Do("XREAD", "COUNT", 1, "BLOCK", "0", "STREAMS", autoupdateKey, id))
+			pending <- streamResult{id, data, err}
+		}()
+	}
+
+	var result streamResult
 	select {
-	case <-received:
+	case result = <-r.pendingUpdate:
+		r.pendingUpdate = nil
 	case <-closing:
 		return nil, closingError{}
 	}
 
-	if id != "" {
-		r.lastAutoupdateID = id
+	if result.id != "" {
+		r.lastAutoupdateID = result.id
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("read autoupdate from redis: %w", err)
+	if result.err != nil {
+		return nil, fmt.Errorf("read autoupdate from redis: %w", result.err)
 	}
 
-	return data, nil
+	return result.data, nil
 }
 
 // ChangedKeys returns all keys in the changeidkey higher from and lower or
@@ -268,6 +355,36 @@ func (r *Redis) Data(keys []string) (map[string]json.RawMessage, error) {
 	return data, nil
 }
 
+// KeyChangeIDs returns the change ids of the last change of the keys. Keys
+// without a change id are not in the result.
+func (r *Redis) KeyChangeIDs(keys []string) (map[string]int, error) {
+	conn := r.readPool.Get()
+	defer conn.Close()
+
+	for _, key := range keys {
+		if err := conn.Send("ZSCORE", changeIDKey, key); err != nil {
+			return nil, fmt.Errorf("sending zscore request: %w", err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return nil, fmt.Errorf("flushing zscore requests: %w", err)
+	}
+
+	changeIDs := make(map[string]int, len(keys))
+	for _, key := range keys {
+		changeID, err := redis.Int(conn.Receive())
+		if err != nil {
+			if err == redis.ErrNil {
+				continue
+			}
+			return nil, fmt.Errorf("zscore %s %s: %w", changeIDKey, key, err)
+		}
+		changeIDs[key] = changeID
+	}
+	return changeIDs, nil
+}
+
 // AddApplause adds a user to the applause set.
 //
 // Also deletes applause that is older then a minute
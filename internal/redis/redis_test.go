@@ -0,0 +1,151 @@
+package redis_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/redis"
+)
+
+// fakeRedis is a redis server, that records the received commands. It answers
+// AUTH with OK and PING with PONG.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can not start fake redis: %v", err)
+	}
+
+	f := &fakeRedis{listener: l}
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) close() {
+	f.listener.Close()
+}
+
+func (f *fakeRedis) received() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commands
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		command, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, command)
+		f.mu.Unlock()
+
+		reply := "-ERR unknown command\r\n"
+		switch strings.ToUpper(command[0]) {
+		case "AUTH":
+			reply = "+OK\r\n"
+		case "PING":
+			reply = "+PONG\r\n"
+		}
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one command in the redis protocol, which is an array of
+// bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid array length %q: %w", line, err)
+	}
+
+	command := make([]string, count)
+	for i := range command {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		command[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return command, nil
+}
+
+func TestAuth(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		username string
+		password string
+		expect   []string
+	}{
+		{"no auth", "", "", nil},
+		{"password", "", "secret", []string{"AUTH", "secret"}},
+		{"acl user", "autoupdate", "secret", []string{"AUTH", "autoupdate", "secret"}},
+		{"username without password", "autoupdate", "", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeRedis(t)
+			defer f.close()
+
+			conn := redis.New(f.addr(), f.addr(), "session:")
+			conn.SetAuth(tt.username, tt.password)
+
+			if err := conn.TestReadConn(); err != nil {
+				t.Fatalf("TestReadConn returned unexpected error: %v", err)
+			}
+
+			commands := f.received()
+			expect := [][]string{{"PING"}}
+			if tt.expect != nil {
+				expect = [][]string{tt.expect, {"PING"}}
+			}
+
+			if fmt.Sprint(commands) != fmt.Sprint(expect) {
+				t.Errorf("Redis received %q, expected %q", commands, expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package restricter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FieldRule describes a field of a collection, that is only visible for users
+// with a permission.
+type FieldRule struct {
+	Collection   string
+	Field        string
+	RequiredPerm string
+}
+
+// MaskFields returns an ElementFunc for the collection, that restricts the
+// element with e and afterwards removes every field of the rules, where the
+// user does not have the required permission.
+//
+// Rules for other collections are ignored. If e is nil, the element is visible
+// for everybody.
+func MaskFields(h HasPermer, collection string, e Element, rules []FieldRule) ElementFunc {
+	var collectionRules []FieldRule
+	for _, rule := range rules {
+		if rule.Collection == collection {
+			collectionRules = append(collectionRules, rule)
+		}
+	}
+
+	if e == nil {
+		e = ForAll
+	}
+
+	return func(uid int, data json.RawMessage) (json.RawMessage, error) {
+		data, err := e.Restrict(uid, data)
+		if err != nil || data == nil {
+			return data, err
+		}
+
+		var hidden []string
+		for _, rule := range collectionRules {
+			if !h.HasPerm(uid, rule.RequiredPerm) {
+				hidden = append(hidden, rule.Field)
+			}
+		}
+
+		if len(hidden) == 0 {
+			return data, nil
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("decoding %s element: %w", collection, err)
+		}
+
+		for _, field := range hidden {
+			delete(fields, field)
+		}
+
+		data, err = json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s element: %w", collection, err)
+		}
+		return data, nil
+	}
+}
@@ -0,0 +1,75 @@
+package restricter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestMaskFields(t *testing.T) {
+	rules := []restricter.FieldRule{
+		{Collection: "test/element", Field: "secret", RequiredPerm: "test.can_see_secret"},
+		{Collection: "test/element", Field: "internal", RequiredPerm: "test.can_see_internal"},
+		{Collection: "test/other", Field: "name", RequiredPerm: "test.can_see_secret"},
+	}
+	element := `{"id": 1, "name": "element", "secret": "xxx", "internal": "yyy"}`
+
+	hideAll := restricter.ElementFunc(func(int, json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	})
+
+	for _, tt := range []struct {
+		name   string
+		perms  []string
+		e      restricter.Element
+		expect string
+	}{
+		{
+			"No permission",
+			nil,
+			nil,
+			`{"id": 1, "name": "element"}`,
+		},
+		{
+			"One permission",
+			[]string{"test.can_see_secret"},
+			nil,
+			`{"id": 1, "name": "element", "secret": "xxx"}`,
+		},
+		{
+			"All permissions",
+			[]string{"test.can_see_secret", "test.can_see_internal"},
+			nil,
+			element,
+		},
+		{
+			"Hidden by element",
+			[]string{"test.can_see_secret", "test.can_see_internal"},
+			hideAll,
+			"",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{Perms: tt.perms}
+
+			got, err := restricter.MaskFields(permer, "test/element", tt.e, rules).Restrict(1, []byte(element))
+			if err != nil {
+				t.Fatalf("Restrict returned unexpected error: %v", err)
+			}
+
+			if tt.expect == "" {
+				if got != nil {
+					t.Errorf("Restrict returned `%s`, expected nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("Restrict returned nil, expected %s", tt.expect)
+			}
+			test.ExpectEqualJSON(t, got, []byte(tt.expect))
+		})
+	}
+}
@@ -19,6 +19,7 @@ type HasPermer interface {
 	HasPerm(uid int, perm string) bool
 	IsSuperadmin(uid int) bool
 	InGroups(uid int, groups []int) bool
+	GroupIDs(uid int) []int
 	UserRequired(uid int) []string
 	Get(collection string, id int, v interface{}) error
 }
@@ -0,0 +1,109 @@
+package restricter
+
+import "encoding/json"
+
+// Permissions holds the resolved permissions of one user.
+//
+// It is created once for a batch of elements, so each permission has only to
+// be looked up once. It is not save for concurrent use.
+type Permissions struct {
+	hasPermer  HasPermer
+	uid        int
+	superadmin bool
+	groups     map[int]bool
+	perms      map[string]bool
+}
+
+// NewPermissions resolves the groups of the user and returns a Permissions
+// object for the user.
+func NewPermissions(h HasPermer, uid int) *Permissions {
+	groupIDs := h.GroupIDs(uid)
+	groups := make(map[int]bool, len(groupIDs))
+	for _, gid := range groupIDs {
+		groups[gid] = true
+	}
+
+	return &Permissions{
+		hasPermer:  h,
+		uid:        uid,
+		superadmin: h.IsSuperadmin(uid),
+		groups:     groups,
+		perms:      make(map[string]bool),
+	}
+}
+
+// UID returns the user id the permissions are for.
+func (p *Permissions) UID() int {
+	return p.uid
+}
+
+// HasPerm tells, if the user has the permission.
+func (p *Permissions) HasPerm(perm string) bool {
+	has, ok := p.perms[perm]
+	if !ok {
+		has = p.hasPermer.HasPerm(p.uid, perm)
+		p.perms[perm] = has
+	}
+	return has
+}
+
+// IsSuperadmin tells, if the user is in the admin group.
+func (p *Permissions) IsSuperadmin() bool {
+	return p.superadmin
+}
+
+// InGroups tells, if the user is in at least one of the groups. Superadmins
+// are in every group.
+func (p *Permissions) InGroups(groups []int) bool {
+	if p.superadmin {
+		return true
+	}
+
+	for _, gid := range groups {
+		if p.groups[gid] {
+			return true
+		}
+	}
+	return false
+}
+
+// ManyElement is an Element that can restrict many elements of the same
+// collection at once.
+type ManyElement interface {
+	Element
+
+	// RestrictMany restricts all elements for the user. The returned slice
+	// has the same order and length as the given elements.
+	RestrictMany(uid int, elements []json.RawMessage) ([]json.RawMessage, error)
+}
+
+// PermFunc creates a ManyElement from a function that uses the resolved
+// permissions of the user.
+//
+// When many elements are restricted, the permissions are only resolved once.
+func PermFunc(h HasPermer, f func(p *Permissions, data json.RawMessage) (json.RawMessage, error)) ManyElement {
+	return permFunc{hasPermer: h, f: f}
+}
+
+type permFunc struct {
+	hasPermer HasPermer
+	f         func(p *Permissions, data json.RawMessage) (json.RawMessage, error)
+}
+
+func (pf permFunc) Restrict(uid int, data json.RawMessage) (json.RawMessage, error) {
+	return pf.f(NewPermissions(pf.hasPermer, uid), data)
+}
+
+func (pf permFunc) RestrictMany(uid int, elements []json.RawMessage) ([]json.RawMessage, error) {
+	p := NewPermissions(pf.hasPermer, uid)
+
+	restricted := make([]json.RawMessage, len(elements))
+	for i, element := range elements {
+		data, err := pf.f(p, element)
+		if err != nil {
+			return nil, err
+		}
+		restricted[i] = data
+	}
+	return restricted, nil
+}
@@ -1,9 +1,22 @@
 package restricter
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+)
+
+var errCount, _ = global.GetMeterProvider().Meter("openslides.org").NewInt64Counter(
+	"openslides.restrict-error-count",
+	metric.WithDescription("elements that could not be restricted"),
 )
 
 // Restricter can restrict some data for an user.
@@ -24,9 +37,35 @@ func New(datastore Datastore, elements map[string]Element) *Restricter {
 	}
 }
 
+// Register sets the Element that restricts all elements of a collection.
+//
+// Collections without a registered Element are hidden from every user. This
+// makes sure, that a new collection from the server is not leaked before there
+// is a restricter for it. Public collections have to be registered explicitly
+// with ForAll.
+//
+// Register is not save for concurrent use. It has to be called before the
+// Restricter is used.
+func (r *Restricter) Register(collection string, e Element) {
+	if r.elements == nil {
+		r.elements = make(map[string]Element)
+	}
+	r.elements[collection] = e
+}
+
 // Restrict changes the data for the given user. If the user is now allowed to
 // see an element at all, it is replaced with nil.
+//
+// Elements from a collection without a registered Element are always replaced
+// with nil.
+//
+// Collections with a ManyElement are restricted at once.
+//
+// If an element can not be restricted, for example because it is malformed,
+// the error is logged and the element is replaced with nil. The other elements
+// are not affected.
 func (r *Restricter) Restrict(uid int, data map[string]json.RawMessage) {
+	many := make(map[string][]string)
 	for k, v := range data {
 		if v == nil {
 			// Element is "deleted". No need to restrict it.
@@ -41,15 +80,109 @@ func (r *Restricter) Restrict(uid int, data map[string]json.RawMessage) {
 			continue
 		}
 
-		restricted, err := e.Restrict(uid, v)
+		if _, ok := e.(ManyElement); ok {
+			many[parts[0]] = append(many[parts[0]], k)
+			continue
+		}
+
+		r.restrictOne(e, uid, k, data)
+	}
+
+	for collection, keys := range many {
+		e := r.elements[collection].(ManyElement)
+
+		elements := make([]json.RawMessage, len(keys))
+		for i, k := range keys {
+			elements[i] = data[k]
+		}
+
+		restricted, err := e.RestrictMany(uid, elements)
 		if err != nil {
-			log.Printf("Can not restrict key %s for user %d: %v", k, uid, err)
-			data[k] = nil
+			// Fallback to restrict each element on its own, so only the broken
+			// elements are removed.
+			for _, k := range keys {
+				r.restrictOne(e, uid, k, data)
+			}
 			continue
 		}
 
-		data[k] = restricted
+		for i, k := range keys {
+			data[k] = restricted[i]
+		}
+	}
+}
+
+// restrictOne restricts the element with the key k in data.
+func (r *Restricter) restrictOne(e Element, uid int, k string, data map[string]json.RawMessage) {
+	restricted, err := e.Restrict(uid, data[k])
+	if err != nil {
+		log.Printf("Can not restrict key %s for user %d: %v", k, uid, err)
+		errCount.Add(context.Background(), 1, label.String("collection", strings.Split(k, ":")[0]))
+		data[k] = nil
+		return
 	}
+
+	data[k] = restricted
+}
+
+// Explain restricts the element with the given key for the user like
+// Restrict. If the user can not see the element, the returned element is nil
+// and the reason tells why.
+func (r *Restricter) Explain(uid int, key string) (json.RawMessage, string) {
+	element := r.datastore.GetMany([]string{key})[key]
+	if element == nil {
+		return nil, fmt.Sprintf("%s does not exist", key)
+	}
+
+	collection := strings.Split(key, ":")[0]
+	e, ok := r.elements[collection]
+	if !ok {
+		return nil, fmt.Sprintf("there is no restricter for the collection %s", collection)
+	}
+
+	restricted, err := e.Restrict(uid, element)
+	if err != nil {
+		return nil, fmt.Sprintf("the restricter for the collection %s returned an error: %v", collection, err)
+	}
+
+	if restricted == nil {
+		return nil, fmt.Sprintf("the restricter for the collection %s hides the element for user %d", collection, uid)
+	}
+	return restricted, ""
+}
+
+// Shared tells, if the restricted elements of the collection only depend on
+// the groups of the user. This is the case, if the collection is registered
+// with a GroupElement.
+func (r *Restricter) Shared(collection string) bool {
+	_, ok := r.elements[collection].(GroupElement)
+	return ok
+}
+
+// Fingerprint returns a string, that is the same for all users with the same
+// groups. These users see the same elements of the shared collections.
+//
+// Returns an empty string, if the datastore does not know the groups.
+func (r *Restricter) Fingerprint(uid int) string {
+	grouper, ok := r.datastore.(interface {
+		GroupIDs(uid int) []int
+	})
+	if !ok {
+		return ""
+	}
+
+	if uid == 0 {
+		return "anonymous"
+	}
+
+	groupIDs := grouper.GroupIDs(uid)
+	sort.Ints(groupIDs)
+
+	parts := make([]string, len(groupIDs))
+	for i, id := range groupIDs {
+		parts[i] = strconv.Itoa(id)
+	}
+	return "groups:" + strings.Join(parts, ",")
 }
 
 // ElementFunc converts a simple element restricter func to a element
@@ -61,10 +194,30 @@ func (f ElementFunc) Restrict(u int, data json.RawMessage) (json.RawMessage, err
 	return f(u, data)
 }
 
+// GroupElement is an Element, whose result only depends on the groups of the
+// user and not on the user id. The restricted elements of its collection can
+// be shared between users with the same groups.
+type GroupElement interface {
+	Element
+	OnlyGroups()
+}
+
+// GroupFunc is like an ElementFunc, but the function must only use the groups
+// of the user, for example with HasPerm. It implements GroupElement.
+type GroupFunc func(int, json.RawMessage) (json.RawMessage, error)
+
+// Restrict calls the GroupFunc.
+func (f GroupFunc) Restrict(u int, data json.RawMessage) (json.RawMessage, error) {
+	return f(u, data)
+}
+
+// OnlyGroups marks the GroupFunc as GroupElement.
+func (f GroupFunc) OnlyGroups() {}
+
 // BasePermission returns a generator to create simple Elements that only check
 // one permission.
-func BasePermission(h HasPermer) func(perm string) ElementFunc {
-	return func(perm string) ElementFunc {
+func BasePermission(h HasPermer) func(perm string) GroupFunc {
+	return func(perm string) GroupFunc {
 		return func(u int, data json.RawMessage) (json.RawMessage, error) {
 			if h.HasPerm(u, perm) {
 				return data, nil
@@ -75,6 +228,6 @@ func BasePermission(h HasPermer) func(perm string) ElementFunc {
 }
 
 // ForAll gets read access for everybody.
-var ForAll ElementFunc = func(_ int, data json.RawMessage) (json.RawMessage, error) {
+var ForAll GroupFunc = func(_ int, data json.RawMessage) (json.RawMessage, error) {
 	return data, nil
 }
@@ -0,0 +1,146 @@
+package restricter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/apps/motion"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/restricter"
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/test"
+)
+
+func TestRestrictUnknownCollection(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		superadmin bool
+	}{
+		{"normal user", false},
+		{"superadmin", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			permer := &test.HasPermMock{IsSuperuser: tt.superadmin}
+			r := restricter.New(nil, nil)
+			r.Register("public/collection", restricter.ForAll)
+			r.Register("private/collection", restricter.BasePermission(permer)("private.can_see"))
+
+			data := map[string]json.RawMessage{
+				"public/collection:1":  []byte(`{"id":1}`),
+				"private/collection:1": []byte(`{"id":1}`),
+				"unknown/collection:1": []byte(`{"id":1}`),
+			}
+
+			r.Restrict(1, data)
+
+			if data["public/collection:1"] == nil {
+				t.Errorf("Restrict removed public/collection:1")
+			}
+
+			if got := data["private/collection:1"]; (got != nil) != tt.superadmin {
+				t.Errorf("Restrict returned `%s` for private/collection:1", got)
+			}
+
+			if got := data["unknown/collection:1"]; got != nil {
+				t.Errorf("Restrict returned `%s` for unknown/collection:1, expected nil", got)
+			}
+		})
+	}
+}
+
+func TestRestrictManyFallback(t *testing.T) {
+	permer := new(test.HasPermMock)
+	r := restricter.New(nil, nil)
+	r.Register("some/collection", restricter.PermFunc(permer, func(p *restricter.Permissions, data json.RawMessage) (json.RawMessage, error) {
+		var element struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(data, &element); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}))
+
+	data := map[string]json.RawMessage{
+		"some/collection:1": []byte(`{"id":1}`),
+		"some/collection:2": []byte(`broken`),
+	}
+
+	r.Restrict(1, data)
+
+	if data["some/collection:1"] == nil {
+		t.Errorf("Restrict removed some/collection:1, expected only the broken element to be removed")
+	}
+
+	if got := data["some/collection:2"]; got != nil {
+		t.Errorf("Restrict returned `%s` for some/collection:2, expected nil", got)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	ds := test.NewDatastoreMock(1, closed)
+	ds.FullData = map[string]json.RawMessage{
+		"public/collection:1":  []byte(`{"id":1}`),
+		"private/collection:1": []byte(`{"id":1}`),
+		"broken/collection:1":  []byte(`broken`),
+		"unknown/collection:1": []byte(`{"id":1}`),
+	}
+
+	permer := new(test.HasPermMock)
+	r := restricter.New(ds, nil)
+	r.Register("public/collection", restricter.ForAll)
+	r.Register("private/collection", restricter.BasePermission(permer)("private.can_see"))
+	r.Register("broken/collection", restricter.ElementFunc(func(_ int, data json.RawMessage) (json.RawMessage, error) {
+		var v struct{}
+		return data, json.Unmarshal(data, &v)
+	}))
+
+	for _, tt := range []struct {
+		key     string
+		visible bool
+		reason  string
+	}{
+		{"public/collection:1", true, ""},
+		{"private/collection:1", false, "the restricter for the collection private/collection hides the element for user 1"},
+		{"broken/collection:1", false, "the restricter for the collection broken/collection returned an error: invalid character 'b' looking for beginning of value"},
+		{"unknown/collection:1", false, "there is no restricter for the collection unknown/collection"},
+		{"public/collection:2", false, "public/collection:2 does not exist"},
+	} {
+		t.Run(tt.key, func(t *testing.T) {
+			element, reason := r.Explain(1, tt.key)
+
+			if (element != nil) != tt.visible {
+				t.Errorf("Explain returned element `%s`, expected visible: %t", element, tt.visible)
+			}
+
+			if reason != tt.reason {
+				t.Errorf("Explain returned reason `%s`, expected `%s`", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRestrictCorruptMotion(t *testing.T) {
+	permer := &test.HasPermMock{Perms: []string{"motions.can_see"}}
+	r := restricter.New(nil, nil)
+	r.Register("motions/motion", motion.Restrict(permer))
+
+	data := map[string]json.RawMessage{
+		"motions/motion:1": []byte(`{"id":1,"comments":[]}`),
+		"motions/motion:2": []byte(`{"id":2,"comments":"broken"}`),
+		"motions/motion:3": []byte(`{"id":3,"comments":[]}`),
+	}
+
+	r.Restrict(1, data)
+
+	for _, key := range []string{"motions/motion:1", "motions/motion:3"} {
+		if data[key] == nil {
+			t.Errorf("Restrict removed %s, expected only the corrupt motion to be removed", key)
+		}
+	}
+
+	if got := data["motions/motion:2"]; got != nil {
+		t.Errorf("Restrict returned `%s` for motions/motion:2, expected nil", got)
+	}
+}
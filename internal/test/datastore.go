@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // DatastoreMock implements the autoupdate.Datastore interface.
@@ -18,6 +19,9 @@ type DatastoreMock struct {
 
 	closed  <-chan struct{}
 	changes chan []string
+
+	mu          sync.Mutex
+	changedKeys map[int][]string
 }
 
 // NewDatastoreMock initializes a DatastoreMock.
@@ -41,6 +45,9 @@ func (d *DatastoreMock) LowestID() int {
 
 // CurrentID returns the max id if the mock.
 func (d *DatastoreMock) CurrentID() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	return d.maxChangeID
 }
 
@@ -52,13 +59,29 @@ func (d *DatastoreMock) KeysChanged() ([]string, int, error) {
 	case <-d.closed:
 		return nil, 0, closingErr{}
 	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	d.maxChangeID++
+	if d.changedKeys == nil {
+		d.changedKeys = make(map[int][]string)
+	}
+	d.changedKeys[d.maxChangeID] = changes
 	return changes, d.maxChangeID, nil
 }
 
-// ChangedKeys does nothing...
+// ChangedKeys returns all keys, that where changed with Change after from
+// until to.
 func (d *DatastoreMock) ChangedKeys(from, to int) ([]string, error) {
-	return nil, nil
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var keys []string
+	for id := from + 1; id <= to; id++ {
+		keys = append(keys, d.changedKeys[id]...)
+	}
+	return keys, nil
 }
 
 // Get sets v to the decoded value of collection:id
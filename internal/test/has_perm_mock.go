@@ -2,8 +2,9 @@ package test
 
 import (
 	"encoding/json"
-	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // HasPermMock implements the restricter.HasPermer interface.
@@ -27,6 +28,13 @@ func (h *HasPermMock) HasPerm(_ int, perm string) bool {
 	return false
 }
 
+// Permissions returns the sorted Perms.
+func (h *HasPermMock) Permissions(_ int) []string {
+	perms := append(h.Perms[:0:0], h.Perms...)
+	sort.Strings(perms)
+	return perms
+}
+
 // IsSuperadmin returns, if the user is superadmin.
 func (h *HasPermMock) IsSuperadmin(_ int) bool {
 	return h.IsSuperuser
@@ -46,6 +54,16 @@ func (h *HasPermMock) InGroups(_ int, groups []int) bool {
 	return false
 }
 
+// GroupIDs returns the ids from HasPermMock.Groups.
+func (h *HasPermMock) GroupIDs(_ int) []int {
+	ids := make([]int, 0, len(h.Groups))
+	for g := range h.Groups {
+		ids = append(ids, g)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
 // UserRequired checks for the given groups.
 func (h *HasPermMock) UserRequired(uid int) []string {
 	perms := make(map[string]bool)
@@ -64,12 +82,38 @@ func (h *HasPermMock) UserRequired(uid int) []string {
 	return keys
 }
 
-// Get tells, if the given user has the ...
+// Get sets v to the element from Data. Returns an error with the method
+// DoesNotExist, if the element is not in Data.
 func (h *HasPermMock) Get(collection string, id int, v interface{}) error {
 	elementID := collection + ":" + strconv.Itoa(id)
 	e := h.Data[elementID]
 	if e == nil {
-		return fmt.Errorf("doesNotExistError: %s", elementID)
+		return doesNotExist(elementID)
 	}
 	return json.Unmarshal(e, v)
 }
+
+// Bool returns the value of the config key from the core/config elements in
+// Data. Like the datastore, it returns false, if there is no such config.
+func (h *HasPermMock) Bool(key string) (bool, error) {
+	for k, e := range h.Data {
+		if !strings.HasPrefix(k, "core/config:") {
+			continue
+		}
+
+		var config struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(e, &config); err != nil {
+			return false, err
+		}
+
+		if config.Key == key {
+			var v bool
+			err := json.Unmarshal(config.Value, &v)
+			return v, err
+		}
+	}
+	return false, nil
+}
@@ -2,29 +2,85 @@ package test
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 )
 
+// redisMockQueue is the number of updates, that can be sent to the RedisMock
+// before Send blocks.
+const redisMockQueue = 100
+
 // RedisMock implements the datastore.RedisConn interface.
+//
+// Updates can be queued with Send or SendChange. The answers of ChangedKeys
+// and Data can be set with ChangedKeysResult, ChangedKeysFunc and FD. Err is
+// returned by all methods except Update. Errors of Update can be sent with
+// SendError.
 type RedisMock struct {
 	FD                map[string]json.RawMessage
 	Min               int
 	Max               int
 	send              chan []byte
+	sendErr           chan error
 	ChangedKeysResult []string
+
+	// ChangedKeysFunc is used by ChangedKeys instead of ChangedKeysResult, if
+	// it is set.
+	ChangedKeysFunc func(from, to int) ([]string, error)
+
+	// FullDataFunc is called by FullData before the values are returned, if
+	// it is set. If it returns an error, FullData returns it.
+	FullDataFunc func() error
+
+	// KeyChangeID is returned by KeyChangeIDs.
+	KeyChangeID map[string]int
+
+	// DataFunc is called by Data with the keys before the values are read, if
+	// it is set.
+	DataFunc func(keys []string)
+
+	// Err is returned by FullData, ChangeIDs, ChangedKeys and Data.
+	Err error
+
+	mu sync.Mutex
 }
 
 // NewRedisMock initializes a RedisMock.
 func NewRedisMock() *RedisMock {
 	return &RedisMock{
-		send: make(chan []byte, 1),
+		send:    make(chan []byte, redisMockQueue),
+		sendErr: make(chan error, 1),
 	}
 }
 
 // FullData returns the given values.
 func (r *RedisMock) FullData() (data map[string]json.RawMessage, max int, min int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return nil, 0, 0, r.Err
+	}
+
+	if r.FullDataFunc != nil {
+		if err := r.FullDataFunc(); err != nil {
+			return nil, 0, 0, err
+		}
+	}
 	return r.FD, r.Max, r.Min, nil
 }
 
+// ChangeIDs returns the given change ids.
+func (r *RedisMock) ChangeIDs() (max int, min int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return 0, 0, r.Err
+	}
+	return r.Max, r.Min, nil
+}
+
 // Update waits for Send() to be called and returned the send data.
 func (r *RedisMock) Update(closing <-chan struct{}) ([]byte, error) {
 	select {
@@ -32,16 +88,39 @@ func (r *RedisMock) Update(closing <-chan struct{}) ([]byte, error) {
 		return nil, closingErr{}
 	case v := <-r.send:
 		return v, nil
+	case err := <-r.sendErr:
+		return nil, err
 	}
 }
 
-// ChangedKeys returnes ChangedKeysResult.
+// ChangedKeys returns the result of ChangedKeysFunc or ChangedKeysResult.
 func (r *RedisMock) ChangedKeys(from, to int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	if r.ChangedKeysFunc != nil {
+		return r.ChangedKeysFunc(from, to)
+	}
 	return r.ChangedKeysResult, nil
 }
 
 // Data returns the keys from FD.
 func (r *RedisMock) Data(keys []string) (map[string]json.RawMessage, error) {
+	if r.DataFunc != nil {
+		r.DataFunc(keys)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
 	data := make(map[string]json.RawMessage, len(keys))
 	for _, key := range keys {
 		data[key] = r.FD[key]
@@ -49,7 +128,64 @@ func (r *RedisMock) Data(keys []string) (map[string]json.RawMessage, error) {
 	return data, nil
 }
 
-// Send sends a value that can be received with Update.
+// KeyChangeIDs returns the change ids of the keys from KeyChangeID.
+func (r *RedisMock) KeyChangeIDs(keys []string) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	changeIDs := make(map[string]int)
+	for _, key := range keys {
+		if changeID, ok := r.KeyChangeID[key]; ok {
+			changeIDs[key] = changeID
+		}
+	}
+	return changeIDs, nil
+}
+
+// Send sends a value that can be received with Update. It only blocks, if
+// there are to many values, that are not received.
 func (r *RedisMock) Send(value []byte) {
 	r.send <- value
 }
+
+// SendChange sends an update with the change id and the elements. A nil value
+// deletes the element.
+func (r *RedisMock) SendChange(changeID int, elements map[string]json.RawMessage) {
+	encodedElements := make(map[string]json.RawMessage, len(elements))
+	for key, value := range elements {
+		if value == nil {
+			value = []byte("null")
+		}
+		encodedElements[key] = value
+	}
+
+	value, err := json.Marshal(struct {
+		ChangeID int                        `json:"change_id"`
+		Elements map[string]json.RawMessage `json:"elements"`
+	}{changeID, encodedElements})
+	if err != nil {
+		panic(fmt.Sprintf("encoding change %d: %v", changeID, err))
+	}
+	r.Send(value)
+}
+
+// SendError sends an error that is returned by Update.
+func (r *RedisMock) SendError(err error) {
+	r.sendErr <- err
+}
+
+// Reset replaces all data, like redis would after a restart. The datastore
+// reads the new data, when it receives an update with a change id, that is much
+// higher then the last one.
+func (r *RedisMock) Reset(data map[string]json.RawMessage, max, min int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.FD = data
+	r.Max = max
+	r.Min = min
+}
@@ -0,0 +1,64 @@
+// Package worker gets data from the OpenSlides worker over http.
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const requestTimeout = 10 * time.Second
+
+// Worker is a client to the data route of the OpenSlides worker.
+type Worker struct {
+	url    string
+	client *http.Client
+}
+
+// New initializes a Worker. dataURL is the url of the route that returns the
+// changed data.
+func New(dataURL string) *Worker {
+	return &Worker{
+		url:    dataURL,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// ChangedData returns all elements that have changed after the change id from
+// until the change id to (inclusive). Deleted elements have the value nil.
+//
+// The worker has to respond to a GET request with the query parameters
+// from_change_id and to_change_id with a body like
+// `{"elements": {"motions/motion:1": {...}, "motions/motion:2": null}}`.
+func (w *Worker) ChangedData(from, to int) (map[string]json.RawMessage, error) {
+	query := url.Values{}
+	query.Set("from_change_id", strconv.Itoa(from))
+	query.Set("to_change_id", strconv.Itoa(to))
+
+	resp, err := w.client.Get(w.url + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("sending request to worker: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker returned status %s", resp.Status)
+	}
+
+	var body struct {
+		Elements map[string]json.RawMessage `json:"elements"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding worker response: %w", err)
+	}
+
+	for key, value := range body.Elements {
+		if string(value) == "null" {
+			body.Elements[key] = nil
+		}
+	}
+	return body.Elements, nil
+}
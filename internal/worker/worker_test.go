@@ -0,0 +1,49 @@
+package worker_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenSlides/openslides3-autoupdate-service/internal/worker"
+)
+
+func TestChangedData(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("from_change_id") != "5" || r.URL.Query().Get("to_change_id") != "9" {
+			http.Error(w, "wrong change ids", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, `{"elements": {"motions/motion:1": {"id": 1}, "motions/motion:2": null}}`)
+	}))
+	defer srv.Close()
+
+	data, err := worker.New(srv.URL).ChangedData(5, 9)
+	if err != nil {
+		t.Fatalf("ChangedData returned unexpected error: %v", err)
+	}
+
+	if len(data) != 2 {
+		t.Errorf("ChangedData returned %d elements, expected 2", len(data))
+	}
+
+	if string(data["motions/motion:1"]) != `{"id": 1}` {
+		t.Errorf("ChangedData returned motions/motion:1 = `%s`, expected `{\"id\": 1}`", data["motions/motion:1"])
+	}
+
+	if v, ok := data["motions/motion:2"]; !ok || v != nil {
+		t.Errorf("ChangedData returned motions/motion:2 = `%s`, expected a deleted element", v)
+	}
+}
+
+func TestChangedDataError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if _, err := worker.New(srv.URL).ChangedData(5, 9); err == nil {
+		t.Errorf("ChangedData returned no error, expected one")
+	}
+}